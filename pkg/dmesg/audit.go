@@ -0,0 +1,212 @@
+package dmesg
+
+import (
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditEvent is a record of the kernel audit logged to the kernel log, e.g. an AppArmor or
+// SELinux denial, when no audit daemon reads them, see ParseAudit.
+type AuditEvent struct {
+	Type   int       // Record type, e.g. 1400 of AppArmor and SELinux AVC or 1300 of a syscall
+	Time   time.Time // Time of the audit event of the record, "audit(1700000000.123:45)"
+	Serial uint64    // Serial number of the audit event, shared by its records
+	Seq    uint64    // Sequence number of the message
+	TsUsec int64     // Timestamp of the message
+
+	AVC   string   // Result of a SELinux access check, "denied" or "granted", empty if not an AVC
+	Perms []string // Permissions of the SELinux access check, e.g. ["read", "write"] of "{ read write }"
+
+	Fields map[string]string // Fields of the record, unquoted and hex decoded, e.g. "pid": "1234"
+}
+
+var (
+	// "audit: type=1400 audit(1700000000.123:45): apparmor="DENIED" operation="open"...", without
+	// "audit: " before 3.16.
+	auditRe = regexp.MustCompile(`^(?:audit: )?type=(\d+) audit\((\d+)\.(\d{3}):(\d+)\): ?`)
+	// "avc:  denied  { read write } for  " of SELinux before its fields.
+	avcRe = regexp.MustCompile(`^avc:\s+(denied|granted)\s+\{ ([^}]*) \}(?: for)?\s*`)
+)
+
+// auditAppArmorDenied is the type of the records of AppArmor denials before it was merged in
+// 2.6.36, which have no apparmor field.
+const auditAppArmorDenied = 1503
+
+// auditHexFields are the fields the kernel encodes in hex when their value contains a space,
+// a quote or a control character, e.g. comm=2F7573722F62696E2F666F6F.
+var auditHexFields = map[string]bool{
+	"comm": true, "exe": true, "name": true, "path": true, "cwd": true, "proctitle": true, "profile": true,
+}
+
+// ParseAudit returns the AuditEvent of msg if it's a record of the kernel audit, "audit:
+// type=1400 audit(1700000000.123:45): ...". The fields of the record are split at spaces, with
+// the value of a field in double or single quotes unquoted. Values the kernel encoded in hex,
+// e.g. of comm or proctitle, are decoded. The "avc:  denied  { read } for" of SELinux is parsed
+// into AVC and Perms. It returns false for other messages, e.g. "audit: backlog limit exceeded".
+func ParseAudit(msg Msg) (AuditEvent, bool) {
+	m := auditRe.FindStringSubmatch(msg.Text)
+	if m == nil {
+		return AuditEvent{}, false
+	}
+	typ, err := strconv.Atoi(m[1])
+	if err != nil {
+		return AuditEvent{}, false
+	}
+	sec, err := strconv.ParseInt(m[2], 10, 64)
+	if err != nil {
+		return AuditEvent{}, false
+	}
+	msec, _ := strconv.Atoi(m[3])
+	serial, err := strconv.ParseUint(m[4], 10, 64)
+	if err != nil {
+		return AuditEvent{}, false
+	}
+
+	event := AuditEvent{
+		Type:   typ,
+		Time:   time.Unix(sec, int64(msec)*int64(time.Millisecond)),
+		Serial: serial,
+		Seq:    msg.Seq,
+		TsUsec: msg.TsUsec,
+	}
+	text := msg.Text[len(m[0]):]
+	if m := avcRe.FindStringSubmatch(text); m != nil {
+		event.AVC, event.Perms = m[1], strings.Fields(m[2])
+		text = text[len(m[0]):]
+	}
+	event.Fields = parseAuditFields(text)
+
+	return event, true
+}
+
+// parseAuditFields splits the key=value fields of text, skipping words which aren't fields.
+func parseAuditFields(text string) map[string]string {
+	fields := make(map[string]string)
+	for text != "" {
+		text = strings.TrimLeft(text, " ")
+		word := text
+		if i := strings.IndexByte(text, ' '); i >= 0 {
+			word = text[:i]
+		}
+		key, value, ok := strings.Cut(word, "=")
+		if !ok || key == "" {
+			text = text[len(word):]
+			continue
+		}
+
+		rest := text[len(key)+1:]
+		if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+			// A quoted value may contain spaces, e.g. msg='op=login acct="root"'.
+			if end := strings.IndexByte(rest[1:], rest[0]); end >= 0 {
+				fields[key] = rest[1 : end+1]
+				text = rest[end+2:]
+				continue
+			}
+		}
+		if auditHexFields[key] {
+			value = decodeAuditHex(value)
+		}
+		fields[key] = value
+		text = text[len(word):]
+	}
+
+	return fields
+}
+
+// decodeAuditHex decodes a value encoded in hex by the kernel, with the NUL bytes between the
+// arguments of proctitle replaced by spaces. It returns other values as they are, e.g. "(null)".
+func decodeAuditHex(value string) string {
+	b, err := hex.DecodeString(value)
+	if err != nil || len(b) == 0 {
+		return value
+	}
+
+	return strings.ReplaceAll(strings.TrimRight(string(b), "\x00"), "\x00", " ")
+}
+
+// PID returns the pid field of e, -1 if not logged.
+func (e AuditEvent) PID() int {
+	return e.intField("pid")
+}
+
+// UID returns the uid field of e, or fsuid of AppArmor, -1 if not logged.
+func (e AuditEvent) UID() int {
+	if _, ok := e.Fields["uid"]; ok {
+		return e.intField("uid")
+	}
+
+	return e.intField("fsuid")
+}
+
+// intField returns the field key of e as an int, -1 if not logged or not a number.
+func (e AuditEvent) intField(key string) int {
+	n, err := strconv.Atoi(e.Fields[key])
+	if err != nil {
+		return -1
+	}
+
+	return n
+}
+
+// Comm returns the comm field of e, the command of the process.
+func (e AuditEvent) Comm() string {
+	return e.Fields["comm"]
+}
+
+// Exe returns the exe field of e, the executable of the process.
+func (e AuditEvent) Exe() string {
+	return e.Fields["exe"]
+}
+
+// Name returns the name field of e, e.g. the path of the file accessed.
+func (e AuditEvent) Name() string {
+	return e.Fields["name"]
+}
+
+// Operation returns the operation field of AppArmor, e.g. "open" or "exec".
+func (e AuditEvent) Operation() string {
+	return e.Fields["operation"]
+}
+
+// Profile returns the profile field of AppArmor, e.g. "/usr/sbin/cupsd".
+func (e AuditEvent) Profile() string {
+	return e.Fields["profile"]
+}
+
+// SContext returns the scontext field of SELinux, the context of the subject, e.g.
+// "system_u:system_r:httpd_t:s0".
+func (e AuditEvent) SContext() string {
+	return e.Fields["scontext"]
+}
+
+// TContext returns the tcontext field of SELinux, the context of the target.
+func (e AuditEvent) TContext() string {
+	return e.Fields["tcontext"]
+}
+
+// TClass returns the tclass field of SELinux, the class of the target, e.g. "file".
+func (e AuditEvent) TClass() string {
+	return e.Fields["tclass"]
+}
+
+// Denied returns whether e is an access denied by AppArmor, apparmor="DENIED" or a record of
+// type 1503 of kernels before 2.6.36, or by SELinux, "avc:  denied". Denials of SELinux in
+// permissive mode, permissive=1, are denied too.
+func (e AuditEvent) Denied() bool {
+	return e.AVC == "denied" || e.Fields["apparmor"] == "DENIED" || e.Type == auditAppArmorDenied
+}
+
+// AuditEvents returns the AuditEvents of msgs, see ParseAudit.
+func AuditEvents(msgs []Msg) []AuditEvent {
+	var events []AuditEvent
+	for _, msg := range msgs {
+		if event, ok := ParseAudit(msg); ok {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
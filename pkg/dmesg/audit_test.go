@@ -0,0 +1,167 @@
+package dmesg
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// auditEvent is what the AuditEvent of the message msg of a fixture is checked against, its
+// fields are only compared for the keys of fields.
+type auditEvent struct {
+	msg    int
+	typ    int
+	sec    int64 // Time of the record, "audit(1697021701.612:211)"
+	msec   int
+	serial uint64
+	avc    string
+	perms  []string
+	denied bool
+	fields map[string]string
+}
+
+func TestAuditEvents(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []auditEvent
+	}{
+		// Linux 3.10 logs the records without "audit: ", the messages of audit itself aren't
+		// records. The records of a denied syscall share its serial.
+		{"audit-3.10", []auditEvent{
+			{msg: 1, typ: 2000, sec: 1697021187, msec: 82, serial: 1, fields: map[string]string{"audit_enabled": "0", "res": "1"}},
+			{msg: 2, typ: 1403, sec: 1697021190, msec: 519, serial: 2, fields: map[string]string{"auid": "4294967295", "ses": "4294967295"}},
+			{msg: 3, typ: 1404, sec: 1697021190, msec: 619, serial: 3, fields: map[string]string{"enforcing": "1", "old_enforcing": "0"}},
+			{msg: 4, typ: 1400, sec: 1697021701, msec: 612, serial: 211, avc: "denied", perms: []string{"read"}, denied: true, fields: map[string]string{
+				"pid": "2471", "comm": "httpd", "name": "index.html", "dev": "dm-0", "ino": "33605723",
+				"scontext": "system_u:system_r:httpd_t:s0", "tcontext": "unconfined_u:object_r:user_home_t:s0", "tclass": "file",
+			}},
+			{msg: 5, typ: 1300, sec: 1697021701, msec: 612, serial: 211, fields: map[string]string{
+				"syscall": "2", "success": "no", "exit": "-13", "uid": "48", "tty": "(none)", "comm": "httpd", "exe": "/usr/sbin/httpd", "key": "(null)",
+			}},
+			{msg: 6, typ: 1327, sec: 1697021701, msec: 612, serial: 211, fields: map[string]string{"proctitle": "/usr/sbin/httpd -DFOREGROUND"}},
+		}},
+		// A name with a space is encoded in hex, a profile in complain mode allows the access.
+		{"audit-4.15", []auditEvent{
+			{msg: 0, typ: 1400, sec: 1697532844, msec: 127, serial: 12, fields: map[string]string{
+				"apparmor": "STATUS", "operation": "profile_load", "profile": "unconfined", "name": "/usr/bin/man",
+			}},
+			{msg: 1, typ: 1400, sec: 1697533051, msec: 498, serial: 40, denied: true, fields: map[string]string{
+				"apparmor": "DENIED", "operation": "open", "profile": "/usr/sbin/cupsd", "name": "/home/alice/My Documents/a.pdf",
+				"pid": "1901", "comm": "cupsd", "requested_mask": "r", "fsuid": "0",
+			}},
+			{msg: 2, typ: 1326, sec: 1697533102, msec: 880, serial: 41, fields: map[string]string{"syscall": "273", "code": "0x50000", "exe": "/opt/google/chrome/chrome"}},
+			{msg: 4, typ: 1400, sec: 1697533210, msec: 4, serial: 42, fields: map[string]string{
+				"apparmor": "ALLOWED", "operation": "exec", "target": "/usr/bin/evince//sanitized_helper",
+			}},
+		}},
+		// The AVC of a user space object manager is in the msg field of its record, a denial of
+		// SELinux in permissive mode is denied.
+		{"audit-6.1", []auditEvent{
+			{msg: 0, typ: 1334, sec: 1697620331, msec: 201, serial: 85, fields: map[string]string{"prog-id": "24", "op": "LOAD"}},
+			{msg: 1, typ: 1400, sec: 1697620402, msec: 556, serial: 86, denied: true, fields: map[string]string{
+				"apparmor": "DENIED", "operation": "capable", "class": "cap", "profile": "/usr/sbin/chronyd", "capability": "1", "capname": "dac_override",
+			}},
+			{msg: 2, typ: 1107, sec: 1697620410, msec: 22, serial: 87, fields: map[string]string{
+				"pid": "1", "subj": "system_u:system_r:init_t:s0",
+				"msg": `avc:  denied  { status } for auid=n/a uid=0 gid=0 path="/usr/lib/systemd/system/crond.service" cmdline="/usr/bin/systemctl status crond" ` +
+					`scontext=system_u:system_r:unconfined_service_t:s0 tcontext=system_u:object_r:systemd_unit_file_t:s0 tclass=service permissive=0 ` +
+					`exe="/usr/lib/systemd/systemd" sauid=0 hostname=? addr=? terminal=?`,
+			}},
+			{msg: 3, typ: 1400, sec: 1697620455, msec: 730, serial: 88, avc: "denied", perms: []string{"read", "write"}, denied: true, fields: map[string]string{
+				"comm": "nginx", "name": "app.sock", "dev": "nvme0n1p3", "tclass": "sock_file", "permissive": "1",
+			}},
+			{msg: 4, typ: 1300, sec: 1697620455, msec: 730, serial: 88, fields: map[string]string{"success": "yes", "uid": "997"}},
+			{msg: 5, typ: 1327, sec: 1697620455, msec: 730, serial: 88, fields: map[string]string{"proctitle": "nginx: worker process"}},
+			{msg: 6, typ: 1400, sec: 1697620470, msec: 118, serial: 89, avc: "granted", perms: []string{"setenforce"}, fields: map[string]string{
+				"comm": "setenforce", "scontext": "unconfined_u:unconfined_r:unconfined_t:s0-s0:c0.c1023", "tclass": "security",
+			}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			msgs := readRecords(t, tt.fixture)
+			checkAuditEvents(t, msgs, AuditEvents(msgs), tt.want)
+		})
+	}
+}
+
+// testdata/audit-2.6.32.txt is the text of dmesg of linux 2.6.32 of Ubuntu, whose AppArmor logs
+// its denials as records of type 1503 without an apparmor field.
+func TestAuditEventsText(t *testing.T) {
+	file, err := os.Open("testdata/audit-2.6.32.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	msgs, err := ParseText(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkAuditEvents(t, msgs, AuditEvents(msgs), []auditEvent{
+		{msg: 0, typ: 1505, sec: 1276533430, msec: 212, serial: 2, fields: map[string]string{"operation": "profile_load", "name": "/sbin/dhclient3"}},
+		{msg: 1, typ: 1505, sec: 1276533430, msec: 212, serial: 3, fields: map[string]string{"name": "/usr/lib/NetworkManager/nm-dhcp-client.action"}},
+		{msg: 2, typ: 1503, sec: 1276534034, msec: 879, serial: 194, denied: true, fields: map[string]string{
+			"operation": "open", "pid": "1422", "profile": "/usr/sbin/mysqld", "denied_mask": "::r", "fsuid": "106", "name": "/etc/mysql/conf.d/custom.cnf",
+		}},
+	})
+}
+
+func TestAuditEventAccessors(t *testing.T) {
+	events := AuditEvents(readRecords(t, "audit-4.15"))
+	apparmor, seccomp := events[1], events[2]
+	if apparmor.PID() != 1901 || apparmor.UID() != 0 || apparmor.Comm() != "cupsd" || apparmor.Operation() != "open" ||
+		apparmor.Profile() != "/usr/sbin/cupsd" || apparmor.Name() != "/home/alice/My Documents/a.pdf" {
+		t.Errorf("AppArmor denial = %+v", apparmor)
+	}
+	if seccomp.UID() != 1000 || seccomp.Exe() != "/opt/google/chrome/chrome" {
+		t.Errorf("seccomp record = %+v", seccomp)
+	}
+
+	avc := AuditEvents(readRecords(t, "audit-6.1"))[3]
+	if avc.SContext() != "system_u:system_r:httpd_t:s0" || avc.TContext() != "unconfined_u:object_r:var_run_t:s0" || avc.TClass() != "sock_file" {
+		t.Errorf("AVC = %+v", avc)
+	}
+	if avc.UID() != -1 || (AuditEvent{}).PID() != -1 {
+		t.Errorf("UID() of an AVC = %d, want -1", avc.UID())
+	}
+}
+
+func TestParseAuditOther(t *testing.T) {
+	for _, text := range []string{
+		"audit: audit_backlog=65 > audit_backlog_limit=64",
+		"audit: audit_lost=1 audit_rate_limit=0 audit_backlog_limit=64",
+		"audit: backlog limit exceeded",
+		"type=1400 audit(1697533051:40): apparmor=\"DENIED\"",
+	} {
+		if event, ok := ParseAudit(Msg{Text: text}); ok {
+			t.Errorf("ParseAudit(%q) = %+v, want false", text, event)
+		}
+	}
+}
+
+func checkAuditEvents(t *testing.T, msgs []Msg, events []AuditEvent, want []auditEvent) {
+	t.Helper()
+	if len(events) != len(want) {
+		t.Fatalf("AuditEvents() = %d events, want %d", len(events), len(want))
+	}
+
+	for i, event := range events {
+		w := want[i]
+		msg, at := msgs[w.msg], time.Unix(w.sec, int64(w.msec)*int64(time.Millisecond))
+		if event.Seq != msg.Seq || event.TsUsec != msg.TsUsec || event.Type != w.typ || !event.Time.Equal(at) || event.Serial != w.serial {
+			t.Errorf("event %d is %d at %d of type %d and %v:%d, want %d at %d of type %d and %v:%d",
+				i, event.Seq, event.TsUsec, event.Type, event.Time, event.Serial, msg.Seq, msg.TsUsec, w.typ, at, w.serial)
+		}
+		if event.AVC != w.avc || !slices.Equal(event.Perms, w.perms) || event.Denied() != w.denied {
+			t.Errorf("event %d AVC = %q %v, denied %v, want %q %v, denied %v", i, event.AVC, event.Perms, event.Denied(), w.avc, w.perms, w.denied)
+		}
+		for key, value := range w.fields {
+			if got, ok := event.Fields[key]; !ok || got != value {
+				t.Errorf("event %d field %s = %q, want %q", i, key, got, value)
+			}
+		}
+	}
+}
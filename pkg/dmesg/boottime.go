@@ -0,0 +1,176 @@
+package dmesg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Clocks of clock_gettime, which the syscall package lacks.
+const (
+	clockMonotonic = 1
+	clockBoottime  = 7
+)
+
+// bootTimeCache is the boot time resolved by the first BootTime call of the process.
+var bootTimeCache struct {
+	sync.Mutex
+	time time.Time
+}
+
+// BootTime returns the time the system booted, read from btime in /proc/stat or computed with
+// CLOCK_BOOTTIME when /proc isn't available. It's resolved once per process and cached, see
+// RefreshBootTime to resolve it again.
+func BootTime() (time.Time, error) {
+	bootTimeCache.Lock()
+	defer bootTimeCache.Unlock()
+
+	if !bootTimeCache.time.IsZero() {
+		return bootTimeCache.time, nil
+	}
+
+	return refreshBootTime()
+}
+
+// RefreshBootTime resolves the boot time again like BootTime and caches it, e.g. after the
+// system clock was set.
+func RefreshBootTime() (time.Time, error) {
+	bootTimeCache.Lock()
+	defer bootTimeCache.Unlock()
+
+	return refreshBootTime()
+}
+
+// refreshBootTime resolves the boot time into bootTimeCache, which must be locked.
+func refreshBootTime() (time.Time, error) {
+	boot, err := procStatBootTime()
+	if err != nil {
+		var clockErr error
+		if boot, clockErr = clockBootTime(); clockErr != nil {
+			return time.Time{}, err
+		}
+	}
+	bootTimeCache.time = boot
+
+	return boot, nil
+}
+
+// clockBootTime computes the time the system booted from CLOCK_BOOTTIME, the time since boot
+// including suspend.
+func clockBootTime() (time.Time, error) {
+	now := time.Now().Round(0)
+	since, err := clockGettime(clockBoottime)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return now.Add(-since), nil
+}
+
+// clockGettime returns the time of clock as a duration since its start.
+func clockGettime(clock int) (time.Duration, error) {
+	var ts syscall.Timespec
+	_, _, errno := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, uintptr(clock), uintptr(unsafe.Pointer(&ts)), 0)
+	if errno != 0 {
+		return 0, fmt.Errorf("dmesg: clock_gettime(%d): %w", clock, errno)
+	}
+
+	return time.Duration(ts.Nano()), nil
+}
+
+// SuspendedTime returns how long the system was suspended since boot, the difference between
+// CLOCK_BOOTTIME and CLOCK_MONOTONIC. Message timestamps don't advance during suspend, see
+// WithSuspendCorrection.
+func SuspendedTime() (time.Duration, error) {
+	mono, err := clockGettime(clockMonotonic)
+	if err != nil {
+		return 0, err
+	}
+	boot, err := clockGettime(clockBoottime)
+	if err != nil {
+		return 0, err
+	}
+
+	// The clocks are read one after the other, truncating drops the time between the reads.
+	return max(boot-mono, 0).Truncate(time.Millisecond), nil
+}
+
+// procStatBootTime returns the time the system booted, read from btime in /proc/stat.
+func procStatBootTime() (time.Time, error) {
+	file, err := os.Open("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.HasPrefix(line, []byte("btime ")) {
+			continue
+		}
+
+		sec, err := strconv.ParseInt(string(bytes.TrimSpace(line[len("btime "):])), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("dmesg: invalid btime in /proc/stat: %w", err)
+		}
+
+		return time.Unix(sec, 0), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Time{}, fmt.Errorf("dmesg: no btime in /proc/stat")
+}
+
+// bootID returns the random ID of the current boot from /proc/sys/kernel/random/boot_id.
+func bootID() (string, error) {
+	data, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes.TrimSpace(data)), nil
+}
+
+// SinceBoot returns the timestamp of m, the time since boot at which it was logged.
+func (m Msg) SinceBoot() time.Duration {
+	return time.Duration(m.TsUsec) * time.Microsecond
+}
+
+// Time returns the wall clock time at which m was logged on a system booted at boot, see
+// BootTime, or WallTime when only it is known.
+func (m Msg) Time(boot time.Time) time.Time {
+	if m.TsUsec < 0 && !m.WallTime.IsZero() {
+		return m.WallTime
+	}
+
+	return boot.Add(m.SinceBoot())
+}
+
+// CorrectedTime returns the wall clock time of m from BootTime, corrected by the time the
+// system was suspended as measured when m was read, see WithSuspendCorrection. It's the same as
+// m.Time(m.BootTime) without the correction.
+func (m Msg) CorrectedTime() time.Time {
+	return m.Time(m.BootTime.Add(m.Suspended))
+}
+
+// knownTime returns the wall clock time of m if it's known, its WallTime when only it is known
+// or its corrected time when BootTime is set.
+func (m Msg) knownTime() (time.Time, bool) {
+	switch {
+	case m.TsUsec < 0 && !m.WallTime.IsZero():
+		return m.WallTime, true
+	case !m.BootTime.IsZero():
+		return m.CorrectedTime(), true
+	default:
+		return time.Time{}, false
+	}
+}
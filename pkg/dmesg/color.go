@@ -0,0 +1,83 @@
+package dmesg
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+const colorReset = "\x1b[0m"
+
+// Palette holds the ANSI escape sequences used to color the text of messages. The timestamp
+// isn't colored.
+type Palette struct {
+	Levels    [8]string // Escape sequence for the text of each level, not colored if empty
+	Subsystem string    // Escape sequence for the subsystem prefix of the text, e.g. "usb 1-1: "
+}
+
+// DefaultPalette colors messages like 'dmesg -L': alert in reverse red, crit in bold red, err
+// in red, warn in bold and the subsystem prefix in brown. Like util-linux 2.38, emerg isn't
+// colored.
+var DefaultPalette = Palette{
+	Levels: [8]string{
+		LevelAlert: "\x1b[7m\x1b[31m",
+		LevelCrit:  "\x1b[1m\x1b[31m",
+		LevelErr:   "\x1b[31m",
+		LevelWarn:  "\x1b[1m",
+	},
+	Subsystem: "\x1b[33m",
+}
+
+// IsTerminal reports whether w is a terminal, i.e. a character device. It can be used to
+// only set Formatter.Color when the output is a terminal.
+func IsTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize returns the text of msg colored with p, the text as is for a nil Palette.
+func (p *Palette) colorize(msg Msg) string {
+	if p == nil {
+		return msg.Text
+	}
+
+	var b strings.Builder
+	text := msg.Text
+	if i := subsystemEnd(text); i > 0 && p.Subsystem != "" {
+		b.WriteString(p.Subsystem + text[:i] + colorReset)
+		text = text[i:]
+	}
+
+	var color string
+	if int(msg.Level) < len(p.Levels) {
+		color = p.Levels[msg.Level]
+	}
+	if color == "" || text == "" {
+		b.WriteString(text)
+	} else {
+		b.WriteString(color + text + colorReset)
+	}
+
+	return b.String()
+}
+
+// subsystemEnd returns the end of the subsystem prefix of text, after the first ':' followed
+// by a blank like 'dmesg' does, or 0 if there is none.
+func subsystemEnd(text string) int {
+	for i := 0; i+1 < len(text); i++ {
+		if text[i] == ':' && (text[i+1] == ' ' || text[i+1] == '\t') {
+			return i + 2
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,87 @@
+package dmesg
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// timestampColor matches the timestamps util-linux colors in green, Formatter doesn't color them.
+var timestampColor = regexp.MustCompile(`(?m)^\x1b\[32m(\[[^]]*\] )\x1b\[0m`)
+
+func TestDefaultPaletteGolden(t *testing.T) {
+	msgs, want := readGolden(t, "color") // --color=always
+	want = timestampColor.ReplaceAllString(want, "$1")
+
+	var buf bytes.Buffer
+	f := Formatter{Color: &DefaultPalette}
+	if err := f.Format(&buf, msgs); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("Format() =\n%q\nwant\n%q", buf.String(), want)
+	}
+}
+
+func TestDefaultPaletteLevels(t *testing.T) {
+	tests := []struct {
+		level Level
+		want  string
+	}{
+		{LevelEmerg, "\x1b[33musb 1-1: \x1b[0mtext"},
+		{LevelAlert, "\x1b[33musb 1-1: \x1b[0m\x1b[7m\x1b[31mtext\x1b[0m"},
+		{LevelCrit, "\x1b[33musb 1-1: \x1b[0m\x1b[1m\x1b[31mtext\x1b[0m"},
+		{LevelErr, "\x1b[33musb 1-1: \x1b[0m\x1b[31mtext\x1b[0m"},
+		{LevelWarn, "\x1b[33musb 1-1: \x1b[0m\x1b[1mtext\x1b[0m"},
+		{LevelNotice, "\x1b[33musb 1-1: \x1b[0mtext"},
+		{LevelInfo, "\x1b[33musb 1-1: \x1b[0mtext"},
+		{LevelDebug, "\x1b[33musb 1-1: \x1b[0mtext"},
+	}
+
+	for _, tt := range tests {
+		f := Formatter{TimeFormat: TimeNotime, Color: &DefaultPalette}
+		got := f.Line(Msg{Level: tt.level, Text: "usb 1-1: text"})
+		if got != tt.want {
+			t.Errorf("Line() of %v = %q, want %q", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestPaletteColorize(t *testing.T) {
+	custom := &Palette{Levels: [8]string{LevelInfo: "\x1b[36m"}}
+	tests := []struct {
+		p    *Palette
+		msg  Msg
+		want string
+	}{
+		{nil, Msg{Level: LevelErr, Text: "ata1: failed"}, "ata1: failed"},
+		// Without a subsystem color, the prefix is colored like the rest of the text.
+		{custom, Msg{Level: LevelInfo, Text: "ata1: ok"}, "\x1b[36mata1: ok\x1b[0m"},
+		{custom, Msg{Level: LevelErr, Text: "ata1: failed"}, "ata1: failed"},
+		// Only the prefix is colored when there's no text after it.
+		{&DefaultPalette, Msg{Level: LevelErr, Text: "ata1: "}, "\x1b[33mata1: \x1b[0m"},
+		{&DefaultPalette, Msg{Level: LevelErr, Text: "no prefix"}, "\x1b[31mno prefix\x1b[0m"},
+		{&DefaultPalette, Msg{Level: LevelErr, Text: "debug:\tx"}, "\x1b[33mdebug:\t\x1b[0m\x1b[31mx\x1b[0m"},
+		{&DefaultPalette, Msg{Level: Level(9), Text: "unknown level"}, "unknown level"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.p.colorize(tt.msg); got != tt.want {
+			t.Errorf("colorize(%q) = %q, want %q", tt.msg.Text, got, tt.want)
+		}
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if IsTerminal(file) || IsTerminal(&bytes.Buffer{}) {
+		t.Error("IsTerminal() = true for a regular file or a buffer")
+	}
+}
@@ -0,0 +1,61 @@
+package dmesg
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// CSVOptions configures WriteCSV.
+type CSVOptions struct {
+	// DeviceInfoKeys are the device info keys written as columns after the text, named by the
+	// key. Nil means SUBSYSTEM and DEVICE, an empty slice means no device info column.
+	DeviceInfoKeys []string
+	// Names writes names of level and facility, e.g. "err" and "kern", instead of numbers.
+	Names bool
+}
+
+// WriteCSV writes msgs to w as CSV: a header row and a row for each message with the columns
+// seq, ts_usec, level, facility, caller, text and the device info keys of opts. A device info
+// key missing from a message is an empty field. Fields are quoted like encoding/csv does. The
+// ts_usec of a message whose WallTime only is known, e.g. parsed from 'dmesg -T' output, is its
+// WallTime in RFC 3339.
+func WriteCSV(w io.Writer, msgs []Msg, opts CSVOptions) error {
+	keys := opts.DeviceInfoKeys
+	if keys == nil {
+		keys = []string{"SUBSYSTEM", "DEVICE"}
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"seq", "ts_usec", "level", "facility", "caller", "text"}, keys...)); err != nil {
+		return err
+	}
+
+	row := make([]string, 6+len(keys))
+	for _, msg := range msgs {
+		row[0] = strconv.FormatUint(msg.Seq, 10)
+		if msg.TsUsec < 0 && !msg.WallTime.IsZero() {
+			row[1] = msg.WallTime.Format(time.RFC3339Nano)
+		} else {
+			row[1] = strconv.FormatInt(msg.TsUsec, 10)
+		}
+		if opts.Names {
+			row[2], row[3] = msg.Level.String(), msg.Facility.String()
+		} else {
+			row[2], row[3] = strconv.Itoa(int(msg.Level)), strconv.Itoa(int(msg.Facility))
+		}
+		row[4] = msg.Caller
+		row[5] = msg.Text
+		for i, key := range keys {
+			row[6+i] = msg.deviceInfo(key)
+		}
+
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
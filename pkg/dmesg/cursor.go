@@ -0,0 +1,105 @@
+package dmesg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrCursorInvalidated is returned by DmesgAfterCursor when the cursor is from another boot,
+// all messages are returned along with it since sequence numbers restart on each boot.
+var ErrCursorInvalidated = errors.New("dmesg: cursor is from another boot")
+
+// Cursor is an opaque position in kernel ring buffer after the last message read, including
+// the boot it belongs to. It can be stored with String and restored with ParseCursor to resume
+// reading across process restarts. The zero Cursor is the position before the oldest message.
+type Cursor struct {
+	bootID string
+	next   uint64 // Sequence number of the next message
+}
+
+// String returns the cursor encoded as text, the zero Cursor is encoded as "".
+func (c Cursor) String() string {
+	if c == (Cursor{}) {
+		return ""
+	}
+
+	return "b=" + c.bootID + ";n=" + strconv.FormatUint(c.next, 10)
+}
+
+// ParseCursor parses a cursor encoded by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	if s == "" {
+		return Cursor{}, nil
+	}
+
+	c := Cursor{}
+	fields := strings.Split(s, ";")
+	if len(fields) != 2 || !strings.HasPrefix(fields[0], "b=") || !strings.HasPrefix(fields[1], "n=") {
+		return c, fmt.Errorf("dmesg: invalid cursor %q", s)
+	}
+
+	next, err := strconv.ParseUint(fields[1][len("n="):], 10, 64)
+	if err != nil {
+		return c, fmt.Errorf("dmesg: invalid cursor %q", s)
+	}
+	c.bootID = fields[0][len("b="):]
+	c.next = next
+
+	return c, nil
+}
+
+// Cursor returns the cursor after the last message read by r. Messages dropped by filters
+// count as read.
+func (r *Reader) Cursor() Cursor {
+	if r.bootID == "" {
+		// Without the boot ID the cursor is still usable, just not checked against reboots.
+		r.bootID, _ = bootID()
+	}
+
+	c := Cursor{bootID: r.bootID}
+	if r.hasSeq {
+		c.next = r.seq + 1
+	}
+
+	return c
+}
+
+// DmesgAfterCursor gets the messages after cursor c and returns them with the cursor after
+// the last message read. If c is from another boot, all messages are returned along with
+// ErrCursorInvalidated. A *GapError is returned along with the messages if some messages
+// after c are no longer in kernel ring buffer.
+func DmesgAfterCursor(c Cursor, opts ...Option) ([]Msg, Cursor, error) {
+	id, err := bootID()
+	if err != nil {
+		return nil, c, err
+	}
+
+	o := newOptions(opts)
+	invalidated := c.bootID != "" && c.bootID != id
+	if c.next > 0 && !invalidated {
+		o.afterSeq = c.next - 1
+		o.hasAfterSeq = true
+	}
+
+	d, err := fetch(context.Background(), o, false)
+
+	next := Cursor{bootID: id, next: c.next}
+	if invalidated {
+		next.next = 0
+	}
+	if d.hasNewest {
+		next.next = d.newest + 1
+	}
+
+	if err == nil && invalidated {
+		err = ErrCursorInvalidated
+	}
+	if err == nil && o.hasAfterSeq {
+		err = d.gap(o.afterSeq)
+	}
+
+	return d.msg, next, err
+}
@@ -0,0 +1,63 @@
+package dmesg
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Decoder reads native messages in /dev/kmsg format from an io.Reader, e.g. a saved
+// dump of /dev/kmsg. Records are separated by newlines, device info lines of a record
+// start with a space.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder returns a Decoder reading records from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads and parses the next record. It returns io.EOF when there are no more records.
+// A record that can't be parsed is skipped and its error wrapping ErrInvalidRecord is returned,
+// the next call continues with the following record.
+func (d *Decoder) Decode() (Msg, error) {
+	record, err := d.readRecord()
+	if err != nil {
+		return Msg{}, err
+	}
+
+	return ParseRecord(record)
+}
+
+// readRecord reads the text line of the next record and its device info lines.
+func (d *Decoder) readRecord() ([]byte, error) {
+	var record []byte
+	for len(record) == 0 {
+		line, err := d.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, err
+		}
+		// Skip empty lines between records.
+		if len(bytes.TrimSpace(line)) != 0 {
+			record = line
+		}
+	}
+
+	for {
+		next, err := d.r.Peek(1)
+		if err != nil || next[0] != ' ' {
+			break
+		}
+
+		line, _ := d.r.ReadBytes('\n')
+		record = append(record, line...)
+	}
+
+	// The last record of the input may miss its trailing newline.
+	if record[len(record)-1] != '\n' {
+		record = append(record, '\n')
+	}
+
+	return record, nil
+}
@@ -0,0 +1,104 @@
+package dmesg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DedupedMsg is a run of consecutive repeated messages collapsed into one, see Dedup.
+type DedupedMsg struct {
+	Msg      Msg    // First message of the run
+	Count    int    // Number of messages of the run, 1 when not repeated
+	FirstSeq uint64 // Seq of the first message
+	LastSeq  uint64 // Seq of the last message
+	FirstTs  int64  // TsUsec of the first message
+	LastTs   int64  // TsUsec of the last message
+}
+
+// DedupOption configures which messages Dedup collapses.
+type DedupOption func(*dedupOptions)
+
+type dedupOptions struct {
+	caller      bool
+	fingerprint bool
+}
+
+// WithDedupCaller collapses only messages of the same caller, see Msg.Caller.
+func WithDedupCaller() DedupOption {
+	return func(o *dedupOptions) {
+		o.caller = true
+	}
+}
+
+// WithDedupFingerprint compares the Fingerprint of texts instead of the texts, so messages
+// differing only in numbers, e.g. counters, are collapsed too.
+func WithDedupFingerprint() DedupOption {
+	return func(o *dedupOptions) {
+		o.fingerprint = true
+	}
+}
+
+// Dedup collapses runs of consecutive messages of the same level, facility and text into one
+// DedupedMsg with the number of messages of the run, like "message repeated N times" of syslog.
+// Messages which aren't repeated are returned with Count 1. See Formatter.FormatDeduped to print
+// them.
+func Dedup(msgs []Msg, opts ...DedupOption) []DedupedMsg {
+	var o dedupOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var deduped []DedupedMsg
+	var key string // Text or fingerprint of the last run
+	for _, msg := range msgs {
+		k := msg.Text
+		if o.fingerprint {
+			k = Fingerprint(msg.Text)
+		}
+
+		if n := len(deduped); n > 0 {
+			last := &deduped[n-1]
+			if k == key && msg.Level == last.Msg.Level && msg.Facility == last.Msg.Facility &&
+				(!o.caller || msg.Caller == last.Msg.Caller) {
+				last.Count++
+				last.LastSeq, last.LastTs = msg.Seq, msg.TsUsec
+				continue
+			}
+		}
+
+		key = k
+		deduped = append(deduped, DedupedMsg{
+			Msg:      msg,
+			Count:    1,
+			FirstSeq: msg.Seq,
+			LastSeq:  msg.Seq,
+			FirstTs:  msg.TsUsec,
+			LastTs:   msg.TsUsec,
+		})
+	}
+
+	return deduped
+}
+
+// Repeated returns the message printed after a repeated message, "message repeated N times:
+// [ text ]" with the timestamp of the last message of the run, N being the number of repeats
+// after the first. It returns false when m isn't repeated.
+func (m DedupedMsg) Repeated() (Msg, bool) {
+	if m.Count < 2 {
+		return Msg{}, false
+	}
+
+	msg := m.Msg
+	msg.Seq, msg.TsUsec = m.LastSeq, m.LastTs
+	msg.Text = fmt.Sprintf("message repeated %d times: [ %s ]", m.Count-1, m.Msg.Text)
+
+	return msg, true
+}
+
+// String formats m like Msg.String, followed by the line of Repeated when m is repeated.
+func (m DedupedMsg) String() string {
+	var b strings.Builder
+	new(Formatter).FormatDeduped(&b, []DedupedMsg{m})
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,239 @@
+package dmesg
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrUnresolvedDevice is returned by ResolveDevice when sysfs doesn't know the device.
+var ErrUnresolvedDevice = errors.New("dmesg: device can't be resolved")
+
+// DeviceType is the kind of device a DEVICE device info value identifies.
+type DeviceType uint8
+
+const (
+	DeviceUnknown   DeviceType = iota // Not a known encoding
+	DeviceBlock                       // Block device by major and minor, "b8:1"
+	DeviceChar                        // Character device by major and minor, "c127:3"
+	DeviceNet                         // Network interface by ifindex, "n2"
+	DeviceSubsystem                   // Other device by subsystem and name, "+usb:3-2"
+)
+
+var deviceTypeNames = [...]string{"unknown", "block", "char", "net", "subsystem"}
+
+// String returns the name of t, e.g. "block".
+func (t DeviceType) String() string {
+	if int(t) < len(deviceTypeNames) {
+		return deviceTypeNames[t]
+	}
+
+	return strconv.Itoa(int(t))
+}
+
+// ParsedDevice is a decoded DEVICE device info value. Only the fields of its Type are set.
+type ParsedDevice struct {
+	Type      DeviceType
+	Major     uint32 // Major number of DeviceBlock and DeviceChar
+	Minor     uint32 // Minor number of DeviceBlock and DeviceChar
+	Ifindex   int    // Interface index of DeviceNet
+	Subsystem string // Subsystem of DeviceSubsystem, e.g. "usb"
+	Name      string // Device name of DeviceSubsystem, e.g. "3-2"
+}
+
+// String encodes d back like the kernel does, e.g. "b8:1", or returns "unknown".
+func (d ParsedDevice) String() string {
+	switch d.Type {
+	case DeviceBlock:
+		return fmt.Sprintf("b%d:%d", d.Major, d.Minor)
+	case DeviceChar:
+		return fmt.Sprintf("c%d:%d", d.Major, d.Minor)
+	case DeviceNet:
+		return "n" + strconv.Itoa(d.Ifindex)
+	case DeviceSubsystem:
+		return "+" + d.Subsystem + ":" + d.Name
+	}
+
+	return d.Type.String()
+}
+
+// ParsedDevice decodes Device of m the way the kernel encodes it: "b8:1" for a block device,
+// "c127:3" for a character device, "n2" for a network interface and "+usb:3-2" for any other
+// device by subsystem and name. It returns false when m has no Device or it isn't one of these
+// encodings.
+func (m Msg) ParsedDevice() (ParsedDevice, bool) {
+	return parseDevice(m.Device)
+}
+
+// parseDevice decodes a DEVICE device info value, see Msg.ParsedDevice.
+func parseDevice(device string) (ParsedDevice, bool) {
+	if len(device) < 2 {
+		return ParsedDevice{}, false
+	}
+
+	value := device[1:]
+	switch device[0] {
+	case 'b', 'c':
+		majorField, minorField, ok := strings.Cut(value, ":")
+		if !ok {
+			return ParsedDevice{}, false
+		}
+		major, err := strconv.ParseUint(majorField, 10, 32)
+		if err != nil {
+			return ParsedDevice{}, false
+		}
+		minor, err := strconv.ParseUint(minorField, 10, 32)
+		if err != nil {
+			return ParsedDevice{}, false
+		}
+
+		typ := DeviceBlock
+		if device[0] == 'c' {
+			typ = DeviceChar
+		}
+		return ParsedDevice{Type: typ, Major: uint32(major), Minor: uint32(minor)}, true
+	case 'n':
+		ifindex, err := strconv.ParseUint(value, 10, 31)
+		if err != nil || ifindex == 0 {
+			return ParsedDevice{}, false
+		}
+
+		return ParsedDevice{Type: DeviceNet, Ifindex: int(ifindex)}, true
+	case '+':
+		// Subsystem names have no ':', device names may, e.g. "+pci:0000:00:1f.2".
+		subsystem, name, ok := strings.Cut(value, ":")
+		if !ok || subsystem == "" || name == "" {
+			return ParsedDevice{}, false
+		}
+
+		return ParsedDevice{Type: DeviceSubsystem, Subsystem: subsystem, Name: name}, true
+	}
+
+	return ParsedDevice{}, false
+}
+
+// DeviceResolver maps parsed devices to /dev paths and interface names through sysfs and
+// caches the results. Devices may come and go and interface indexes are reused, a cached
+// result may be stale after hotplug. It's safe for concurrent use.
+type DeviceResolver struct {
+	root string
+
+	mu    sync.Mutex
+	cache map[ParsedDevice]string
+}
+
+// NewDeviceResolver returns a DeviceResolver reading sysfs mounted at root, "/sys" when empty.
+func NewDeviceResolver(root string) *DeviceResolver {
+	if root == "" {
+		root = "/sys"
+	}
+
+	return &DeviceResolver{root: root, cache: make(map[ParsedDevice]string)}
+}
+
+// ResolveDevice resolves dev like DeviceResolver.Resolve with the sysfs of the running system,
+// without caching.
+func ResolveDevice(dev ParsedDevice) (string, error) {
+	return resolveDevice("/sys", dev)
+}
+
+// Resolve maps dev to the /dev path of its device node, e.g. "/dev/sda1" for a DeviceBlock
+// 8:1, read from DEVNAME of its uevent in sysfs, or to the interface name of a DeviceNet, e.g.
+// "eth0". A DeviceSubsystem resolves to its device node if it has one, e.g.
+// "/dev/bus/usb/003/002" for "+usb:3-2", or to its name in the net subsystem. It returns an
+// error wrapping ErrUnresolvedDevice when sysfs doesn't know dev or it has no device node.
+func (r *DeviceResolver) Resolve(dev ParsedDevice) (string, error) {
+	r.mu.Lock()
+	name, ok := r.cache[dev]
+	r.mu.Unlock()
+	if ok {
+		return name, nil
+	}
+
+	name, err := resolveDevice(r.root, dev)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	r.cache[dev] = name
+	r.mu.Unlock()
+
+	return name, nil
+}
+
+// resolveDevice resolves dev with the sysfs mounted at root, see DeviceResolver.Resolve.
+func resolveDevice(root string, dev ParsedDevice) (string, error) {
+	switch dev.Type {
+	case DeviceBlock, DeviceChar:
+		class := "block"
+		if dev.Type == DeviceChar {
+			class = "char"
+		}
+		return devNode(filepath.Join(root, "dev", class, fmt.Sprintf("%d:%d", dev.Major, dev.Minor)), dev)
+	case DeviceNet:
+		return netdevName(root, dev)
+	case DeviceSubsystem:
+		if dev.Subsystem == "net" {
+			return dev.Name, nil
+		}
+		path := filepath.Join(root, "bus", dev.Subsystem, "devices", dev.Name)
+		if _, err := os.Stat(path); err != nil {
+			path = filepath.Join(root, "class", dev.Subsystem, dev.Name)
+		}
+		return devNode(path, dev)
+	}
+
+	return "", fmt.Errorf("%w: unknown device type", ErrUnresolvedDevice)
+}
+
+// devNode returns the /dev path of DEVNAME in the uevent of the sysfs device at path.
+func devNode(path string, dev ParsedDevice) (string, error) {
+	file, err := os.Open(filepath.Join(path, "uevent"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %v not in sysfs", ErrUnresolvedDevice, dev)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if name, ok := bytes.CutPrefix(scanner.Bytes(), []byte("DEVNAME=")); ok && len(name) > 0 {
+			return "/dev/" + string(name), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("%w: %v has no device node", ErrUnresolvedDevice, dev)
+}
+
+// netdevName returns the name of the interface with the ifindex of dev, found by walking
+// class/net of sysfs at root.
+func netdevName(root string, dev ParsedDevice) (string, error) {
+	entries, err := os.ReadDir(filepath.Join(root, "class", "net"))
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(root, "class", "net", entry.Name(), "ifindex"))
+		if err != nil {
+			continue
+		}
+		if ifindex, err := strconv.Atoi(string(bytes.TrimSpace(data))); err == nil && ifindex == dev.Ifindex {
+			return entry.Name(), nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: no interface with ifindex %d", ErrUnresolvedDevice, dev.Ifindex)
+}
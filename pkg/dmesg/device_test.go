@@ -0,0 +1,104 @@
+package dmesg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeviceResolverFixture(t *testing.T) {
+	r := NewDeviceResolver(extractArchive(t, "sysfs.txtar"))
+
+	tests := []struct {
+		device string
+		want   string // Empty when the device can't be resolved
+	}{
+		{"b8:0", "/dev/sda"},
+		{"b8:1", "/dev/sda1"},
+		{"b259:2", "/dev/nvme0n1p2"},
+		{"b8:5", ""},
+		{"c4:64", "/dev/ttyS0"},
+		{"c189:1", "/dev/bus/usb/001/002"},
+		{"c226:0", "/dev/dri/card0"},
+		{"c8:0", ""}, // Block numbers aren't char numbers
+		{"n1", "lo"},
+		{"n2", "enp0s31f6"},
+		{"n4", "docker0"},
+		{"n9", ""},
+		{"+usb:1-1", "/dev/bus/usb/001/002"},
+		{"+usb:1-1:1.0", ""}, // Interfaces have no device node
+		{"+usb:2-1", ""},
+		{"+pci:0000:00:17.0", ""},
+		{"+input:event3", "/dev/input/event3"}, // Found in class instead of bus
+		{"+sound:card0", ""},
+		{"+net:wlp2s0", "wlp2s0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.device, func(t *testing.T) {
+			dev, ok := parseDevice(tt.device)
+			if !ok {
+				t.Fatalf("parseDevice(%q) failed", tt.device)
+			}
+
+			got, err := r.Resolve(dev)
+			if tt.want == "" {
+				if !errors.Is(err, ErrUnresolvedDevice) {
+					t.Errorf("Resolve() = %q, %v, want ErrUnresolvedDevice", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Resolve() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := r.Resolve(ParsedDevice{}); !errors.Is(err, ErrUnresolvedDevice) {
+		t.Errorf("Resolve() of an unknown device = %v, want ErrUnresolvedDevice", err)
+	}
+}
+
+func TestDeviceResolverCache(t *testing.T) {
+	root := extractArchive(t, "sysfs.txtar")
+	r := NewDeviceResolver(root)
+	sda1 := ParsedDevice{Type: DeviceBlock, Major: 8, Minor: 1}
+	sdb := ParsedDevice{Type: DeviceBlock, Major: 8, Minor: 16}
+
+	if name, err := r.Resolve(sda1); err != nil || name != "/dev/sda1" {
+		t.Fatalf("Resolve() = %q, %v, want /dev/sda1", name, err)
+	}
+	if _, err := r.Resolve(sdb); !errors.Is(err, ErrUnresolvedDevice) {
+		t.Fatalf("Resolve() of an unplugged disk = %v, want ErrUnresolvedDevice", err)
+	}
+
+	// The disk is replaced: the resolved name is cached, the failure isn't.
+	if err := os.RemoveAll(filepath.Join(root, "dev", "block", "8:1")); err != nil {
+		t.Fatal(err)
+	}
+	uevent := filepath.Join(root, "dev", "block", "8:16", "uevent")
+	if err := os.MkdirAll(filepath.Dir(uevent), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(uevent, []byte("MAJOR=8\nMINOR=16\nDEVNAME=sdb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, err := r.Resolve(sda1); err != nil || name != "/dev/sda1" {
+		t.Errorf("cached Resolve() = %q, %v, want /dev/sda1", name, err)
+	}
+	if name, err := r.Resolve(sdb); err != nil || name != "/dev/sdb" {
+		t.Errorf("Resolve() of the plugged disk = %q, %v, want /dev/sdb", name, err)
+	}
+	if _, err := resolveDevice(root, sda1); !errors.Is(err, ErrUnresolvedDevice) {
+		t.Errorf("resolveDevice() of the removed disk = %v, want ErrUnresolvedDevice", err)
+	}
+}
+
+func TestDeviceResolverWithoutNetClass(t *testing.T) {
+	r := NewDeviceResolver(t.TempDir())
+	if _, err := r.Resolve(ParsedDevice{Type: DeviceNet, Ifindex: 2}); err == nil {
+		t.Error("Resolve() without class/net succeeded")
+	}
+}
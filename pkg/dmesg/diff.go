@@ -0,0 +1,46 @@
+package dmesg
+
+// Diff returns the messages of cur logged after the ones of prev, e.g. to check only what's new
+// since the last read of a periodic check. Both must be read from kernel ring buffer in order,
+// with sequence numbers, cur after prev.
+//
+// lost is the number of messages logged after the newest of prev which were evicted from kernel
+// ring buffer before cur was read, told by the gap of sequence numbers between the two. With
+// filters, the messages dropped by them between the two are counted too.
+//
+// When the system rebooted between the reads, all of cur is added and rebooted is true, lost is
+// the messages of the new boot before the oldest of cur. A reboot is told by BootID of the
+// messages, see TakeSnapshot, or else by BootTime to the second, see WithBootTime, or else by the
+// sequence numbers of cur going back before the newest of prev.
+func Diff(prev, cur []Msg) (added []Msg, lost int, rebooted bool) {
+	if len(cur) == 0 {
+		return nil, 0, false
+	}
+	if len(prev) == 0 {
+		return cur, 0, false
+	}
+
+	newest, first := prev[len(prev)-1], cur[0]
+	last := cur[len(cur)-1]
+	switch {
+	case newest.BootID != "" && last.BootID != "":
+		rebooted = newest.BootID != last.BootID
+	case !newest.BootTime.IsZero() && !last.BootTime.IsZero():
+		rebooted = newest.BootTime.Unix() != last.BootTime.Unix()
+	default:
+		rebooted = last.Seq < newest.Seq
+	}
+	if rebooted {
+		return cur, int(first.Seq), true
+	}
+
+	i := 0
+	for i < len(cur) && cur[i].Seq <= newest.Seq {
+		i++
+	}
+	if first.Seq > newest.Seq+1 {
+		lost = int(first.Seq - newest.Seq - 1)
+	}
+
+	return cur[i:], lost, false
+}
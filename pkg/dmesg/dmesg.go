@@ -1,173 +1,355 @@
 // Package dmesg provides interfaces to get log messages from linux kernel ring buffer like
 // cmd util 'dmesg' by reading data from /dev/kmsg.
+//
+// Dmesg returns a snapshot of all messages in kernel ring buffer, Follow keeps delivering
+// new messages like 'dmesg --follow':
+//
+//	msgs, errs := dmesg.Follow(ctx)
+//	for msgs != nil || errs != nil {
+//		select {
+//		case msg, ok := <-msgs:
+//			if !ok {
+//				msgs = nil
+//				continue
+//			}
+//			fmt.Println(msg.Text)
+//		case err, ok := <-errs:
+//			if !ok {
+//				errs = nil
+//				continue
+//			}
+//			log.Println(err)
+//		}
+//	}
 package dmesg
 
 import (
-	"bytes"
+	"context"
 	"errors"
-	"os"
-	"strconv"
+	"fmt"
+	"slices"
 	"syscall"
+	"time"
 )
 
+type Msg struct {
+	Priority       uint64            // SYSLOG priority as in the record prefix, untouched: Facility<<3 | Level
+	Level          Level             // SYSLOG level
+	Facility       Facility          // SYSLOG facility
+	Seq            uint64            // Message sequence number
+	TsUsec         int64             // Timestamp in microsecond
+	Caller         string            // Message caller, e.g. "T123" for a task or "C3" for a CPU
+	Flag           Flag              // Flag of the record prefix, see IsFragment
+	Fragments      []uint64          // Sequence numbers of the continuation records merged into this message, see MergeFragments
+	Text           string            // Log text
+	Subsystem      string            // SUBSYSTEM of the device info, e.g. "pci"
+	Device         string            // DEVICE of the device info, e.g. "+pci:0000:00:1f.2"
+	DeviceInfo     map[string]string // Device info keys other than SUBSYSTEM and DEVICE
+	DeviceInfoList []KV              // All device info lines in the order of the record, including repeated keys
+	Truncated      bool              // This message didn't fit in the buf, only Seq is known
+	BootTime       time.Time         // Time the system booted, TsUsec is relative to it. Only set with WithBootTime
+	Suspended      time.Duration     // Time the system was suspended when the message was read. Only set with WithSuspendCorrection
+	WallTime       time.Time         // Wall clock time when only it is known, e.g. parsed from 'dmesg -T' output by ParseText. TsUsec is -1 then
+	BootID         string            // Boot ID of the system which logged the message when known, e.g. from a Snapshot, see Merge
+}
+
+// Flag is the flag character of a record prefix, telling whether a message is complete or a
+// part of a message continued over several records. Other characters are kept as they are.
+type Flag byte
+
 const (
-	defaultBufSize = uint32(1 << 14) // 16KB by default
-	levelMask      = uint64(1<<3 - 1)
+	FlagNone         Flag = '-' // A complete message
+	FlagFragment     Flag = 'c' // First part of a message continued in the following records
+	FlagContinuation Flag = '+' // Continuation of the message in the previous record, only older kernels
 )
 
-type Msg struct {
-	Level      uint64            // SYSLOG lvel
-	Facility   uint64            // SYSLOG facility
-	Seq        uint64            // Message sequence number
-	TsUsec     int64             // Timestamp in microsecond
-	Caller     string            // Message caller
-	IsFragment bool              // This message is a fragment of an early message which is not a fragment
-	Text       string            // Log text
-	DeviceInfo map[string]string // Device info
+// IsFragment reports whether m is a part of a message continued over several records, i.e.
+// Flag is FlagFragment or FlagContinuation.
+func (m Msg) IsFragment() bool {
+	return m.Flag == FlagFragment || m.Flag == FlagContinuation
+}
+
+// KV is a key and value of a device info line.
+type KV struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// OverrunError is returned when the kernel overwrote records before they could be read.
+// The messages read are still returned along with it.
+type OverrunError struct {
+	Count  int    // Number of overruns while reading
+	Missed uint64 // Number of messages lost, as far as known from sequence numbers
+}
+
+func (e *OverrunError) Error() string {
+	return fmt.Sprintf("dmesg: %d overrun(s) while reading, %d message(s) lost", e.Count, e.Missed)
+}
+
+// Unwrap makes errors.Is(err, syscall.EPIPE) report overruns.
+func (e *OverrunError) Unwrap() error {
+	return syscall.EPIPE
+}
+
+// ErrTruncatedResult is returned along with the messages read when reading stopped because
+// the limit of WithMaxMessages or WithMaxBytes was reached.
+var ErrTruncatedResult = errors.New("dmesg: result truncated by the max messages or bytes")
+
+// GapError is returned by DmesgSince when some messages after the requested sequence number
+// are no longer in kernel ring buffer. The messages read are still returned along with it.
+type GapError struct {
+	After  uint64 // The requested sequence number
+	Oldest uint64 // Sequence number of the oldest message in kernel ring buffer
+}
+
+func (e *GapError) Error() string {
+	return fmt.Sprintf("dmesg: %d message(s) after %d are no longer in kernel ring buffer", e.Missed(), e.After)
+}
+
+// Missed returns the number of messages lost between the requested sequence number and
+// the oldest message.
+func (e *GapError) Missed() uint64 {
+	return e.Oldest - e.After - 1
 }
 
 type dmesg struct {
 	raw [][]byte
 	msg []Msg
-}
 
-func parseData(data []byte) *Msg {
-	msg := Msg{}
+	tail  int // Keep only the last tail messages if > 0
+	start int // Index of the oldest message once the tail is full
+	count int // Number of messages added
+	bytes int // Size of messages added
 
-	dataLen := len(data)
-	prefixEnd := bytes.IndexByte(data, ';')
-	if prefixEnd == -1 {
-		return nil
-	}
+	limit    int // Stop reading after limit messages are added if > 0
+	maxBytes int // Stop reading after maxBytes of messages are added if > 0
 
-	for index, prefix := range bytes.Split(data[:prefixEnd], []byte(",")) {
-		switch index {
-		case 0:
-			val, _ := strconv.ParseUint(string(prefix), 10, 64)
-			msg.Level = val & levelMask
-			msg.Facility = val & (^levelMask)
-		case 1:
-			val, _ := strconv.ParseUint(string(prefix), 10, 64)
-			msg.Seq = val
-		case 2:
-			val, _ := strconv.ParseInt(string(prefix), 10, 64)
-			msg.TsUsec = val
-		case 3:
-			msg.IsFragment = prefix[0] != '-'
-		case 4:
-			msg.Caller = string(prefix)
-		}
+	oldest    uint64 // Sequence number of the first record read, kept or not
+	hasOldest bool
+	newest    uint64 // Sequence number of the last record read, kept or not
+	hasNewest bool
+}
+
+// gap returns a *GapError if messages after seq were lost before the oldest record read.
+func (d *dmesg) gap(seq uint64) error {
+	if d.hasOldest && d.oldest > seq+1 {
+		return &GapError{After: seq, Oldest: d.oldest}
 	}
 
-	textEnd := bytes.IndexByte(data, '\n')
-	if textEnd == -1 || textEnd <= prefixEnd {
-		return nil
+	return nil
+}
+
+// full reports whether no more messages should be read into d.
+func (d *dmesg) full() bool {
+	return d.limit > 0 && d.count >= d.limit || d.maxBytes > 0 && d.bytes >= d.maxBytes
+}
+
+func (d *dmesg) addMsg(msg Msg) {
+	d.count++
+	d.bytes += len(msg.Text) + len(msg.Subsystem) + len(msg.Device)
+	for key, value := range msg.DeviceInfo {
+		d.bytes += len(key) + len(value)
 	}
+	if d.tail == 0 || len(d.msg) < d.tail {
+		d.msg = append(d.msg, msg)
+		return
+	}
+
+	d.msg[d.start] = msg
+	d.start = (d.start + 1) % d.tail
+}
 
-	msg.Text = string(data[prefixEnd+1 : textEnd])
-	if textEnd == dataLen-1 {
-		return nil
+func (d *dmesg) addRaw(raw []byte) {
+	d.count++
+	d.bytes += len(raw)
+	if d.tail == 0 || len(d.raw) < d.tail {
+		d.raw = append(d.raw, raw)
+		return
 	}
 
-	msg.DeviceInfo = make(map[string]string, 2)
-	deviceInfo := bytes.Split(data[textEnd+1:dataLen-1], []byte("\n"))
-	for _, info := range deviceInfo {
-		if info[0] != ' ' {
-			continue
-		}
+	d.raw[d.start] = raw
+	d.start = (d.start + 1) % d.tail
+}
 
-		kv := bytes.Split(info, []byte("="))
-		if len(kv) != 2 {
-			continue
-		}
+// order puts the messages kept for the tail in order from the oldest to the newest.
+func (d *dmesg) order() {
+	if d.start == 0 {
+		return
+	}
 
-		msg.DeviceInfo[string(kv[0])] = string(kv[1])
+	if len(d.msg) > 0 {
+		rotate(d.msg, d.start)
+	} else {
+		rotate(d.raw, d.start)
 	}
+	d.start = 0
+}
 
-	return &msg
+func (d *dmesg) reverse() {
+	slices.Reverse(d.msg)
+	slices.Reverse(d.raw)
 }
 
-func fetch(bufSize uint32, fetchRaw bool) (dmesg, error) {
-	d := dmesg{}
-	file, err := os.OpenFile("/dev/kmsg", syscall.O_RDONLY|syscall.O_NONBLOCK, 0)
+// rotate rotates s in place so s[start] becomes the first element.
+func rotate[T any](s []T, start int) {
+	slices.Reverse(s[:start])
+	slices.Reverse(s[start:])
+	slices.Reverse(s)
+}
+
+const (
+	// avgRecordSize estimates the bytes a record takes in kernel ring buffer, see
+	// estimateRecords.
+	avgRecordSize = 64
+	// maxEstimatedRecords limits the estimate so a large and mostly empty kernel ring buffer
+	// doesn't allocate megabytes up front.
+	maxEstimatedRecords = 1 << 14
+)
+
+// estimateRecords returns the capacity to allocate for the messages read with o: the number of
+// records a full kernel ring buffer holds, limited by WithTail and WithMaxMessages. It returns 0
+// when the size can't be read or filters are set, since they may drop most records.
+func estimateRecords(o options) int {
+	if o.tail > 0 {
+		return o.tail
+	}
+	if o.filtered() {
+		return 0
+	}
+	size, err := bufferSize()
 	if err != nil {
-		return d, err
+		return 0
 	}
-	defer file.Close()
 
-	var conn syscall.RawConn
-	conn, err = file.SyscallConn()
-	if err != nil {
+	n := min(size/avgRecordSize, maxEstimatedRecords)
+	if o.maxMessages > 0 {
+		n = min(n, o.maxMessages)
+	}
+
+	return n
+}
+
+func fetch(ctx context.Context, o options, fetchRaw bool) (dmesg, error) {
+	d := dmesg{}
+	r, err := open(o)
+	fallback := err != nil && !fetchRaw && o.useKlogFallback(err)
+	if err != nil && !fallback {
 		return d, err
 	}
 
 	if fetchRaw {
-		d.raw = make([][]byte, 0)
+		d.raw = make([][]byte, 0, estimateRecords(o))
+	} else {
+		d.msg = make([]Msg, 0, estimateRecords(o))
+	}
+	d.tail = o.tail
+	d.limit, d.maxBytes = o.maxMessages, o.maxBytes
+	if fallback {
+		err = readAllKlogctl(o, &d)
 	} else {
-		d.msg = make([]Msg, 0)
-	}
-
-	var syscallError error = nil
-	err = conn.Read(func(fd uintptr) bool {
-		for {
-			buf := make([]byte, bufSize)
-			_, err := syscall.Read(int(fd), buf)
-			if err != nil {
-				syscallError = err
-				// EINVAL means buf is not enough, data would be truncated, but still can continue.
-				if !errors.Is(err, syscall.EINVAL) {
-					return true
-				}
-			}
-
-			if fetchRaw {
-				d.raw = append(d.raw, buf)
-			} else {
-				msg := parseData(buf)
-				if msg == nil {
-					continue
-				}
-				d.msg = append(d.msg, *msg)
-			}
-		}
-	})
-
-	// EAGAIN means no more data, should be treated as normal.
-	if syscallError != nil && !errors.Is(syscallError, syscall.EAGAIN) {
-		err = syscallError
+		defer r.Close()
+		err = r.read(ctx, &d, fetchRaw)
+	}
+	if err == nil && d.full() {
+		err = ErrTruncatedResult
+	}
+	d.order()
+	if o.mergeFragments && !fetchRaw {
+		d.msg = MergeFragments(d.msg)
+	}
+	if o.reverse {
+		d.reverse()
 	}
 
 	return d, err
 }
 
-// DmesgWithBufSize gets all messages from kernel ring buffer with specific buf size for each message.
+// Dmesg gets all messages from kernel ring buffer, by default with buf size 16KB for each message.
 // It returns serialized message structure and the error while getting messages.
-func DmesgWithBufSize(bufSize uint32) ([]Msg, error) {
-	d, err := fetch(bufSize, false)
+// The error syscall.EINVAL means a message doesn't fit even in the max buf size, only before
+// linux 5.10: since then the message is skipped with Truncated set. An *OverrunError means some
+// messages were lost while reading, the messages read are still returned.
+func Dmesg(opts ...Option) ([]Msg, error) {
+	return DmesgContext(context.Background(), opts...)
+}
+
+// DmesgContext is like Dmesg but stops reading when ctx is done. It returns ctx.Err()
+// along with the messages read so far.
+func DmesgContext(ctx context.Context, opts ...Option) ([]Msg, error) {
+	d, err := fetch(ctx, newOptions(opts), false)
 
 	return d.msg, err
 }
 
-// RawDmesgWithBufSize gets all messages from kernel ring buffer with specific buf size for each message.
+// RawDmesg gets all messages from kernel ring buffer, by default with buf size 16KB for each message.
 // It returns native message from kernel without parsing and the error while getting messages.
-func RawDmesgWithBufSize(bufSize uint32) ([][]byte, error) {
-	d, err := fetch(bufSize, true)
+// The error syscall.EINVAL means a message doesn't fit even in the max buf size, only before
+// linux 5.10: since then the message is skipped with Truncated set. An *OverrunError means some
+// messages were lost while reading, the messages read are still returned.
+func RawDmesg(opts ...Option) ([][]byte, error) {
+	return RawDmesgContext(context.Background(), opts...)
+}
+
+// RawDmesgContext is like RawDmesg but stops reading when ctx is done. It returns ctx.Err()
+// along with the messages read so far.
+func RawDmesgContext(ctx context.Context, opts ...Option) ([][]byte, error) {
+	d, err := fetch(ctx, newOptions(opts), true)
 
 	return d.raw, err
 }
 
-// Dmesg gets all messages from kernel ring buffer with default buf size 16KB for each message.
-// It returns serialized message structure and the error while getting messages.
-// The error syscall.EINVAL means the buf size is not enough, consider to use
-// DmesgWithBufSize instead.
-func Dmesg() ([]Msg, error) {
-	return DmesgWithBufSize(defaultBufSize)
+// DmesgSince gets the messages newer than the message with sequence number seq, e.g. the
+// last one handled before. Older records are dropped before they are parsed. A *GapError is
+// returned along with the messages if some messages after seq are no longer in kernel ring
+// buffer.
+func DmesgSince(seq uint64, opts ...Option) ([]Msg, error) {
+	o := newOptions(opts)
+	o.afterSeq = seq
+	o.hasAfterSeq = true
+	d, err := fetch(context.Background(), o, false)
+	if err == nil {
+		err = d.gap(seq)
+	}
+
+	return d.msg, err
 }
 
-// RawDmesg gets all messages from kernel ring buffer with default buf size 16KB for each message.
-// It returns native message from kernel without parsing and the error while getting messages.
-// The error syscall.EINVAL means the buf size is not enough, consider to use
-// RawDmesgWithBufSize instead.
-func RawDmesg() ([][]byte, error) {
-	return RawDmesgWithBufSize(defaultBufSize)
+// Tail gets the last n messages in kernel ring buffer. It still reads all messages, but
+// only keeps the last n, so it doesn't materialize all messages in memory. Filter options
+// apply before the last n messages are chosen.
+func Tail(n int, opts ...Option) ([]Msg, error) {
+	if n <= 0 {
+		return []Msg{}, nil
+	}
+
+	o := newOptions(opts)
+	o.tail = n
+	d, err := fetch(context.Background(), o, false)
+
+	return d.msg, err
+}
+
+// TailRaw is like Tail but returns native messages from kernel without parsing.
+func TailRaw(n int, opts ...Option) ([][]byte, error) {
+	if n <= 0 {
+		return [][]byte{}, nil
+	}
+
+	o := newOptions(opts)
+	o.tail = n
+	d, err := fetch(context.Background(), o, true)
+
+	return d.raw, err
+}
+
+// DmesgWithBufSize gets all messages from kernel ring buffer with specific buf size for each message.
+// It's the same as Dmesg(WithBufSize(bufSize)), 0 means DefaultBufSize.
+func DmesgWithBufSize(bufSize uint32) ([]Msg, error) {
+	return Dmesg(WithBufSize(bufSize))
+}
+
+// RawDmesgWithBufSize gets all messages from kernel ring buffer with specific buf size for each message.
+// It's the same as RawDmesg(WithBufSize(bufSize)), 0 means DefaultBufSize.
+func RawDmesgWithBufSize(bufSize uint32) ([][]byte, error) {
+	return RawDmesg(WithBufSize(bufSize))
 }
@@ -0,0 +1,178 @@
+package dmesg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeDump writes records to a dump of /dev/kmsg and returns its path, to be read with
+// WithPath.
+func writeDump(t *testing.T, records ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "kmsg")
+	if err := os.WriteFile(path, []byte(strings.Join(records, "")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// readRecords returns the messages of the dump of /dev/kmsg testdata/name.
+func readRecords(t *testing.T, name string) []Msg {
+	t.Helper()
+
+	msgs, err := Dmesg(WithPath(filepath.Join("testdata", name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return msgs
+}
+
+// extractArchive extracts the files of the txtar archive testdata/name, each "-- path --" line
+// followed by the content of the file, into a temporary directory and returns its path. The
+// text before the first file describes the archive.
+func extractArchive(t *testing.T, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	var file string
+	var content strings.Builder
+	flush := func() {
+		if file == "" {
+			return
+		}
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if name, ok := strings.CutPrefix(strings.TrimSuffix(line, "\n"), "-- "); ok && strings.HasSuffix(name, " --") {
+			flush()
+			file = strings.TrimSuffix(name, " --")
+			content.Reset()
+			continue
+		}
+		content.WriteString(line)
+	}
+	flush()
+
+	return dir
+}
+
+// seqs returns the sequence numbers of msgs.
+func seqs(msgs []Msg) []uint64 {
+	s := make([]uint64, 0, len(msgs))
+	for _, msg := range msgs {
+		s = append(s, msg.Seq)
+	}
+
+	return s
+}
+
+// benchRecords returns n records like the ones of a booting system, every third one with device
+// info.
+func benchRecords(n int) []string {
+	records := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		seq, ts := i+1, i*1731
+		var record string
+		switch i % 6 {
+		case 0:
+			record = fmt.Sprintf("6,%d,%d,-;usb 1-%d: new high-speed USB device number %d using xhci_hcd\n"+
+				" SUBSYSTEM=usb\n DEVICE=c189:%d\n", seq, ts, i%8, i%128, i%128)
+		case 1:
+			record = fmt.Sprintf("6,%d,%d,-;pci 0000:00:%02x.0: [8086:a0ed] type 00 class 0x0c0330\n", seq, ts, i%32)
+		case 2:
+			record = fmt.Sprintf("4,%d,%d,-;ACPI Warning: SystemIO range 0x%04x conflicts with OpRegion\n", seq, ts, i%0xffff)
+		case 3:
+			record = fmt.Sprintf("3,%d,%d,-;I/O error, dev sda, sector %d op 0x0:(READ) flags 0x0 phys_seg 1 prio class 2\n"+
+				" SUBSYSTEM=block\n DEVICE=b8:0\n DEVNAME=sda\n DEVTYPE=disk\n", seq, ts, i*8)
+		case 4:
+			record = fmt.Sprintf("6,%d,%d,-;EXT4-fs (nvme0n1p2): mounted filesystem with ordered data mode\n", seq, ts)
+		default:
+			record = fmt.Sprintf("30,%d,%d,-;systemd[1]: Started Journal Service (%d).\n", seq, ts, i)
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// BenchmarkDmesgDeviceInfo measures what WithoutDeviceInfo saves on a 10k record dump.
+func BenchmarkDmesgDeviceInfo(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "kmsg")
+	if err := os.WriteFile(path, []byte(strings.Join(benchRecords(10_000), "")), 0o644); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bb := range []struct {
+		name string
+		opts []Option
+	}{
+		{"parsed", nil},
+		{"without", []Option{WithoutDeviceInfo()}},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				msgs, err := Dmesg(append(bb.opts, WithPath(path))...)
+				if err != nil || len(msgs) != 10_000 {
+					b.Fatalf("Dmesg() = %d messages, %v", len(msgs), err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDmesgPreallocate compares reading 10k records into room allocated up front from the
+// size of kernel ring buffer with growing the slices from empty, like when the size can't be
+// read.
+func BenchmarkDmesgPreallocate(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "kmsg")
+	if err := os.WriteFile(path, []byte(strings.Join(benchRecords(10_000), "")), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	bufferSize0 := bufferSize
+	b.Cleanup(func() { bufferSize = bufferSize0 })
+
+	for _, bb := range []struct {
+		name string
+		size func() (int, error)
+	}{
+		{"preallocated", func() (int, error) { return 10_000 * avgRecordSize, nil }},
+		{"growing", func() (int, error) { return 0, errors.ErrUnsupported }},
+	} {
+		bufferSize = bb.size
+		b.Run(bb.name+"/msgs", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if msgs, err := Dmesg(WithPath(path), WithoutDeviceInfo()); err != nil || len(msgs) != 10_000 {
+					b.Fatalf("Dmesg() = %d messages, %v", len(msgs), err)
+				}
+			}
+		})
+		b.Run(bb.name+"/raw", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if raw, err := RawDmesg(WithPath(path)); err != nil || len(raw) != 10_000 {
+					b.Fatalf("RawDmesg() = %d records, %v", len(raw), err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package dmesg
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// DroppedMarker is a message of the kernel telling that messages were lost, e.g.
+// "** 4 printk messages dropped **" when the console couldn't keep up.
+type DroppedMarker struct {
+	Count      int    // Number of messages dropped or suppressed
+	Seq        uint64 // Sequence number of the message with the marker
+	Suppressed bool   // Messages were suppressed by rate limiting rather than dropped
+	Source     string // Function whose messages were suppressed, e.g. "net_ratelimit"
+}
+
+var (
+	// "** 4 printk messages dropped **", older kernels put the next message in the same line.
+	printkDroppedRe = regexp.MustCompile(`^\*\* (\d+) printk messages dropped \*\*`)
+	// Rate limited messages of ___ratelimit, e.g. "net_ratelimit: 32 callbacks suppressed".
+	callbacksSuppressedRe = regexp.MustCompile(`^(\S+): (\d+) callbacks suppressed$`)
+	// Messages suppressed by printk_ratelimit of older kernels, "printk: 5 messages suppressed.".
+	messagesSuppressedRe = regexp.MustCompile(`^printk: (\d+) messages suppressed\.?$`)
+	// Lines written to /dev/kmsg by a task and suppressed by the rate limit of printk.devkmsg,
+	// since 4.10, e.g. "printk: systemd: 22 output lines suppressed due to ratelimiting". Kernels
+	// before 4.17 log it without "printk: ".
+	linesSuppressedRe = regexp.MustCompile(`^(?:printk: )?(.+): (\d+) output lines suppressed due to ratelimiting$`)
+)
+
+// ParseDroppedMarker returns the DroppedMarker of msg if its text is one of the markers the
+// kernel logs when messages were lost: "** 4 printk messages dropped **", and for messages
+// suppressed by rate limiting "net_ratelimit: 32 callbacks suppressed", the
+// "printk: 5 messages suppressed." of older kernels or the lines of a task written to /dev/kmsg,
+// "printk: systemd: 22 output lines suppressed due to ratelimiting", whose Source is the task.
+func ParseDroppedMarker(msg Msg) (DroppedMarker, bool) {
+	if m := printkDroppedRe.FindStringSubmatch(msg.Text); m != nil {
+		return droppedMarker(msg, m[1], false, "")
+	}
+	if m := callbacksSuppressedRe.FindStringSubmatch(msg.Text); m != nil {
+		return droppedMarker(msg, m[2], true, m[1])
+	}
+	if m := messagesSuppressedRe.FindStringSubmatch(msg.Text); m != nil {
+		return droppedMarker(msg, m[1], true, "printk")
+	}
+	if m := linesSuppressedRe.FindStringSubmatch(msg.Text); m != nil {
+		return droppedMarker(msg, m[2], true, m[1])
+	}
+
+	return DroppedMarker{}, false
+}
+
+func droppedMarker(msg Msg, count string, suppressed bool, source string) (DroppedMarker, bool) {
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return DroppedMarker{}, false
+	}
+
+	return DroppedMarker{Count: n, Seq: msg.Seq, Suppressed: suppressed, Source: source}, true
+}
+
+// DroppedMarkers returns the DroppedMarkers of msgs, see ParseDroppedMarker. Together with Gaps
+// it tells all messages lost, the markers the ones lost by the kernel before they reached the
+// ring buffer or a console, Gaps the ones lost in the ring buffer.
+func DroppedMarkers(msgs []Msg) []DroppedMarker {
+	var markers []DroppedMarker
+	for _, msg := range msgs {
+		if marker, ok := ParseDroppedMarker(msg); ok {
+			markers = append(markers, marker)
+		}
+	}
+
+	return markers
+}
@@ -0,0 +1,157 @@
+package dmesg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDroppedMarkers(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []DroppedMarker
+	}{
+		// Linux 4.15 logs the lines suppressed by printk.devkmsg without "printk: ".
+		{"dropped-4.15", []DroppedMarker{
+			{Count: 32, Seq: 2211, Suppressed: true, Source: "net_ratelimit"},
+			{Count: 18, Seq: 2213, Suppressed: true, Source: "systemd-udevd"},
+			{Count: 4, Seq: 2214, Suppressed: true, Source: "print_req_error"},
+		}},
+		// Since 4.17 "printk: " is in front of the task, whose name may have parentheses.
+		{"dropped-6.1", []DroppedMarker{
+			{Count: 22, Seq: 48211, Suppressed: true, Source: "systemd"},
+			{Count: 62, Seq: 48212, Suppressed: true, Source: "dmar_fault"},
+			{Count: 3, Seq: 48214, Suppressed: true, Source: "(udev-worker)"},
+			{Count: 5, Seq: 48216, Suppressed: true, Source: "blk_print_req_error"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			if got := DroppedMarkers(readRecords(t, tt.fixture)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DroppedMarkers() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// The text fixtures are captures of dmesg, testdata/dropped-2.6.18.txt with the
+// printk_ratelimit messages of kernels before 2.6.28, and of a console, which prints
+// "** 12 printk messages dropped **" without prefix, linux 3.10 in front of the next message.
+func TestDroppedMarkersText(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []DroppedMarker
+		texts   []string // Texts of the messages
+	}{
+		{"dropped-2.6.18.txt", []DroppedMarker{
+			{Count: 27, Suppressed: true, Source: "printk"},
+			{Count: 5, Suppressed: true, Source: "printk"},
+		}, nil},
+		{"dropped-2.6.32.txt", []DroppedMarker{
+			{Count: 102, Suppressed: true, Source: "__ratelimit"},
+			{Count: 37, Suppressed: true, Source: "__ratelimit"},
+		}, nil},
+		{"dropped-3.10-console.txt", []DroppedMarker{{Count: 12}, {Count: 3}}, []string{
+			"INFO: task kworker/u16:3:1822 blocked for more than 120 seconds.",
+			`"echo 0 > /proc/sys/kernel/hung_task_timeout_secs" disables this message.`,
+			"** 12 printk messages dropped **",
+			"Call Trace:",
+			" [<ffffffff8163a909>] schedule+0x29/0x70",
+			"** 3 printk messages dropped **",
+			" [<ffffffff810a5b8f>] kthread+0xcf/0xe0",
+		}},
+		{"dropped-6.1-console.txt", []DroppedMarker{{Count: 41}}, []string{
+			"sysrq: Show Blocked State",
+			"task:kworker/0:2     state:D stack:0     pid:112   ppid:2      flags:0x00004000",
+			"** 41 printk messages dropped **",
+			" __schedule+0x349/0x9b0",
+			" schedule+0x5d/0xe0",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			file, err := os.Open(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			msgs, err := ParseText(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := DroppedMarkers(msgs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DroppedMarkers() = %+v, want %+v", got, tt.want)
+			}
+			if tt.texts == nil {
+				return
+			}
+			texts := make([]string, 0, len(msgs))
+			for _, msg := range msgs {
+				texts = append(texts, msg.Text)
+			}
+			if !reflect.DeepEqual(texts, tt.texts) {
+				t.Errorf("ParseText() = %q, want %q", texts, tt.texts)
+			}
+		})
+	}
+}
+
+// A marker printed by a console gets the time of the previous message, or none when it's the
+// first line.
+func TestParseTextDroppedMarkerTime(t *testing.T) {
+	file, err := os.Open("testdata/dropped-3.10-console.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	msgs, err := ParseText(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgs[2].TsUsec != msgs[1].TsUsec || msgs[3].TsUsec != 502117412 || msgs[2].Level != LevelWarn {
+		t.Errorf("ParseText() = %+v, %+v, want the marker at %d and the next message at 502117412",
+			msgs[2], msgs[3], msgs[1].TsUsec)
+	}
+
+	first, err := ParseText(strings.NewReader("** 2 printk messages dropped ** [    3.000001] usb 1-1: new device\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 2 || first[0].TsUsec != 0 || first[1].TsUsec != 3000001 || first[1].Text != "usb 1-1: new device" {
+		t.Errorf("ParseText() = %+v, want the marker at 0 and the next message at 3000001", first)
+	}
+}
+
+func TestParseDroppedMarker(t *testing.T) {
+	tests := []struct {
+		text string
+		want DroppedMarker
+		ok   bool
+	}{
+		{"** 4 printk messages dropped **", DroppedMarker{Count: 4, Seq: 7}, true},
+		{"** 4 printk messages dropped ** [   12.345678] next message", DroppedMarker{Count: 4, Seq: 7}, true},
+		{"net_ratelimit: 32 callbacks suppressed", DroppedMarker{Count: 32, Seq: 7, Suppressed: true, Source: "net_ratelimit"}, true},
+		{"printk: 5 messages suppressed.", DroppedMarker{Count: 5, Seq: 7, Suppressed: true, Source: "printk"}, true},
+		{"printk: systemd: 22 output lines suppressed due to ratelimiting", DroppedMarker{Count: 22, Seq: 7, Suppressed: true, Source: "systemd"}, true},
+		{"systemd: 22 output lines suppressed due to ratelimiting", DroppedMarker{Count: 22, Seq: 7, Suppressed: true, Source: "systemd"}, true},
+
+		{"printk messages dropped", DroppedMarker{}, false},
+		{"next message ** 4 printk messages dropped **", DroppedMarker{}, false},
+		{"net_ratelimit: 32 callbacks suppressed, see above", DroppedMarker{}, false},
+		{"a b: 3 callbacks suppressed", DroppedMarker{}, false},
+		{"printk: many messages suppressed.", DroppedMarker{}, false},
+		{"** 99999999999999999999 printk messages dropped **", DroppedMarker{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseDroppedMarker(Msg{Seq: 7, Text: tt.text})
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseDroppedMarker(%q) = %+v, %v, want %+v, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package dmesg
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// recordPrefixRe matches the start of a record of /dev/kmsg, e.g. "6,1234,5678901,".
+var recordPrefixRe = regexp.MustCompile(`^\d+,\d+,\d+,`)
+
+// ReadDumpFile reads the messages saved in the file at path, a dump of /dev/kmsg records like
+// Decoder reads or the text output of dmesg like ParseText parses, told apart by the first
+// line. The file may be compressed with gzip or zstd, told by its magic bytes, e.g. a
+// dmesg.txt.gz of a support bundle.
+//
+// Records and lines which can't be parsed are skipped and a *MalformedLinesError is returned
+// along with the messages, counting records for a dump of records.
+func ReadDumpFile(path string) ([]Msg, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r, err := decompress(bufio.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	content := bufio.NewReader(r)
+	if isRecordDump(content) {
+		return decodeAll(NewDecoder(content))
+	}
+
+	return ParseText(content)
+}
+
+// decompress returns a reader of the content of r, decompressed if r starts with the magic
+// bytes of gzip or zstd.
+func decompress(r *bufio.Reader) (io.ReadCloser, error) {
+	// A short file can't be compressed, Peek returns what there is.
+	magic, _ := r.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(r)
+	case bytes.HasPrefix(magic, zstdMagic):
+		dec, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	}
+
+	return io.NopCloser(r), nil
+}
+
+// isRecordDump reports whether the first line of r is a record of /dev/kmsg rather than dmesg
+// output.
+func isRecordDump(r *bufio.Reader) bool {
+	// The prefix of a record is far shorter, empty lines before it are skipped by Decoder.
+	head, _ := r.Peek(256)
+	head = bytes.TrimLeft(head, "\r\n")
+
+	return recordPrefixRe.Match(head)
+}
+
+// decodeAll decodes all records of dec, skipping the invalid ones.
+func decodeAll(dec *Decoder) ([]Msg, error) {
+	malformed := &MalformedLinesError{}
+
+	var msgs []Msg
+	for number := 1; ; number++ {
+		msg, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, ErrInvalidRecord) {
+			if malformed.Count == 0 {
+				malformed.First = number
+			}
+			malformed.Count++
+			continue
+		}
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, msg)
+	}
+
+	if malformed.Count > 0 {
+		return msgs, malformed
+	}
+
+	return msgs, nil
+}
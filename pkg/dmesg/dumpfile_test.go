@@ -0,0 +1,144 @@
+package dmesg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressions are the ways a dump file may be stored, each writing data compressed.
+var compressions = []struct {
+	name     string
+	compress func(t *testing.T, data []byte) []byte
+}{
+	{"plain", func(t *testing.T, data []byte) []byte { return data }},
+	{"gzip", func(t *testing.T, data []byte) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}},
+	{"zstd", func(t *testing.T, data []byte) []byte {
+		w, err := zstd.NewWriter(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Close()
+		return w.EncodeAll(data, nil)
+	}},
+}
+
+// writeDumpFile writes data compressed by compress into a temporary file and returns its path.
+func writeDumpFile(t *testing.T, data []byte, compress func(*testing.T, []byte) []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "dmesg")
+	if err := os.WriteFile(path, compress(t, data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestReadDumpFile(t *testing.T) {
+	records, err := os.ReadFile("testdata/kmsg-6.1-caller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := os.ReadFile("testdata/usb-2.6.32.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRecords := readRecords(t, "kmsg-6.1-caller")
+	wantText, err := ParseText(bytes.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want []Msg
+	}{
+		{"records", records, wantRecords},
+		// Empty lines in front of the first record are skipped.
+		{"records after empty lines", append([]byte("\n\r\n"), records...), wantRecords},
+		{"text", text, wantText},
+		{"empty", nil, nil},
+	}
+
+	for _, c := range compressions {
+		for _, tt := range tests {
+			t.Run(c.name+"/"+tt.name, func(t *testing.T) {
+				msgs, err := ReadDumpFile(writeDumpFile(t, tt.data, c.compress))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(msgs, tt.want) {
+					t.Errorf("ReadDumpFile() = %+v, want %+v", msgs, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestReadDumpFileMalformed(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		texts     []string
+		malformed MalformedLinesError
+	}{
+		{
+			"records",
+			"6,1,100,-;first\n6,x,200,-;bad seq\n6,3,300,-;third\nnot a record\n6,5,500,-;fifth\n",
+			[]string{"first", "third", "fifth"},
+			MalformedLinesError{Count: 2, First: 2},
+		},
+		{
+			"text",
+			"[    1.000000] first\n<x>[    2.000000] bad priority\n[    3.000000] third\n[bad] timestamp\n",
+			[]string{"first", "third"},
+			MalformedLinesError{Count: 2, First: 2},
+		},
+	}
+
+	for _, c := range compressions {
+		for _, tt := range tests {
+			t.Run(c.name+"/"+tt.name, func(t *testing.T) {
+				msgs, err := ReadDumpFile(writeDumpFile(t, []byte(tt.data), c.compress))
+				var malformed *MalformedLinesError
+				if !errors.As(err, &malformed) || *malformed != tt.malformed {
+					t.Fatalf("ReadDumpFile() error = %v, want %+v", err, tt.malformed)
+				}
+				if got := texts(msgs); !reflect.DeepEqual(got, tt.texts) {
+					t.Errorf("ReadDumpFile() = %q, want %q", got, tt.texts)
+				}
+			})
+		}
+	}
+}
+
+func TestReadDumpFileErrors(t *testing.T) {
+	if _, err := ReadDumpFile(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadDumpFile() of a missing file = %v, want %v", err, os.ErrNotExist)
+	}
+
+	// A file starting with the magic bytes of gzip which isn't gzip.
+	path := writeDumpFile(t, append(slices.Clone(gzipMagic), "6,1,100,-;text\n"...), compressions[0].compress)
+	if _, err := ReadDumpFile(path); err == nil {
+		t.Error("ReadDumpFile() of a corrupt gzip file succeeded")
+	}
+}
@@ -0,0 +1,154 @@
+package dmesg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxEmitRecord is the size of the longest write /dev/kmsg accepts with its prefix on every
+// kernel, LOG_LINE_MAX of kernels since 5.1 built with CONFIG_PRINTK_CALLER, whose PREFIX_MAX
+// is 48. Kernels before 5.1 accept 992 bytes, kernels since 6.7 1024 bytes.
+const maxEmitRecord = 1024 - 48
+
+// Emit writes text into kernel ring buffer through DefaultPath with level and facility, like
+// 'echo "<14>text" > /dev/kmsg'. Each line of text becomes a message, lines too long for the
+// kernel are split into several messages at UTF-8 boundaries. The kernel logs FacilityKern
+// messages from userspace as FacilityUser, and rate limits writes unless printk.devkmsg is on,
+// dropping the messages over the limit without an error.
+func Emit(level Level, facility Facility, text string) error {
+	if level > LevelDebug {
+		return fmt.Errorf("dmesg: invalid level %d", level)
+	}
+
+	file, err := os.OpenFile(DefaultPath, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	header := emitHeader(level, facility)
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if err := writeLine(file, header, line); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	return file.Close()
+}
+
+// emitHeader returns the priority prefix of messages written with level and facility, e.g.
+// "<14>".
+func emitHeader(level Level, facility Facility) string {
+	return fmt.Sprintf("<%d>", uint64(facility)<<facilityShift|uint64(level))
+}
+
+// writeLine writes line to w as records starting with header, split so each record fits
+// maxEmitRecord.
+func writeLine(w io.Writer, header, line string) error {
+	for _, part := range splitLine(line, maxEmitRecord-len(header)-1) {
+		if _, err := io.WriteString(w, header+part+"\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Emitf is like Emit with the text formatted by fmt.Sprintf.
+func Emitf(level Level, facility Facility, format string, args ...any) error {
+	return Emit(level, facility, fmt.Sprintf(format, args...))
+}
+
+// kmsgWriter writes lines into kernel ring buffer, see NewKmsgWriter.
+type kmsgWriter struct {
+	mu     sync.Mutex
+	file   *os.File
+	header string
+	buf    []byte // Partial line not yet written
+}
+
+// NewKmsgWriter returns a writer of messages into kernel ring buffer through DefaultPath, e.g.
+// to point a logger at it in an initramfs where only kernel ring buffer survives. Each line
+// written becomes a message with level and FacilityUser, starting with prefix, e.g. "init: ".
+// A partial line is kept until its newline is written or the writer is closed, lines too long
+// for the kernel are split like by Emit. It's safe for concurrent use.
+func NewKmsgWriter(level Level, prefix string) (io.WriteCloser, error) {
+	if level > LevelDebug {
+		return nil, fmt.Errorf("dmesg: invalid level %d", level)
+	}
+	if len(prefix) > maxEmitRecord/2 {
+		return nil, fmt.Errorf("dmesg: prefix of %d bytes is too long", len(prefix))
+	}
+
+	file, err := os.OpenFile(DefaultPath, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kmsgWriter{file: file, header: emitHeader(level, FacilityUser) + prefix}, nil
+}
+
+// Write writes the complete lines of p as messages and keeps the rest for the next write.
+func (w *kmsgWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	end := bytes.LastIndexByte(w.buf, '\n')
+	if end == -1 {
+		return len(p), nil
+	}
+
+	lines := string(w.buf[:end])
+	// Keep the partial line at the start of buf, so buf is reused.
+	w.buf = append(w.buf[:0], w.buf[end+1:]...)
+	for _, line := range strings.Split(lines, "\n") {
+		if err := writeLine(w.file, w.header, line); err != nil {
+			return len(p), err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close writes the partial line kept, if any, and closes the writer.
+func (w *kmsgWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var err error
+	if len(w.buf) > 0 {
+		err = writeLine(w.file, w.header, string(w.buf))
+		w.buf = nil
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// splitLine splits line into parts of at most size bytes without splitting UTF-8 sequences.
+// An empty line is a single empty part.
+func splitLine(line string, size int) []string {
+	parts := make([]string, 0, len(line)/size+1)
+	for len(line) > size {
+		end := size
+		for end > 0 && !utf8.RuneStart(line[end]) {
+			end--
+		}
+		if end == 0 {
+			end = size
+		}
+
+		parts = append(parts, line[:end])
+		line = line[end:]
+	}
+
+	return append(parts, line)
+}
@@ -0,0 +1,214 @@
+package dmesg
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// emitPath points DefaultPath at an empty file for the test and returns the file.
+func emitPath(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kmsg")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := DefaultPath
+	DefaultPath = path
+	t.Cleanup(func() {
+		DefaultPath = old
+	})
+
+	return path
+}
+
+// emitted returns the records written to path and empties it, a writer opened later writes at
+// its start.
+func emitted(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	records := strings.SplitAfter(string(data), "\n")
+	if records[len(records)-1] == "" {
+		records = records[:len(records)-1]
+	}
+
+	return records
+}
+
+// recordWriter keeps each write as a record, like /dev/kmsg does.
+type recordWriter struct {
+	records []string
+}
+
+func (w *recordWriter) Write(p []byte) (int, error) {
+	w.records = append(w.records, string(p))
+
+	return len(p), nil
+}
+
+func TestSplitLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		size int
+		want []string
+	}{
+		{"empty", "", 4, []string{""}},
+		{"fits", "abcd", 4, []string{"abcd"}},
+		{"one more", "abcde", 4, []string{"abcd", "e"}},
+		{"several", "abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		// "é" is 2 bytes, "€" 3 bytes: a part ends before a sequence crossing its end.
+		{"2 bytes at the end", "abcé", 4, []string{"abc", "é"}},
+		{"3 bytes at the end", "ab€c", 4, []string{"ab", "€c"}},
+		{"3 bytes fitting", "a€", 4, []string{"a€"}},
+		{"only sequences", "€€€", 4, []string{"€", "€", "€"}},
+		// A part can't end at a rune boundary when size is smaller than a sequence.
+		{"sequence longer than size", "€", 2, []string{"\xe2\x82", "\xac"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitLine(tt.line, tt.size); !slices.Equal(got, tt.want) {
+				t.Errorf("splitLine(%q, %d) = %q, want %q", tt.line, tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+// The records written with their header and newline fit LOG_LINE_MAX of kernels built with
+// CONFIG_PRINTK_CALLER, 976 bytes.
+func TestWriteLineBoundary(t *testing.T) {
+	const header = "<14>"
+	size := 976 - len(header) - 1
+
+	tests := []struct {
+		name  string
+		line  string
+		parts []string
+	}{
+		{"fits", strings.Repeat("a", size), []string{strings.Repeat("a", size)}},
+		{"one more", strings.Repeat("a", size+1), []string{strings.Repeat("a", size), "a"}},
+		{"2 bytes at the split", strings.Repeat("a", size-1) + "é", []string{strings.Repeat("a", size-1), "é"}},
+		{"3 bytes at the split", strings.Repeat("a", size-2) + "€b", []string{strings.Repeat("a", size-2), "€b"}},
+		{"3 bytes before the split", strings.Repeat("a", size-3) + "€b", []string{strings.Repeat("a", size-3) + "€", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w recordWriter
+			if err := writeLine(&w, header, tt.line); err != nil {
+				t.Fatal(err)
+			}
+			if len(w.records) != len(tt.parts) {
+				t.Fatalf("writeLine() wrote %d records, want %d", len(w.records), len(tt.parts))
+			}
+			for i, record := range w.records {
+				if len(record) > maxEmitRecord || !utf8.ValidString(record) {
+					t.Errorf("record %d of %d bytes, valid UTF-8 %v", i, len(record), utf8.ValidString(record))
+				}
+				if want := header + tt.parts[i] + "\n"; record != want {
+					t.Errorf("record %d = %q, want %q", i, record, want)
+				}
+			}
+		})
+	}
+
+	var w recordWriter
+	if err := writeLine(&w, header, strings.Repeat("a", size)); err != nil || len(w.records[0]) != 976 {
+		t.Errorf("writeLine() of a full record wrote %d bytes, %v, want 976", len(w.records[0]), err)
+	}
+}
+
+func TestKmsgWriter(t *testing.T) {
+	path := emitPath(t)
+	w, err := NewKmsgWriter(LevelNotice, "init: ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A partial line is kept until its newline.
+	for _, p := range []string{"mounting ", "/sysroot"} {
+		if n, err := w.Write([]byte(p)); n != len(p) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", p, n, err)
+		}
+	}
+	if records := emitted(t, path); len(records) != 0 {
+		t.Fatalf("partial line written: %q", records)
+	}
+
+	long := strings.Repeat("x", maxEmitRecord)
+	if _, err := w.Write([]byte("\nswitching root\n" + long + "\ntrailing")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// <13> is LevelNotice of FacilityUser.
+	header := "<13>init: "
+	size := maxEmitRecord - len(header) - 1
+	want := []string{
+		header + "mounting /sysroot\n",
+		header + "switching root\n",
+		header + long[:size] + "\n",
+		header + long[size:] + "\n",
+		header + "trailing\n",
+	}
+	if records := emitted(t, path); !slices.Equal(records, want) {
+		t.Errorf("records =\n%q\nwant\n%q", records, want)
+	}
+}
+
+func TestKmsgWriterInvalid(t *testing.T) {
+	emitPath(t)
+	if _, err := NewKmsgWriter(LevelDebug+1, ""); err == nil {
+		t.Error("NewKmsgWriter() of an invalid level succeeded")
+	}
+	if _, err := NewKmsgWriter(LevelInfo, strings.Repeat("p", maxEmitRecord)); err == nil {
+		t.Error("NewKmsgWriter() of a too long prefix succeeded")
+	}
+
+	DefaultPath = filepath.Join(t.TempDir(), "missing", "kmsg")
+	if _, err := NewKmsgWriter(LevelInfo, ""); !os.IsNotExist(err) {
+		t.Errorf("NewKmsgWriter() of a missing path = %v, want not exist", err)
+	}
+}
+
+func TestEmit(t *testing.T) {
+	path := emitPath(t)
+	if err := Emit(LevelErr, FacilityDaemon, "first\nsecond\n"); err != nil {
+		t.Fatal(err)
+	}
+	if records, want := emitted(t, path), []string{"<27>first\n", "<27>second\n"}; !slices.Equal(records, want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+	if err := Emitf(LevelInfo, FacilityKern, "%s %d", "count", 3); err != nil {
+		t.Fatal(err)
+	}
+	if records, want := emitted(t, path), []string{"<6>count 3\n"}; !slices.Equal(records, want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+
+	if err := Emit(LevelDebug+1, FacilityUser, "text"); err == nil {
+		t.Error("Emit() of an invalid level succeeded")
+	}
+	if err := Emitf(Level(42), FacilityUser, "%s", "text"); err == nil {
+		t.Error("Emitf() of an invalid level succeeded")
+	}
+	if records := emitted(t, path); len(records) != 0 {
+		t.Errorf("invalid levels wrote %q", records)
+	}
+}
@@ -0,0 +1,124 @@
+package dmesg
+
+import (
+	"fmt"
+	"time"
+)
+
+// FloodEvent is the start or the end of a flood of messages, see FloodDetector.
+type FloodEvent struct {
+	Ended bool          // Whether the flood ended, else it started
+	Start time.Duration // Time since boot of the first message of the flood
+	End   time.Duration // Time since boot of the message noticing the end, if Ended
+	Count int           // Messages counted since the flood started, more than the threshold
+	Msg   Msg           // Message which started or ended the flood
+}
+
+// FloodOption configures which messages a FloodDetector counts.
+type FloodOption func(*FloodDetector)
+
+// WithFloodLevel counts only messages of level or more severe.
+func WithFloodLevel(level Level) FloodOption {
+	return func(d *FloodDetector) {
+		d.level = level
+	}
+}
+
+// WithFloodFingerprint counts only messages whose text has fingerprint, see Fingerprint, e.g. to
+// detect a flood of a single message of a driver.
+func WithFloodFingerprint(fingerprint string) FloodOption {
+	return func(d *FloodDetector) {
+		d.fingerprint = fingerprint
+	}
+}
+
+// FloodDetector detects floods of messages, more than a threshold of messages logged within a
+// sliding window of time, e.g. to alert on printk storms of a driver without shipping every
+// message. It's fed messages in the order they were logged, from a snapshot or Follow, and
+// measures time by their timestamps, so the end of a flood is noticed by the next message
+// observed. It isn't safe for concurrent use.
+type FloodDetector struct {
+	window      time.Duration
+	threshold   int
+	level       Level
+	fingerprint string
+
+	times    []time.Duration // Ring of the times of the last threshold+1 messages counted
+	next     int             // Index in times of the oldest, overwritten next
+	now      time.Duration   // Time of the newest message observed
+	flooding bool
+	event    FloodEvent // Start of the current flood
+}
+
+// NewFloodDetector returns a FloodDetector firing when more than threshold messages are logged
+// within window, counting the messages selected by opts, all by default. It returns an error
+// when window isn't positive or threshold is negative.
+func NewFloodDetector(window time.Duration, threshold int, opts ...FloodOption) (*FloodDetector, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("dmesg: invalid flood window %v", window)
+	}
+	if threshold < 0 {
+		return nil, fmt.Errorf("dmesg: invalid flood threshold %d", threshold)
+	}
+
+	d := &FloodDetector{window: window, threshold: threshold, level: LevelDebug}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d, nil
+}
+
+// Observe counts msg and returns an event when a flood starts or ends with it. A flood ends
+// when no more than the threshold of messages were counted within the window up to msg.
+// Messages not counted still advance the time, messages without timestamp, e.g. parsed from
+// 'dmesg -T' output, are ignored.
+func (d *FloodDetector) Observe(msg Msg) (FloodEvent, bool) {
+	if msg.TsUsec < 0 {
+		return FloodEvent{}, false
+	}
+	d.now = max(d.now, msg.SinceBoot())
+
+	counted := d.counts(&msg)
+	if counted {
+		if len(d.times) <= d.threshold {
+			d.times = append(d.times, d.now)
+		} else {
+			d.times[d.next] = d.now
+			d.next = (d.next + 1) % len(d.times)
+		}
+	}
+
+	// More than threshold messages are within the window when the oldest of the last
+	// threshold+1 is.
+	inWindow := len(d.times) > d.threshold && d.now-d.times[d.next] <= d.window
+	switch {
+	case !d.flooding && inWindow:
+		d.flooding = true
+		d.event = FloodEvent{Start: d.times[d.next], Count: len(d.times), Msg: msg}
+		return d.event, true
+	case d.flooding && !inWindow:
+		d.flooding = false
+		event := d.event
+		event.Ended, event.End, event.Msg = true, d.now, msg
+		return event, true
+	case d.flooding && counted:
+		d.event.Count++
+	}
+
+	return FloodEvent{}, false
+}
+
+// Flooding reports whether a flood started and didn't end yet.
+func (d *FloodDetector) Flooding() bool {
+	return d.flooding
+}
+
+// counts reports whether msg is counted by the options of d.
+func (d *FloodDetector) counts(msg *Msg) bool {
+	if msg.Level > d.level {
+		return false
+	}
+
+	return d.fingerprint == "" || Fingerprint(msg.Text) == d.fingerprint
+}
@@ -0,0 +1,112 @@
+package dmesg
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+)
+
+// Follow reads all messages in kernel ring buffer and then waits for new ones like
+// 'dmesg --follow', delivering each message on the returned message channel. It doesn't
+// spin while waiting, the goroutine sleeps until /dev/kmsg becomes readable. With
+// WithSource(SourceProcKmsg) it reads /proc/kmsg instead, see SourceProcKmsg.
+//
+// An *OverrunError is sent on the error channel when records were overwritten before
+// being read, following continues after it. Any other error ends following. Following
+// also ends when ctx is done or WithMaxMessages messages were delivered. Both channels
+// are closed when following ends, callers must receive from both until then.
+func Follow(ctx context.Context, opts ...Option) (<-chan Msg, <-chan error) {
+	msgs := make(chan Msg)
+	errs := make(chan error)
+
+	o := newOptions(opts)
+	if o.source == SourceProcKmsg {
+		go func() {
+			defer close(errs)
+			defer close(msgs)
+
+			if err := followProcKmsg(ctx, o, msgs); err != nil && ctx.Err() == nil {
+				errs <- err
+			}
+		}()
+		return msgs, errs
+	}
+
+	r, err := open(o)
+	if err != nil {
+		go func() {
+			errs <- err
+			close(errs)
+			close(msgs)
+		}()
+		return msgs, errs
+	}
+
+	go func() {
+		defer close(errs)
+		defer close(msgs)
+		defer r.Close()
+
+		if err := r.follow(ctx, msgs, errs); err != nil && ctx.Err() == nil {
+			errs <- err
+		}
+	}()
+
+	return msgs, errs
+}
+
+// follow delivers messages to msgs until ctx is done or reading fails.
+func (r *Reader) follow(ctx context.Context, msgs chan<- Msg, errs chan<- error) error {
+	// Waiting for /dev/kmsg to become readable only ends with a deadline.
+	stop := context.AfterFunc(ctx, func() {
+		r.file.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	delivered := 0
+
+	var followErr error
+	err := r.readFd(func(fd uintptr) bool {
+		d := dmesg{}
+		overrun := &OverrunError{}
+		readErr := r.readRecords(ctx, int(fd), &d, false, overrun)
+
+		for _, msg := range d.msg {
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				followErr = ctx.Err()
+				return true
+			}
+
+			delivered++
+			if delivered == r.o.maxMessages {
+				return true
+			}
+		}
+
+		if overrun.Count > 0 {
+			select {
+			case errs <- overrun:
+			case <-ctx.Done():
+				followErr = ctx.Err()
+				return true
+			}
+		}
+
+		// Wait until /dev/kmsg becomes readable when there are no more records,
+		// following a file ends at its end.
+		if errors.Is(readErr, syscall.EAGAIN) {
+			return r.dec != nil
+		}
+
+		followErr = readErr
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	return followErr
+}
@@ -0,0 +1,322 @@
+package dmesg
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// String formats msg like the default output of 'dmesg': "[ 1234.567890] text", without
+// a trailing newline. Device info isn't included.
+func (m Msg) String() string {
+	return new(Formatter).Line(m)
+}
+
+// StringDecoded formats msg like 'dmesg -x', prefixing String with the facility and level
+// names: "kern  :err   : [ 1234.567890] text". The local facilities, which util-linux 2.38
+// prints no prefix for, are named too: "local0:warn  : ".
+func (m Msg) StringDecoded() string {
+	return (&Formatter{Decoded: true}).Line(m)
+}
+
+// HumanFormatter formats messages like 'dmesg -H'. A message is prefixed with its wall clock
+// time "[Jan02 15:04]" when the minute changed since the previous message, otherwise with
+// the time elapsed since the previous message "[  +0.123456]". It's stateful, the messages
+// must be formatted in the order they are printed.
+type HumanFormatter struct {
+	bootTime time.Time
+	last     int64
+	header   string // Header of the last minute printed
+}
+
+// NewHumanFormatter returns a HumanFormatter converting timestamps to wall clock time
+// relative to bootTime, in the location of bootTime.
+func NewHumanFormatter(bootTime time.Time) *HumanFormatter {
+	return &HumanFormatter{bootTime: bootTime}
+}
+
+// Format formats msg without a trailing newline. A message whose WallTime only is known, e.g.
+// parsed from 'dmesg -T' output, is prefixed with the minute of its WallTime.
+func (f *HumanFormatter) Format(msg Msg) string {
+	if msg.TsUsec < 0 && !msg.WallTime.IsZero() {
+		return f.wallPrefix(msg.WallTime) + " " + printable(msg.Text)
+	}
+
+	return f.prefix(msg.TsUsec) + " " + printable(msg.Text)
+}
+
+// wallPrefix returns the header of the minute of wall, there's no delta without a timestamp.
+func (f *HumanFormatter) wallPrefix(wall time.Time) string {
+	f.header = "[" + wall.Format("Jan02 15:04") + "]"
+
+	return f.header
+}
+
+func (f *HumanFormatter) prefix(ts int64) string {
+	header := "[" + f.bootTime.Add(time.Duration(ts)*time.Microsecond).Format("Jan02 15:04") + "]"
+	delta := sinceLast(ts, f.last)
+	f.last = ts
+	if header != f.header {
+		f.header = header
+		return header
+	}
+
+	return "[" + formatDelta(delta, 4, "+") + "]"
+}
+
+// sinceLast returns the time elapsed from the timestamp last of the previous message to ts.
+// Like 'dmesg', a previous timestamp of zero counts as none, the delta is zero then.
+func sinceLast(ts, last int64) int64 {
+	if last == 0 {
+		return 0
+	}
+
+	return ts - last
+}
+
+// formatDelta formats a delta in microsecond as "1.000042", padded to width digits of seconds
+// including the sign. Positive deltas are prefixed with plus if given.
+func formatDelta(usec int64, width int, plus string) string {
+	sign := plus
+	if usec < 0 {
+		sign = "-"
+		usec = -usec
+	}
+
+	return fmt.Sprintf("%*s.%06d", width, sign+strconv.FormatInt(usec/1e6, 10), usec%1e6)
+}
+
+// TimeFormat selects how Formatter prints the timestamp of a message, like the
+// --time-format option of 'dmesg'.
+type TimeFormat int
+
+const (
+	TimeRaw     TimeFormat = iota // Seconds since boot, "[ 1234.567890]"
+	TimeCtime                     // Wall clock time like 'dmesg -T', "[Wed Oct 14 03:03:22 2026]"
+	TimeISO8601                   // Wall clock time like 'dmesg --time-format=iso', "2026-10-14T03:03:22,123456+00:00"
+	TimeReltime                   // Wall clock minute and delta like 'dmesg -H', see HumanFormatter
+	TimeNotime                    // No timestamp
+)
+
+// Formatter formats messages like 'dmesg' with the timestamp printed in TimeFormat. Wall clock
+// time is computed from BootTime, in the location of BootTime.
+//
+// Like 'dmesg', the timestamps of the kernel don't include the time the system was suspended,
+// so the wall clock time of messages printed after a suspend is earlier than the real one.
+//
+// With Delta set, the time elapsed since the previous message is printed after the timestamp
+// like 'dmesg -d': "[ 1234.567890 <    0.000042>]". The delta of the first message is zero,
+// as is the delta of a message after one at timestamp zero like 'dmesg' does, and a message
+// older than the previous one has a negative delta. Delta is ignored with
+// TimeReltime which already prints deltas.
+//
+// A message whose WallTime only is known, e.g. parsed from 'dmesg -T' output by ParseText,
+// has no timestamp since boot: its WallTime is printed like TimeCtime instead of a timestamp,
+// or like TimeISO8601 or TimeReltime with those, without delta.
+//
+// A Formatter is stateful with TimeReltime or Delta, the messages must be formatted in the
+// order they are printed.
+//
+// With Color set, the text of a message is colored with the escape sequences of the palette,
+// see Palette. With Decoded set, lines are prefixed with the facility and level names like
+// 'dmesg -x': "kern  :err   : ".
+//
+// A Formatter returned by NewTemplateFormatter renders messages with its template instead,
+// TimeFormat, Delta, Color and Decoded are ignored.
+type Formatter struct {
+	TimeFormat TimeFormat
+	BootTime   time.Time
+	Delta      bool
+	Color      *Palette
+	Decoded    bool
+
+	tmpl *template.Template
+	rel  HumanFormatter
+	last int64
+}
+
+// NewTemplateFormatter returns a Formatter rendering each message with the text/template tmpl,
+// executed with the Msg. Besides the fields of Msg, these functions are available:
+//
+//	levelName    name of a level, e.g. "err"
+//	facilityName name of a facility, e.g. "kern"
+//	walltime     wall clock time of a timestamp in microsecond, relative to BootTime
+//	sinceBoot    time.Duration of a timestamp in microsecond
+//
+// For example "{{.Seq}} {{levelName .Level}} {{walltime .TsUsec}} {{.Text}}".
+// A trailing newline of the output is dropped, each message is printed on its own line.
+//
+// tmpl is executed once with a zero Msg, so a template using fields or functions that don't
+// exist fails here rather than in the first Format.
+func NewTemplateFormatter(tmpl string) (*Formatter, error) {
+	f := &Formatter{}
+	t, err := template.New("dmesg").Funcs(template.FuncMap{
+		"levelName":    Level.String,
+		"facilityName": Facility.String,
+		"walltime": func(ts int64) time.Time {
+			return f.BootTime.Add(time.Duration(ts) * time.Microsecond)
+		},
+		"sinceBoot": func(ts int64) time.Duration {
+			return time.Duration(ts) * time.Microsecond
+		},
+	}).Parse(tmpl)
+	if err == nil {
+		err = t.Execute(io.Discard, Msg{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dmesg: invalid template: %w", err)
+	}
+	f.tmpl = t
+
+	return f, nil
+}
+
+// Line formats msg without a trailing newline. If the template of the Formatter fails, the
+// output rendered until the error is returned, use Format to get the error.
+func (f *Formatter) Line(msg Msg) string {
+	line, _ := f.line(msg)
+	return line
+}
+
+// Format writes msgs to w, one line for each.
+func (f *Formatter) Format(w io.Writer, msgs []Msg) error {
+	for _, msg := range msgs {
+		line, err := f.line(msg)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FormatDeduped writes msgs collapsed by Dedup to w like Format, each repeated message
+// followed by its DedupedMsg.Repeated line, "message repeated N times: [ text ]".
+func (f *Formatter) FormatDeduped(w io.Writer, msgs []DedupedMsg) error {
+	for _, msg := range msgs {
+		lines := []Msg{msg.Msg}
+		if repeated, ok := msg.Repeated(); ok {
+			lines = append(lines, repeated)
+		}
+		if err := f.Format(w, lines); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *Formatter) line(msg Msg) (string, error) {
+	if f.tmpl != nil {
+		var b strings.Builder
+		err := f.tmpl.Execute(&b, msg)
+		return strings.TrimSuffix(b.String(), "\n"), err
+	}
+
+	var prefix string
+	if msg.TsUsec < 0 && !msg.WallTime.IsZero() {
+		prefix = f.wallStamp(msg.WallTime)
+	} else {
+		prefix = f.timestamp(msg.TsUsec)
+	}
+	msg.Text = printable(msg.Text)
+	text := f.Color.colorize(msg)
+	if prefix != "" {
+		text = prefix + " " + text
+	}
+	if f.Decoded {
+		text = fmt.Sprintf("%-6s:%-6s: %s", msg.Facility, msg.Level, text)
+	}
+
+	return text, nil
+}
+
+// printable escapes the characters of s which 'dmesg' doesn't print as is like the kernel does,
+// as \xNN for each byte. Tabs and printable UTF-8 characters are kept.
+func printable(s string) string {
+	i := 0
+	for i < len(s) && (s[i] >= ' ' && s[i] < utf8.RuneSelf && s[i] != 0x7f || s[i] == '\t') {
+		i++
+	}
+	if i == len(s) {
+		return s
+	}
+
+	buf := []byte(s[:i])
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == '\t' || (r != utf8.RuneError || size > 1) && unicode.IsPrint(r) {
+			buf = append(buf, s[i:i+size]...)
+		} else {
+			buf = appendEscaped(buf, s[i:i+size])
+		}
+		i += size
+	}
+
+	return string(buf)
+}
+
+// isoLayout is the time format of 'dmesg --time-format=iso'.
+const isoLayout = "2006-01-02T15:04:05,000000-07:00"
+
+// wallStamp returns the prefix of a message whose WallTime only is known. The state of Delta
+// isn't changed, the delta of the next message is from the message before.
+func (f *Formatter) wallStamp(wall time.Time) string {
+	switch f.TimeFormat {
+	case TimeISO8601:
+		return wall.Format(isoLayout)
+	case TimeReltime:
+		return f.rel.wallPrefix(wall)
+	case TimeNotime:
+		return ""
+	default:
+		return "[" + wall.Format(ctimeLayout) + "]"
+	}
+}
+
+func (f *Formatter) timestamp(ts int64) string {
+	wall := func() time.Time {
+		return f.BootTime.Add(time.Duration(ts) * time.Microsecond)
+	}
+
+	var stamp string
+	switch f.TimeFormat {
+	case TimeCtime:
+		stamp = wall().Format(ctimeLayout)
+	case TimeISO8601:
+		stamp = wall().Format(isoLayout)
+	case TimeReltime:
+		f.rel.bootTime = f.BootTime
+		return f.rel.prefix(ts)
+	case TimeNotime:
+	default:
+		stamp = fmt.Sprintf("%5d.%06d", ts/1e6, ts%1e6)
+	}
+
+	if f.Delta {
+		delta := sinceLast(ts, f.last)
+		f.last = ts
+
+		if stamp != "" {
+			stamp += " "
+		}
+		stamp += "<" + formatDelta(delta, 5, "") + ">"
+	}
+
+	switch {
+	case stamp == "":
+		return ""
+	case f.TimeFormat == TimeISO8601 && !f.Delta:
+		return stamp
+	default:
+		return "[" + stamp + "]"
+	}
+}
@@ -0,0 +1,328 @@
+package dmesg
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readGolden returns the messages parsed from testdata/format.txt and the content of
+// testdata/format.<name>, the output of util-linux 2.38.1:
+//
+//	TZ=Europe/Berlin LC_ALL=C.UTF-8 dmesg -F testdata/format.txt [options] > testdata/format.<name>
+func readGolden(t *testing.T, name string) ([]Msg, string) {
+	t.Helper()
+	text, err := os.ReadFile("testdata/format.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := ParseText(bytes.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/format." + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return msgs, string(want)
+}
+
+// goldenBootTime is the boot time of the system the golden files were captured on. The last
+// messages of format.txt are printed after the change from CEST to CET.
+func goldenBootTime(t *testing.T) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone database: %v", err)
+	}
+
+	return time.Date(2026, time.October, 14, 5, 3, 22, 0, loc)
+}
+
+func TestFormatterGolden(t *testing.T) {
+	boot := goldenBootTime(t)
+	tests := []struct {
+		name string // Golden file, with the options of dmesg
+		f    Formatter
+	}{
+		{"default", Formatter{}},
+		{"decoded", Formatter{Decoded: true}},                         // -x
+		{"ctime", Formatter{TimeFormat: TimeCtime, BootTime: boot}},   // -T
+		{"iso", Formatter{TimeFormat: TimeISO8601, BootTime: boot}},   // --time-format=iso
+		{"human", Formatter{TimeFormat: TimeReltime, BootTime: boot}}, // -H
+		{"notime", Formatter{TimeFormat: TimeNotime}},                 // --time-format=notime
+		{"delta", Formatter{Delta: true}},                             // -d
+		{"decoded-delta", Formatter{Decoded: true, Delta: true}},      // -x -d
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs, want := readGolden(t, tt.name)
+			var buf bytes.Buffer
+			if err := tt.f.Format(&buf, msgs); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != want {
+				t.Errorf("Format() =\n%s\nwant\n%s", buf.String(), want)
+			}
+		})
+	}
+}
+
+func TestMsgStringGolden(t *testing.T) {
+	msgs, want := readGolden(t, "default")
+	_, decoded := readGolden(t, "decoded")
+	lines := strings.SplitAfter(want, "\n")
+	decodedLines := strings.SplitAfter(decoded, "\n")
+
+	for i, msg := range msgs {
+		if got := msg.String() + "\n"; got != lines[i] {
+			t.Errorf("String() = %q, want %q", got, lines[i])
+		}
+		if got := msg.StringDecoded() + "\n"; got != decodedLines[i] {
+			t.Errorf("StringDecoded() = %q, want %q", got, decodedLines[i])
+		}
+	}
+}
+
+func TestMsgStringDeviceInfo(t *testing.T) {
+	msg, err := ParseRecord([]byte("3,1,1250000,c;ata1: COMRESET failed\n SUBSYSTEM=scsi\n DEVICE=+scsi:0:0:0:0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := msg.String(), "[    1.250000] ata1: COMRESET failed"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+// The local facilities have no name in util-linux 2.38, which prints no prefix for them with
+// -x, they are printed with their names instead.
+func TestMsgStringDecodedLocal(t *testing.T) {
+	msg := Msg{Facility: FacilityLocal0, Level: LevelWarn, TsUsec: 1_000_000, Text: "local"}
+	if got, want := msg.StringDecoded(), "local0:warn  : [    1.000000] local"; got != want {
+		t.Errorf("StringDecoded() = %q, want %q", got, want)
+	}
+}
+
+func TestHumanFormatterGolden(t *testing.T) {
+	msgs, want := readGolden(t, "human")
+	f := NewHumanFormatter(goldenBootTime(t))
+
+	var b strings.Builder
+	for _, msg := range msgs {
+		b.WriteString(f.Format(msg) + "\n")
+	}
+	if b.String() != want {
+		t.Errorf("Format() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestFormatterTimestampDST(t *testing.T) {
+	boot := goldenBootTime(t)
+	// The clocks go back from 03:00 CEST to 02:00 CET on Oct 25 2026, 02:30 happens twice.
+	change := time.Date(2026, time.October, 25, 1, 0, 0, 0, time.UTC)
+	ts := func(at time.Time) int64 {
+		return at.Sub(boot).Microseconds()
+	}
+	msgs := []Msg{
+		{TsUsec: ts(change.Add(-30 * time.Minute)), Text: "before"},
+		{TsUsec: ts(change.Add(30 * time.Minute)), Text: "after"},
+		{TsUsec: ts(change.Add(30*time.Minute + time.Second)), Text: "same minute"},
+	}
+
+	tests := []struct {
+		f    Formatter
+		want string
+	}{
+		{Formatter{TimeFormat: TimeCtime, BootTime: boot}, "[Sun Oct 25 02:30:00 2026] before\n" +
+			"[Sun Oct 25 02:30:00 2026] after\n[Sun Oct 25 02:30:01 2026] same minute\n"},
+		{Formatter{TimeFormat: TimeISO8601, BootTime: boot}, "2026-10-25T02:30:00,000000+02:00 before\n" +
+			"2026-10-25T02:30:00,000000+01:00 after\n2026-10-25T02:30:01,000000+01:00 same minute\n"},
+		// The wall clock minute after the change is the one printed before, a delta follows.
+		{Formatter{TimeFormat: TimeReltime, BootTime: boot}, "[Oct25 02:30] before\n" +
+			"[+3600.000000] after\n[  +1.000000] same minute\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := tt.f.Format(&buf, msgs); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("Format() with %v =\n%s\nwant\n%s", tt.f.TimeFormat, buf.String(), tt.want)
+		}
+	}
+}
+
+// A message parsed from 'dmesg -T' output has no timestamp since boot, its WallTime is printed
+// by every formatter instead.
+func TestFormattersWallTime(t *testing.T) {
+	msgs, err := ParseText(strings.NewReader("<6>[Wed Oct 14 03:03:22 2026] usb 1-1: new high-speed USB device number 2 using xhci_hcd\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := msgs[0]
+	wall := time.Date(2026, time.October, 14, 3, 3, 22, 0, time.Local)
+	if msg.TsUsec != -1 || !msg.WallTime.Equal(wall) {
+		t.Fatalf("ParseText() = %+v, want the wall time %v", msg, wall)
+	}
+	text := "usb 1-1: new high-speed USB device number 2 using xhci_hcd"
+	iso := wall.Format("2006-01-02T15:04:05,000000-07:00")
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"String", msg.String(), "[Wed Oct 14 03:03:22 2026] " + text},
+		{"StringDecoded", msg.StringDecoded(), "kern  :info  : [Wed Oct 14 03:03:22 2026] " + text},
+		{"ctime", (&Formatter{TimeFormat: TimeCtime}).Line(msg), "[Wed Oct 14 03:03:22 2026] " + text},
+		{"iso", (&Formatter{TimeFormat: TimeISO8601}).Line(msg), iso + " " + text},
+		{"delta", (&Formatter{Delta: true}).Line(msg), "[Wed Oct 14 03:03:22 2026] " + text},
+		{"reltime", (&Formatter{TimeFormat: TimeReltime}).Line(msg), "[Oct14 03:03] " + text},
+		{"notime", (&Formatter{TimeFormat: TimeNotime}).Line(msg), text},
+		{"human", NewHumanFormatter(time.Time{}).Format(msg), "[Oct14 03:03] " + text},
+		{"RFC 3164", string(RenderRFC3164(msg, "host")), "<6>Oct 14 03:03:22 host kernel: " + text},
+		{
+			"RFC 5424", string(RenderRFC5424(msg, "host", "kernel")),
+			"<6>1 " + wall.Format("2006-01-02T15:04:05.000000Z07:00") + ` host kernel - - [kmsg@32473 seq="0"] ` + text,
+		},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSONCompat(&buf, msgs); err != nil {
+		t.Fatal(err)
+	}
+	if want := `"time": "Wed Oct 14 03:03:22 2026",`; !strings.Contains(buf.String(), want) {
+		t.Errorf("WriteJSONCompat() =\n%s\nwant %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := WriteCSV(&buf, msgs, CSVOptions{DeviceInfoKeys: []string{}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "0," + wall.Format(time.RFC3339Nano) + ",6,0,," + text + "\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("WriteCSV() =\n%s\nwant the row %s", buf.String(), want)
+	}
+
+	gelf, err := EncodeGELF(msg, "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(gelf, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if ts, _ := fields["timestamp"].(float64); ts != float64(wall.Unix()) {
+		t.Errorf("GELF timestamp = %v, want %d", fields["timestamp"], wall.Unix())
+	}
+}
+
+// The wall clock times of WithSinceTime and WithUntilTime are compared with the WallTime of a
+// message without timestamp, WithSince and WithUntil drop it.
+func TestMatcherWallTime(t *testing.T) {
+	wall := time.Date(2026, time.October, 14, 3, 3, 22, 0, time.Local)
+	msg := Msg{TsUsec: -1, WallTime: wall, Text: "text"}
+
+	tests := []struct {
+		opt  Option
+		want bool
+	}{
+		{WithSinceTime(wall.Add(-time.Second)), true},
+		{WithSinceTime(wall.Add(time.Second)), false},
+		{WithUntilTime(wall), true},
+		{WithUntilTime(wall.Add(-time.Second)), false},
+		{WithSince(0), false},
+		{WithUntil(time.Hour), false},
+	}
+	for i, tt := range tests {
+		match, err := Matcher(tt.opt)
+		if err != nil {
+			t.Skipf("boot time: %v", err)
+		}
+		if got := match(msg); got != tt.want {
+			t.Errorf("option %d matched %v, want %v", i, got, tt.want)
+		}
+	}
+	if match, err := Matcher(WithUntil(time.Hour)); err == nil && match(Msg{TsUsec: -1}) {
+		t.Error("WithUntil() kept a message without time")
+	}
+}
+
+// exampleMsgs are the messages formatted by the examples of NewTemplateFormatter.
+var exampleMsgs = []Msg{
+	{Seq: 42, Level: LevelErr, TsUsec: 1_250_000, Text: "ata1.00: failed command: READ FPDMA QUEUED"},
+	{Seq: 43, Level: LevelInfo, Facility: FacilityDaemon, TsUsec: 62_000_001, Text: `usb 1-1: Product: "USB Mouse"`},
+}
+
+func ExampleNewTemplateFormatter() {
+	f, err := NewTemplateFormatter(`{{printf "[%12.6f]" (sinceBoot .TsUsec).Seconds}} {{.Text}}`)
+	if err != nil {
+		panic(err)
+	}
+	f.Format(os.Stdout, exampleMsgs)
+	// Output:
+	// [    1.250000] ata1.00: failed command: READ FPDMA QUEUED
+	// [   62.000001] usb 1-1: Product: "USB Mouse"
+}
+
+func ExampleNewTemplateFormatter_logfmt() {
+	f, err := NewTemplateFormatter(`seq={{.Seq}} facility={{facilityName .Facility}} level={{levelName .Level}} msg={{printf "%q" .Text}}`)
+	if err != nil {
+		panic(err)
+	}
+	f.Format(os.Stdout, exampleMsgs)
+	// Output:
+	// seq=42 facility=kern level=err msg="ata1.00: failed command: READ FPDMA QUEUED"
+	// seq=43 facility=daemon level=info msg="usb 1-1: Product: \"USB Mouse\""
+}
+
+func ExampleNewTemplateFormatter_json() {
+	f, err := NewTemplateFormatter(`{"seq":{{.Seq}},"level":"{{levelName .Level}}",` +
+		`"time":"{{(walltime .TsUsec).Format "2006-01-02T15:04:05.000000Z07:00"}}","text":{{printf "%q" .Text}}}`)
+	if err != nil {
+		panic(err)
+	}
+	f.BootTime = time.Date(2026, 10, 14, 3, 0, 0, 0, time.UTC)
+	f.Format(os.Stdout, exampleMsgs)
+	// Output:
+	// {"seq":42,"level":"err","time":"2026-10-14T03:00:01.250000Z","text":"ata1.00: failed command: READ FPDMA QUEUED"}
+	// {"seq":43,"level":"info","time":"2026-10-14T03:01:02.000001Z","text":"usb 1-1: Product: \"USB Mouse\""}
+}
+
+// Templates which can't be executed with a Msg fail in NewTemplateFormatter, not in Format.
+func TestNewTemplateFormatterInvalid(t *testing.T) {
+	for _, tmpl := range []string{
+		"{{.Text",
+		"{{end}}",
+		"{{nosuch .Level}}",
+		"{{.NoSuchField}}",
+		"{{.Text.Foo}}",
+		"{{levelName .Text}}",
+		`{{template "missing"}}`,
+	} {
+		if f, err := NewTemplateFormatter(tmpl); err == nil || f != nil || !strings.HasPrefix(err.Error(), "dmesg: invalid template: ") {
+			t.Errorf("NewTemplateFormatter(%q) = %v, %v, want an invalid template error", tmpl, f, err)
+		}
+	}
+
+	// Templates depending on the values of a message are fine, their errors are the ones of
+	// Format.
+	f, err := NewTemplateFormatter(`{{index .DeviceInfo "DRIVER"}} {{if .Text}}{{slice .Text 0 3}}{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	if err := f.Format(&buf, []Msg{{Text: "usb 1-1", DeviceInfo: map[string]string{"DRIVER": "usb"}}}); err != nil || buf.String() != "usb usb\n" {
+		t.Errorf("Format() = %q, %v", buf.String(), err)
+	}
+}
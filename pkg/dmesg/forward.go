@@ -0,0 +1,252 @@
+package dmesg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ForwarderOption configures a Forwarder.
+type ForwarderOption func(*forwarderOptions)
+
+type forwarderOptions struct {
+	hostname   string
+	appName    string
+	rfc3164    bool
+	timeout    time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// WithHostname sets the HOSTNAME of the syslog messages, the host name of the system by default.
+func WithHostname(hostname string) ForwarderOption {
+	return func(o *forwarderOptions) {
+		o.hostname = hostname
+	}
+}
+
+// WithAppName sets the APP-NAME of RFC 5424 syslog messages, "kernel" by default.
+func WithAppName(appName string) ForwarderOption {
+	return func(o *forwarderOptions) {
+		o.appName = appName
+	}
+}
+
+// WithRFC3164 sends BSD syslog messages of RFC 3164 instead of RFC 5424 ones.
+func WithRFC3164() ForwarderOption {
+	return func(o *forwarderOptions) {
+		o.rfc3164 = true
+	}
+}
+
+// WithTimeout sets the timeout to connect and to send a message, 10 seconds by default.
+func WithTimeout(d time.Duration) ForwarderOption {
+	return func(o *forwarderOptions) {
+		o.timeout = d
+	}
+}
+
+// WithBackoff sets how long to wait before connecting again after a connection to a stream
+// endpoint failed, starting from initial and doubling up to limit, 1 second to 1 minute by
+// default.
+func WithBackoff(initial, limit time.Duration) ForwarderOption {
+	return func(o *forwarderOptions) {
+		o.minBackoff, o.maxBackoff = initial, limit
+	}
+}
+
+// Forwarder ships messages to a remote syslog endpoint. Messages are rendered with
+// RenderRFC5424, or RenderRFC3164 with WithRFC3164. Over a datagram network like "udp" each
+// message is a datagram, over a stream network like "tcp" messages are framed by octet
+// counting of RFC 6587.
+//
+// When a stream connection fails, it's connected again and the message is sent again once.
+// If that fails too, the message is dropped and messages are dropped without trying to connect
+// until the backoff elapsed. As TCP only reports a connection closed by the peer on the write
+// after, the first message written after that is lost while counted as sent.
+//
+// A Forwarder is safe for concurrent use.
+type Forwarder struct {
+	network string
+	addr    string
+	stream  bool
+	o       forwarderOptions
+
+	mu      sync.Mutex
+	conn    net.Conn
+	backoff time.Duration
+	retryAt time.Time
+
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// NewForwarder returns a Forwarder connected to addr on network, e.g. "udp" or "tcp" like
+// net.Dial. It fails when the HOSTNAME of the messages isn't known.
+func NewForwarder(network, addr string, opts ...ForwarderOption) (*Forwarder, error) {
+	o := forwarderOptions{
+		appName:    "kernel",
+		timeout:    10 * time.Second,
+		minBackoff: time.Second,
+		maxBackoff: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.hostname == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("dmesg: forwarder hostname: %w", err)
+		}
+		o.hostname = hostname
+	}
+	if o.hostname == "" {
+		return nil, errors.New("dmesg: forwarder hostname is empty")
+	}
+
+	f := &Forwarder{
+		network: network,
+		addr:    addr,
+		stream:  !strings.HasPrefix(network, "udp") && network != "unixgram",
+		o:       o,
+	}
+	if err := f.dial(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Send sends msgs in order. Messages failing to be sent are dropped, the first error is
+// returned after all messages were tried.
+func (f *Forwarder) Send(msgs []Msg) error {
+	var firstErr error
+	for _, msg := range msgs {
+		if err := f.WriteMsg(msg); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// WriteMsg sends msg, it makes a Forwarder a Sink. The error of a message dropped is returned.
+func (f *Forwarder) WriteMsg(msg Msg) error {
+	var data []byte
+	if f.o.rfc3164 {
+		data = RenderRFC3164(msg, f.o.hostname)
+	} else {
+		data = RenderRFC5424(msg, f.o.hostname, f.o.appName)
+	}
+	if f.stream {
+		data = append(strconv.AppendInt(nil, int64(len(data)), 10), append([]byte{' '}, data...)...)
+	}
+
+	if err := f.send(data); err != nil {
+		f.dropped.Add(1)
+		return err
+	}
+	f.sent.Add(1)
+
+	return nil
+}
+
+// Run follows messages in kernel ring buffer with opts like Follow and forwards them until
+// ctx is done or following fails. The messages have their BootTime, so the syslog messages have
+// the times the messages were logged. Messages failing to be sent are dropped without ending Run.
+func (f *Forwarder) Run(ctx context.Context, opts ...Option) error {
+	return FollowTo(ctx, forwardSink{f}, append(opts, WithBootTime())...)
+}
+
+// Sent returns the number of messages sent.
+func (f *Forwarder) Sent() uint64 {
+	return f.sent.Load()
+}
+
+// Dropped returns the number of messages dropped because they failed to be sent.
+func (f *Forwarder) Dropped() uint64 {
+	return f.dropped.Load()
+}
+
+// Close closes the connection.
+func (f *Forwarder) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.conn == nil {
+		return nil
+	}
+	err := f.conn.Close()
+	f.conn = nil
+
+	return err
+}
+
+func (f *Forwarder) send(data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		if f.conn == nil {
+			if time.Now().Before(f.retryAt) {
+				return fmt.Errorf("dmesg: forward to %s: not connected", f.addr)
+			}
+			if err := f.dial(); err != nil {
+				f.retryLater()
+				return err
+			}
+			f.backoff = 0
+		}
+
+		f.conn.SetWriteDeadline(time.Now().Add(f.o.timeout))
+		_, err := f.conn.Write(data)
+		if err == nil {
+			return nil
+		}
+		if !f.stream {
+			return fmt.Errorf("dmesg: forward to %s: %w", f.addr, err)
+		}
+
+		// The message may have been partially written, only a new connection can recover.
+		f.conn.Close()
+		f.conn = nil
+		if attempt > 0 {
+			f.retryLater()
+			return fmt.Errorf("dmesg: forward to %s: %w", f.addr, err)
+		}
+	}
+}
+
+// retryLater doubles the backoff after a connection failed, messages are dropped without
+// connecting until it elapsed.
+func (f *Forwarder) retryLater() {
+	f.backoff = min(max(2*f.backoff, f.o.minBackoff), f.o.maxBackoff)
+	f.retryAt = time.Now().Add(f.backoff)
+}
+
+func (f *Forwarder) dial() error {
+	conn, err := net.DialTimeout(f.network, f.addr, f.o.timeout)
+	if err != nil {
+		return fmt.Errorf("dmesg: forward to %s: %w", f.addr, err)
+	}
+	f.conn = conn
+
+	return nil
+}
+
+// forwardSink forwards messages without ending following when a message is dropped.
+type forwardSink struct {
+	f *Forwarder
+}
+
+func (s forwardSink) WriteMsg(msg Msg) error {
+	s.f.WriteMsg(msg)
+	return nil
+}
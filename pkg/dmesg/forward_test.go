@@ -0,0 +1,142 @@
+package dmesg
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForwarderStream(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	f, err := NewForwarder("tcp", ln.Addr().String(), WithHostname("host"), WithRFC3164())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	msg := Msg{Level: LevelErr, Text: "disk failed"}
+	if err := f.Send([]Msg{msg}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := string(RenderRFC3164(msg, "host"))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	size, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != strconv.Itoa(len(want))+" " {
+		t.Errorf("frame size = %q, want %d", size, len(want))
+	}
+	got := make([]byte, len(want))
+	if _, err := r.Read(got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("frame = %q, want %q", got, want)
+	}
+	if f.Sent() != 1 || f.Dropped() != 0 {
+		t.Errorf("Sent() = %d, Dropped() = %d, want 1, 0", f.Sent(), f.Dropped())
+	}
+}
+
+func TestForwarderRetryWriteFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "syslog.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// A zero timeout sets a write deadline which already passed, so every write fails while
+	// connecting succeeds.
+	f, err := NewForwarder("unix", path, WithTimeout(0), WithBackoff(time.Hour, 2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	err = f.WriteMsg(Msg{Text: "first"})
+	if err == nil {
+		t.Fatal("WriteMsg() succeeded, want a write error")
+	}
+	if f.conn != nil {
+		t.Error("connection kept after the retry failed")
+	}
+	if f.backoff != time.Hour || f.retryAt.Before(time.Now().Add(time.Hour-time.Minute)) {
+		t.Errorf("backoff = %v, retry at %v, want 1h", f.backoff, f.retryAt)
+	}
+
+	err = f.WriteMsg(Msg{Text: "second"})
+	if err == nil || !strings.Contains(err.Error(), "not connected") {
+		t.Errorf("WriteMsg() during backoff = %v, want not connected", err)
+	}
+	if f.Dropped() != 2 || f.Sent() != 0 {
+		t.Errorf("Sent() = %d, Dropped() = %d, want 0, 2", f.Sent(), f.Dropped())
+	}
+}
+
+// Run follows the messages with their BootTime, so the syslog messages have the times the messages
+// were logged rather than the time they were sent.
+func TestForwarderRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "syslog.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	f, err := NewForwarder("unixgram", path, WithHostname("host"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fixture := WithPath("testdata/kmsg-6.1")
+	msgs, err := Dmesg(fixture, WithBootTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Following a file ends at its end. The queue of a datagram socket is short, the messages
+	// are read while they are sent.
+	done := make(chan error)
+	go func() {
+		done <- f.Run(context.Background(), fixture)
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	for _, msg := range msgs {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := RenderRFC5424(msg, "host", "kernel"); string(buf[:n]) != string(want) {
+			t.Errorf("message = %q, want %q", buf[:n], want)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if f.Sent() != uint64(len(msgs)) {
+		t.Errorf("Sent() = %d, want %d", f.Sent(), len(msgs))
+	}
+}
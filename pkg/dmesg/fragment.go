@@ -0,0 +1,44 @@
+package dmesg
+
+import "slices"
+
+// MergeFragments joins the parts of messages continued over several records, e.g. by drivers
+// printing a line in pieces, into single messages. A record with FlagFragment starts a message
+// and each following FlagContinuation record of the same caller is appended to its Text, with
+// its sequence number added to Fragments. A merged message keeps Seq, TsUsec and the device info
+// of its first record and gets FlagNone.
+//
+// Records of other CPUs or tasks may be interleaved with the parts. With Caller known, see
+// CONFIG_PRINTK_CALLER, the parts are matched by caller, and another complete record of the
+// caller ends its message. Without it the kernel only flags a record as continuation if it
+// directly follows the previous part, so parts are never mixed up. Continuations whose first
+// part is missing, e.g. overwritten, and records of other flags are kept as they are. Newer
+// kernels join the parts in the ring buffer themselves and emit no continuations.
+//
+// msgs must be in the order they were logged. The merged messages are returned in a new slice,
+// msgs is not modified.
+func MergeFragments(msgs []Msg) []Msg {
+	merged := make([]Msg, 0, len(msgs))
+	open := make(map[string]int) // Index in merged of the open message of each caller
+
+	for _, msg := range msgs {
+		i, ok := open[msg.Caller]
+		switch {
+		case msg.Flag == FlagContinuation && ok:
+			merged[i].Text += msg.Text
+			merged[i].Fragments = append(merged[i].Fragments, msg.Seq)
+			merged[i].Flag = FlagNone
+			continue
+		case msg.Flag == FlagFragment:
+			open[msg.Caller] = len(merged)
+			msg.Fragments = slices.Clip(msg.Fragments)
+		case msg.Flag != FlagContinuation:
+			// A new message of the caller ends its previous one.
+			delete(open, msg.Caller)
+		}
+
+		merged = append(merged, msg)
+	}
+
+	return merged
+}
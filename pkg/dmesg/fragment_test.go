@@ -0,0 +1,168 @@
+package dmesg
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// fragment is the part of a merged Msg checked by the tests.
+type fragment struct {
+	seq       uint64
+	flag      Flag
+	text      string
+	fragments []uint64
+}
+
+func fragments(msgs []Msg) []fragment {
+	frags := make([]fragment, 0, len(msgs))
+	for _, msg := range msgs {
+		frags = append(frags, fragment{msg.Seq, msg.Flag, msg.Text, msg.Fragments})
+	}
+
+	return frags
+}
+
+func TestMergeFragmentsFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []fragment
+	}{
+		// Linux 3.10 logs no caller, the parts of a message directly follow each other. The
+		// first record is a continuation whose first part was overwritten, and the last message
+		// of e1000e is never continued.
+		{"fragment-3.10", []fragment{
+			{147, FlagContinuation, " #7", nil},
+			{148, FlagNone, "smpboot: CPU0: Intel(R) Xeon(R) CPU E5-2680 v2 @ 2.80GHz (fam: 06, model: 3e, stepping: 04)", nil},
+			{149, FlagNone, "smpboot: Booting Node   0, Processors  #1 #2 #3 OK", []uint64{150, 151, 152, 153}},
+			{154, FlagNone, "Brought up 4 CPUs", nil},
+			{155, FlagNone, "ACPI: (supports S0 S3 S4 S5)", []uint64{156, 157}},
+			{158, FlagNone, "ACPI: Using IOAPIC for interrupt routing", nil},
+			{159, FlagFragment, "e1000e 0000:00:19.0 eth0: (PCI Express:2.5GT/s:Width x1) 00:1b:21:aa:bb:cc", nil},
+			{160, FlagNone, "e1000e 0000:00:19.0 eth0: Intel(R) PRO/1000 Network Connection", nil},
+		}},
+		// With CONFIG_PRINTK_CALLER the parts of two callers are interleaved. A complete record
+		// of C2 ends its message, so its following continuation is kept as it is, like the
+		// continuation of T120 whose first part is missing.
+		{"fragment-caller", []fragment{
+			{900, FlagContinuation, " stale", nil},
+			{901, FlagNone, "ACPI: (supports S0 S3 S4 S5)", []uint64{903, 905}},
+			{902, FlagNone, "smpboot: Booting Node   1, Processors  #8 #9", []uint64{904, 906}},
+			{907, FlagNone, "smpboot: Booting Node   1 done", nil},
+			{908, FlagContinuation, " #10", nil},
+			{909, FlagNone, "ACPI: Using IOAPIC for interrupt routing", nil},
+			{910, FlagFragment, "usb 1-1: Product: ", nil},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			msgs := readRecords(t, tt.fixture)
+			orig := slices.Clone(msgs)
+
+			merged := MergeFragments(msgs)
+			if got := fragments(merged); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeFragments() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(msgs, orig) {
+				t.Error("MergeFragments() modified msgs")
+			}
+
+			// A merged message keeps the timestamp and device info of its first record.
+			for _, m := range merged {
+				i := slices.IndexFunc(msgs, func(msg Msg) bool { return msg.Seq == m.Seq })
+				first := msgs[i]
+				if m.TsUsec != first.TsUsec || m.Caller != first.Caller || m.Subsystem != first.Subsystem ||
+					m.Device != first.Device || !reflect.DeepEqual(m.DeviceInfoList, first.DeviceInfoList) {
+					t.Errorf("merged message %d = %+v, want the fields of %+v", m.Seq, m, first)
+				}
+			}
+
+			got, err := Dmesg(WithPath("testdata/"+tt.fixture), WithMergeFragments())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, merged) {
+				t.Errorf("Dmesg(WithMergeFragments()) = %v, want %v", got, merged)
+			}
+		})
+	}
+}
+
+func TestMergeFragments(t *testing.T) {
+	rec := func(seq uint64, caller string, flag Flag, text string) Msg {
+		return Msg{Seq: seq, TsUsec: int64(seq) * 10, Caller: caller, Flag: flag, Text: text}
+	}
+
+	tests := []struct {
+		name string
+		msgs []Msg
+		want []fragment
+	}{
+		{"empty", nil, []fragment{}},
+		{
+			"orphan continuation",
+			[]Msg{rec(1, "", FlagContinuation, " b"), rec(2, "", FlagNone, "c")},
+			[]fragment{{1, FlagContinuation, " b", nil}, {2, FlagNone, "c", nil}},
+		},
+		{
+			"continuation after complete message",
+			[]Msg{rec(1, "", FlagFragment, "a"), rec(2, "", FlagNone, "b"), rec(3, "", FlagContinuation, " c")},
+			[]fragment{{1, FlagFragment, "a", nil}, {2, FlagNone, "b", nil}, {3, FlagContinuation, " c", nil}},
+		},
+		{
+			"new record of the caller ends the fragment",
+			[]Msg{
+				rec(1, "T1", FlagFragment, "a"), rec(2, "T2", FlagNone, "x"), rec(3, "T1", FlagContinuation, " b"),
+				rec(4, "T1", FlagNone, "c"), rec(5, "T1", FlagContinuation, " d"),
+			},
+			[]fragment{{1, FlagNone, "a b", []uint64{3}}, {2, FlagNone, "x", nil}, {4, FlagNone, "c", nil}, {5, FlagContinuation, " d", nil}},
+		},
+		{
+			"new fragment of the caller ends the fragment",
+			[]Msg{
+				rec(1, "T1", FlagFragment, "a"), rec(2, "T1", FlagContinuation, " b"),
+				rec(3, "T1", FlagFragment, "c"), rec(4, "T1", FlagContinuation, " d"), rec(5, "T1", FlagContinuation, " e"),
+			},
+			[]fragment{{1, FlagNone, "a b", []uint64{2}}, {3, FlagNone, "c d e", []uint64{4, 5}}},
+		},
+		{
+			"interleaved callers",
+			[]Msg{
+				rec(1, "T1", FlagFragment, "a"), rec(2, "C0", FlagFragment, "x"), rec(3, "C0", FlagContinuation, " y"),
+				rec(4, "T1", FlagContinuation, " b"), rec(5, "C0", FlagContinuation, " z"),
+			},
+			[]fragment{{1, FlagNone, "a b", []uint64{4}}, {2, FlagNone, "x y z", []uint64{3, 5}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fragments(MergeFragments(tt.msgs)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeFragments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Merging a message merged before appends to a copy of its Fragments, the Fragments of msgs
+// are never written to.
+func TestMergeFragmentsRemerge(t *testing.T) {
+	frags := make([]uint64, 1, 4)
+	frags[0] = 2
+	msgs := []Msg{
+		{Seq: 1, Flag: FlagFragment, Text: "a b", Fragments: frags},
+		{Seq: 3, Flag: FlagContinuation, Text: " c"},
+	}
+
+	merged := MergeFragments(msgs)
+	if want := []fragment{{1, FlagNone, "a b c", []uint64{2, 3}}}; !reflect.DeepEqual(fragments(merged), want) {
+		t.Errorf("MergeFragments() = %v, want %v", fragments(merged), want)
+	}
+	if got := frags[:2]; got[1] != 0 {
+		t.Errorf("Fragments of msgs = %v, want %v", got, []uint64{2, 0})
+	}
+	if got := MergeFragments(merged); !reflect.DeepEqual(got, merged) {
+		t.Errorf("MergeFragments() of merged messages = %v, want %v", got, merged)
+	}
+}
@@ -0,0 +1,105 @@
+package dmesg
+
+import (
+	"regexp"
+	"strings"
+)
+
+// FSErrorEvent is an error a filesystem detected, e.g. corrupted metadata, or the filesystem
+// remounted read-only or shut down because of one, see ParseFSError.
+type FSErrorEvent struct {
+	Device      string // Device of the filesystem, e.g. "sda1", empty if not logged
+	Filesystem  string // "ext2", "ext3", "ext4", "jbd2", "xfs" or "btrfs"
+	Description string // Message after the device, e.g. "Corruption detected. Unmount and run xfs_repair"
+	RemountedRO bool   // Whether the filesystem was remounted read-only or, for XFS, shut down
+	Seq         uint64 // Sequence number of the message
+	TsUsec      int64  // Timestamp of the message
+}
+
+var (
+	// "EXT4-fs error (device sda1): ext4_lookup:1234: inode #2: comm ls: deleted inode referenced:
+	// 12", "EXT4-fs (sda1): Remounting filesystem read-only" and "EXT2-fs (sda1): error: ...", and
+	// "EXT4-fs error (device sda1) in ext4_reserve_inode_write:4915: Journal has aborted" of the
+	// errors of a function.
+	extErrorRe = regexp.MustCompile(`^(EXT[234])-fs(?: (error|critical|warning))? \((?:device )?([^)]+)\)(?:: | in )(.+)`)
+	// "Aborting journal on device sda1-8." of jbd2, with the inode of the journal after the device.
+	jbd2AbortRe = regexp.MustCompile(`^Aborting journal on device (\S+?)(?:-\d+)?\.?$`)
+	// "XFS (nvme0n1p2): Corruption detected. Unmount and run xfs_repair".
+	xfsErrorRe = regexp.MustCompile(`^XFS \(([^)]+)\): (.+)`)
+	// "BTRFS error (device sda1): parent transid verify failed on 1234 wanted 5 found 4", with
+	// ": state EA" after the device once the filesystem is in error since 5.x, "BTRFS: error
+	// (device sda1) in btrfs_commit_transaction:2345: errno=-5 IO failure" and "BTRFS info
+	// (device sda1: state EA): forced readonly".
+	btrfsErrorRe = regexp.MustCompile(`^BTRFS(?::? (error|critical|warning|info))? \(device ([^):\s]+)(?::? state \w+)?\)(?: in |:? )(.+)`)
+)
+
+// ParseFSError returns the FSErrorEvent of msg if it's an error of ext2, ext3, ext4, the jbd2
+// journal of ext4, XFS or btrfs, or a message about one of these filesystems remounted
+// read-only. It returns false for other messages, e.g. about a filesystem mounted.
+func ParseFSError(msg Msg) (FSErrorEvent, bool) {
+	event := FSErrorEvent{Seq: msg.Seq, TsUsec: msg.TsUsec}
+
+	switch {
+	case strings.HasPrefix(msg.Text, "EXT"):
+		m := extErrorRe.FindStringSubmatch(msg.Text)
+		if m == nil {
+			return FSErrorEvent{}, false
+		}
+		event.Filesystem, event.Device, event.Description = strings.ToLower(m[1]), m[3], m[4]
+		if strings.HasPrefix(m[4], "Remounting filesystem read-only") {
+			event.RemountedRO = true
+		} else if m[2] == "warning" || (m[2] == "" && !strings.HasPrefix(m[4], "error: ") &&
+			!strings.Contains(m[4], "I/O error")) {
+			return FSErrorEvent{}, false
+		}
+	case strings.HasPrefix(msg.Text, "Aborting journal"):
+		m := jbd2AbortRe.FindStringSubmatch(msg.Text)
+		if m == nil {
+			return FSErrorEvent{}, false
+		}
+		event.Filesystem, event.Device, event.Description = "jbd2", m[1], "Aborting journal"
+	case strings.HasPrefix(msg.Text, "XFS "):
+		m := xfsErrorRe.FindStringSubmatch(msg.Text)
+		if m == nil {
+			return FSErrorEvent{}, false
+		}
+		event.Filesystem, event.Device, event.Description = "xfs", m[1], m[2]
+		text := strings.ToLower(m[2])
+		if strings.Contains(text, "shutting down filesystem") || strings.Contains(text, "has been shut down") {
+			event.RemountedRO = true
+		} else if !strings.Contains(text, "corruption") && !strings.Contains(text, "i/o error") {
+			return FSErrorEvent{}, false
+		}
+	case strings.HasPrefix(msg.Text, "BTRFS"):
+		m := btrfsErrorRe.FindStringSubmatch(msg.Text)
+		if m == nil {
+			return FSErrorEvent{}, false
+		}
+		event.Filesystem, event.Device, event.Description = "btrfs", m[2], m[3]
+		if strings.HasPrefix(m[3], "forced readonly") {
+			event.RemountedRO = true
+		} else if m[1] != "error" && m[1] != "critical" && !strings.HasPrefix(m[3], "parent transid verify failed") &&
+			!strings.HasPrefix(m[3], "csum failed") {
+			return FSErrorEvent{}, false
+		}
+	case strings.HasPrefix(msg.Text, "parent transid verify failed"):
+		// btrfs before 3.x, without device.
+		event.Filesystem, event.Description = "btrfs", msg.Text
+	default:
+		return FSErrorEvent{}, false
+	}
+
+	return event, true
+}
+
+// FSErrorEvents returns the FSErrorEvents of msgs, see ParseFSError.
+func FSErrorEvents(msgs []Msg) []FSErrorEvent {
+	var events []FSErrorEvent
+	for _, msg := range msgs {
+		if event, ok := ParseFSError(msg); ok {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
@@ -0,0 +1,90 @@
+package dmesg
+
+import (
+	"testing"
+)
+
+// The fixtures have the filesystem errors logged by linux 3.10, 4.15 and 6.1, which adds the
+// state of a btrfs filesystem in error to its messages.
+func TestFSErrorEvents(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []FSErrorEvent
+	}{
+		{"fserror-3.10", []FSErrorEvent{
+			{Seq: 7710, Device: "sda1", Filesystem: "ext4", Description: "ext4_lookup:1437: inode #2: comm ls: deleted inode referenced: 12"},
+			{Seq: 7711, Device: "sda1", Filesystem: "jbd2", Description: "Aborting journal"},
+			{Seq: 7712, Device: "sda1", Filesystem: "ext4", Description: "ext4_reserve_inode_write:4915: Journal has aborted"},
+			{Seq: 7713, Device: "sda1", Filesystem: "ext4", Description: "Remounting filesystem read-only", RemountedRO: true},
+			{Seq: 7714, Device: "sdb1", Filesystem: "xfs", Description: "Corruption detected. Unmount and run xfs_repair"},
+			{Seq: 7716, Device: "sdb1", Filesystem: "xfs", Description: "Corruption of in-memory data detected.  Shutting down filesystem", RemountedRO: true},
+		}},
+		// The warnings and the messages of a filesystem mounted aren't errors.
+		{"fserror-4.15", []FSErrorEvent{
+			{Seq: 3307, Device: "sda2", Filesystem: "ext4", Description: "ext4_find_entry:1455: inode #1835009: comm systemd: reading directory lblock 0"},
+			{Seq: 3309, Device: "sdb", Filesystem: "btrfs", Description: "parent transid verify failed on 1103101952 wanted 171867 found 171865"},
+			{Seq: 3310, Device: "sdb", Filesystem: "btrfs", Description: "btrfs_run_delayed_refs:3089: errno=-5 IO failure"},
+			{Seq: 3311, Device: "sdb", Filesystem: "btrfs", Description: "forced readonly", RemountedRO: true},
+		}},
+		{"fserror-6.1", []FSErrorEvent{
+			{Seq: 14220, Device: "nvme0n1p2", Filesystem: "ext4", Description: "ext4_validate_block_bitmap:390: comm kworker/u16:2: bg 1: bad block bitmap checksum"},
+			{Seq: 14221, Device: "nvme0n1p2", Filesystem: "jbd2", Description: "Aborting journal"},
+			{Seq: 14222, Device: "nvme0n1p2", Filesystem: "ext4", Description: "Remounting filesystem read-only", RemountedRO: true},
+			{Seq: 14223, Device: "nvme0n1p2", Filesystem: "ext4", Description: "I/O error while writing superblock"},
+			{Seq: 14224, Device: "dm-1", Filesystem: "xfs", Description: "Metadata corruption detected at xfs_dinode_verify+0xa0/0x730 [xfs], inode 0x8012c dinode"},
+			{
+				Seq: 14226, Device: "dm-1", Filesystem: "xfs", RemountedRO: true,
+				Description: "Corruption of in-memory data (0x8) detected at xfs_trans_cancel+0x13a/0x160 [xfs] (fs/xfs/xfs_trans.c:1098).  Shutting down filesystem.",
+			},
+			{Seq: 14228, Device: "sda1", Filesystem: "btrfs", Description: "parent transid verify failed on logical 30736384 mirror 1 wanted 1313 found 1309"},
+			{Seq: 14229, Device: "sda1", Filesystem: "btrfs", Description: "btrfs_finish_ordered_io:3302: errno=-5 IO failure"},
+			{Seq: 14230, Device: "sda1", Filesystem: "btrfs", Description: "forced readonly", RemountedRO: true},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			msgs := readRecords(t, tt.fixture)
+			events := FSErrorEvents(msgs)
+			if len(events) != len(tt.want) {
+				t.Fatalf("FSErrorEvents() = %d events, want %d: %+v", len(events), len(tt.want), events)
+			}
+			for i, event := range events {
+				want := tt.want[i]
+				want.TsUsec = msgs[want.Seq-msgs[0].Seq].TsUsec
+				if event != want {
+					t.Errorf("event %d =\n%+v\nwant\n%+v", i, event, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFSErrorOther(t *testing.T) {
+	tests := []struct {
+		text string
+		want FSErrorEvent
+	}{
+		// btrfs before 3.x logs no device.
+		{"parent transid verify failed on 29360128 wanted 1486 found 1488", FSErrorEvent{Filesystem: "btrfs", Description: "parent transid verify failed on 29360128 wanted 1486 found 1488"}},
+		{"EXT2-fs (sdc1): error: ext2_check_page: bad entry in directory #2", FSErrorEvent{Device: "sdc1", Filesystem: "ext2", Description: "error: ext2_check_page: bad entry in directory #2"}},
+		{"XFS (sda1): Filesystem has been shut down due to log error (0x2).", FSErrorEvent{Device: "sda1", Filesystem: "xfs", Description: "Filesystem has been shut down due to log error (0x2).", RemountedRO: true}},
+		{"BTRFS warning (device sda1): csum failed root 5 ino 257 off 0 csum 0x98f94189 expected csum 0x8941f998 mirror 1", FSErrorEvent{Device: "sda1", Filesystem: "btrfs", Description: "csum failed root 5 ino 257 off 0 csum 0x98f94189 expected csum 0x8941f998 mirror 1"}},
+	}
+	for _, tt := range tests {
+		if event, ok := ParseFSError(Msg{Text: tt.text}); !ok || event != tt.want {
+			t.Errorf("ParseFSError(%q) = %+v, %v, want %+v", tt.text, event, ok, tt.want)
+		}
+	}
+
+	for _, text := range []string{
+		"EXT4-fs (sda1): mounted filesystem 5d1b4f0e-2b8c-4e4e-9d1a-0c3f2a7d8e61 with ordered data mode. Quota mode: none.",
+		"XFS (sda1): Mounting V5 Filesystem",
+		"BTRFS info (device sda1): enabling ssd optimizations",
+		"Aborting journal",
+	} {
+		if event, ok := ParseFSError(Msg{Text: text}); ok {
+			t.Errorf("ParseFSError(%q) = %+v, want false", text, event)
+		}
+	}
+}
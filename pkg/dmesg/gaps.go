@@ -0,0 +1,37 @@
+package dmesg
+
+import "fmt"
+
+// SeqRange is a range of sequence numbers from First to Last, both included.
+type SeqRange struct {
+	First uint64
+	Last  uint64
+}
+
+// Len returns the number of sequence numbers in r.
+func (r SeqRange) Len() uint64 {
+	return r.Last - r.First + 1
+}
+
+func (r SeqRange) String() string {
+	if r.First == r.Last {
+		return fmt.Sprint(r.First)
+	}
+
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// Gaps returns the ranges of sequence numbers missing between msgs, which must be in the order
+// they were read, e.g. records lost to overruns. Messages dropped by filters are gaps too. It
+// returns nil when there are no gaps.
+func Gaps(msgs []Msg) []SeqRange {
+	var gaps []SeqRange
+	for i := 1; i < len(msgs); i++ {
+		prev, seq := msgs[i-1].Seq, msgs[i].Seq
+		if seq > prev+1 {
+			gaps = append(gaps, SeqRange{First: prev + 1, Last: seq - 1})
+		}
+	}
+
+	return gaps
+}
@@ -0,0 +1,47 @@
+package dmesg
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGaps(t *testing.T) {
+	msgsOf := func(seqs ...uint64) []Msg {
+		msgs := make([]Msg, 0, len(seqs))
+		for _, seq := range seqs {
+			msgs = append(msgs, Msg{Seq: seq})
+		}
+		return msgs
+	}
+
+	tests := []struct {
+		name string
+		msgs []Msg
+		want []SeqRange
+	}{
+		{"empty", nil, nil},
+		{"single message", msgsOf(7), nil},
+		{"no gap", msgsOf(1, 2, 3), nil},
+		{"single gap", msgsOf(1, 2, 5, 6), []SeqRange{{3, 4}}},
+		{"one missing", msgsOf(1, 3), []SeqRange{{2, 2}}},
+		{"multiple gaps", msgsOf(1, 3, 4, 10, 100), []SeqRange{{2, 2}, {5, 9}, {11, 99}}},
+		{"repeated and older messages", msgsOf(5, 5, 3, 4), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Gaps(tt.msgs); !slices.Equal(got, tt.want) {
+				t.Errorf("Gaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeqRange(t *testing.T) {
+	if r := (SeqRange{First: 3, Last: 3}); r.Len() != 1 || r.String() != "3" {
+		t.Errorf("Len() = %d, String() = %q, want 1, 3", r.Len(), r.String())
+	}
+	if r := (SeqRange{First: 5, Last: 9}); r.Len() != 5 || r.String() != "5-9" {
+		t.Errorf("Len() = %d, String() = %q, want 5, 5-9", r.Len(), r.String())
+	}
+}
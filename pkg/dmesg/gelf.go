@@ -0,0 +1,63 @@
+package dmesg
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// EncodeGELF encodes msg as a GELF 1.1 message of Graylog from host. The level is the syslog
+// level of msg, the timestamp its wall clock time when BootTime is set, see WithBootTime, or
+// its WallTime when only it is known, otherwise it's left to the server. Seq, facility, caller
+// and the device info are additional fields, e.g. "_seq" and "_subsystem". Device info keys are
+// lower cased and characters not allowed in field names are replaced with '_'.
+//
+// GELF requires a host and a short message, an error is returned if host or the text of msg
+// is empty, e.g. for a truncated message.
+func EncodeGELF(msg Msg, host string) ([]byte, error) {
+	if host == "" {
+		return nil, errors.New("dmesg: GELF message without host")
+	}
+	if msg.Text == "" {
+		return nil, errors.New("dmesg: GELF message without short_message")
+	}
+
+	fields := map[string]any{
+		"version":       "1.1",
+		"host":          host,
+		"short_message": msg.Text,
+		"level":         msg.Level,
+		"_seq":          msg.Seq,
+		"_facility":     msg.Facility.String(),
+	}
+	if t, ok := msg.knownTime(); ok {
+		fields["timestamp"] = float64(t.UnixMicro()) / 1e6
+	}
+	if msg.Caller != "" {
+		fields["_caller"] = msg.Caller
+	}
+	for _, key := range msg.deviceInfoKeys() {
+		name := "_" + gelfFieldName(key)
+		if _, ok := fields[name]; ok || name == "_id" {
+			continue
+		}
+		fields[name] = msg.deviceInfo(key)
+	}
+
+	return json.Marshal(fields)
+}
+
+// gelfFieldName lower cases key and replaces characters GELF doesn't allow in field names,
+// which are letters, digits, '_', '.' and '-'.
+func gelfFieldName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
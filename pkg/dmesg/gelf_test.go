@@ -0,0 +1,89 @@
+package dmesg
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// decodeGELF decodes a GELF message keeping its numbers as json.Number.
+func decodeGELF(t *testing.T, data []byte) map[string]any {
+	t.Helper()
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var fields map[string]any
+	if err := dec.Decode(&fields); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+
+	return fields
+}
+
+func TestEncodeGELF(t *testing.T) {
+	boot := time.Date(2026, 10, 14, 3, 0, 0, 0, time.UTC)
+	msg := Msg{
+		Level: LevelErr, Facility: FacilityDaemon, Seq: 42, TsUsec: 1500001, BootTime: boot, Caller: "T123",
+		Text: "usb 1-1: device descriptor read/64, error -71", Subsystem: "usb", Device: "c189:1",
+		DeviceInfo: map[string]string{"ID": "x", "SEQ": "7", "Foo Bar": "baz", "FACILITY": "y"},
+	}
+	data, err := EncodeGELF(msg, "host.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The keys are lower cased, so "ID" would be the reserved "_id" and "SEQ" and "FACILITY"
+	// the fields of msg, they are skipped.
+	want := map[string]any{
+		"version":       "1.1",
+		"host":          "host.example.com",
+		"short_message": msg.Text,
+		"level":         json.Number("3"),
+		"timestamp":     json.Number("1791946801.500001"),
+		"_seq":          json.Number("42"),
+		"_facility":     "daemon",
+		"_caller":       "T123",
+		"_subsystem":    "usb",
+		"_device":       "c189:1",
+		"_foo_bar":      "baz",
+	}
+	if got := decodeGELF(t, data); !reflect.DeepEqual(got, want) {
+		t.Errorf("EncodeGELF() = %v, want %v", got, want)
+	}
+}
+
+func TestEncodeGELFTimestamp(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Msg
+		want any // nil when there's no timestamp
+	}{
+		{"boot time", Msg{TsUsec: 2000000, BootTime: time.Unix(1000, 0)}, json.Number("1002")},
+		{"wall time", Msg{TsUsec: -1, WallTime: time.Unix(1234, 250000000)}, json.Number("1234.25")},
+		{"unknown", Msg{TsUsec: 2000000}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.msg.Text = "text"
+			data, err := EncodeGELF(tt.msg, "host")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := decodeGELF(t, data)["timestamp"]; got != tt.want {
+				t.Errorf("timestamp = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeGELFInvalid(t *testing.T) {
+	if _, err := EncodeGELF(Msg{Text: "text"}, ""); err == nil {
+		t.Error("EncodeGELF() without host succeeded")
+	}
+	if _, err := EncodeGELF(Msg{Seq: 3, Truncated: true}, "host"); err == nil {
+		t.Error("EncodeGELF() without text succeeded")
+	}
+}
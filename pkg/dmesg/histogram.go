@@ -0,0 +1,71 @@
+package dmesg
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxHistogramBuckets is the most buckets Histogram returns, so a tiny bucket size doesn't
+// allocate a bucket for each microsecond of uptime.
+const maxHistogramBuckets = 1 << 20
+
+// Bucket is the number of messages logged in a span of time since boot, see Histogram.
+type Bucket struct {
+	Start  time.Duration       // Time since boot the bucket starts at, a multiple of its size
+	Count  int                 // Number of messages
+	Levels [LevelDebug + 1]int // Messages by level
+}
+
+// Histogram counts msgs in buckets of bucket size by the time since boot they were logged, e.g.
+// to plot the rate of messages and spot bursts. The buckets cover the first to the last message,
+// buckets without messages in between are returned too. Messages without timestamp, e.g.
+// parsed from 'dmesg -T' output, aren't counted, messages of levels above LevelDebug are only
+// counted in Count. It returns an error when bucket isn't positive or makes more than 1<<20
+// buckets.
+func Histogram(msgs []Msg, bucket time.Duration) ([]Bucket, error) {
+	if bucket <= 0 {
+		return nil, fmt.Errorf("dmesg: invalid bucket size %v", bucket)
+	}
+
+	var first, last time.Duration
+	hasTs := false
+	for _, msg := range msgs {
+		if msg.TsUsec < 0 {
+			continue
+		}
+		since := msg.SinceBoot()
+		if !hasTs || since < first {
+			first = since
+		}
+		if !hasTs || since > last {
+			last = since
+		}
+		hasTs = true
+	}
+	if !hasTs {
+		return nil, nil
+	}
+
+	first -= first % bucket
+	count := (last-first)/bucket + 1
+	if count > maxHistogramBuckets {
+		return nil, fmt.Errorf("dmesg: bucket size %v makes %d buckets, more than %d", bucket, count, maxHistogramBuckets)
+	}
+
+	buckets := make([]Bucket, count)
+	for i := range buckets {
+		buckets[i].Start = first + time.Duration(i)*bucket
+	}
+	for _, msg := range msgs {
+		if msg.TsUsec < 0 {
+			continue
+		}
+		b := &buckets[(msg.SinceBoot()-first)/bucket]
+		b.Count++
+		if msg.Level <= LevelDebug {
+			b.Levels[msg.Level]++
+		}
+	}
+
+	return buckets, nil
+}
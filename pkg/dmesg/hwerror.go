@@ -0,0 +1,304 @@
+package dmesg
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HardwareSeverity is the severity of a HardwareErrorEvent.
+type HardwareSeverity uint8
+
+const (
+	SeverityUnknown     HardwareSeverity = iota // Not logged, e.g. "mce: [Hardware Error]: Machine check events logged"
+	SeverityCorrected                           // Corrected by the hardware, e.g. an ECC error of a DIMM
+	SeverityUncorrected                         // Not corrected, the data is lost but the system may go on
+	SeverityFatal                               // Not corrected and fatal, e.g. processor context corrupt
+)
+
+var hardwareSeverityNames = [...]string{
+	SeverityUnknown:     "unknown",
+	SeverityCorrected:   "corrected",
+	SeverityUncorrected: "uncorrected",
+	SeverityFatal:       "fatal",
+}
+
+func (s HardwareSeverity) String() string {
+	if int(s) < len(hardwareSeverityNames) {
+		return hardwareSeverityNames[s]
+	}
+
+	return "severity(" + strconv.Itoa(int(s)) + ")"
+}
+
+// HardwareErrorEvent is an error of the hardware reported by the kernel over several
+// consecutive messages, see HardwareErrorDetector.
+type HardwareErrorEvent struct {
+	Source    string           // Reporter, "mce", "ghes", "edac" or "aer"
+	Component string           // Failing component, e.g. "CPU 2 Bank 7", the DIMM label "DIMM_A1" or the PCI device "0000:03:00.0", empty if not logged
+	Severity  HardwareSeverity // Whether the error was corrected
+	Seq       uint64           // Sequence number of the first message
+	TsUsec    int64            // Timestamp of the first message
+	Msgs      []Msg            // Messages of the report
+}
+
+var (
+	// "mce: [Hardware Error]: " of the machine check handler, "[Hardware Error]: " of the AMD
+	// decoder and "{1}[Hardware Error]: " of the firmware reports of GHES.
+	hardwareErrorRe = regexp.MustCompile(`^(?:mce: |(\{\d+\}))?\[Hardware Error\]: `)
+	// "CPU 2: Machine Check: 0 Bank 7: cc00008000010090", "Machine Check Exception" in kernels
+	// before 4.x.
+	mceBankRe = regexp.MustCompile(`CPU (\d+): Machine Check(?: Exception)?: \d+ Bank (\d+): ([0-9a-f]+)`)
+	// "CPU:0 (17:31:0) MC27_STATUS[-|CE|MiscV|-|AddrV|-|-|SyndV|-|CECC|-|-|-]: 0x9c2040000000011b"
+	// of the AMD decoder.
+	mceAMDBankRe = regexp.MustCompile(`CPU:(\d+) \([0-9a-f:]+\) MC(\d+)_STATUS\[([^\]]*)\]`)
+	// "EDAC MC0: 1 CE memory read error on CPU_SrcID#0_Ha#0_Chan#1_DIMM#0 (channel:1 slot:0 ...)"
+	// of kernels since 3.6, "EDAC sbridge MC0: " for messages of the driver.
+	edacErrorRe = regexp.MustCompile(`^EDAC (?:\S+ )?MC\d+: \d+ (CE|UE) .*? on (.+?) \(`)
+	// "EDAC MC0: CE row 2, channel 0, label "DIMM_B1": 1 Unknown error(s)" of older kernels.
+	edacOldErrorRe = regexp.MustCompile(`^EDAC (?:\S+ )?MC\d+: (CE|UE) .*?labels? "([^"]*)"`)
+	// "AER: Corrected error received: 0000:03:00.0", "AER: Uncorrected (Non-Fatal) error
+	// received: ...", "AER: Multiple Uncorrected (Fatal) error received: id=00e0", and
+	// "AER: Correctable error message received from 0000:03:00.0" of newer kernels, e.g. 6.12,
+	// which name the severities "Correctable" and "Uncorrectable (Fatal)".
+	aerReceivedRe = regexp.MustCompile(`AER: (?:Multiple )?(Corrected|Correctable|Uncorrected \((?:Non-)?Fatal\)|Uncorrectable(?: \((?:Non-)?Fatal\))?) error (?:message )?received(?::| from) (?:id=)?(\S+)`)
+	// "PCIe Bus Error: severity=Corrected, type=Physical Layer, (Receiver ID)" and the details
+	// of the error, e.g. "  device [144d:a808] error status/mask=00000001/0000e000" and
+	// "   [ 0] RxErr", after the device prefix and "AER: " in kernels since 4.19.
+	aerDetailRe = regexp.MustCompile(`^\S+ ([0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-9a-f]): (?:AER: )?(?:PCIe Bus Error: severity=([^,]+)|  +(?:device \[|\[ ?\d+\]|TLP Header|Error of this Agent))`)
+)
+
+// pciAddrRe matches the address of a PCI device, e.g. "0000:03:00.0".
+var pciAddrRe = regexp.MustCompile(`^[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-9a-f]$`)
+
+// HardwareErrorDetector groups the messages of reports of hardware errors into
+// HardwareErrorEvents: machine checks, "mce: [Hardware Error]: ...", firmware reports of GHES,
+// "{1}[Hardware Error]: ...", memory errors of EDAC, "EDAC MC0: 1 CE ...", and PCIe errors of AER,
+// "pcieport 0000:00:1c.5: AER: Corrected error received: ...". It's fed messages in the order
+// they were logged, from a snapshot or Follow.
+//
+// The messages of a report are consecutive, by sequence number when messages have one, of the
+// same caller when they have one, and of the device of the error for AER. A report ends with
+// the first message which isn't part of it, so it's returned when the next message is observed,
+// an EDAC report after its CE or UE message. The zero value is ready to use, it isn't safe for
+// concurrent use.
+type HardwareErrorDetector struct {
+	event     HardwareErrorEvent
+	open      bool // Whether a report started
+	hasRecord bool // Whether the MCE report has the record of a bank
+	last      Msg  // Last message of the report
+}
+
+// Observe adds msg to the report in progress and returns the HardwareErrorEvent of a report
+// ended by msg.
+func (d *HardwareErrorDetector) Observe(msg Msg) (HardwareErrorEvent, bool) {
+	var done HardwareErrorEvent
+	var ok bool
+	if d.open && !d.continues(msg) {
+		done, ok = d.Flush()
+	}
+
+	if !d.open {
+		source, starts := hardwareErrorSource(msg.Text)
+		if !starts {
+			return done, ok
+		}
+		d.event = HardwareErrorEvent{Source: source, Seq: msg.Seq, TsUsec: msg.TsUsec}
+		d.open, d.hasRecord = true, false
+	}
+
+	d.add(msg)
+
+	return done, ok
+}
+
+// Flush returns the HardwareErrorEvent of the report in progress, if any, and ends it, e.g. at
+// the end of a snapshot or when Follow stops. EDAC reports without CE or UE message, e.g. of a
+// driver loaded, aren't errors and return false.
+func (d *HardwareErrorDetector) Flush() (HardwareErrorEvent, bool) {
+	if !d.open {
+		return HardwareErrorEvent{}, false
+	}
+
+	event := d.event
+	d.event, d.open = HardwareErrorEvent{}, false
+	if event.Source == "edac" && event.Severity == SeverityUnknown {
+		return HardwareErrorEvent{}, false
+	}
+
+	return event, true
+}
+
+// hardwareErrorSource returns the source of the report text starts, if any.
+func hardwareErrorSource(text string) (string, bool) {
+	if m := hardwareErrorRe.FindStringSubmatch(text); m != nil {
+		if m[1] != "" {
+			return "ghes", true
+		}
+		return "mce", true
+	}
+	if strings.HasPrefix(text, "EDAC ") {
+		return "edac", true
+	}
+	if aerReceivedRe.MatchString(text) || aerDetailRe.MatchString(text) {
+		return "aer", true
+	}
+
+	return "", false
+}
+
+// continues reports whether msg is part of the report in progress.
+func (d *HardwareErrorDetector) continues(msg Msg) bool {
+	last := d.last
+	if msg.Seq != 0 && last.Seq != 0 && msg.Seq != last.Seq+1 {
+		return false
+	}
+	if msg.TsUsec-last.TsUsec > reportGap.Microseconds() {
+		return false
+	}
+	if msg.Caller != "" && last.Caller != "" && msg.Caller != last.Caller {
+		return false
+	}
+
+	switch d.event.Source {
+	case "mce", "ghes":
+		source, ok := hardwareErrorSource(msg.Text)
+		// Each record of a bank is a report of its own.
+		return ok && source == d.event.Source && !(d.hasRecord && mceBankRe.MatchString(msg.Text))
+	case "edac":
+		// The CE or UE message is the last of a report.
+		return strings.HasPrefix(msg.Text, "EDAC ") && d.event.Severity == SeverityUnknown
+	case "aer":
+		m := aerDetailRe.FindStringSubmatch(msg.Text)
+		if m == nil {
+			return false
+		}
+		component := d.event.Component
+		return component == "" || component == m[1] || msg.Device != "" && msg.Device == "+pci:"+component
+	}
+
+	return false
+}
+
+// add adds msg to the report in progress and parses the component and severity it logs.
+func (d *HardwareErrorDetector) add(msg Msg) {
+	e := &d.event
+	if len(e.Msgs) < maxReportMsgs {
+		e.Msgs = append(e.Msgs, msg)
+	}
+	d.last = msg
+
+	switch e.Source {
+	case "mce":
+		if m := mceBankRe.FindStringSubmatch(msg.Text); m != nil {
+			e.Component = "CPU " + m[1] + " Bank " + m[2]
+			d.hasRecord = true
+			// Bit 61 of MCi_STATUS is UC, bit 57 PCC, the processor context is corrupt.
+			if status, err := strconv.ParseUint(m[3], 16, 64); err == nil {
+				e.Severity = max(e.Severity, mceSeverity(status&(1<<61) != 0, status&(1<<57) != 0))
+			}
+		} else if m := mceAMDBankRe.FindStringSubmatch(msg.Text); m != nil {
+			e.Component = "CPU " + m[1] + " Bank " + m[2]
+			d.hasRecord = true
+			flags := "|" + m[3] + "|"
+			e.Severity = max(e.Severity, mceSeverity(strings.Contains(flags, "|UE|"), strings.Contains(flags, "|PCC|")))
+		} else if strings.Contains(msg.Text, "Processor context corrupt") {
+			e.Severity = SeverityFatal
+		} else if strings.Contains(msg.Text, "Corrected error") {
+			e.Severity = max(e.Severity, SeverityCorrected)
+		} else if strings.Contains(msg.Text, "Uncorrected") {
+			e.Severity = max(e.Severity, SeverityUncorrected)
+		}
+	case "ghes":
+		if _, severity, ok := strings.Cut(msg.Text, "event severity: "); ok {
+			e.Severity = max(e.Severity, ghesSeverity(severity))
+		} else if _, section, ok := strings.Cut(msg.Text, "section_type: "); ok && e.Component == "" {
+			e.Component = section
+		}
+	case "edac":
+		if m := edacErrorRe.FindStringSubmatch(msg.Text); m != nil {
+			e.Severity, e.Component = edacSeverity(m[1]), m[2]
+		} else if m := edacOldErrorRe.FindStringSubmatch(msg.Text); m != nil {
+			e.Severity, e.Component = edacSeverity(m[1]), m[2]
+		}
+	case "aer":
+		if m := aerReceivedRe.FindStringSubmatch(msg.Text); m != nil {
+			if pciAddrRe.MatchString(m[2]) {
+				e.Component = m[2]
+			}
+			e.Severity = max(e.Severity, aerSeverity(m[1]))
+		} else if m := aerDetailRe.FindStringSubmatch(msg.Text); m != nil {
+			if e.Component == "" {
+				e.Component = m[1]
+			}
+			if m[2] != "" {
+				e.Severity = max(e.Severity, aerSeverity(m[2]))
+			}
+		}
+	}
+}
+
+func mceSeverity(uncorrected, contextCorrupt bool) HardwareSeverity {
+	switch {
+	case contextCorrupt:
+		return SeverityFatal
+	case uncorrected:
+		return SeverityUncorrected
+	default:
+		return SeverityCorrected
+	}
+}
+
+// ghesSeverity returns the severity of a CPER record, "corrected", "recoverable", "fatal" or
+// "info".
+func ghesSeverity(severity string) HardwareSeverity {
+	switch strings.TrimSpace(severity) {
+	case "corrected", "info":
+		return SeverityCorrected
+	case "recoverable":
+		return SeverityUncorrected
+	case "fatal":
+		return SeverityFatal
+	}
+
+	return SeverityUnknown
+}
+
+func edacSeverity(kind string) HardwareSeverity {
+	if kind == "UE" {
+		return SeverityUncorrected
+	}
+
+	return SeverityCorrected
+}
+
+// aerSeverity returns the severity of an AER error, e.g. "Corrected" or "Uncorrected (Fatal)",
+// or "Correctable" and "Uncorrectable (Fatal)" of newer kernels.
+func aerSeverity(severity string) HardwareSeverity {
+	switch {
+	case severity == "Corrected" || severity == "Correctable":
+		return SeverityCorrected
+	case strings.Contains(severity, "(Fatal)"):
+		return SeverityFatal
+	case strings.HasPrefix(severity, "Uncorrect"):
+		return SeverityUncorrected
+	}
+
+	return SeverityUnknown
+}
+
+// HardwareErrorEvents returns the HardwareErrorEvents of msgs, see HardwareErrorDetector.
+func HardwareErrorEvents(msgs []Msg) []HardwareErrorEvent {
+	var d HardwareErrorDetector
+	var events []HardwareErrorEvent
+	for _, msg := range msgs {
+		if event, ok := d.Observe(msg); ok {
+			events = append(events, event)
+		}
+	}
+	if event, ok := d.Flush(); ok {
+		events = append(events, event)
+	}
+
+	return events
+}
@@ -0,0 +1,94 @@
+package dmesg
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+// The fixtures have the reports of hardware errors logged by linux 3.10, with the "[Hardware
+// Error]: " of the MCE handler without "mce: " and the AER messages without "AER: ", by linux
+// 5.15 and by linux 6.12, which logs AER errors as correctable and uncorrectable.
+func TestHardwareErrorEvents(t *testing.T) {
+	type report struct {
+		event HardwareErrorEvent
+		msgs  int
+	}
+	tests := []struct {
+		fixture string
+		want    []report
+	}{
+		{"hwerror-3.10", []report{
+			{HardwareErrorEvent{Source: "edac", Component: "CPU_SrcID#0_Ha#0_Chan#1_DIMM#0", Severity: SeverityCorrected, Seq: 2871}, 6},
+			{HardwareErrorEvent{Source: "aer", Component: "0000:00:03.0", Severity: SeverityCorrected, Seq: 2877}, 4},
+			// The panic following the report isn't part of it.
+			{HardwareErrorEvent{Source: "mce", Component: "CPU 2 Bank 4", Severity: SeverityFatal, Seq: 2881}, 6},
+		}},
+		{"hwerror-5.15", []report{
+			{HardwareErrorEvent{Source: "mce", Component: "CPU 0 Bank 7", Severity: SeverityCorrected, Seq: 10422}, 4},
+			{HardwareErrorEvent{Source: "mce", Component: "CPU 8 Bank 7", Severity: SeverityCorrected, Seq: 10426}, 3},
+			{HardwareErrorEvent{Source: "edac", Component: "CPU_SrcID#0_MC#0_Chan#1_DIMM#0", Severity: SeverityCorrected, Seq: 10429}, 1},
+			{HardwareErrorEvent{Source: "ghes", Component: "memory error", Severity: SeverityCorrected, Seq: 10430}, 8},
+			{HardwareErrorEvent{Source: "aer", Component: "0000:03:00.0", Severity: SeverityCorrected, Seq: 10438}, 4},
+		}},
+		{"hwerror-6.12", []report{
+			{HardwareErrorEvent{Source: "aer", Component: "0000:02:00.0", Severity: SeverityCorrected, Seq: 3309}, 4},
+			{HardwareErrorEvent{Source: "aer", Component: "0000:01:00.0", Severity: SeverityUncorrected, Seq: 3313}, 4},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			msgs := readRecords(t, tt.fixture)
+			events := HardwareErrorEvents(msgs)
+			if len(events) != len(tt.want) {
+				t.Fatalf("HardwareErrorEvents() = %d events, want %d", len(events), len(tt.want))
+			}
+
+			for i, event := range events {
+				want := tt.want[i]
+				first := want.event.Seq - msgs[0].Seq
+				want.event.TsUsec = msgs[first].TsUsec
+				want.event.Msgs = msgs[first : first+uint64(want.msgs)]
+				if !reflect.DeepEqual(event, want.event) {
+					t.Errorf("report %d =\n%+v\nwant\n%+v", i, event, want.event)
+				}
+			}
+		})
+	}
+}
+
+// testdata/hwerror-2.6.32.txt is the text of dmesg of linux 2.6.32, before /dev/kmsg, with the
+// EDAC messages of kernels before 3.6. The messages have no sequence numbers.
+func TestHardwareErrorEventsText(t *testing.T) {
+	file, err := os.Open("testdata/hwerror-2.6.32.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	msgs, err := ParseText(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The message of lost errors has no CE or UE, it isn't a report.
+	want := []HardwareErrorEvent{
+		{Source: "edac", Component: "CPU_SrcID#0_Channel#1_DIMM#0", Severity: SeverityCorrected, TsUsec: 8213412203, Msgs: msgs[0:1]},
+		{Source: "edac", Component: "CPU_SrcID#0_Channel#1_DIMM#0", Severity: SeverityCorrected, TsUsec: 8213412210, Msgs: msgs[1:2]},
+		{Source: "edac", Component: "DIMM_A2:DIMM_B2", Severity: SeverityUncorrected, TsUsec: 9120007731, Msgs: msgs[2:3]},
+	}
+	if events := HardwareErrorEvents(msgs); !reflect.DeepEqual(events, want) {
+		t.Errorf("HardwareErrorEvents() =\n%+v\nwant\n%+v", events, want)
+	}
+}
+
+func TestHardwareErrorDetectorGap(t *testing.T) {
+	msgs := readRecords(t, "hwerror-5.15")[10:13]
+
+	// A message missing from the report, e.g. dropped by the ring buffer, ends it.
+	var d HardwareErrorDetector
+	d.Observe(msgs[0])
+	if event, ok := d.Observe(msgs[2]); !ok || len(event.Msgs) != 1 || event.Seq != msgs[0].Seq {
+		t.Errorf("Observe() after a gap = %+v, %v, want the report ended", event, ok)
+	}
+}
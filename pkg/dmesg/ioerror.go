@@ -0,0 +1,224 @@
+package dmesg
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// IOErrorEvent is a failed request of a block device, grouped from the messages of the block
+// layer and of the SCSI or NVMe driver about it, see IOErrorDetector.
+type IOErrorEvent struct {
+	Device string // Block device, e.g. "sda" or "nvme0n1", the controller, e.g. "nvme0", for an error of an NVMe controller
+	Seq    uint64 // Sequence number of the first message
+	TsUsec int64  // Timestamp of the first message
+
+	Op     string // Operation, e.g. "READ", "WRITE" or "FLUSH", empty if not logged
+	Sector int64  // 512-byte sector of the request, -1 if not logged
+	LBA    int64  // Logical block address of an NVMe command, in blocks of the namespace, -1 if not logged
+	Class  string // Error of the block layer, e.g. "I/O error" or "critical medium error", "timeout" of a command or "controller is down" of NVMe, empty if not logged
+	Sense  string // Sense key of SCSI, e.g. "Medium Error", or status of NVMe, e.g. "Unrecovered Read Error", empty if not logged
+	Detail string // Additional sense of SCSI, e.g. "Unrecovered read error - auto reallocate failed", empty if not logged
+	Status string // Result of SCSI, e.g. "hostbyte=DID_OK driverbyte=DRIVER_SENSE", or of NVMe, e.g. "sct 0x2 / sc 0x81", empty if not logged
+
+	Msgs []Msg // Messages of the event
+}
+
+var (
+	// "blk_update_request: I/O error, dev sda, sector 123456" of kernels before 5.x, with
+	// "print_req_error: " since 4.18 or "end_request: " before 3.x, and "critical medium error,
+	// dev sda, sector 123456 op 0x0:(READ) flags 0x80700 phys_seg 1 prio class 0" without prefix
+	// since 5.10.
+	blkErrorRe = regexp.MustCompile(`^(?:(?:blk_update_request|print_req_error|end_request): )?([A-Za-z/ ]+? error), dev (\S+?),? sector (\d+)(?: op 0x[0-9a-f]+:\((\w+)\))?`)
+	// "sd 0:0:0:0: [sda] tag#0 " of the SCSI disk and CD-ROM drivers, without tag before 4.x.
+	scsiPrefixRe = regexp.MustCompile(`^s[dr] \d+:\d+:\d+:\d+: \[(\w+)\]\s+(?:tag#\d+ )?`)
+	// "FAILED Result: hostbyte=DID_OK driverbyte=DRIVER_SENSE cmd_age=0s", "Result: hostbyte=..."
+	// of kernels before 4.x.
+	scsiResultRe = regexp.MustCompile(`^(?:FAILED )?Result: (hostbyte=\S+ driverbyte=\S+)`)
+	// "Sense Key : Medium Error [current] [descriptor]", with a blank after the last flag.
+	scsiSenseRe = regexp.MustCompile(`^Sense Key : (.+?)(?: \[\w+\])* ?$`)
+	// "CDB: Read(10) 28 00 00 01 e2 40 00 00 08 00", "CDB: Read(10): 28 00 ..." before 4.x.
+	scsiCDBRe = regexp.MustCompile(`^CDB: ([A-Za-z][A-Za-z ]*?)\(\d+\)`)
+	// "nvme0n1: Read(0x2) @ LBA 2048, 8 blocks, Unrecovered Read Error (sct 0x2 / sc 0x81) DNR"
+	// of kernels since 5.18.
+	nvmeErrorRe = regexp.MustCompile(`^(nvme\d+n\d+): ([A-Za-z][A-Za-z ]*?)\(0x[0-9a-f]+\) @ LBA (\d+), \d+ blocks, (.+?) \((sct 0x[0-9a-f]+ / sc 0x[0-9a-f]+)\)`)
+	// "nvme nvme0: I/O 12 QID 3 timeout, aborting", with the operation "I/O 12 (Read) QID 3"
+	// since 5.x, and "nvme nvme0: controller is down; will reset: CSTS=0x3, PCI_STATUS=0x10".
+	nvmeControllerRe = regexp.MustCompile(`^nvme (nvme\d+): (?:I/O (?:tag )?\d+ (?:\((\w+)\) )?QID \d+ (timeout)|(controller is down); will reset: (.+))`)
+)
+
+// IOErrorDetector groups the messages about failed requests of block devices into
+// IOErrorEvents. It's fed messages in the order they were logged, from a snapshot or Follow.
+// The SCSI disk driver logs the result, sense key, additional sense and CDB of a failed command,
+// "sd 0:0:0:0: [sda] tag#0 Sense Key : Medium Error [current]", and NVMe the status of a failed
+// command, "nvme0n1: Read(0x2) @ LBA 2048, ...", before the block layer logs the error and
+// sector of the request, "blk_update_request: I/O error, dev sda, sector 123456" before 5.10 and
+// "I/O error, dev sda, sector 123456 op 0x0:(READ) ..." since. The messages are told apart by
+// device, an event ends with the message of the block layer, with the next failed command of the
+// device or with a message more than a second later. Timeouts and resets of NVMe controllers are
+// events of their own. The zero value is ready to use, it isn't safe for concurrent use.
+type IOErrorDetector struct {
+	failing map[string]*IOErrorEvent // Events in progress by device
+}
+
+// Observe adds msg to the events in progress and returns the IOErrorEvents ended by it, usually
+// none or one, the ones of several devices when msg is more than a second after them.
+func (d *IOErrorDetector) Observe(msg Msg) []IOErrorEvent {
+	events := d.expire(msg.TsUsec)
+
+	if m := blkErrorRe.FindStringSubmatch(msg.Text); m != nil {
+		e := d.failing[m[2]]
+		if e == nil {
+			e = d.start(m[2], msg)
+		} else {
+			e.add(msg)
+		}
+		e.Class = m[1]
+		e.Sector, _ = strconv.ParseInt(m[3], 10, 64)
+		if m[4] != "" {
+			e.Op = m[4]
+		}
+		return append(events, d.end(m[2]))
+	}
+
+	if m := scsiPrefixRe.FindStringSubmatch(msg.Text); m != nil {
+		device, text := m[1], msg.Text[len(m[0]):]
+		e := d.failing[device]
+		if m := scsiResultRe.FindStringSubmatch(text); m != nil {
+			// The result is the first message of a failed command.
+			if e != nil {
+				events = append(events, d.end(device))
+			}
+			e = d.start(device, msg)
+			e.Status = m[1]
+			return events
+		}
+
+		m := scsiSenseRe.FindStringSubmatch(text)
+		if m != nil && e != nil && e.Sense != "" {
+			events = append(events, d.end(device))
+			e = nil
+		}
+		detail, isDetail := strings.CutPrefix(text, "Add. Sense: ")
+		cdb := scsiCDBRe.FindStringSubmatch(text)
+		timeout := strings.HasPrefix(text, "timing out command")
+		if m == nil && !isDetail && cdb == nil && !timeout {
+			return events
+		}
+		if e == nil {
+			e = d.start(device, msg)
+		} else {
+			e.add(msg)
+		}
+		switch {
+		case m != nil:
+			e.Sense = m[1]
+		case isDetail:
+			e.Detail = detail
+		case cdb != nil:
+			e.Op = ioOp(cdb[1])
+		case timeout:
+			e.Class = "timeout"
+		}
+		return events
+	}
+
+	if m := nvmeErrorRe.FindStringSubmatch(msg.Text); m != nil {
+		if d.failing[m[1]] != nil {
+			events = append(events, d.end(m[1]))
+		}
+		e := d.start(m[1], msg)
+		e.Op = ioOp(m[2])
+		e.LBA, _ = strconv.ParseInt(m[3], 10, 64)
+		e.Sense, e.Status = m[4], m[5]
+		return events
+	}
+	if m := nvmeControllerRe.FindStringSubmatch(msg.Text); m != nil {
+		if d.failing[m[1]] != nil {
+			events = append(events, d.end(m[1]))
+		}
+		e := d.start(m[1], msg)
+		if m[3] != "" {
+			e.Class, e.Op = m[3], strings.ToUpper(m[2])
+		} else {
+			e.Class, e.Status = m[4], m[5]
+		}
+		return append(events, d.end(m[1]))
+	}
+
+	return events
+}
+
+// Flush returns the events in progress and ends them, e.g. at the end of a snapshot or when
+// Follow stops.
+func (d *IOErrorDetector) Flush() []IOErrorEvent {
+	return d.expire(-1)
+}
+
+// ioOp returns the operation of the block layer for a command name of SCSI or NVMe, e.g.
+// "READ" for "Read" and "SYNCHRONIZE_CACHE" for "Synchronize Cache".
+func ioOp(name string) string {
+	return strings.ReplaceAll(strings.ToUpper(name), " ", "_")
+}
+
+// start starts an event of device with msg.
+func (d *IOErrorDetector) start(device string, msg Msg) *IOErrorEvent {
+	if d.failing == nil {
+		d.failing = make(map[string]*IOErrorEvent)
+	}
+
+	e := &IOErrorEvent{Device: device, Seq: msg.Seq, TsUsec: msg.TsUsec, Sector: -1, LBA: -1, Msgs: []Msg{msg}}
+	d.failing[device] = e
+
+	return e
+}
+
+// add adds msg to the event in progress.
+func (e *IOErrorEvent) add(msg Msg) {
+	if len(e.Msgs) < maxReportMsgs {
+		e.Msgs = append(e.Msgs, msg)
+	}
+}
+
+// end ends the event in progress of device and returns it.
+func (d *IOErrorDetector) end(device string) IOErrorEvent {
+	e := d.failing[device]
+	delete(d.failing, device)
+
+	return *e
+}
+
+// expire ends the events in progress started more than reportGap before ts, all of them when ts
+// is negative, in the order they started.
+func (d *IOErrorDetector) expire(ts int64) []IOErrorEvent {
+	var devices []string
+	for device, e := range d.failing {
+		if ts < 0 || ts-e.TsUsec > reportGap.Microseconds() {
+			devices = append(devices, device)
+		}
+	}
+	slices.SortFunc(devices, func(a, b string) int {
+		ea, eb := d.failing[a], d.failing[b]
+		return cmp.Or(cmp.Compare(ea.TsUsec, eb.TsUsec), cmp.Compare(ea.Seq, eb.Seq))
+	})
+
+	var events []IOErrorEvent
+	for _, device := range devices {
+		events = append(events, d.end(device))
+	}
+
+	return events
+}
+
+// IOErrorEvents returns the IOErrorEvents of msgs, see IOErrorDetector.
+func IOErrorEvents(msgs []Msg) []IOErrorEvent {
+	var d IOErrorDetector
+	var events []IOErrorEvent
+	for _, msg := range msgs {
+		events = append(events, d.Observe(msg)...)
+	}
+
+	return append(events, d.Flush()...)
+}
@@ -0,0 +1,115 @@
+package dmesg
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// ioError is an IOErrorEvent without its messages, and the sequence numbers of the messages.
+type ioError struct {
+	event IOErrorEvent
+	seqs  []uint64
+}
+
+// The fixtures have the failed requests logged by linux 3.10, with "end_request: " and the
+// SCSI messages logged in parts, by linux 4.15 and 5.4 with "blk_update_request: ", which logs
+// the operation since 5.x, and by linux 6.1, which logs the error of the block layer without
+// prefix, and errors of NVMe.
+func TestIOErrorEvents(t *testing.T) {
+	const (
+		medium     = "Medium Error"
+		reallocate = "Unrecovered read error - auto reallocate failed"
+		sense      = "hostbyte=DID_OK driverbyte=DRIVER_SENSE"
+	)
+	tests := []struct {
+		fixture string
+		want    []ioError
+	}{
+		{"ioerror-3.10", []ioError{
+			{IOErrorEvent{
+				Device: "sdb", Op: "READ", Sector: 123456, LBA: -1, Class: "I/O error",
+				Sense: medium, Detail: reallocate, Status: sense,
+			}, []uint64{5523, 5525, 5527, 5528, 5529}},
+		}},
+		{"ioerror-4.15", []ioError{
+			{IOErrorEvent{
+				Device: "sda", Op: "READ", Sector: 191713792, LBA: -1, Class: "I/O error",
+				Sense: medium, Detail: reallocate, Status: sense,
+			}, []uint64{10311, 10312, 10313, 10314, 10315}},
+			{IOErrorEvent{
+				Device: "sdc", Op: "WRITE", Sector: 2048, LBA: -1, Class: "I/O error",
+				Status: "hostbyte=DID_NO_CONNECT driverbyte=DRIVER_OK",
+			}, []uint64{10317, 10318, 10319}},
+		}},
+		{"ioerror-5.4", []ioError{
+			{IOErrorEvent{
+				Device: "sda", Op: "READ", Sector: 191713792, LBA: -1, Class: "critical medium error",
+				Sense: medium, Detail: reallocate, Status: sense,
+			}, []uint64{8107, 8108, 8109, 8110, 8111}},
+			{IOErrorEvent{Device: "fd0", Op: "READ", Sector: 0, LBA: -1, Class: "I/O error"}, []uint64{8112}},
+		}},
+		// The commands of two disks fail at the same time.
+		{"ioerror-6.1", []ioError{
+			{IOErrorEvent{
+				Device: "sda", Op: "READ", Sector: 191713792, LBA: -1, Class: "critical medium error",
+				Sense: medium, Detail: reallocate, Status: "hostbyte=DID_OK driverbyte=DRIVER_OK",
+			}, []uint64{20411, 20413, 20415, 20417, 20419}},
+			{IOErrorEvent{
+				Device: "sdb", Op: "WRITE", Sector: 29375000, LBA: -1, Class: "I/O error",
+				Sense: "Aborted Command", Detail: "Information unit iuCRC error detected",
+				Status: "hostbyte=DID_OK driverbyte=DRIVER_OK",
+			}, []uint64{20412, 20414, 20416, 20418, 20420}},
+			{IOErrorEvent{
+				Device: "nvme0n1", Op: "READ", Sector: 16384, LBA: 2048, Class: "critical medium error",
+				Sense: "Unrecovered Read Error", Status: "sct 0x2 / sc 0x81",
+			}, []uint64{20421, 20422}},
+			{IOErrorEvent{Device: "nvme0", Op: "READ", Sector: -1, LBA: -1, Class: "timeout"}, []uint64{20423}},
+			{IOErrorEvent{Device: "nvme0", Sector: -1, LBA: -1, Class: "timeout"}, []uint64{20424}},
+			{IOErrorEvent{
+				Device: "nvme0", Sector: -1, LBA: -1, Class: "controller is down", Status: "CSTS=0x3, PCI_STATUS=0x10",
+			}, []uint64{20425}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			msgs := readRecords(t, tt.fixture)
+			events := IOErrorEvents(msgs)
+			if len(events) != len(tt.want) {
+				t.Fatalf("IOErrorEvents() = %d events, want %d: %+v", len(events), len(tt.want), events)
+			}
+
+			for i, event := range events {
+				w := tt.want[i]
+				w.event.Seq = w.seqs[0]
+				w.event.TsUsec = msgs[w.seqs[0]-msgs[0].Seq].TsUsec
+				if got := seqs(event.Msgs); !slices.Equal(got, w.seqs) {
+					t.Errorf("event %d of messages %v, want %v", i, got, w.seqs)
+				}
+				event.Msgs = nil
+				if !reflect.DeepEqual(event, w.event) {
+					t.Errorf("event %d =\n%+v\nwant\n%+v", i, event, w.event)
+				}
+			}
+		})
+	}
+}
+
+// The messages of a failed command without the error of the block layer, e.g. of a command
+// retried, end with a message more than a second later.
+func TestIOErrorDetectorExpire(t *testing.T) {
+	msgs := readRecords(t, "ioerror-4.15")[:4]
+
+	var d IOErrorDetector
+	for _, msg := range msgs {
+		if events := d.Observe(msg); len(events) != 0 {
+			t.Fatalf("Observe() of message %d = %+v, want no event", msg.Seq, events)
+		}
+	}
+	later := Msg{Seq: 20000, TsUsec: msgs[0].TsUsec + 1_000_001, Text: "usb 1-1: new high-speed USB device number 3 using xhci_hcd"}
+	events := d.Observe(later)
+	if len(events) != 1 || events[0].Device != "sda" || events[0].Sector != -1 || events[0].Sense != "Medium Error" || len(events[0].Msgs) != 4 {
+		t.Errorf("Observe() of a later message = %+v, want the failed command of sda", events)
+	}
+}
@@ -0,0 +1,64 @@
+//go:build go1.23
+
+package dmesg
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// All returns an iterator over all messages in kernel ring buffer. Messages are read and
+// parsed one per iteration instead of being collected into a slice, /dev/kmsg is closed
+// as soon as the loop ends. An *OverrunError is yielded when records were overwritten
+// before being read and iteration continues after it, any other error ends iteration.
+func All(opts ...Option) iter.Seq2[Msg, error] {
+	return func(yield func(Msg, error) bool) {
+		each(opts, false, func(d *dmesg, err error) bool {
+			for _, msg := range d.msg {
+				if !yield(msg, nil) {
+					return false
+				}
+			}
+			return err == nil || yield(Msg{}, err)
+		})
+	}
+}
+
+// Records is like All but yields native messages from kernel without parsing.
+func Records(opts ...Option) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		each(opts, true, func(d *dmesg, err error) bool {
+			for _, raw := range d.raw {
+				if !yield(raw, nil) {
+					return false
+				}
+			}
+			return err == nil || yield(nil, err)
+		})
+	}
+}
+
+// each reads messages one by one and passes them to f until there are no more messages,
+// WithMaxMessages messages are read, reading fails or f returns false.
+func each(opts []Option, fetchRaw bool, f func(d *dmesg, err error) bool) {
+	r, err := Open(opts...)
+	if err != nil {
+		f(&dmesg{}, err)
+		return
+	}
+	defer r.Close()
+
+	for count := 0; r.o.maxMessages == 0 || count < r.o.maxMessages; count++ {
+		d := dmesg{limit: 1}
+		err := r.read(context.Background(), &d, fetchRaw)
+		if !f(&d, err) {
+			return
+		}
+
+		var overrun *OverrunError
+		if len(d.raw)+len(d.msg) == 0 || err != nil && !errors.As(err, &overrun) {
+			return
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package dmesg
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// jsonMsg is the JSON shape of a Msg, see MarshalJSON.
+type jsonMsg struct {
+	Seq            uint64            `json:"seq"`
+	Priority       uint64            `json:"priority"`
+	Level          Level             `json:"level"`
+	LevelName      string            `json:"level_name"`
+	Facility       Facility          `json:"facility"`
+	FacilityName   string            `json:"facility_name"`
+	TsUsec         int64             `json:"ts_usec"`
+	Time           *time.Time        `json:"time,omitempty"`
+	Suspended      time.Duration     `json:"suspended_nsec,omitempty"`
+	Caller         string            `json:"caller,omitempty"`
+	Flag           *string           `json:"flag,omitempty"`
+	IsFragment     bool              `json:"fragment,omitempty"`
+	Fragments      []uint64          `json:"fragments,omitempty"`
+	Text           string            `json:"text"`
+	DeviceInfo     map[string]string `json:"device_info,omitempty"`
+	DeviceInfoList []KV              `json:"device_info_list,omitempty"`
+	Truncated      bool              `json:"truncated,omitempty"`
+	BootID         string            `json:"boot_id,omitempty"`
+}
+
+// MarshalJSON encodes m as a JSON object with these fields, the shape is kept stable:
+//
+//	seq               sequence number
+//	priority          combined priority value
+//	level             level number, e.g. 3
+//	level_name        level name, e.g. "err"
+//	facility          facility number, e.g. 0
+//	facility_name     facility name, e.g. "kern"
+//	ts_usec           timestamp in microsecond since boot
+//	time              RFC 3339 wall clock time, only when BootTime or WallTime is set
+//	suspended_nsec    Suspended in nanosecond, omitted if zero
+//	caller            caller, omitted if empty
+//	flag              flag character, e.g. "c", escaped like the kernel, "" for no Flag
+//	fragment          true for a fragment, omitted otherwise
+//	fragments         sequence numbers of the merged continuation records, omitted if none
+//	text              text of the message
+//	device_info       object of the device info, omitted if empty
+//	device_info_list  array of the device info lines as {"key", "value"}, omitted if empty
+//	truncated         true for a truncated message, omitted otherwise
+//	boot_id           boot ID, omitted if empty
+//
+// time is CorrectedTime, i.e. includes suspended_nsec, or WallTime for a message without
+// timestamp.
+func (m Msg) MarshalJSON() ([]byte, error) {
+	flag := ""
+	if m.Flag != 0 {
+		flag = string(appendEscaped(nil, string([]byte{byte(m.Flag)})))
+	}
+	j := jsonMsg{
+		Seq:            m.Seq,
+		Priority:       m.Priority,
+		Level:          m.Level,
+		LevelName:      m.Level.String(),
+		Facility:       m.Facility,
+		FacilityName:   m.Facility.String(),
+		TsUsec:         m.TsUsec,
+		Suspended:      m.Suspended,
+		Caller:         m.Caller,
+		Flag:           &flag,
+		IsFragment:     m.IsFragment(),
+		Fragments:      m.Fragments,
+		Text:           m.Text,
+		DeviceInfo:     m.allDeviceInfo(),
+		DeviceInfoList: m.DeviceInfoList,
+		Truncated:      m.Truncated,
+		BootID:         m.BootID,
+	}
+	switch {
+	case m.TsUsec < 0 && !m.WallTime.IsZero():
+		j.Time = &m.WallTime
+	case !m.BootTime.IsZero():
+		t := m.CorrectedTime()
+		j.Time = &t
+	}
+
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a JSON object produced by MarshalJSON into m, giving back the Msg
+// marshaled. The numbers of level and facility are used, the names are ignored. BootTime is
+// computed back from time, ts_usec and suspended_nsec, or WallTime is time for a message
+// without timestamp. device_info_list is used when it's there, device_info otherwise. Without
+// flag, a fragment gets FlagFragment and other messages FlagNone.
+func (m *Msg) UnmarshalJSON(data []byte) error {
+	var j jsonMsg
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	*m = Msg{
+		Priority:       j.Priority,
+		Level:          j.Level,
+		Facility:       j.Facility,
+		Seq:            j.Seq,
+		TsUsec:         j.TsUsec,
+		Suspended:      j.Suspended,
+		Caller:         j.Caller,
+		Flag:           FlagNone,
+		Fragments:      j.Fragments,
+		Text:           j.Text,
+		DeviceInfo:     j.DeviceInfo,
+		DeviceInfoList: j.DeviceInfoList,
+		Truncated:      j.Truncated,
+		BootID:         j.BootID,
+	}
+	switch {
+	case j.Flag != nil && *j.Flag != "":
+		m.Flag = Flag(unescape(*j.Flag)[0])
+	case j.Flag != nil:
+		m.Flag = 0
+	case j.IsFragment:
+		m.Flag = FlagFragment
+	}
+	if j.DeviceInfoList != nil {
+		m.DeviceInfo = deviceInfoMap(j.DeviceInfoList)
+	}
+	splitDeviceInfo(m)
+	switch {
+	case j.Time == nil:
+	case m.TsUsec < 0:
+		m.WallTime = *j.Time
+	default:
+		m.BootTime = j.Time.Add(-m.SinceBoot() - m.Suspended)
+	}
+
+	return nil
+}
+
+// WriteJSONL writes msgs to w as JSON Lines, one object encoded by MarshalJSON per line.
+func WriteJSONL(w io.Writer, msgs []Msg) error {
+	sink := NewJSONLSink(w)
+	for _, msg := range msgs {
+		if err := sink.WriteMsg(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewJSONLSink returns a Sink writing each message to w as a line of JSON Lines like
+// WriteJSONL. Each line is written with a single call to w.Write.
+func NewJSONLSink(w io.Writer) Sink {
+	return &jsonlSink{w: w}
+}
+
+type jsonlSink struct {
+	w io.Writer
+}
+
+func (s *jsonlSink) WriteMsg(msg Msg) error {
+	line, err := msg.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(append(line, '\n'))
+
+	return err
+}
+
+// WriteJSONCompat writes msgs to w in the format of 'dmesg --json' of util-linux: a "dmesg"
+// array of objects with pri, time, caller if any, and msg, indented the same way. The escapes
+// of the kernel in the text are decoded and the text is quoted like util-linux does. Nothing
+// is written for no messages, like 'dmesg --json'.
+//
+// pri is the facility and level combined with a bitwise or, not the priority value, since
+// util-linux 2.38 computes it that way. Like util-linux, pri is omitted for the facilities it
+// has no name for, the ones after FacilityFtp. The time of a message whose WallTime only is
+// known, e.g. parsed from 'dmesg -T' output, is its WallTime as a string like 'dmesg -T' prints.
+func WriteJSONCompat(w io.Writer, msgs []Msg) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("{\n   \"dmesg\": [\n      {\n")
+	for i, msg := range msgs {
+		if i > 0 {
+			bw.WriteString("      },{\n")
+		}
+		if msg.Facility <= FacilityFtp {
+			fmt.Fprintf(bw, "         \"pri\": %d,\n", msg.Facility|Facility(msg.Level))
+		}
+		if msg.TsUsec < 0 && !msg.WallTime.IsZero() {
+			bw.WriteString("         \"time\": ")
+			writeJSONCompatString(bw, msg.WallTime.Format(ctimeLayout))
+			bw.WriteString(",\n")
+		} else {
+			fmt.Fprintf(bw, "         \"time\": %5d.%06d,\n", msg.TsUsec/1e6, msg.TsUsec%1e6)
+		}
+		if msg.Caller != "" {
+			bw.WriteString("         \"caller\": ")
+			writeJSONCompatString(bw, msg.Caller)
+			bw.WriteString(",\n")
+		}
+		bw.WriteString("         \"msg\": ")
+		writeJSONCompatString(bw, msg.Text)
+		bw.WriteString("\n")
+	}
+	bw.WriteString("      }\n   ]\n}\n")
+
+	return bw.Flush()
+}
+
+// writeJSONCompatString writes s quoted like libsmartcols does for JSON: '"' and '\\' are
+// escaped with a backslash, control characters with their short escape or \u00NN.
+func writeJSONCompatString(bw *bufio.Writer, s string) {
+	bw.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			bw.WriteByte('\\')
+			bw.WriteByte(c)
+		case c == '\b':
+			bw.WriteString(`\b`)
+		case c == '\f':
+			bw.WriteString(`\f`)
+		case c == '\n':
+			bw.WriteString(`\n`)
+		case c == '\r':
+			bw.WriteString(`\r`)
+		case c == '\t':
+			bw.WriteString(`\t`)
+		case c < ' ':
+			bw.WriteString(`\u00` + strconv.FormatUint(uint64(c)>>4, 16) + strconv.FormatUint(uint64(c)&0xf, 16))
+		default:
+			bw.WriteByte(c)
+		}
+	}
+	bw.WriteByte('"')
+}
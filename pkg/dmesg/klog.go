@@ -0,0 +1,298 @@
+package dmesg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// Actions of syslog(2), see klogctl(3).
+const (
+	syslogActionReadAll      = 3
+	syslogActionReadClear    = 4
+	syslogActionClear        = 5
+	syslogActionConsoleOff   = 6
+	syslogActionConsoleOn    = 7
+	syslogActionConsoleLevel = 8
+	syslogActionSizeUnread   = 9
+	syslogActionSizeBuffer   = 10
+)
+
+// printkSysctl holds the console log levels: current, default for messages, minimum and
+// default for the console.
+const printkSysctl = "/proc/sys/kernel/printk"
+
+// klogctl calls syslog(2) with action and buf, or arg for actions taking a value instead of a
+// buffer, what describes the action for errors. A permission error tells the capability needed
+// and matches os.ErrPermission with errors.Is. syscall.Klogctl can't pass arg.
+func klogctl(action int, buf []byte, what string, arg int) (int, error) {
+	var ptr unsafe.Pointer
+	if len(buf) > 0 {
+		ptr, arg = unsafe.Pointer(&buf[0]), len(buf)
+	}
+
+	r, _, errno := syscall.Syscall(syscall.SYS_SYSLOG, uintptr(action), uintptr(ptr), uintptr(arg))
+	n, err := int(r), error(nil)
+	if errno != 0 {
+		err = errno
+	}
+	if errors.Is(err, syscall.EPERM) {
+		return 0, fmt.Errorf("dmesg: %s needs CAP_SYSLOG (CAP_SYS_ADMIN before linux 2.6.37): %w", what, err)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("dmesg: %s: %w", what, err)
+	}
+
+	return n, nil
+}
+
+// Clear clears kernel ring buffer like 'dmesg -C', with SYSLOG_ACTION_CLEAR of syslog(2).
+// Readers of /dev/kmsg are not affected, only reads starting after the clear, see
+// WithStartAfterClear. It needs CAP_SYSLOG.
+func Clear() error {
+	_, err := klogctl(syslogActionClear, nil, "clearing kernel ring buffer", 0)
+
+	return err
+}
+
+// BufferSize returns the size of kernel ring buffer in bytes, set by log_buf_len at boot,
+// with SYSLOG_ACTION_SIZE_BUFFER of syslog(2). It needs CAP_SYSLOG when
+// kernel.dmesg_restrict is set.
+func BufferSize() (int, error) {
+	return klogctl(syslogActionSizeBuffer, nil, "getting the size of kernel ring buffer", 0)
+}
+
+// bufferSize is cachedBufferSize, replaced by benchmarks.
+var bufferSize = cachedBufferSize
+
+// bufferSizeCache is the size read by cachedBufferSize, 0 until it was read.
+var bufferSizeCache atomic.Int64
+
+// cachedBufferSize returns BufferSize, read once since the size can't change after boot. Errors
+// aren't kept, reading fails again only while the cause lasts, e.g. missing CAP_SYSLOG with
+// kernel.dmesg_restrict set.
+func cachedBufferSize() (int, error) {
+	if size := bufferSizeCache.Load(); size > 0 {
+		return int(size), nil
+	}
+
+	size, err := BufferSize()
+	if err != nil {
+		return 0, err
+	}
+	bufferSizeCache.Store(int64(size))
+
+	return size, nil
+}
+
+// UnreadBytes returns the number of bytes of kernel ring buffer not yet read by syslog(2) or
+// /proc/kmsg readers, e.g. klogd, with SYSLOG_ACTION_SIZE_UNREAD of syslog(2). Readers of
+// /dev/kmsg have their own positions and don't change it. It needs CAP_SYSLOG.
+func UnreadBytes() (int, error) {
+	return klogctl(syslogActionSizeUnread, nil, "getting the unread size of kernel ring buffer", 0)
+}
+
+// GetConsoleLevel returns the least severe level of messages printed to the console, read from
+// /proc/sys/kernel/printk. A console log level above debug, e.g. after booting with "debug",
+// is returned as LevelDebug.
+func GetConsoleLevel() (Level, error) {
+	data, err := os.ReadFile(printkSysctl)
+	if err != nil {
+		return 0, err
+	}
+
+	fields := bytes.Fields(data)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("dmesg: empty %s", printkSysctl)
+	}
+	n, err := strconv.ParseUint(string(fields[0]), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("dmesg: parsing console log level of %s: %w", printkSysctl, err)
+	}
+
+	// Messages of levels below the console log level are printed.
+	switch {
+	case n == 0:
+		return LevelEmerg, nil
+	case n > uint64(LevelDebug)+1:
+		return LevelDebug, nil
+	}
+
+	return Level(n - 1), nil
+}
+
+// SetConsoleLevel prints messages of level l and more severe ones to the console like
+// 'dmesg -n', with SYSLOG_ACTION_CONSOLE_LEVEL of syslog(2). It needs CAP_SYSLOG, the error
+// matches os.ErrPermission with errors.Is without it.
+func SetConsoleLevel(l Level) error {
+	if l > LevelDebug {
+		return fmt.Errorf("dmesg: invalid console level %d", l)
+	}
+	_, err := klogctl(syslogActionConsoleLevel, nil, "setting console log level", int(l)+1)
+
+	return err
+}
+
+// ConsoleOff disables printing messages to the console like 'dmesg -D', with
+// SYSLOG_ACTION_CONSOLE_OFF of syslog(2). It needs CAP_SYSLOG.
+func ConsoleOff() error {
+	_, err := klogctl(syslogActionConsoleOff, nil, "disabling console logging", 0)
+
+	return err
+}
+
+// ConsoleOn enables printing messages to the console again like 'dmesg -E', restoring the
+// console log level before ConsoleOff, with SYSLOG_ACTION_CONSOLE_ON of syslog(2). It needs
+// CAP_SYSLOG.
+func ConsoleOn() error {
+	_, err := klogctl(syslogActionConsoleOn, nil, "enabling console logging", 0)
+
+	return err
+}
+
+// ReadClear reads the messages since the last clear and clears kernel ring buffer like
+// 'dmesg -c', with SYSLOG_ACTION_READ_CLEAR of syslog(2). It needs CAP_SYSLOG.
+// The messages are parsed from the text lines syslog(2) returns, "<6>[    1.234567] text",
+// which have no sequence number, device info or caller unless the kernel prints it, so Seq is
+// 0. Filter options and WithBootTime apply, the other options are ignored.
+func ReadClear(opts ...Option) ([]Msg, error) {
+	o := newOptions(opts)
+	if err := o.resolveTimes(); err != nil {
+		return nil, err
+	}
+
+	size, err := bufferSize()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	n, err := klogctl(syslogActionReadClear, buf, "reading and clearing kernel ring buffer", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.filter(parseSyslogText(buf[:n])), nil
+}
+
+// readAllKlogctl reads the messages since the last clear into d with SYSLOG_ACTION_READ_ALL of
+// syslog(2), see WithKlogctlFallback.
+func readAllKlogctl(o options, d *dmesg) error {
+	if err := o.resolveTimes(); err != nil {
+		return err
+	}
+
+	size, err := bufferSize()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, size)
+	n, err := klogctl(syslogActionReadAll, buf, "reading kernel ring buffer", 0)
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range o.filter(parseSyslogText(buf[:n])) {
+		if d.full() {
+			break
+		}
+		d.addMsg(msg)
+	}
+
+	return nil
+}
+
+// parseSyslogText parses the lines of syslog(2) and /proc/kmsg, "<pri>[ts][caller] text".
+// The timestamp is missing when printk.time is off and the caller without
+// CONFIG_PRINTK_CALLER. The kernel prefixes each line of a message with several lines, a line
+// without prefix is taken as a continuation of the previous message.
+func parseSyslogText(data []byte) []Msg {
+	var msgs []Msg
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		msg, ok := parseSyslogLine(line)
+		if !ok && len(msgs) > 0 {
+			last := &msgs[len(msgs)-1]
+			last.Text += "\n" + string(line)
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs
+}
+
+// parseSyslogLine parses a line "<pri>[ts][caller] text" of syslog(2). It returns false for a
+// line without the "<pri>" prefix, leaving the whole line as text of a message at the default
+// level of printk, LevelWarn.
+func parseSyslogLine(line []byte) (Msg, bool) {
+	msg := Msg{Priority: uint64(LevelWarn), Level: LevelWarn, Flag: FlagNone}
+
+	end := bytes.IndexByte(line, '>')
+	if len(line) == 0 || line[0] != '<' || end == -1 {
+		msg.Text = string(line)
+		return msg, false
+	}
+	pri, err := strconv.ParseUint(string(line[1:end]), 10, 64)
+	if err != nil {
+		msg.Text = string(line)
+		return msg, false
+	}
+	msg.Priority = pri
+	msg.Level = Level(pri & levelMask)
+	msg.Facility = Facility(pri >> facilityShift)
+	line = line[end+1:]
+
+	if field, rest, ok := cutBracket(line); ok {
+		if ts, ok := parseSyslogTime(field); ok {
+			msg.TsUsec = ts
+			line = rest
+		}
+	}
+	if field, rest, ok := cutBracket(line); ok && isCaller(field) {
+		msg.Caller = string(field)
+		line = rest
+	}
+	msg.Text = string(bytes.TrimPrefix(line, []byte(" ")))
+
+	return msg, true
+}
+
+// cutBracket cuts a "[field]" off the start of line, returning field without the spaces
+// padding it.
+func cutBracket(line []byte) ([]byte, []byte, bool) {
+	if len(line) == 0 || line[0] != '[' {
+		return nil, line, false
+	}
+	end := bytes.IndexByte(line, ']')
+	if end == -1 {
+		return nil, line, false
+	}
+
+	return bytes.TrimSpace(line[1:end]), line[end+1:], true
+}
+
+// parseSyslogTime parses a timestamp "1234.567890" in seconds into microseconds.
+func parseSyslogTime(field []byte) (int64, bool) {
+	secs, usecs, ok := bytes.Cut(field, []byte("."))
+	if !ok || len(usecs) != 6 {
+		return 0, false
+	}
+	sec, err := strconv.ParseInt(string(secs), 10, 64)
+	if err != nil || sec < 0 {
+		return 0, false
+	}
+	usec, err := strconv.ParseInt(string(usecs), 10, 64)
+	if err != nil || usec < 0 {
+		return 0, false
+	}
+
+	return sec*1e6 + usec, true
+}
@@ -0,0 +1,100 @@
+package dmesg
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// TestConsoleLevel changes the console log level of the system and restores it.
+func TestConsoleLevel(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("setting the console log level needs root")
+	}
+
+	orig, err := GetConsoleLevel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetConsoleLevel(orig); errors.Is(err, os.ErrPermission) {
+		t.Skip("setting the console log level needs CAP_SYSLOG:", err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ConsoleOn()
+		if err := SetConsoleLevel(orig); err != nil {
+			t.Errorf("restoring the console log level %v: %v", orig, err)
+		}
+	})
+
+	for _, level := range []Level{LevelErr, LevelDebug, LevelEmerg, LevelWarn} {
+		if err := SetConsoleLevel(level); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := GetConsoleLevel(); err != nil || got != level {
+			t.Errorf("GetConsoleLevel() after SetConsoleLevel(%v) = %v, %v", level, got, err)
+		}
+	}
+
+	// The console log level is the minimum one while console logging is off, and the one
+	// before ConsoleOff once it's on again.
+	if err := ConsoleOff(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := GetConsoleLevel(); err != nil || got >= LevelWarn {
+		t.Errorf("GetConsoleLevel() after ConsoleOff() = %v, %v, want the minimum level", got, err)
+	}
+	if err := ConsoleOn(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := GetConsoleLevel(); err != nil || got != LevelWarn {
+		t.Errorf("GetConsoleLevel() after ConsoleOn() = %v, %v, want %v", got, err, LevelWarn)
+	}
+
+	if err := SetConsoleLevel(LevelDebug + 1); err == nil {
+		t.Error("SetConsoleLevel() of an invalid level succeeded")
+	}
+}
+
+func TestConsoleLevelUnprivileged(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("running as root")
+	}
+
+	if _, err := GetConsoleLevel(); err != nil {
+		t.Errorf("GetConsoleLevel() = %v, /proc/sys/kernel/printk is readable by anyone", err)
+	}
+	for _, tt := range []struct {
+		name string
+		f    func() error
+	}{
+		{"SetConsoleLevel", func() error { return SetConsoleLevel(LevelWarn) }},
+		{"ConsoleOff", ConsoleOff},
+		{"ConsoleOn", ConsoleOn},
+	} {
+		if err := tt.f(); !errors.Is(err, os.ErrPermission) {
+			t.Errorf("%s() = %v, want a permission error", tt.name, err)
+		}
+	}
+}
+
+// A failure to read the size isn't kept, a size read is.
+func TestCachedBufferSize(t *testing.T) {
+	bufferSizeCache.Store(0)
+	t.Cleanup(func() { bufferSizeCache.Store(0) })
+
+	size, err := cachedBufferSize()
+	if err != nil {
+		if got := bufferSizeCache.Load(); got != 0 {
+			t.Errorf("size cached after %v: %d", err, got)
+		}
+		t.Skip("reading the size of kernel ring buffer:", err)
+	}
+	if size <= 0 || bufferSizeCache.Load() != int64(size) {
+		t.Errorf("cachedBufferSize() = %d, cached %d", size, bufferSizeCache.Load())
+	}
+	if got, err := cachedBufferSize(); err != nil || got != size {
+		t.Errorf("cachedBufferSize() again = %d, %v, want %d", got, err, size)
+	}
+}
@@ -0,0 +1,134 @@
+package dmesg
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LinkEvent is the link of a network interface going up or down, see ParseLink.
+type LinkEvent struct {
+	Interface string // Interface, e.g. "eth0"
+	Up        bool   // Whether the link went up, else down
+	Speed     int    // Speed in Mbit/s, e.g. 1000, 0 if not logged
+	Duplex    string // "full" or "half", empty if not logged
+	Driver    string // Driver of Interface, e.g. "e1000e", "bonding" or "bridge" for a port of Master, empty if not logged
+	Master    string // Bond or bridge of Interface, e.g. "bond0", empty if not a port of one
+	Seq       uint64 // Sequence number of the message
+	TsUsec    int64  // Timestamp of the message
+}
+
+var (
+	// "e1000e 0000:00:19.0 eth0: NIC Link is Up 1000 Mbps Full Duplex, Flow Control: Rx/Tx",
+	// "igb 0000:01:00.0 eno1: igb: eno1 NIC Link is Up 1000 Mbps Full Duplex", "r8169
+	// 0000:02:00.0 enp2s0: Link is Up - 1Gbps/Full - flow control rx/tx" of phylib, "tg3
+	// 0000:02:00.0 eth0: Link is up at 1000 Mbps, full duplex" and "mlx5_core 0000:5e:00.0 ens1f0:
+	// Link down", and "r8169 0000:02:00.0 enp2s0: link up" of r8169 before phylib.
+	linkRe = regexp.MustCompile(`^(?:(\S+) \S+ )?([^\s:]+): (?:\S+: \S+ )?(?:NIC (?:Copper )?)?[Ll]ink (?:is )?([Uu]p|[Dd]own)\b(.*)`)
+	// "e1000e: eth0 NIC Link is Up 1000 Mbps Full Duplex, Flow Control: Rx/Tx" of the e1000,
+	// e1000e and igb drivers before they logged the device.
+	driverLinkRe = regexp.MustCompile(`^(\S+): (\S+) NIC (?:Copper )?Link is (Up|Down)\b(.*)`)
+	// "bond0: (slave eth0): link status definitely up, 1000 Mbps full duplex" of kernels since
+	// 5.x, "bond0: link status definitely down for interface eth1, disabling it" before, with
+	// "bonding: " before the bond before 3.17.
+	bondLinkRe = regexp.MustCompile(`^(?:bonding: )?(\S+): (?:\(slave ([^)]+)\): link status definitely (up|down)|link status definitely (up|down) for interface ([^,\s]+))(.*)`)
+	// "br0: port 1(eth0) entered forwarding state".
+	bridgeLinkRe = regexp.MustCompile(`^(\S+): port \d+\((\S+)\) entered (forwarding|disabled) state`)
+	// "IPv6: ADDRCONF(NETDEV_CHANGE): eth0: link becomes ready" of kernels before 6.3, without
+	// "IPv6: " before 3.x.
+	addrconfLinkRe = regexp.MustCompile(`^(?:IPv6: )?ADDRCONF\(NETDEV_CHANGE\): (\S+): link becomes ready`)
+	// "1000 Mbps", "1Gbps" or "2.5Gbps" of the speed of a link.
+	linkSpeedRe = regexp.MustCompile(`(\d+(?:\.\d+)?) ?([MG])bps`)
+	// "Full Duplex", "/Full" or "full duplex" of the duplex of a link.
+	linkDuplexRe = regexp.MustCompile(`(?i)\b(full|half)\b`)
+)
+
+// ParseLink returns the LinkEvent of msg if it's the message of a network driver about the link
+// of an interface going up or down, "eth0: NIC Link is Up 1000 Mbps Full Duplex" or "Link is
+// Down", of bonding about a port, "link status definitely up", of a bridge about a port entering
+// the forwarding or disabled state, or "ADDRCONF(NETDEV_CHANGE): eth0: link becomes ready" of
+// IPv6. It returns false for other messages.
+func ParseLink(msg Msg) (LinkEvent, bool) {
+	event := LinkEvent{Seq: msg.Seq, TsUsec: msg.TsUsec}
+
+	var details string
+	if m := bondLinkRe.FindStringSubmatch(msg.Text); m != nil {
+		event.Driver, event.Master, details = "bonding", m[1], m[6]
+		if m[2] != "" {
+			event.Interface, event.Up = m[2], m[3] == "up"
+		} else {
+			event.Interface, event.Up = m[5], m[4] == "up"
+		}
+	} else if m := bridgeLinkRe.FindStringSubmatch(msg.Text); m != nil {
+		event.Driver, event.Master, event.Interface, event.Up = "bridge", m[1], m[2], m[3] == "forwarding"
+	} else if m := addrconfLinkRe.FindStringSubmatch(msg.Text); m != nil {
+		event.Interface, event.Up = m[1], true
+	} else if m := driverLinkRe.FindStringSubmatch(msg.Text); m != nil {
+		event.Driver, event.Interface, event.Up, details = m[1], m[2], m[3] == "Up", m[4]
+	} else if m := linkRe.FindStringSubmatch(msg.Text); m != nil {
+		event.Driver, event.Interface, event.Up, details = m[1], m[2], strings.EqualFold(m[3], "up"), m[4]
+	} else {
+		return LinkEvent{}, false
+	}
+
+	if !event.Up {
+		return event, true
+	}
+	if m := linkSpeedRe.FindStringSubmatch(details); m != nil {
+		speed, _ := strconv.ParseFloat(m[1], 64)
+		if m[2] == "G" {
+			speed *= 1000
+		}
+		event.Speed = int(speed)
+	}
+	if m := linkDuplexRe.FindStringSubmatch(details); m != nil {
+		event.Duplex = strings.ToLower(m[1])
+	}
+
+	return event, true
+}
+
+// LinkEvents returns the LinkEvents of msgs, see ParseLink.
+func LinkEvents(msgs []Msg) []LinkEvent {
+	var events []LinkEvent
+	for _, msg := range msgs {
+		if event, ok := ParseLink(msg); ok {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// LinkFlaps returns by interface the most transitions of its link within window, e.g. to alert
+// on interfaces whose link flapped more than N times in a minute. events are in the order they
+// were logged. An event is a transition if its link is up and the one before of the interface is
+// down or the other way around, so the messages of the driver and of IPv6 about a link going up
+// count once. Interfaces without transition aren't in the result.
+func LinkFlaps(events []LinkEvent, window time.Duration) map[string]int {
+	transitions := make(map[string][]int64)
+	last := make(map[string]bool)
+	for _, e := range events {
+		if up, ok := last[e.Interface]; ok && up != e.Up {
+			transitions[e.Interface] = append(transitions[e.Interface], e.TsUsec)
+		}
+		last[e.Interface] = e.Up
+	}
+
+	flaps := make(map[string]int)
+	for iface, ts := range transitions {
+		slices.SortFunc(ts, cmp.Compare[int64])
+		start := 0
+		for end := range ts {
+			for start < end && ts[end]-ts[start] >= window.Microseconds() {
+				start++
+			}
+			flaps[iface] = max(flaps[iface], end-start+1)
+		}
+	}
+
+	return flaps
+}
@@ -0,0 +1,128 @@
+package dmesg
+
+import (
+	"maps"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLinkEvents(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []LinkEvent
+	}{
+		// Linux 3.10 logs the links of e1000e and of the ports of a bond without their device,
+		// and those of r8169 before it used phylib in lower case.
+		{"link-3.10", []LinkEvent{
+			{Seq: 612, Interface: "enp2s0", Driver: "r8169"},
+			{Seq: 614, Interface: "enp2s0", Up: true, Driver: "r8169"},
+			{Seq: 615, Interface: "enp2s0", Up: true},
+			{Seq: 618, Interface: "eth0", Up: true, Speed: 1000, Duplex: "full", Driver: "e1000e"},
+			{Seq: 619, Interface: "eth0", Up: true, Speed: 1000, Duplex: "full", Driver: "bonding", Master: "bond0"},
+			{Seq: 620, Interface: "eth1", Up: true, Speed: 100, Duplex: "half", Driver: "tg3"},
+			{Seq: 622, Interface: "eth1", Up: true, Speed: 100, Duplex: "half", Driver: "bonding", Master: "bond0"},
+			{Seq: 626, Interface: "bond0", Up: true, Driver: "bridge", Master: "br0"},
+			{Seq: 627, Interface: "eth0", Driver: "e1000e"},
+			{Seq: 628, Interface: "eth0", Driver: "bonding", Master: "bond0"},
+		}},
+		{"link-4.15", []LinkEvent{
+			{Seq: 871, Interface: "enp0s31f6", Up: true, Speed: 1000, Duplex: "full", Driver: "e1000e"},
+			{Seq: 872, Interface: "enp0s31f6", Up: true},
+			{Seq: 873, Interface: "eno1", Up: true, Speed: 1000, Duplex: "full", Driver: "igb"},
+			{Seq: 874, Interface: "eno1", Up: true, Speed: 1000, Duplex: "full", Driver: "bonding", Master: "bond0"},
+			{Seq: 876, Interface: "enp4s0f0", Up: true, Speed: 10000, Driver: "ixgbe"},
+			{Seq: 877, Interface: "eno1", Driver: "igb"},
+			{Seq: 878, Interface: "eno1", Driver: "bonding", Master: "bond0"},
+			{Seq: 880, Interface: "enp4s0f0", Driver: "ixgbe"},
+		}},
+		// The bridge port entering the blocking state isn't a link going down.
+		{"link-6.1", []LinkEvent{
+			{Seq: 2210, Interface: "enp0s31f6", Up: true, Speed: 1000, Duplex: "full", Driver: "e1000e"},
+			{Seq: 2211, Interface: "enp0s31f6", Up: true},
+			{Seq: 2212, Interface: "enp2s0", Up: true, Speed: 2500, Duplex: "full", Driver: "r8169"},
+			{Seq: 2213, Interface: "enp2s0", Up: true, Speed: 2500, Duplex: "full", Driver: "bonding", Master: "bond0"},
+			{Seq: 2216, Interface: "bond0", Up: true, Driver: "bridge", Master: "br0"},
+			{Seq: 2217, Interface: "ens1f0", Driver: "mlx5_core"},
+			{Seq: 2218, Interface: "ens1f0", Up: true, Driver: "mlx5_core"},
+			{Seq: 2219, Interface: "ens1f0", Driver: "mlx5_core"},
+			{Seq: 2220, Interface: "ens1f0", Up: true, Driver: "mlx5_core"},
+			{Seq: 2221, Interface: "ens1f0", Driver: "mlx5_core"},
+			{Seq: 2222, Interface: "ens1f0", Up: true, Driver: "mlx5_core"},
+			{Seq: 2223, Interface: "enp2s0", Driver: "r8169"},
+			{Seq: 2224, Interface: "enp2s0", Driver: "bonding", Master: "bond0"},
+			{Seq: 2225, Interface: "bond0", Driver: "bridge", Master: "br0"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			msgs := readRecords(t, tt.fixture)
+			events := LinkEvents(msgs)
+			if len(events) != len(tt.want) {
+				t.Fatalf("LinkEvents() = %d events, want %d: %+v", len(events), len(tt.want), events)
+			}
+			for i, event := range events {
+				want := tt.want[i]
+				want.TsUsec = msgs[want.Seq-msgs[0].Seq].TsUsec
+				if event != want {
+					t.Errorf("event %d =\n%+v\nwant\n%+v", i, event, want)
+				}
+			}
+		})
+	}
+}
+
+// testdata/link-2.6.32.txt is the text of dmesg of linux 2.6.32, which logs ADDRCONF without
+// "IPv6: ".
+func TestLinkEventsText(t *testing.T) {
+	file, err := os.Open("testdata/link-2.6.32.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	msgs, err := ParseText(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []LinkEvent{
+		{Interface: "eth0", Up: true, Speed: 1000, Duplex: "full", Driver: "e1000e", TsUsec: msgs[2].TsUsec},
+		{Interface: "eth0", Up: true, TsUsec: msgs[3].TsUsec},
+		{Interface: "eth0", Up: true, Speed: 1000, Duplex: "full", Driver: "bonding", Master: "bond0", TsUsec: msgs[4].TsUsec},
+		{Interface: "eth0", Driver: "e1000e", TsUsec: msgs[5].TsUsec},
+		{Interface: "eth0", Driver: "bonding", Master: "bond0", TsUsec: msgs[6].TsUsec},
+	}
+	events := LinkEvents(msgs)
+	if len(events) != len(want) {
+		t.Fatalf("LinkEvents() = %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, event := range events {
+		if event != want[i] {
+			t.Errorf("event %d =\n%+v\nwant\n%+v", i, event, want[i])
+		}
+	}
+}
+
+// ens1f0 of testdata/link-6.1 flaps, the driver and bonding messages about enp2s0 going up and
+// down are one transition, as are those of bond0 entering the forwarding and disabled states.
+func TestLinkFlaps(t *testing.T) {
+	events := LinkEvents(readRecords(t, "link-6.1"))
+
+	tests := []struct {
+		window time.Duration
+		want   map[string]int
+	}{
+		{time.Minute, map[string]int{"ens1f0": 5, "enp2s0": 1, "bond0": 1}},
+		{time.Microsecond, map[string]int{"ens1f0": 1, "enp2s0": 1, "bond0": 1}},
+	}
+	for _, tt := range tests {
+		if got := LinkFlaps(events, tt.window); !maps.Equal(got, tt.want) {
+			t.Errorf("LinkFlaps() in %v = %v, want %v", tt.window, got, tt.want)
+		}
+	}
+
+	if got := LinkFlaps(events[:2], time.Minute); len(got) != 0 {
+		t.Errorf("LinkFlaps() of a link going up = %v, want none", got)
+	}
+}
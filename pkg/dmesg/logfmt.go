@@ -0,0 +1,86 @@
+package dmesg
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteLogfmt writes msgs to w as logfmt, one line for each:
+//
+//	ts=123.456789 level=err facility=kern seq=42 caller=T123 msg="text here" subsystem=pci device=0000:00:1f.2
+//
+// ts is the timestamp in second since boot. A message whose timestamp isn't known, e.g. parsed
+// from 'dmesg -T' output by ParseText, has time with its WallTime in RFC 3339 instead. Empty
+// fields are omitted, values are quoted when they contain spaces, '=', '"' or '\\'. The device
+// is the DEVICE device info with the "+subsystem:" prefix removed, e.g. 0000:00:1f.2 for
+// +pci:0000:00:1f.2.
+func WriteLogfmt(w io.Writer, msgs []Msg) error {
+	sink := NewLogfmtSink(w)
+	for _, msg := range msgs {
+		if err := sink.WriteMsg(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewLogfmtSink returns a Sink writing each message to w as a line of logfmt like
+// WriteLogfmt. Each line is written with a single call to w.Write.
+func NewLogfmtSink(w io.Writer) Sink {
+	return &logfmtSink{w: w}
+}
+
+type logfmtSink struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (s *logfmtSink) WriteMsg(msg Msg) error {
+	buf := s.buf[:0]
+	switch {
+	case msg.TsUsec >= 0:
+		buf = append(buf, " ts="...)
+		buf = strconv.AppendInt(buf, msg.TsUsec/1e6, 10)
+		buf = append(buf, '.')
+		usec := strconv.FormatInt(msg.TsUsec%1e6, 10)
+		buf = append(buf, "000000"[len(usec):]...)
+		buf = append(buf, usec...)
+	case !msg.WallTime.IsZero():
+		buf = appendLogfmt(buf, "time", msg.WallTime.Format(time.RFC3339Nano))
+	}
+
+	buf = appendLogfmt(buf, "level", msg.Level.String())
+	buf = appendLogfmt(buf, "facility", msg.Facility.String())
+	buf = appendLogfmt(buf, "seq", strconv.FormatUint(msg.Seq, 10))
+	buf = appendLogfmt(buf, "caller", msg.Caller)
+	buf = appendLogfmt(buf, "msg", msg.Text)
+
+	buf = appendLogfmt(buf, "subsystem", msg.Subsystem)
+	buf = appendLogfmt(buf, "device", strings.TrimPrefix(msg.Device, "+"+msg.Subsystem+":"))
+	buf = append(buf, '\n')
+	s.buf = buf
+
+	// Each field is prefixed with a blank, the first one isn't written.
+	_, err := s.w.Write(buf[1:])
+	return err
+}
+
+// appendLogfmt appends " key=value" to buf, quoting value if needed. Nothing is appended
+// for an empty value.
+func appendLogfmt(buf []byte, key, value string) []byte {
+	if value == "" {
+		return buf
+	}
+
+	buf = append(buf, ' ')
+	buf = append(buf, key...)
+	buf = append(buf, '=')
+	if strings.ContainsAny(value, " =\"\\") || strings.ContainsFunc(value, func(r rune) bool { return r < ' ' }) {
+		return strconv.AppendQuote(buf, value)
+	}
+
+	return append(buf, value...)
+}
@@ -0,0 +1,69 @@
+package dmesg
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteLogfmt(t *testing.T) {
+	wall := time.Date(2026, time.October, 14, 4, 23, 54, 0, time.UTC)
+	tests := []struct {
+		name string
+		msg  Msg
+		want string
+	}{
+		{
+			name: "timestamp",
+			msg:  Msg{Level: LevelErr, Seq: 42, TsUsec: 123456789, Caller: "T123", Text: "text here"},
+			want: `ts=123.456789 level=err facility=kern seq=42 caller=T123 msg="text here"`,
+		},
+		{
+			name: "zero timestamp",
+			msg:  Msg{Level: LevelInfo, Text: "boot"},
+			want: `ts=0.000000 level=info facility=kern seq=0 msg=boot`,
+		},
+		{
+			name: "device",
+			msg:  Msg{Level: LevelInfo, TsUsec: 1, Text: "x", Subsystem: "pci", Device: "+pci:0000:00:1f.2"},
+			want: `ts=0.000001 level=info facility=kern seq=0 msg=x subsystem=pci device=0000:00:1f.2`,
+		},
+		{
+			name: "wall time only",
+			msg:  Msg{Level: LevelWarn, TsUsec: -1, WallTime: wall, Text: "ctime"},
+			want: `time=2026-10-14T04:23:54Z level=warn facility=kern seq=0 msg=ctime`,
+		},
+		{
+			name: "no timestamp",
+			msg:  Msg{Level: LevelWarn, TsUsec: -1, Text: "none"},
+			want: `level=warn facility=kern seq=0 msg=none`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := WriteLogfmt(&b, []Msg{tt.msg}); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tt.want+"\n" {
+				t.Errorf("WriteLogfmt() = %q, want %q", got, tt.want+"\n")
+			}
+		})
+	}
+}
+
+func TestWriteLogfmtParseTextCtime(t *testing.T) {
+	msgs, err := ParseText(strings.NewReader("[Tue Oct 14 04:23:54 2026] usb 1-1: new device\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := WriteLogfmt(&b, msgs); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); strings.Contains(got, "ts=") || !strings.HasPrefix(got, "time=2026-10-14T04:23:54") {
+		t.Errorf("WriteLogfmt() = %q, want time without ts", got)
+	}
+}
@@ -0,0 +1,183 @@
+package dmesg
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MarshalRecord formats msg as a native message the way /dev/kmsg does:
+// "pri,seq,ts,flags[,caller=id];text\n" followed by " KEY=VALUE\n" device info lines.
+// The flags field is Flag, or '-' when Flag is zero. The caller field is omitted when Caller is
+// empty, device info lines are emitted with SUBSYSTEM and DEVICE first and the other keys
+// sorted, or in the order of DeviceInfoList when it's set. Non-printable characters and '\' are
+// escaped as \xNN like the kernel does, and '=' in device info keys. The priority is Priority
+// when it agrees with Level and Facility, so out-of-range priorities survive a round trip, and
+// is computed from Level and Facility otherwise.
+func MarshalRecord(msg Msg) []byte {
+	buf := make([]byte, 0, 32+len(msg.Text))
+
+	buf = strconv.AppendUint(buf, msg.priority(), 10)
+	buf = append(buf, ',')
+	buf = strconv.AppendUint(buf, msg.Seq, 10)
+	buf = append(buf, ',')
+	buf = strconv.AppendInt(buf, msg.TsUsec, 10)
+	buf = append(buf, ',')
+	if msg.Flag == 0 {
+		buf = append(buf, byte(FlagNone))
+	} else {
+		buf = append(buf, byte(msg.Flag))
+	}
+	if msg.Caller != "" {
+		buf = append(buf, ",caller="...)
+		buf = append(buf, msg.Caller...)
+	}
+	buf = append(buf, ';')
+	buf = appendEscaped(buf, msg.Text)
+	buf = append(buf, '\n')
+
+	for _, kv := range msg.deviceInfoPairs() {
+		buf = append(buf, ' ')
+		buf = appendEscapedKey(buf, kv.Key)
+		buf = append(buf, '=')
+		buf = appendEscaped(buf, kv.Value)
+		buf = append(buf, '\n')
+	}
+
+	return buf
+}
+
+// MarshalText implements encoding.TextMarshaler, formatting m as a native message like
+// MarshalRecord.
+func (m Msg) MarshalText() ([]byte, error) {
+	return MarshalRecord(m), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, parsing a native message like ParseRecord,
+// so that a Msg marshaled by MarshalText is unmarshaled back equal. Fragments, Truncated,
+// BootTime, Suspended, WallTime and BootID aren't part of a native message, they are left unset.
+func (m *Msg) UnmarshalText(text []byte) error {
+	msg, err := ParseRecord(text)
+	if err != nil {
+		return err
+	}
+	*m = msg
+
+	return nil
+}
+
+// deviceInfoKeys returns the device info keys of m in a stable order, SUBSYSTEM and DEVICE
+// first like the kernel emits them, then the others sorted. The values are looked up with
+// deviceInfo.
+func (m Msg) deviceInfoKeys() []string {
+	keys := make([]string, 0, 2+len(m.DeviceInfo))
+	for key := range m.DeviceInfo {
+		if key != "SUBSYSTEM" && key != "DEVICE" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	if _, ok := m.DeviceInfo["DEVICE"]; ok || m.Device != "" {
+		keys = append([]string{"DEVICE"}, keys...)
+	}
+	if _, ok := m.DeviceInfo["SUBSYSTEM"]; ok || m.Subsystem != "" {
+		keys = append([]string{"SUBSYSTEM"}, keys...)
+	}
+
+	return keys
+}
+
+// deviceInfo returns the device info value of key in m, Subsystem and Device for SUBSYSTEM and
+// DEVICE unless they are empty, otherwise the value in DeviceInfo.
+func (m Msg) deviceInfo(key string) string {
+	switch {
+	case key == "SUBSYSTEM" && m.Subsystem != "":
+		return m.Subsystem
+	case key == "DEVICE" && m.Device != "":
+		return m.Device
+	}
+
+	return m.DeviceInfo[key]
+}
+
+// deviceInfoPairs returns the device info of m in the order to emit it, DeviceInfoList when it's
+// set, otherwise the keys of deviceInfoKeys.
+func (m Msg) deviceInfoPairs() []KV {
+	if m.DeviceInfoList != nil {
+		return m.DeviceInfoList
+	}
+
+	keys := m.deviceInfoKeys()
+	pairs := make([]KV, len(keys))
+	for i, key := range keys {
+		pairs[i] = KV{Key: key, Value: m.deviceInfo(key)}
+	}
+
+	return pairs
+}
+
+// allDeviceInfo returns the device info of m as a single map including SUBSYSTEM and DEVICE.
+// It's DeviceInfo itself when Subsystem and Device are empty, or nil when there is none.
+func (m Msg) allDeviceInfo() map[string]string {
+	if m.Subsystem == "" && m.Device == "" {
+		return m.DeviceInfo
+	}
+
+	deviceInfo := make(map[string]string, 2+len(m.DeviceInfo))
+	for _, key := range m.deviceInfoKeys() {
+		deviceInfo[key] = m.deviceInfo(key)
+	}
+
+	return deviceInfo
+}
+
+// appendEscaped appends s to buf, escaping non-printable characters and '\' as \xNN.
+func appendEscaped(buf []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < ' ' || c >= 127 || c == '\\' {
+			buf = append(buf, '\\', 'x', hex[c>>4], hex[c&0xf])
+			continue
+		}
+		buf = append(buf, c)
+	}
+
+	return buf
+}
+
+// appendEscapedKey appends a device info key to buf like appendEscaped, also escaping '=' which
+// would end the key.
+func appendEscapedKey(buf []byte, key string) []byte {
+	for {
+		i := strings.IndexByte(key, '=')
+		if i == -1 {
+			return appendEscaped(buf, key)
+		}
+		buf = append(appendEscaped(buf, key[:i]), `\x3d`...)
+		key = key[i+1:]
+	}
+}
+
+// unescape decodes the "\xNN" escapes of the kernel in s, invalid escapes are kept as is.
+func unescape(s string) string {
+	if !strings.Contains(s, `\x`) {
+		return s
+	}
+
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			if c, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				buf = append(buf, byte(c))
+				i += 3
+				continue
+			}
+		}
+		buf = append(buf, s[i])
+	}
+
+	return string(buf)
+}
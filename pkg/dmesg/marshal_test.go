@@ -0,0 +1,134 @@
+package dmesg
+
+import (
+	"encoding"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseRecordEscapes(t *testing.T) {
+	tests := []struct {
+		name   string
+		escape string
+		want   string
+	}{
+		{"newline", `a\x0ab`, "a\nb"},
+		{"backslash", `a\x5cb`, `a\b`},
+		{"tab", `a\x09b`, "a\tb"},
+		{"high bit", `\xc3\xa9`, "é"},
+		{"uppercase hex", `\x5C`, `\`},
+		{"invalid hex", `a\xzzb`, `a\xzzb`},
+		{"short at end", `a\x4`, `a\x4`},
+		{"prefix only", `a\x`, `a\x`},
+		{"backslash without x", `a\n`, `a\n`},
+		{"escaped escape", `\x5cx41`, `\x41`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := ParseRecord([]byte("6,1,0,-;" + tt.escape + "\n KEY=" + tt.escape + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Text != tt.want || msg.DeviceInfo["KEY"] != tt.want {
+				t.Errorf("text %q, value %q, want %q", msg.Text, msg.DeviceInfo["KEY"], tt.want)
+			}
+
+			raw, _, err := parseRecord([]byte("6,1,0,-;"+tt.escape+"\n KEY="+tt.escape+"\n"), &options{rawEscapes: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if raw.Text != tt.escape || raw.DeviceInfo["KEY"] != tt.escape {
+				t.Errorf("WithRawEscapes text %q, value %q, want %q", raw.Text, raw.DeviceInfo["KEY"], tt.escape)
+			}
+		})
+	}
+}
+
+func TestMarshalRecordRoundTrip(t *testing.T) {
+	tests := []Msg{
+		{Priority: 6, Level: LevelInfo, Seq: 1, TsUsec: 1000, Flag: FlagNone, Text: "plain"},
+		{Priority: 30, Level: LevelInfo, Facility: FacilityDaemon, Seq: 2, Flag: FlagFragment, Caller: "T42", Text: "tab\there\nnewline \\ backslash \x7f é"},
+		{Priority: 3, Level: LevelErr, Seq: 3, Flag: FlagContinuation, Text: `\x41 stays escaped`},
+		{
+			Priority: 3, Level: LevelErr, Seq: 4, Flag: FlagNone, Text: "device",
+			Subsystem: "pci", Device: "+pci:0000:00:1f.2", DeviceInfo: map[string]string{"K=EY": "value=x\n"},
+			DeviceInfoList: []KV{{"SUBSYSTEM", "pci"}, {"DEVICE", "+pci:0000:00:1f.2"}, {"K=EY", "value=x\n"}},
+		},
+		{Priority: 4096, Level: LevelEmerg, Facility: 0, Seq: 5, Flag: FlagNone, Text: "out of range priority"},
+	}
+
+	for _, msg := range tests {
+		got, err := ParseRecord(MarshalRecord(msg))
+		if err != nil {
+			t.Fatalf("ParseRecord(MarshalRecord(%+v)) error = %v", msg, err)
+		}
+		if !reflect.DeepEqual(got, msg) {
+			t.Errorf("ParseRecord(MarshalRecord(%+v)) = %+v", msg, got)
+		}
+	}
+}
+
+func TestMarshalRecordEscapes(t *testing.T) {
+	msg := Msg{Level: LevelInfo, Seq: 1, Text: "a\tb\\c", DeviceInfoList: []KV{{"K=EY", "v=1"}}}
+	want := "6,1,0,-;a\\x09b\\x5cc\n K\\x3dEY=v=1\n"
+	if got := string(MarshalRecord(msg)); got != want {
+		t.Errorf("MarshalRecord() = %q, want %q", got, want)
+	}
+}
+
+// Messages of the fixtures are marshaled by MarshalText back to the records of the fixtures and
+// unmarshaled by UnmarshalText back equal, with fragments, escapes, '=' in keys and repeated
+// device info keys.
+func TestMarshalTextRoundTrip(t *testing.T) {
+	for _, fixture := range []string{"kmsg-6.1", "kmsg-6.1-caller", "kmsg-6.1-escapes", "fragment-3.10", "fragment-caller"} {
+		t.Run(fixture, func(t *testing.T) {
+			records, err := os.ReadFile(filepath.Join("testdata", fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var marshaled []byte
+			for _, msg := range readRecords(t, fixture) {
+				var m encoding.TextMarshaler = msg
+				text, err := m.MarshalText()
+				if err != nil {
+					t.Fatal(err)
+				}
+				marshaled = append(marshaled, text...)
+
+				var got Msg
+				var u encoding.TextUnmarshaler = &got
+				if err := u.UnmarshalText(text); err != nil {
+					t.Fatalf("UnmarshalText(%q) = %v", text, err)
+				}
+				if !reflect.DeepEqual(got, msg) {
+					t.Errorf("UnmarshalText(%q) = %+v, want %+v", text, got, msg)
+				}
+			}
+			if string(marshaled) != string(records) {
+				t.Errorf("MarshalText() =\n%s\nwant\n%s", marshaled, records)
+			}
+		})
+	}
+}
+
+// A message merged by MergeFragments is marshaled as one record, its Fragments are lost.
+func TestMarshalTextMerged(t *testing.T) {
+	for _, msg := range MergeFragments(readRecords(t, "fragment-caller")) {
+		text, err := msg.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Msg
+		if err := got.UnmarshalText(text); err != nil {
+			t.Fatalf("UnmarshalText(%q) = %v", text, err)
+		}
+		msg.Fragments = nil
+		if !reflect.DeepEqual(got, msg) {
+			t.Errorf("UnmarshalText(%q) = %+v, want %+v", text, got, msg)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package dmesg
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Merge merges messages of several sources into one timeline, e.g. a live read, the logs of
+// ReadPstore and a loaded Snapshot, see MergeSources.
+func Merge(sources ...[]Msg) []Msg {
+	msgs, _ := MergeSources(sources...)
+
+	return msgs
+}
+
+// MergeSources merges messages of several sources into one timeline and returns with it the
+// index in sources of the source of each message.
+//
+// Messages are grouped by the boot they were logged in, told by BootID, or by BootTime to
+// the second when BootID is empty. A message with only BootTime is of the same boot as
+// messages with BootID and the same BootTime, messages with neither are of one unknown boot.
+// The unknown boot comes first, e.g. for the logs of an earlier boot read from pstore, the
+// others follow in the order they booted. Within a boot messages are ordered by TsUsec and then
+// Seq, messages equal in both keep the order of sources and of each source. Messages of the same
+// boot with the same Seq, TsUsec and Text are duplicates, only the first one is kept.
+func MergeSources(sources ...[]Msg) ([]Msg, []int) {
+	type entry struct {
+		msg    Msg
+		source int
+		boot   int // Rank of the boot in the order of boots
+	}
+	type boot struct {
+		time time.Time // Earliest BootTime of the messages of the boot
+	}
+
+	// Boot IDs by boot time, for messages with only BootTime.
+	ids := make(map[int64]string)
+	for _, msgs := range sources {
+		for _, msg := range msgs {
+			if msg.BootID != "" && !msg.BootTime.IsZero() {
+				ids[msg.BootTime.Unix()] = msg.BootID
+			}
+		}
+	}
+	bootKey := func(msg Msg) string {
+		if msg.BootID != "" {
+			return "id:" + msg.BootID
+		}
+		if msg.BootTime.IsZero() {
+			return ""
+		}
+		if id, ok := ids[msg.BootTime.Unix()]; ok {
+			return "id:" + id
+		}
+		return "time:" + strconv.FormatInt(msg.BootTime.Unix(), 10)
+	}
+
+	var entries []entry
+	boots := make(map[string]*boot)
+	keys := make([]string, 0, 1)
+	for source, msgs := range sources {
+		for _, msg := range msgs {
+			key := bootKey(msg)
+			b, ok := boots[key]
+			if !ok {
+				b = &boot{time: msg.BootTime}
+				boots[key] = b
+				keys = append(keys, key)
+			}
+			if b.time.IsZero() || !msg.BootTime.IsZero() && msg.BootTime.Before(b.time) {
+				b.time = msg.BootTime
+			}
+
+			entries = append(entries, entry{msg: msg, source: source})
+		}
+	}
+
+	// Boots without boot time first in the order they appear, then by boot time.
+	slices.SortStableFunc(keys, func(a, b string) int {
+		ta, tb := boots[a].time, boots[b].time
+		if ta.IsZero() || tb.IsZero() {
+			return cmp.Compare(boolRank(!ta.IsZero()), boolRank(!tb.IsZero()))
+		}
+		return ta.Compare(tb)
+	})
+	rank := make(map[string]int, len(keys))
+	for i, key := range keys {
+		rank[key] = i
+	}
+	for i := range entries {
+		entries[i].boot = rank[bootKey(entries[i].msg)]
+	}
+
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		return cmp.Or(
+			cmp.Compare(a.boot, b.boot),
+			cmp.Compare(a.msg.TsUsec, b.msg.TsUsec),
+			cmp.Compare(a.msg.Seq, b.msg.Seq),
+		)
+	})
+
+	type identity struct {
+		boot   int
+		seq    uint64
+		tsUsec int64
+		text   string
+	}
+	seen := make(map[identity]bool, len(entries))
+	msgs := make([]Msg, 0, len(entries))
+	indexes := make([]int, 0, len(entries))
+	for _, e := range entries {
+		id := identity{e.boot, e.msg.Seq, e.msg.TsUsec, e.msg.Text}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		msgs = append(msgs, e.msg)
+		indexes = append(indexes, e.source)
+	}
+
+	return msgs, indexes
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,130 @@
+package dmesg
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// texts returns the texts of msgs.
+func texts(msgs []Msg) []string {
+	texts := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		texts = append(texts, msg.Text)
+	}
+
+	return texts
+}
+
+func TestMergeSources(t *testing.T) {
+	boot1 := time.Date(2026, 10, 14, 3, 0, 0, 0, time.UTC)
+	boot2 := boot1.Add(time.Hour)
+	msg := func(seq uint64, tsUsec int64, text string) Msg {
+		return Msg{Seq: seq, TsUsec: tsUsec, Text: text}
+	}
+	withBoot := func(m Msg, id string, bootTime time.Time) Msg {
+		m.BootID, m.BootTime = id, bootTime
+		return m
+	}
+
+	tests := []struct {
+		name    string
+		sources [][]Msg
+		want    []string
+		indexes []int
+	}{
+		{"none", nil, []string{}, []int{}},
+		{
+			"by time and seq",
+			[][]Msg{
+				{msg(1, 10, "a1"), msg(3, 30, "a3")},
+				{msg(2, 20, "b2"), msg(5, 30, "b5")},
+			},
+			[]string{"a1", "b2", "a3", "b5"},
+			[]int{0, 1, 0, 1},
+		},
+		// Equal in TsUsec and Seq, like messages parsed from text with Seq 0, the messages keep
+		// the order of the sources and of each source.
+		{
+			"equal keys",
+			[][]Msg{
+				{msg(0, 10, "a1"), msg(0, 10, "a2")},
+				{msg(0, 10, "b1"), msg(0, 5, "b0")},
+			},
+			[]string{"b0", "a1", "a2", "b1"},
+			[]int{1, 0, 0, 1},
+		},
+		// Duplicates are messages of the same boot with the same Seq, TsUsec and Text, the first
+		// one is kept. The same message in another boot isn't a duplicate.
+		{
+			"duplicates",
+			[][]Msg{
+				{msg(1, 10, "a"), msg(2, 20, "b")},
+				{msg(2, 20, "b"), msg(2, 20, "b'"), msg(3, 30, "c")},
+				{withBoot(msg(2, 20, "b"), "x", boot1)},
+			},
+			[]string{"a", "b", "b'", "c", "b"},
+			[]int{0, 0, 1, 1, 2},
+		},
+		// The boot of unknown boot time comes first, then the boots by boot time. A message with
+		// only BootTime is of the boot whose messages have the BootID and the same BootTime to
+		// the second.
+		{
+			"boots",
+			[][]Msg{
+				{withBoot(msg(7, 70, "2:7"), "y", boot2), withBoot(msg(1, 10, "1:1"), "x", boot1)},
+				{msg(4, 40, "?:4"), withBoot(msg(3, 30, "1:3"), "", boot1.Add(300*time.Millisecond))},
+				{withBoot(msg(2, 20, "1:2"), "x", time.Time{}), withBoot(msg(1, 10, "2:1"), "", boot2)},
+			},
+			[]string{"?:4", "1:1", "1:2", "1:3", "2:1", "2:7"},
+			[]int{1, 0, 2, 1, 2, 0},
+		},
+		// Different boot IDs are different boots even with the same boot time.
+		{
+			"boot ids",
+			[][]Msg{
+				{withBoot(msg(1, 10, "x"), "x", boot1)},
+				{withBoot(msg(1, 10, "x"), "y", boot1)},
+			},
+			[]string{"x", "x"},
+			[]int{0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs, indexes := MergeSources(tt.sources...)
+			if got := texts(msgs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeSources() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(indexes, tt.indexes) {
+				t.Errorf("MergeSources() indexes = %v, want %v", indexes, tt.indexes)
+			}
+			if got := Merge(tt.sources...); !reflect.DeepEqual(got, msgs) {
+				t.Errorf("Merge() = %q, want %q", texts(got), texts(msgs))
+			}
+		})
+	}
+}
+
+// Merging overlapping reads of a fixture gives the messages of the fixture once.
+func TestMergeOverlapping(t *testing.T) {
+	msgs := readRecords(t, "kmsg-6.1")
+	half := len(msgs) / 2
+	newer, older := slices.Clone(msgs[half-3:]), slices.Clone(msgs[:half+3])
+
+	merged, indexes := MergeSources(newer, older)
+	if !reflect.DeepEqual(seqs(merged), seqs(msgs)) {
+		t.Errorf("MergeSources() = %v, want %v", seqs(merged), seqs(msgs))
+	}
+	for i, source := range indexes {
+		want := 1
+		if i >= half-3 {
+			want = 0
+		}
+		if source != want {
+			t.Errorf("source of message %d = %d, want %d", merged[i].Seq, source, want)
+		}
+	}
+}
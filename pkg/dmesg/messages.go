@@ -0,0 +1,124 @@
+package dmesg
+
+import (
+	"io"
+	"regexp"
+	"time"
+)
+
+// Messages is a list of messages, e.g. Messages(msgs) for the messages returned by Dmesg, which
+// can be written to a destination in one call or filtered in chained calls like
+// Messages(msgs).Level(LevelErr).Subsystem("usb").Last(10). Filters return new slices and
+// leave the messages filtered unchanged, none of them returns nil.
+type Messages []Msg
+
+// Filter returns the messages for which keep returns true, e.g. a Predicate.
+func (m Messages) Filter(keep func(Msg) bool) Messages {
+	kept := Messages{}
+	for _, msg := range m {
+		if keep(msg) {
+			kept = append(kept, msg)
+		}
+	}
+
+	return kept
+}
+
+// Level returns the messages at least as severe as level, i.e. with Level <= level, like
+// WithMinLevel.
+func (m Messages) Level(level Level) Messages {
+	return m.Filter(func(msg Msg) bool {
+		return msg.Level <= level
+	})
+}
+
+// Match returns the messages whose text matches re, like WithMatch.
+func (m Messages) Match(re *regexp.Regexp) Messages {
+	return m.Filter(func(msg Msg) bool {
+		return re.MatchString(msg.Text)
+	})
+}
+
+// Between returns the messages logged from from to to since boot, both included, like WithSince
+// and WithUntil. Messages without timestamp, e.g. parsed from 'dmesg -T' output, are dropped.
+func (m Messages) Between(from, to time.Duration) Messages {
+	return m.Filter(func(msg Msg) bool {
+		return msg.TsUsec >= 0 && msg.SinceBoot() >= from && msg.SinceBoot() <= to
+	})
+}
+
+// Subsystem returns the messages whose SUBSYSTEM device info is name, like WithSubsystem.
+func (m Messages) Subsystem(name string) Messages {
+	return m.Filter(func(msg Msg) bool {
+		return msg.Subsystem != "" && msg.Subsystem == name
+	})
+}
+
+// Last returns the last n messages, or all when there are fewer, like Tail. It returns no
+// messages when n <= 0.
+func (m Messages) Last(n int) Messages {
+	if n <= 0 {
+		return Messages{}
+	}
+
+	return append(Messages{}, m[len(m)-min(n, len(m)):]...)
+}
+
+// FormatOption configures how Messages.PrintTo formats messages.
+type FormatOption func(*Formatter)
+
+// WithTimeFormat prints timestamps in format, converting them to wall clock time relative
+// to bootTime, see Formatter.
+func WithTimeFormat(format TimeFormat, bootTime time.Time) FormatOption {
+	return func(f *Formatter) {
+		f.TimeFormat = format
+		f.BootTime = bootTime
+	}
+}
+
+// WithDelta prints the time elapsed since the previous message, see Formatter.Delta.
+func WithDelta() FormatOption {
+	return func(f *Formatter) {
+		f.Delta = true
+	}
+}
+
+// WithColor colors the text of messages with palette, see Formatter.Color.
+func WithColor(palette *Palette) FormatOption {
+	return func(f *Formatter) {
+		f.Color = palette
+	}
+}
+
+// WithDecoded prefixes lines with the facility and level names, see Formatter.Decoded.
+func WithDecoded() FormatOption {
+	return func(f *Formatter) {
+		f.Decoded = true
+	}
+}
+
+// WriteTo writes the messages to w like 'dmesg', one Msg.String line for each. It implements
+// io.WriterTo, the bytes written until an error are reported along with it.
+func (m Messages) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, msg := range m {
+		n, err := io.WriteString(w, msg.String()+"\n")
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// PrintTo writes the messages to w formatted by a Formatter configured by opts, one line
+// for each.
+func (m Messages) PrintTo(w io.Writer, opts ...FormatOption) error {
+	f := &Formatter{}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f.Format(w, m)
+}
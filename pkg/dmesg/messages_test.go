@@ -0,0 +1,194 @@
+package dmesg
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMessagesFilters(t *testing.T) {
+	msgs, err := Dmesg(WithPath(writeDump(t, mixedRecords...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := Messages(msgs)
+
+	tests := []struct {
+		name string
+		got  Messages
+		want []uint64
+	}{
+		{"level", all.Level(LevelErr), []uint64{1, 3, 5, 8}},
+		{"match", all.Match(regexp.MustCompile(`^user`)), []uint64{3, 4, 8}},
+		{"between", all.Between(2*time.Second, 4*time.Second), []uint64{2, 3, 4}},
+		{"subsystem", all.Subsystem("block"), []uint64{2, 7}},
+		{"last", all.Last(2), []uint64{7, 8}},
+		{"last more than all", all.Last(100), []uint64{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"last zero", all.Last(0), []uint64{}},
+		{"chained", all.Level(LevelWarn).Subsystem("block").Last(1), []uint64{7}},
+		{"no match", all.Match(regexp.MustCompile(`nothing`)), []uint64{}},
+		{"no match chained", all.Subsystem("net").Level(LevelDebug).Last(3), []uint64{}},
+		{"empty subsystem", all.Subsystem(""), []uint64{}},
+		{"empty", Messages{}.Level(LevelDebug).Match(regexp.MustCompile(``)).Last(1), []uint64{}},
+		{"nil", Messages(nil).Between(0, time.Hour), []uint64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got == nil {
+				t.Error("filter returned nil")
+			}
+			if got := seqs(tt.got); !slices.Equal(got, tt.want) {
+				t.Errorf("seqs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The filters return new slices, the messages filtered are unchanged.
+	last := all.Last(2)
+	last[0].Text = "changed"
+	if got := seqs(all); !slices.Equal(got, []uint64{1, 2, 3, 4, 5, 6, 7, 8}) || all[6].Text == "changed" {
+		t.Errorf("messages changed by the filters: %v", got)
+	}
+}
+
+func TestMessagesBetweenWithoutTimestamp(t *testing.T) {
+	msgs := Messages{{Seq: 1, TsUsec: -1}, {Seq: 2, TsUsec: 0}}
+	if got := seqs(msgs.Between(0, time.Hour)); !slices.Equal(got, []uint64{2}) {
+		t.Errorf("seqs = %v, want [2]", got)
+	}
+}
+
+// Matcher applies the filters of a read to messages already read and keeps the same messages.
+func TestMatcherLikeRead(t *testing.T) {
+	path := writeDump(t, mixedRecords...)
+	every, err := Dmesg(WithPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filters := [][]Option{
+		nil,
+		{WithMinLevel(LevelErr)},
+		{WithLevels(LevelInfo), WithFacilities(FacilityUser, FacilityDaemon)},
+		{WithSubsystem("block"), WithExclude(regexp.MustCompile(`sda`))},
+		{WithDevice("+scsi:0:0:0:0")},
+		{WithSince(3 * time.Second), WithUntil(7 * time.Second), WithMatch(regexp.MustCompile(`err`))},
+	}
+	for _, opts := range filters {
+		read, err := Dmesg(append(slices.Clone(opts), WithPath(path))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		match, err := Matcher(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := seqs(Messages(every).Filter(match)), seqs(read); !slices.Equal(got, want) {
+			t.Errorf("Filter(Matcher()) seqs = %v, read with the options %v", got, want)
+		}
+	}
+}
+
+func TestPredicateCombinators(t *testing.T) {
+	msgs, err := Dmesg(WithPath(writeDump(t, mixedRecords...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := Messages(msgs)
+	kern, err := Matcher(WithFacilities(FacilityKern))
+	if err != nil {
+		t.Fatal(err)
+	}
+	severe, err := Matcher(WithMinLevel(LevelErr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		pred Predicate
+		want []uint64
+	}{
+		{"and", And(kern, severe), []uint64{1}},
+		{"or", Or(kern, severe), []uint64{1, 2, 3, 5, 7, 8}},
+		{"not", Not(kern), []uint64{3, 4, 5, 6, 8}},
+		{"and not", And(severe, Not(kern)), []uint64{3, 5, 8}},
+		{"not or", Not(Or(kern, severe)), []uint64{4, 6}},
+		{"empty and", And(), []uint64{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"empty or", Or(), []uint64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := seqs(all.Filter(tt.pred)); !slices.Equal(got, tt.want) {
+				t.Errorf("seqs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessagesWriteTo(t *testing.T) {
+	msgs := Messages{
+		{Level: LevelErr, TsUsec: 1_250_000, Text: "ata1: failed"},
+		{Level: LevelInfo, TsUsec: 2_000_000, Text: "done"},
+	}
+
+	var buf bytes.Buffer
+	n, err := msgs.WriteTo(&buf)
+	want := "[    1.250000] ata1: failed\n[    2.000000] done\n"
+	if err != nil || buf.String() != want || n != int64(len(want)) {
+		t.Errorf("WriteTo() = %d, %v, wrote %q, want %q", n, err, buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := msgs.PrintTo(&buf, WithDecoded(), WithTimeFormat(TimeNotime, time.Time{})); err != nil {
+		t.Fatal(err)
+	}
+	if want := "kern  :err   : ata1: failed\nkern  :info  : done\n"; buf.String() != want {
+		t.Errorf("PrintTo() wrote %q, want %q", buf.String(), want)
+	}
+}
+
+// failingWriter writes the first n bytes written to it into buf and fails after them.
+type failingWriter struct {
+	buf bytes.Buffer
+	n   int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n-w.buf.Len() {
+		p = p[:w.n-w.buf.Len()]
+		w.buf.Write(p)
+		return len(p), errWriteFailed
+	}
+
+	return w.buf.Write(p)
+}
+
+// WriteTo returns the bytes written until a write fails, along with its error.
+func TestMessagesWriteToFails(t *testing.T) {
+	msgs := Messages{
+		{Level: LevelErr, TsUsec: 1_250_000, Text: "ata1: failed"},
+		{Level: LevelInfo, TsUsec: 2_000_000, Text: "done"},
+	}
+	text := "[    1.250000] ata1: failed\n[    2.000000] done\n"
+
+	for _, limit := range []int{0, 10, len("[    1.250000] ata1: failed\n"), len(text) - 1} {
+		w := &failingWriter{n: limit}
+		n, err := msgs.WriteTo(w)
+		if !errors.Is(err, errWriteFailed) || n != int64(limit) || w.buf.String() != text[:limit] {
+			t.Errorf("WriteTo() after %d bytes = %d, %v, wrote %q", limit, n, err, w.buf.String())
+		}
+	}
+
+	w := &failingWriter{n: len(text)}
+	if n, err := msgs.WriteTo(w); err != nil || n != int64(len(text)) || w.buf.String() != text {
+		t.Errorf("WriteTo() = %d, %v, wrote %q, want %q", n, err, w.buf.String(), text)
+	}
+}
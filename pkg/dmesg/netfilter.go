@@ -0,0 +1,249 @@
+package dmesg
+
+import (
+	"net"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NFLogEvent is a packet logged by the LOG target of iptables or the log statement of nftables,
+// see ParseNetfilterLog.
+type NFLogEvent struct {
+	Seq    uint64 // Sequence number of the message
+	TsUsec int64  // Timestamp of the message
+
+	Prefix  string // Log prefix of the rule, e.g. "[UFW BLOCK]", empty if none
+	In      string // Input interface, empty for a packet sent by the host
+	Out     string // Output interface, empty for a packet received by the host
+	PhysIn  string // Bridge port the packet was received on, empty if not bridged
+	PhysOut string // Bridge port the packet is sent on, empty if not bridged
+
+	SrcMAC    net.HardwareAddr // Source MAC address, nil if not logged, e.g. for a packet sent
+	DstMAC    net.HardwareAddr // Destination MAC address, nil if not logged
+	EtherType uint16           // EtherType of the frame, e.g. 0x0800, 0 if not logged
+
+	Src   netip.Addr // Source address, IPv4 or IPv6
+	Dst   netip.Addr // Destination address
+	Len   int        // Length of the packet
+	TTL   int        // TTL of IPv4 or hop limit of IPv6
+	ID    uint32     // Identification of IPv4, of the fragment header of IPv6, 0 if not logged
+	DF    bool       // Whether the IPv4 don't fragment flag is set
+	MF    bool       // Whether more fragments follow, the MF flag of IPv4 or INCOMPLETE of IPv6
+	Frag  int        // Offset of a fragment in bytes, 0 for the first fragment or a packet not fragmented
+	Proto string     // Protocol, e.g. "TCP", "UDP", "ICMP", "ICMPv6" or the number of another one
+
+	SrcPort  uint16   // Source port of TCP, UDP, UDPLITE, SCTP or DCCP, 0 if not logged, e.g. for a fragment
+	DstPort  uint16   // Destination port
+	TCPFlags []string // Flags of TCP, e.g. ["ACK", "SYN"]
+	ICMPType int      // Type of ICMP or ICMPv6, -1 if not logged
+	ICMPCode int      // Code of ICMP or ICMPv6, -1 if not logged
+
+	UID  int    // UID of the socket of a packet sent, -1 if not logged
+	GID  int    // GID of the socket of a packet sent, -1 if not logged
+	Mark uint32 // Mark of the packet, 0 if not logged
+
+	Fields map[string]string // key=value fields as logged, the first value of keys logged twice, e.g. ID of IPv4 before ID of ICMP
+}
+
+// tcpFlags are the flags of TCP netfilter logs, in the order it logs them.
+var tcpFlags = map[string]bool{
+	"CWR": true, "ECE": true, "URG": true, "ACK": true, "PSH": true, "RST": true, "SYN": true, "FIN": true,
+}
+
+// nfLogStartRe matches the start of the fields of a packet after the prefix,
+// "IN=eth0 OUT= ". The prefix is only followed by a space if the rule ends it with one, e.g.
+// "DROPIN=eth0 OUT= " of --log-prefix DROP.
+var nfLogStartRe = regexp.MustCompile(`IN=\S* OUT=`)
+
+// ParseNetfilterLog returns the NFLogEvent of msg if it's a packet logged by netfilter, e.g.
+// "[UFW BLOCK] IN=eth0 OUT= MAC=00:11:22:33:44:55:66:77:88:99:aa:bb:08:00 SRC=10.0.0.1
+// DST=10.0.0.2 LEN=60 TOS=0x00 PREC=0x00 TTL=64 ID=54321 DF PROTO=TCP SPT=51234 DPT=22
+// WINDOW=64240 RES=0x00 SYN URGP=0". It parses IPv4 and IPv6 packets, their fragment fields,
+// "FRAG:185" of IPv4 and "FRAG:1448 INCOMPLETE ID:deadbeef" of IPv6, and skips the TCP options
+// and the packet quoted in brackets by an ICMP error. It returns false for other messages and
+// for malformed addresses or ports.
+func ParseNetfilterLog(msg Msg) (NFLogEvent, bool) {
+	loc := nfLogStartRe.FindStringIndex(msg.Text)
+	if loc == nil {
+		return NFLogEvent{}, false
+	}
+	start := loc[0]
+
+	event := NFLogEvent{
+		Seq:      msg.Seq,
+		TsUsec:   msg.TsUsec,
+		Prefix:   strings.TrimSpace(msg.Text[:start]),
+		ICMPType: -1,
+		ICMPCode: -1,
+		UID:      -1,
+		GID:      -1,
+		Fields:   make(map[string]string),
+	}
+
+	afterProto := false
+	for _, word := range nfLogWords(msg.Text[start:]) {
+		key, value, ok := strings.Cut(word, "=")
+		if !ok {
+			switch {
+			case strings.HasPrefix(word, "FRAG:"):
+				frag, _ := strconv.Atoi(word[len("FRAG:"):])
+				if event.Src.Is4() {
+					// IPv4 logs the offset in units of 8 bytes, IPv6 in bytes.
+					frag *= 8
+				}
+				event.Frag = frag
+			case strings.HasPrefix(word, "ID:"):
+				id, _ := strconv.ParseUint(word[len("ID:"):], 16, 32)
+				event.ID = uint32(id)
+			case word == "INCOMPLETE":
+				event.MF = true
+			case afterProto && event.Proto == "TCP" && tcpFlags[word]:
+				event.TCPFlags = append(event.TCPFlags, word)
+			case word == "DF":
+				event.DF = true
+			case word == "MF":
+				event.MF = true
+			}
+			continue
+		}
+		if _, ok := event.Fields[key]; ok {
+			continue
+		}
+		event.Fields[key] = value
+
+		var err error
+		switch key {
+		case "IN":
+			event.In = value
+		case "OUT":
+			event.Out = value
+		case "PHYSIN":
+			event.PhysIn = value
+		case "PHYSOUT":
+			event.PhysOut = value
+		case "MAC":
+			event.parseMAC(value)
+		case "MACSRC":
+			event.SrcMAC, _ = net.ParseMAC(value)
+		case "MACDST":
+			event.DstMAC, _ = net.ParseMAC(value)
+		case "MACPROTO":
+			etherType, _ := strconv.ParseUint(value, 16, 16)
+			event.EtherType = uint16(etherType)
+		case "SRC":
+			event.Src, err = netip.ParseAddr(value)
+		case "DST":
+			event.Dst, err = netip.ParseAddr(value)
+		case "LEN":
+			event.Len, _ = strconv.Atoi(value)
+		case "TTL", "HOPLIMIT":
+			event.TTL, _ = strconv.Atoi(value)
+		case "ID":
+			// ICMP echoes log their identifier after the protocol.
+			if !afterProto {
+				id, _ := strconv.ParseUint(value, 10, 32)
+				event.ID = uint32(id)
+			}
+		case "PROTO":
+			event.Proto, afterProto = value, true
+		case "SPT", "DPT":
+			var port uint64
+			if port, err = strconv.ParseUint(value, 10, 16); err == nil {
+				if key == "SPT" {
+					event.SrcPort = uint16(port)
+				} else {
+					event.DstPort = uint16(port)
+				}
+			}
+		case "TYPE":
+			event.ICMPType, _ = strconv.Atoi(value)
+		case "CODE":
+			event.ICMPCode, _ = strconv.Atoi(value)
+		case "UID":
+			event.UID, _ = strconv.Atoi(value)
+		case "GID":
+			event.GID, _ = strconv.Atoi(value)
+		case "MARK":
+			mark, _ := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 32)
+			event.Mark = uint32(mark)
+		}
+		if err != nil {
+			return NFLogEvent{}, false
+		}
+	}
+	if !event.Src.IsValid() || !event.Dst.IsValid() {
+		return NFLogEvent{}, false
+	}
+
+	return event, true
+}
+
+// parseMAC parses the MAC field of an Ethernet frame, the destination and source addresses and
+// the EtherType, "00:11:22:33:44:55:66:77:88:99:aa:bb:08:00". The header of other links is
+// only kept in Fields.
+func (e *NFLogEvent) parseMAC(value string) {
+	header := strings.Split(value, ":")
+	if len(header) != 14 {
+		return
+	}
+	b := make([]byte, len(header))
+	for i, octet := range header {
+		n, err := strconv.ParseUint(octet, 16, 8)
+		if err != nil {
+			return
+		}
+		b[i] = byte(n)
+	}
+
+	e.DstMAC, e.SrcMAC = net.HardwareAddr(b[0:6]), net.HardwareAddr(b[6:12])
+	e.EtherType = uint16(b[12])<<8 | uint16(b[13])
+}
+
+// nfLogWords splits the fields of a packet at spaces, skipping the groups in brackets or
+// parentheses, e.g. "OPT (020405B4)" of TCP and the packet "[SRC=... ]" of an ICMP error.
+func nfLogWords(text string) []string {
+	var words []string
+	depth, start := 0, -1
+	for i := 0; i <= len(text); i++ {
+		var c byte = ' '
+		if i < len(text) {
+			c = text[i]
+		}
+		switch {
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth = max(depth-1, 0)
+			start = -1
+			continue
+		}
+		if depth > 0 {
+			start = -1
+			continue
+		}
+		if c == ' ' {
+			if start >= 0 {
+				words = append(words, text[start:i])
+			}
+			start = -1
+		} else if start < 0 {
+			start = i
+		}
+	}
+
+	return words
+}
+
+// NFLogEvents returns the NFLogEvents of msgs, see ParseNetfilterLog.
+func NFLogEvents(msgs []Msg) []NFLogEvent {
+	var events []NFLogEvent
+	for _, msg := range msgs {
+		if event, ok := ParseNetfilterLog(msg); ok {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
@@ -0,0 +1,193 @@
+package dmesg
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// nfLog returns e logged by the message seq, with the ICMP fields of another protocol and the
+// socket of a packet without UID set to -1 as ParseNetfilterLog does.
+func nfLog(seq uint64, e NFLogEvent) NFLogEvent {
+	e.Seq = seq
+	if e.Proto != "ICMP" && e.Proto != "ICMPv6" {
+		e.ICMPType, e.ICMPCode = -1, -1
+	}
+	if e.UID == 0 {
+		e.UID, e.GID = -1, -1
+	}
+
+	return e
+}
+
+// ether returns the destination and source addresses of an Ethernet frame.
+func ether(t *testing.T, dst, src string) (net.HardwareAddr, net.HardwareAddr) {
+	t.Helper()
+	dstMAC, err := net.ParseMAC(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcMAC, err := net.ParseMAC(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dstMAC, srcMAC
+}
+
+func TestNFLogEvents(t *testing.T) {
+	ufwDst, ufwSrc := ether(t, "08:00:27:5a:1c:3e", "52:54:00:12:35:02")
+	vmDst, vmSrc := ether(t, "52:54:00:ab:cd:ef", "52:54:00:12:34:56")
+	bridgeDst, bridgeSrc := ether(t, "02:42:ac:11:00:03", "02:42:ac:11:00:02")
+	host, gateway := netip.MustParseAddr("10.0.2.15"), netip.MustParseAddr("10.0.2.2")
+	v6Src, v6Dst := netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2")
+
+	tests := []struct {
+		fixture string
+		want    []NFLogEvent
+	}{
+		// The ufw rules of Ubuntu: the fragments of a datagram, a packet sent by the host, an
+		// ICMP error quoting a packet and a SYN ACK with TCP options.
+		{"netfilter-4.15", []NFLogEvent{
+			nfLog(5120, NFLogEvent{
+				Prefix: "[UFW BLOCK]", In: "enp0s3", DstMAC: ufwDst, SrcMAC: ufwSrc, EtherType: 0x0800,
+				Src: netip.MustParseAddr("185.220.101.47"), Dst: host, Len: 40, TTL: 245, ID: 54321,
+				Proto: "TCP", SrcPort: 44530, DstPort: 3389, TCPFlags: []string{"SYN"},
+			}),
+			nfLog(5121, NFLogEvent{
+				Prefix: "[UFW BLOCK]", In: "enp0s3", DstMAC: ufwDst, SrcMAC: ufwSrc, EtherType: 0x0800,
+				Src: gateway, Dst: host, Len: 1500, TTL: 64, ID: 4242, MF: true, Proto: "UDP", SrcPort: 5353, DstPort: 5353,
+			}),
+			nfLog(5122, NFLogEvent{
+				Prefix: "[UFW BLOCK]", In: "enp0s3", DstMAC: ufwDst, SrcMAC: ufwSrc, EtherType: 0x0800,
+				Src: gateway, Dst: host, Len: 548, TTL: 64, ID: 4242, Frag: 1480, Proto: "UDP",
+			}),
+			nfLog(5123, NFLogEvent{
+				Prefix: "[UFW AUDIT]", Out: "enp0s3", Src: host, Dst: netip.MustParseAddr("91.189.91.157"), Len: 76, TTL: 64, ID: 33117, DF: true,
+				Proto: "UDP", SrcPort: 37610, DstPort: 123, UID: 107, GID: 112,
+			}),
+			nfLog(5124, NFLogEvent{
+				Prefix: "[UFW BLOCK]", In: "enp0s3", DstMAC: ufwDst, SrcMAC: ufwSrc, EtherType: 0x0800,
+				Src: gateway, Dst: host, Len: 88, TTL: 64, ID: 2290, Proto: "ICMP", ICMPType: 3, ICMPCode: 3,
+			}),
+			nfLog(5126, NFLogEvent{
+				Prefix: "[UFW BLOCK]", In: "enp0s3", DstMAC: ufwDst, SrcMAC: ufwSrc, EtherType: 0x0800,
+				Src: gateway, Dst: host, Len: 60, TTL: 64, DF: true, Proto: "TCP", SrcPort: 22, DstPort: 49822, TCPFlags: []string{"ACK", "SYN"},
+			}),
+		}},
+		// The log statement of nftables: the fragments of an IPv6 datagram, echoes whose ID
+		// isn't the one of the packet, a prefix without a space, a bridged packet and a packet
+		// sent with its socket.
+		{"netfilter-6.1", []NFLogEvent{
+			nfLog(88104, NFLogEvent{
+				Prefix: "nft drop in:", In: "eth0", DstMAC: vmDst, SrcMAC: vmSrc, EtherType: 0x86dd,
+				Src: v6Src, Dst: v6Dst, Len: 1456, TTL: 64, ID: 0x6c0f3ab2, MF: true, Proto: "UDP", SrcPort: 40002, DstPort: 4789,
+			}),
+			nfLog(88105, NFLogEvent{
+				Prefix: "nft drop in:", In: "eth0", DstMAC: vmDst, SrcMAC: vmSrc, EtherType: 0x86dd,
+				Src: v6Src, Dst: v6Dst, Len: 152, TTL: 64, ID: 0x6c0f3ab2, Frag: 1448, Proto: "UDP",
+			}),
+			nfLog(88106, NFLogEvent{
+				Prefix: "nft-ping:", In: "eth0", DstMAC: vmDst, SrcMAC: vmSrc, EtherType: 0x86dd,
+				Src: netip.MustParseAddr("fe80::5054:ff:fe12:3456"), Dst: netip.MustParseAddr("fe80::5054:ff:feab:cdef"), Len: 104, TTL: 64,
+				Proto: "ICMPv6", ICMPType: 128, ICMPCode: 0,
+			}),
+			nfLog(88107, NFLogEvent{
+				Prefix: "nft-ping:", In: "eth0", DstMAC: vmDst, SrcMAC: vmSrc, EtherType: 0x0800,
+				Src: netip.MustParseAddr("192.168.122.1"), Dst: netip.MustParseAddr("192.168.122.50"), Len: 84, TTL: 64, ID: 28121, DF: true,
+				Proto: "ICMP", ICMPType: 8, ICMPCode: 0, Mark: 0x2a,
+			}),
+			nfLog(88108, NFLogEvent{
+				Prefix: "fwd bridged:", In: "br0", Out: "br0", PhysIn: "veth1a2b3c", PhysOut: "vethd4e5f6",
+				DstMAC: bridgeDst, SrcMAC: bridgeSrc, EtherType: 0x0800,
+				Src: netip.MustParseAddr("172.17.0.2"), Dst: netip.MustParseAddr("172.17.0.3"), Len: 60, TTL: 64, ID: 40417, DF: true,
+				Proto: "TCP", SrcPort: 39448, DstPort: 5432, TCPFlags: []string{"SYN"},
+			}),
+			nfLog(88110, NFLogEvent{
+				Prefix: "nft out:", Out: "wg0", Src: netip.MustParseAddr("10.8.0.2"), Dst: netip.MustParseAddr("10.8.0.1"), Len: 52, TTL: 64, DF: true,
+				Proto: "TCP", SrcPort: 51520, DstPort: 443, TCPFlags: []string{"ACK", "FIN"}, UID: 1000, GID: 1000,
+			}),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			msgs := readRecords(t, tt.fixture)
+			checkNFLogEvents(t, msgs, NFLogEvents(msgs), tt.want)
+		})
+	}
+}
+
+// testdata/netfilter-2.6.32.txt is the text of dmesg of linux 2.6.32 with the LOG targets of
+// iptables, one of them with a prefix without a space, and of ip6tables.
+func TestNFLogEventsText(t *testing.T) {
+	file, err := os.Open("testdata/netfilter-2.6.32.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	msgs, err := ParseText(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst, src := ether(t, "00:50:56:9a:3b:11", "00:1b:17:00:01:13")
+	mcast, _ := ether(t, "33:33:ff:12:34:56", "00:1b:17:00:01:13")
+	host := netip.MustParseAddr("192.0.2.10")
+	checkNFLogEvents(t, msgs, NFLogEvents(msgs), []NFLogEvent{
+		nfLog(0, NFLogEvent{
+			Prefix: "IPTables-Dropped:", In: "eth0", DstMAC: dst, SrcMAC: src, EtherType: 0x0800,
+			Src: netip.MustParseAddr("203.0.113.77"), Dst: host, Len: 48, TTL: 113, ID: 25211, DF: true,
+			Proto: "TCP", SrcPort: 3125, DstPort: 445, TCPFlags: []string{"SYN"},
+		}),
+		nfLog(0, NFLogEvent{
+			Prefix: "DROP", In: "eth0", DstMAC: dst, SrcMAC: src, EtherType: 0x0800,
+			Src: netip.MustParseAddr("198.51.100.23"), Dst: host, Len: 78, TTL: 119, ID: 1288, Proto: "UDP", SrcPort: 137, DstPort: 137,
+		}),
+		nfLog(0, NFLogEvent{
+			Prefix: "ip6tables:", In: "eth0", DstMAC: mcast, SrcMAC: src, EtherType: 0x86dd,
+			Src: netip.MustParseAddr("fe80::21b:17ff:fe00:113"), Dst: netip.MustParseAddr("ff02::1:ff12:3456"), Len: 72, TTL: 255,
+			Proto: "ICMPv6", ICMPType: 135, ICMPCode: 0,
+		}),
+	})
+}
+
+func TestParseNetfilterLogMalformed(t *testing.T) {
+	for _, text := range []string{
+		"IN=eth0 OUT= SRC=10.0.0.1 LEN=60 PROTO=TCP",
+		"IN=eth0 OUT= SRC=10.0.0.300 DST=10.0.0.2 LEN=60 PROTO=TCP",
+		"IN=eth0 OUT= SRC=10.0.0.1 DST=10.0.0.2 LEN=60 PROTO=UDP SPT=70000 DPT=53",
+		"IN=eth0 OUT= ARP HTYPE=1 PTYPE=0x0800 OPCODE=1",
+		"IN=eth0",
+	} {
+		if event, ok := ParseNetfilterLog(Msg{Text: text}); ok {
+			t.Errorf("ParseNetfilterLog(%q) = %+v, want false", text, event)
+		}
+	}
+}
+
+func checkNFLogEvents(t *testing.T, msgs []Msg, events []NFLogEvent, want []NFLogEvent) {
+	t.Helper()
+	if len(events) != len(want) {
+		t.Fatalf("NFLogEvents() = %d events, want %d", len(events), len(want))
+	}
+
+	for i, event := range events {
+		w := want[i]
+		// Every message of a text fixture, whose messages have no sequence numbers, is a packet.
+		w.TsUsec = msgs[i].TsUsec
+		if msgs[0].Seq != 0 {
+			w.TsUsec = msgs[w.Seq-msgs[0].Seq].TsUsec
+		}
+		// The fields of the packet quoted by an ICMP error are skipped.
+		if src, ok := event.Fields["SRC"]; !ok || netip.MustParseAddr(src) != w.Src || event.Fields["PROTO"] != w.Proto {
+			t.Errorf("event %d fields = %v", i, event.Fields)
+		}
+		event.Fields = nil
+		if !reflect.DeepEqual(event, w) {
+			t.Errorf("event %d =\n%+v\nwant\n%+v", i, event, w)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package dmesg
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxReportMsgs is the most messages kept of a report spanning several messages, e.g. the task
+// dump of an OOM report on a system with many tasks.
+const maxReportMsgs = 4096
+
+// OOMEvent is a process killed by the OOM killer, parsed from the report the kernel logs over
+// several messages, see OOMDetector.
+type OOMEvent struct {
+	Seq    uint64 // Sequence number of the "Killed process" message
+	TsUsec int64  // Timestamp of the "Killed process" message
+
+	Trigger string // Command which invoked the OOM killer, e.g. "stress"
+	GFPMask string // Allocation flags of the trigger, e.g. "0xcc0(GFP_KERNEL)"
+	Order   int    // Allocation order of the trigger
+
+	Constraint string // e.g. "CONSTRAINT_NONE" or "CONSTRAINT_MEMCG", empty if not logged
+	MemCG      string // Memory cgroup out of memory, e.g. "/system.slice/foo.service", empty for a global OOM
+	TaskMemCG  string // Memory cgroup of the victim, empty if not logged
+
+	PID      int    // PID of the victim
+	Comm     string // Command of the victim
+	UID      int    // UID of the victim, -1 if not logged
+	TotalVM  uint64 // total-vm of the victim in bytes
+	AnonRSS  uint64 // anon-rss of the victim in bytes
+	FileRSS  uint64 // file-rss of the victim in bytes
+	ShmemRSS uint64 // shmem-rss of the victim in bytes
+
+	Msgs []Msg // Messages of the report, from "invoked oom-killer" to "Killed process"
+}
+
+var (
+	// "stress invoked oom-killer: gfp_mask=0xcc0(GFP_KERNEL), order=0, oom_score_adj=0", with
+	// "nodemask=(null), " before order in kernels 4.x.
+	oomInvokedRe = regexp.MustCompile(`^(.+) invoked oom-killer: gfp_mask=(0x[0-9a-f]+(?:\([^)]*\))?),(?: nodemask=[^,]*,)? order=(-?\d+)`)
+	// "Out of memory: Killed process 1234 (stress) total-vm:8192kB, anon-rss:4096kB,
+	// file-rss:0kB, shmem-rss:0kB, UID:0 pgtables:40kB oom_score_adj:0", "Killed process" on its
+	// own line in kernels before 4.19 and without shmem-rss before 4.6.
+	oomKilledRe = regexp.MustCompile(`^(?:(Memory cgroup out of memory|Out of memory[^:]*): )?Killed process (\d+) \((.*)\) total-vm:(\d+)kB, anon-rss:(\d+)kB, file-rss:(\d+)kB(?:, shmem-rss:(\d+)kB)?(?:, UID:(\d+))?`)
+	// "Task in /foo killed as a result of limit of /foo" of kernels before 4.19.
+	oomTaskInRe = regexp.MustCompile(`^Task in (\S+) killed as a result of limit of (\S+)`)
+)
+
+// OOMDetector groups the messages of OOM reports into OOMEvents. It's fed messages in the order
+// they were logged, from a snapshot or Follow. A report starts with "invoked oom-killer" and
+// ends with the "Killed process" message, the summary "oom-kill:constraint=..." of kernels since
+// 4.19 and the "Task in ... killed as a result of limit of" of older kernels fill the cgroups
+// and constraint. When messages have callers, only the ones of the caller of the report are
+// part of it. The zero value is ready to use, it isn't safe for concurrent use.
+type OOMDetector struct {
+	event     OOMEvent
+	reporting bool   // Whether a report started
+	caller    string // Caller of the report
+}
+
+// Observe adds msg to the report in progress and returns the OOMEvent when msg ends it. A
+// "Killed process" message without report is an event of its own.
+func (d *OOMDetector) Observe(msg Msg) (OOMEvent, bool) {
+	if m := oomInvokedRe.FindStringSubmatch(msg.Text); m != nil {
+		order, _ := strconv.Atoi(m[3])
+		d.event = OOMEvent{Trigger: m[1], GFPMask: m[2], Order: order, UID: -1}
+		d.reporting, d.caller = true, msg.Caller
+	}
+	if d.reporting && d.caller != "" && msg.Caller != "" && msg.Caller != d.caller {
+		return OOMEvent{}, false
+	}
+
+	if m := oomKilledRe.FindStringSubmatch(msg.Text); m != nil {
+		event := d.event
+		if !d.reporting {
+			event = OOMEvent{UID: -1}
+		}
+		if len(event.Msgs) < maxReportMsgs {
+			event.Msgs = append(event.Msgs, msg)
+		}
+		d.event, d.reporting = OOMEvent{}, false
+
+		event.Seq, event.TsUsec = msg.Seq, msg.TsUsec
+		event.PID, _ = strconv.Atoi(m[2])
+		event.Comm = m[3]
+		event.TotalVM = parseKB(m[4])
+		event.AnonRSS = parseKB(m[5])
+		event.FileRSS = parseKB(m[6])
+		event.ShmemRSS = parseKB(m[7])
+		if m[8] != "" {
+			event.UID, _ = strconv.Atoi(m[8])
+		}
+		if m[1] == "Memory cgroup out of memory" && event.Constraint == "" {
+			event.Constraint = "CONSTRAINT_MEMCG"
+		}
+
+		return event, true
+	}
+	if !d.reporting {
+		return OOMEvent{}, false
+	}
+
+	if len(d.event.Msgs) < maxReportMsgs {
+		d.event.Msgs = append(d.event.Msgs, msg)
+	}
+	if summary, ok := strings.CutPrefix(msg.Text, "oom-kill:"); ok {
+		d.event.parseSummary(summary)
+	} else if m := oomTaskInRe.FindStringSubmatch(msg.Text); m != nil {
+		d.event.TaskMemCG, d.event.MemCG = m[1], m[2]
+	} else if strings.HasPrefix(msg.Text, "Memory cgroup out of memory: Kill process") {
+		d.event.Constraint = "CONSTRAINT_MEMCG"
+	}
+
+	return OOMEvent{}, false
+}
+
+// parseSummary parses the fields of the "oom-kill:" summary, e.g.
+// "constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=/,mems_allowed=0-1,oom_memcg=/foo,
+// task_memcg=/foo,task=stress,pid=1234,uid=0". Node lists may contain commas, a global OOM
+// has the flag "global_oom" instead of oom_memcg.
+func (e *OOMEvent) parseSummary(summary string) {
+	fields := make(map[string]string)
+	var last string
+	for _, field := range strings.Split(summary, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			if last != "" && strings.Trim(field, "0123456789-") == "" {
+				fields[last] += "," + field
+			}
+			continue
+		}
+		fields[key], last = value, key
+	}
+
+	e.Constraint = fields["constraint"]
+	e.MemCG = fields["oom_memcg"]
+	e.TaskMemCG = fields["task_memcg"]
+	if uid, err := strconv.Atoi(fields["uid"]); err == nil {
+		e.UID = uid
+	}
+}
+
+// parseKB parses a size in kB of the kernel into bytes, 0 if empty.
+func parseKB(s string) uint64 {
+	kb, _ := strconv.ParseUint(s, 10, 64)
+
+	return kb * 1024
+}
+
+// OOMEvents returns the OOMEvents of msgs, see OOMDetector.
+func OOMEvents(msgs []Msg) []OOMEvent {
+	var d OOMDetector
+	var events []OOMEvent
+	for _, msg := range msgs {
+		if event, ok := d.Observe(msg); ok {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
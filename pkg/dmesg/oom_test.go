@@ -0,0 +1,99 @@
+package dmesg
+
+import (
+	"reflect"
+	"testing"
+)
+
+// The OOM reports of the fixtures are the ones of a memcg OOM of linux 4.14, logging the
+// cgroups in "Task in ... killed as a result of limit of", and of a global and a memcg OOM of
+// linux 6.1, logging them in the "oom-kill:" summary.
+func TestOOMEvents(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    OOMEvent
+		first   uint64 // Sequence number of the first message of the report
+		msgs    int
+	}{
+		{
+			fixture: "oom-4.14",
+			want: OOMEvent{
+				Seq: 1870, Trigger: "stress", GFPMask: "0x14000c0(GFP_KERNEL)",
+				Constraint: "CONSTRAINT_MEMCG", MemCG: "/user.slice/user-1000.slice",
+				TaskMemCG: "/user.slice/user-1000.slice/session-3.scope",
+				PID:       23190, Comm: "stress", UID: -1,
+				TotalVM: 540184 << 10, AnonRSS: 520372 << 10, FileRSS: 1288 << 10,
+			},
+			first: 1841, msgs: 30,
+		},
+		{
+			fixture: "oom-6.1",
+			want: OOMEvent{
+				Seq: 2237, Trigger: "stress", GFPMask: "0x140dca(GFP_HIGHUSER_MOVABLE|__GFP_COMP|__GFP_ZERO)",
+				Constraint: "CONSTRAINT_NONE", TaskMemCG: "/user.slice/user-1000.slice/session-2.scope",
+				PID: 4021, Comm: "stress", UID: 1000,
+				TotalVM: 8396900 << 10, AnonRSS: 7939316 << 10,
+			},
+			// The message of another task logged during the report isn't part of it.
+			first: 2203, msgs: 34,
+		},
+		{
+			fixture: "oom-6.1-memcg",
+			want: OOMEvent{
+				Seq: 942, Trigger: "java", GFPMask: "0xcc0(GFP_KERNEL)",
+				Constraint: "CONSTRAINT_MEMCG",
+				MemCG:      "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod5f0c1b7e.slice",
+				TaskMemCG:  "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod5f0c1b7e.slice/cri-containerd-3b9f0e.scope",
+				PID:        8812, Comm: "java", UID: 0,
+				TotalVM: 4409672 << 10, AnonRSS: 2081692 << 10, FileRSS: 1084 << 10,
+			},
+			first: 918, msgs: 25,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			msgs := readRecords(t, tt.fixture)
+			events := OOMEvents(msgs)
+			if len(events) != 1 {
+				t.Fatalf("OOMEvents() = %d events, want 1", len(events))
+			}
+
+			event := events[0]
+			tt.want.TsUsec = msgs[tt.want.Seq-msgs[0].Seq].TsUsec
+			if got := event.Msgs; len(got) != tt.msgs || got[0].Seq != tt.first || got[len(got)-1].Seq != tt.want.Seq {
+				t.Errorf("report of %d messages from %d to %d, want %d from %d to %d",
+					len(got), got[0].Seq, got[len(got)-1].Seq, tt.msgs, tt.first, tt.want.Seq)
+			}
+			for _, msg := range event.Msgs {
+				if msg.Caller != event.Msgs[0].Caller {
+					t.Errorf("message %d of caller %s in the report of %s", msg.Seq, msg.Caller, event.Msgs[0].Caller)
+				}
+			}
+			event.Msgs = nil
+			if !reflect.DeepEqual(event, tt.want) {
+				t.Errorf("OOMEvents() =\n%+v\nwant\n%+v", event, tt.want)
+			}
+		})
+	}
+}
+
+func TestOOMEventsWithoutReport(t *testing.T) {
+	// The report is cut off, e.g. by the start of the buffer.
+	msgs := readRecords(t, "oom-4.14")[27:]
+	events := OOMEvents(msgs)
+	if len(events) != 1 {
+		t.Fatalf("OOMEvents() = %d events, want 1", len(events))
+	}
+	if event := events[0]; event.PID != 23190 || event.Trigger != "" || event.UID != -1 || len(event.Msgs) != 1 {
+		t.Errorf("OOMEvents() = %+v, want the killed process only", event)
+	}
+}
+
+func TestOOMSummaryNodeList(t *testing.T) {
+	var e OOMEvent
+	e.parseSummary("constraint=CONSTRAINT_CPUSET,nodemask=0-1,3,cpuset=/batch,mems_allowed=0-1,3,global_oom,task_memcg=/batch,task=make,pid=7,uid=1000")
+	if e.Constraint != "CONSTRAINT_CPUSET" || e.MemCG != "" || e.TaskMemCG != "/batch" || e.UID != 1000 {
+		t.Errorf("parseSummary() = %+v", e)
+	}
+}
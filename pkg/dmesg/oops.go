@@ -0,0 +1,224 @@
+package dmesg
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportGap is the longest time between two messages of a report, a message later than that
+// isn't part of it. The kernel logs a report at once, its messages are microseconds apart.
+const reportGap = time.Second
+
+// OopsKind is the kind of report of an OopsEvent.
+type OopsKind uint8
+
+const (
+	OopsKindOops      OopsKind = iota // Oops, e.g. "Oops: 0000 [#1] SMP PTI" or "Unable to handle kernel paging request"
+	OopsKindBug                       // BUG, e.g. "BUG: kernel NULL pointer dereference" or "kernel BUG at mm/slub.c:123!"
+	OopsKindGPF                       // General protection fault, "general protection fault: 0000 [#1] SMP"
+	OopsKindPanic                     // Panic, "Kernel panic - not syncing: Fatal exception"
+	OopsKindWarning                   // Warning, "WARNING: CPU: 0 PID: 1 at kernel/fork.c:123"
+	OopsKindCallTrace                 // Call trace without report, e.g. of dump_stack
+)
+
+var oopsKindNames = [...]string{
+	OopsKindOops:      "oops",
+	OopsKindBug:       "bug",
+	OopsKindGPF:       "general protection fault",
+	OopsKindPanic:     "panic",
+	OopsKindWarning:   "warning",
+	OopsKindCallTrace: "call trace",
+}
+
+func (k OopsKind) String() string {
+	if int(k) < len(oopsKindNames) {
+		return oopsKindNames[k]
+	}
+
+	return "oopskind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// OopsEvent is a report of the kernel about an error of its own, an oops, BUG, panic or warning,
+// grouped from the messages it spans, see OopsDetector.
+type OopsEvent struct {
+	Kind      OopsKind
+	Title     string // Text of the first message, e.g. "BUG: kernel NULL pointer dereference, address: 0000000000000000"
+	Seq       uint64 // Sequence number of the first message
+	TsUsec    int64  // Timestamp of the first message
+	EndTsUsec int64  // Timestamp of the last message
+
+	PC      string // Faulting instruction, e.g. "my_init+0x5/0x1000" of "RIP: 0010:my_init+0x5/0x1000 [oops]"
+	Symbol  string // Function of PC, e.g. "my_init", empty if PC is an address
+	Module  string // Module of PC, e.g. "oops", empty if in the kernel
+	CPU     int    // CPU of the "CPU: 2 PID: 1234 Comm: insmod" line, -1 if not logged
+	PID     int    // PID of the current task, -1 if not logged
+	Comm    string // Command of the current task
+	Tainted string // Taint flags, e.g. "G           O" of "Tainted: G           O", empty if not tainted
+	Taint   Taint  // Tainted parsed, the known flags of it
+
+	Msgs []Msg // Messages of the report
+}
+
+var (
+	// "RIP: 0010:my_init+0x5/0x1000 [oops]", "RIP: 0010:[<ffffffffa0000005>]  [<ffffffffa0000005>]
+	// my_init+0x5/0x1000 [oops]" of kernels 3.x, "IP: my_init+0x5/0x1000 [oops]" of 4.x, and
+	// "pc : my_init+0x10/0x20 [oops]" or "PC is at my_init+0x10/0x20 [oops]" of arm64.
+	oopsPCRe = regexp.MustCompile(`^(?:R?IP: (?:[0-9a-f]{4}:)?(?:\[<[0-9a-f]+>\]\s*)*|pc : |PC is at )(\S+)(?: \[(\S+)\])?`)
+	// "CPU: 2 PID: 1234 Comm: insmod Tainted: G           O      5.15.0 #1", with "UID: 0 " before
+	// PID in kernels since 6.11.
+	oopsCPURe = regexp.MustCompile(`^CPU: (\d+) (?:UID: \d+ )?PID: (\d+) Comm: (.+?) (?:Not tainted|Tainted: ([A-Z ]*[A-Z]))`)
+)
+
+// OopsDetector groups the messages of reports of the kernel about its own errors into
+// OopsEvents. It's fed messages in the order they were logged, from a snapshot or Follow. A
+// report starts with a marker like "Oops:", "BUG:", "Kernel panic - not syncing",
+// "general protection fault", "WARNING:" or with "Call Trace:" of dump_stack, and ends with the
+// "---[ end trace ... ]---" marker, the start of another report, or a message more than a second
+// later. When messages have callers, only the ones of the caller of the report are part of it.
+// The zero value is ready to use, it isn't safe for concurrent use.
+type OopsDetector struct {
+	event   OopsEvent
+	open    bool  // Whether a report started
+	cutOnly bool  // Whether the report is only the "cut here" line of a WARN or BUG so far
+	last    int64 // Timestamp of the last message of the report
+}
+
+// Observe adds msg to the report in progress and returns the OopsEvent of a report ended by
+// msg, either by its end marker or by msg starting another report.
+func (d *OopsDetector) Observe(msg Msg) (OopsEvent, bool) {
+	var done OopsEvent
+	var ok bool
+
+	if d.open && msg.TsUsec-d.last > reportGap.Microseconds() {
+		done, ok = d.Flush()
+	}
+	kind, start := d.starts(msg.Text)
+	if d.open && start {
+		done, ok = d.Flush()
+	}
+	if !d.open {
+		if !start {
+			return done, ok
+		}
+		d.event = OopsEvent{Kind: kind, Seq: msg.Seq, TsUsec: msg.TsUsec, CPU: -1, PID: -1}
+		d.open = true
+		d.cutOnly = msg.Text == cutHere
+	} else if d.event.Msgs[0].Caller != "" && msg.Caller != "" && msg.Caller != d.event.Msgs[0].Caller {
+		return done, ok
+	}
+
+	d.add(msg, kind)
+	if strings.HasPrefix(msg.Text, "---[ end ") {
+		return d.Flush()
+	}
+
+	return done, ok
+}
+
+// Flush returns the OopsEvent of the report in progress, if any, and ends it, e.g. at the end
+// of a snapshot or when Follow stops.
+func (d *OopsDetector) Flush() (OopsEvent, bool) {
+	if !d.open {
+		return OopsEvent{}, false
+	}
+
+	event := d.event
+	d.event, d.open, d.cutOnly = OopsEvent{}, false, false
+
+	return event, true
+}
+
+// cutHere is the line the kernel logs before the report of a WARN or BUG.
+const cutHere = "------------[ cut here ]------------"
+
+// starts returns the kind of report text starts, if any. Markers which the kernel logs within
+// a report, e.g. "Oops:" after "BUG: unable to handle page fault", continue the report in
+// progress.
+func (d *OopsDetector) starts(text string) (OopsKind, bool) {
+	switch {
+	case text == cutHere:
+		return OopsKindWarning, true
+	case strings.HasPrefix(text, "BUG: "):
+		return OopsKindBug, true
+	case strings.HasPrefix(text, "Unable to handle kernel "):
+		return OopsKindOops, true
+	case strings.HasPrefix(text, "general protection fault"):
+		return OopsKindGPF, true
+	case strings.HasPrefix(text, "Kernel panic - not syncing"):
+		return OopsKindPanic, true
+	case strings.HasPrefix(text, "kernel BUG at "):
+		return OopsKindBug, !d.cutOnly
+	case strings.HasPrefix(text, "WARNING: "):
+		return OopsKindWarning, !d.cutOnly
+	case strings.HasPrefix(text, "Oops: ") || strings.HasPrefix(text, "Internal error: "):
+		return OopsKindOops, !d.open
+	case strings.HasPrefix(text, "Call Trace:") || strings.HasPrefix(text, "Call trace:"):
+		return OopsKindCallTrace, !d.open
+	}
+
+	return 0, false
+}
+
+// add adds msg to the report in progress and parses the fields it logs. kind is the kind of
+// the marker of msg, the kind of a report of only the "cut here" line so far.
+func (d *OopsDetector) add(msg Msg, kind OopsKind) {
+	e := &d.event
+	if len(e.Msgs) < maxReportMsgs {
+		e.Msgs = append(e.Msgs, msg)
+	}
+	e.EndTsUsec = msg.TsUsec
+	d.last = msg.TsUsec
+
+	if msg.Text == cutHere {
+		return
+	}
+	if d.cutOnly {
+		e.Kind = kind
+		d.cutOnly = false
+	}
+	if e.Title == "" {
+		e.Title = msg.Text
+	}
+
+	if e.PC == "" {
+		if m := oopsPCRe.FindStringSubmatch(msg.Text); m != nil {
+			e.PC, e.Module = m[1], m[2]
+			if !strings.HasPrefix(e.PC, "0x") {
+				e.Symbol, _, _ = strings.Cut(e.PC, "+")
+			}
+			return
+		}
+	}
+	if e.PID == -1 {
+		if m := oopsCPURe.FindStringSubmatch(msg.Text); m != nil {
+			e.CPU, _ = strconv.Atoi(m[1])
+			e.PID, _ = strconv.Atoi(m[2])
+			e.Comm, e.Tainted = m[3], m[4]
+			e.Taint, _ = ParseTaintFlags(e.Tainted)
+			return
+		}
+	}
+	// Kernels since 6.10 log the taint flags on a line of their own, "Tainted: [W]=WARN".
+	if tainted, ok := strings.CutPrefix(msg.Text, "Tainted: "); ok && e.Tainted == "" {
+		e.Tainted = tainted
+		e.Taint, _ = ParseTaintFlags(tainted)
+	}
+}
+
+// OopsEvents returns the OopsEvents of msgs, see OopsDetector.
+func OopsEvents(msgs []Msg) []OopsEvent {
+	var d OopsDetector
+	var events []OopsEvent
+	for _, msg := range msgs {
+		if event, ok := d.Observe(msg); ok {
+			events = append(events, event)
+		}
+	}
+	if event, ok := d.Flush(); ok {
+		events = append(events, event)
+	}
+
+	return events
+}
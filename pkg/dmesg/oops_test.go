@@ -0,0 +1,117 @@
+package dmesg
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testdata/oops-5.15-x86_64 has a warning, then an oops of linux 5.15 on x86_64 followed by the
+// registers logged again and the panic of panic_on_oops. testdata/oops-6.1-arm64 has an oops of
+// linux 6.1 on arm64, with a message of another task logged during the report.
+func TestOopsEvents(t *testing.T) {
+	type report struct {
+		event OopsEvent
+		last  uint64 // Sequence number of the last message of the report
+		msgs  int
+	}
+	tests := []struct {
+		fixture string
+		want    []report
+	}{
+		{"oops-5.15-x86_64", []report{
+			{OopsEvent{
+				Kind: OopsKindWarning, Seq: 1422,
+				Title: "WARNING: CPU: 5 PID: 611 at drivers/gpu/drm/i915/display/intel_display_power.c:2245 intel_power_domains_verify_state+0x1ab/0x220 [i915]",
+				PC:    "intel_power_domains_verify_state+0x1ab/0x220", Symbol: "intel_power_domains_verify_state", Module: "i915",
+				CPU: 5, PID: 611, Comm: "kworker/5:2",
+			}, 1439, 18},
+			{OopsEvent{
+				Kind: OopsKindBug, Seq: 1440,
+				Title: "BUG: kernel NULL pointer dereference, address: 0000000000000008",
+				PC:    "oops_init+0x15/0x1000", Symbol: "oops_init", Module: "oops",
+				CPU: 2, PID: 2804, Comm: "insmod",
+				Tainted: "G        W  OE", Taint: TaintWarn | TaintOOTModule | TaintUnsignedModule,
+			}, 1469, 30},
+			{OopsEvent{
+				Kind: OopsKindPanic, Seq: 1473, Title: "Kernel panic - not syncing: Fatal exception",
+				CPU: -1, PID: -1,
+			}, 1475, 3},
+		}},
+		{"oops-6.1-arm64", []report{
+			{OopsEvent{
+				Kind: OopsKindOops, Seq: 655,
+				Title: "Unable to handle kernel NULL pointer dereference at virtual address 0000000000000000",
+				PC:    "oops_init+0x1c/0x1000", Symbol: "oops_init", Module: "oops",
+				CPU: 2, PID: 1203, Comm: "insmod",
+				Tainted: "G           O", Taint: TaintOOTModule,
+			}, 692, 37},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			msgs := readRecords(t, tt.fixture)
+			events := OopsEvents(msgs)
+			if len(events) != len(tt.want) {
+				t.Fatalf("OopsEvents() = %d events, want %d", len(events), len(tt.want))
+			}
+
+			for i, event := range events {
+				want := tt.want[i]
+				want.event.TsUsec = msgs[want.event.Seq-msgs[0].Seq].TsUsec
+				want.event.EndTsUsec = msgs[want.last-msgs[0].Seq].TsUsec
+				if got := event.Msgs; len(got) != want.msgs || got[0].Seq != want.event.Seq || got[len(got)-1].Seq != want.last {
+					t.Errorf("report %d of %d messages from %d to %d, want %d from %d to %d", i,
+						len(got), got[0].Seq, got[len(got)-1].Seq, want.msgs, want.event.Seq, want.last)
+				}
+				for _, msg := range event.Msgs {
+					if msg.Caller != event.Msgs[0].Caller {
+						t.Errorf("message %d of caller %s in the report of %s", msg.Seq, msg.Caller, event.Msgs[0].Caller)
+					}
+				}
+				event.Msgs = nil
+				if !reflect.DeepEqual(event, want.event) {
+					t.Errorf("report %d =\n%+v\nwant\n%+v", i, event, want.event)
+				}
+			}
+		})
+	}
+}
+
+func TestOopsDetectorReportGap(t *testing.T) {
+	msgs := readRecords(t, "oops-6.1-arm64")
+
+	// The report is cut off, e.g. by a reboot, the next message is more than a second later.
+	var d OopsDetector
+	for _, msg := range msgs[:20] {
+		if event, ok := d.Observe(msg); ok {
+			t.Fatalf("Observe() of message %d ended the report %q", msg.Seq, event.Title)
+		}
+	}
+	later := Msg{Seq: 700, TsUsec: msgs[19].TsUsec + 1_000_001, Text: "usb 1-1: new high-speed USB device number 3 using xhci_hcd"}
+	event, ok := d.Observe(later)
+	if !ok || event.Seq != 655 || len(event.Msgs) != 19 || event.PC != "oops_init+0x1c/0x1000" {
+		t.Errorf("Observe() of a later message = %+v, %v, want the report cut off", event, ok)
+	}
+	if _, ok := d.Flush(); ok {
+		t.Error("Flush() returned a report after the one cut off")
+	}
+}
+
+func TestOopsDetectorTaintedLine(t *testing.T) {
+	// Linux 6.12 logs the UID of the task, and the taint flags on a line of their own too.
+	msgs := []Msg{
+		{Seq: 1, TsUsec: 10, Text: "Oops: general protection fault, probably for non-canonical address 0xdead000000000122: 0000 [#1] PREEMPT SMP NOPTI"},
+		{Seq: 2, TsUsec: 11, Text: "CPU: 0 UID: 0 PID: 77 Comm: kworker/0:2 Tainted: G        W          6.12.9 #1"},
+		{Seq: 3, TsUsec: 12, Text: "Tainted: [W]=WARN"},
+		{Seq: 4, TsUsec: 13, Text: "RIP: 0010:__list_del_entry_valid_or_report+0x5a/0xd0"},
+		{Seq: 5, TsUsec: 14, Text: "---[ end trace 0000000000000000 ]---"},
+	}
+	events := OopsEvents(msgs)
+	if len(events) != 1 {
+		t.Fatalf("OopsEvents() = %d events, want 1", len(events))
+	}
+	if e := events[0]; e.Kind != OopsKindOops || e.PID != 77 || e.Tainted != "G        W" || e.Taint != TaintWarn || e.Symbol != "__list_del_entry_valid_or_report" {
+		t.Errorf("OopsEvents() = %+v", e)
+	}
+}
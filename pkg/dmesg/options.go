@@ -0,0 +1,538 @@
+package dmesg
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// seekData is SEEK_DATA, which positions /dev/kmsg after the last clear of kernel ring buffer.
+const seekData = 3
+
+// Option configures how messages are read from kernel ring buffer. All options can be
+// combined, options setting the same value override the earlier ones. Filters apply before
+// WithMaxMessages, WithMaxBytes and Tail count the messages kept, WithReverse applies last.
+// Filter options apply to both parsed and raw messages, a raw message is only parsed when a
+// filter is set. Raw messages are read with the same options by RawDmesg and TailRaw rather
+// than with a WithRaw option, since they are returned as [][]byte instead of []Msg.
+type Option func(*options)
+
+// DefaultPath is the path messages are read from when WithPath isn't given.
+var DefaultPath = "/dev/kmsg"
+
+type options struct {
+	source         Source
+	path           string
+	bufSize        uint32
+	maxBufSize     uint32
+	maxMessages    int
+	maxBytes       int
+	whence         int
+	tail           int
+	afterSeq       uint64
+	hasAfterSeq    bool
+	reverse        bool
+	noDevInfo      bool
+	rawEscapes     bool
+	mergeFragments bool
+	klogFallback   bool
+	levels         uint8 // Bit set of the levels to keep
+	levelFilter    bool
+
+	facilities     uint64 // Bit set of the facilities to keep
+	facilityFilter bool
+
+	caller       string
+	callerFilter bool
+
+	since, until         int64 // Timestamps in microsecond
+	hasSince, hasUntil   bool
+	sinceTime, untilTime time.Time // Resolved to since and until when reading starts
+
+	withBootTime bool
+	bootTime     time.Time // Resolved when reading starts with withBootTime
+
+	suspendCorrection bool
+	suspended         time.Duration // Measured when reading starts and every suspendResync
+	suspendSynced     time.Time     // Wall clock time suspended was measured at
+
+	slogLevels map[Level]slog.Level
+
+	matches  []*regexp.Regexp
+	excludes []*regexp.Regexp
+
+	subsystems []string
+	device     string
+	hasDevice  bool
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		path:       DefaultPath,
+		bufSize:    DefaultBufSize,
+		maxBufSize: defaultMaxBufSize,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithPath reads messages from path instead of DefaultPath, e.g. when /dev/kmsg of the host is
+// mounted somewhere else in a container. A regular file or a FIFO, e.g. a dump of /dev/kmsg, is
+// read as a stream of records until its end.
+func WithPath(path string) Option {
+	return func(o *options) {
+		o.path = path
+	}
+}
+
+// WithBufSize sets the initial buf size for each message, DefaultBufSize by default, 0 also
+// means DefaultBufSize. The buf grows when a message doesn't fit, up to the max buf size.
+// Sizes over 16MB are rejected.
+func WithBufSize(size uint32) Option {
+	return func(o *options) {
+		o.bufSize = size
+	}
+}
+
+// WithMaxBufSize sets the max size the buf can grow to, 1MB by default.
+// A message which doesn't fit in it is returned with Truncated set and only Seq known, before
+// linux 5.10 it makes the read fail with syscall.EINVAL.
+// Sizes over 16MB are rejected.
+func WithMaxBufSize(size uint32) Option {
+	return func(o *options) {
+		o.maxBufSize = size
+	}
+}
+
+// WithMaxMessages stops reading after n messages are kept, 0 means no limit.
+// The limit counts messages after filtering. When reading stops because of it,
+// ErrTruncatedResult is returned along with the messages.
+func WithMaxMessages(n int) Option {
+	return func(o *options) {
+		o.maxMessages = n
+	}
+}
+
+// WithMaxBytes stops reading after n bytes of messages are kept, 0 means no limit.
+// The size of a message is the size of its text and device info, or of the whole record
+// for raw messages, the last message kept may exceed the limit. When reading stops because
+// of it, ErrTruncatedResult is returned along with the messages. It has no effect on Follow
+// and the iterators, which don't keep messages.
+func WithMaxBytes(n int) Option {
+	return func(o *options) {
+		o.maxBytes = n
+	}
+}
+
+// WithSource reads messages from source instead of SourceDevKmsg.
+func WithSource(source Source) Option {
+	return func(o *options) {
+		o.source = source
+	}
+}
+
+// WithKlogctlFallback reads kernel ring buffer with syslog(2) when DefaultPath doesn't exist or
+// can't be opened, e.g. in containers without /dev/kmsg, and WithPath isn't given. The lines of
+// syslog(2) carry less than /dev/kmsg records: Seq is 0, there is no device info and no
+// continuation flags, and lines like "<6>[    1.234567] text" are split on newlines in the
+// text. Only messages since the last clear are read, like with WithStartAfterClear, at most
+// the size of kernel ring buffer. Filters, WithTail, WithMaxMessages and WithBootTime apply.
+// It only applies to Dmesg, DmesgContext and Tail, not to raw messages, Follow or Reader, and
+// not with WithStartAtEnd or after a sequence number.
+func WithKlogctlFallback() Option {
+	return func(o *options) {
+		o.klogFallback = true
+	}
+}
+
+// WithStartAtEnd starts reading after the newest message in kernel ring buffer, so only
+// messages appended later are read. It's mostly useful with Follow and Reader.
+func WithStartAtEnd() Option {
+	return func(o *options) {
+		o.whence = io.SeekEnd
+	}
+}
+
+// WithStartAfterClear starts reading after the last clear of kernel ring buffer, e.g. by
+// 'dmesg -c' or 'dmesg -C', instead of the oldest message.
+func WithStartAfterClear() Option {
+	return func(o *options) {
+		o.whence = seekData
+	}
+}
+
+// WithReverse returns messages from the newest to the oldest like 'dmesg -r'. It's applied
+// after all other options, e.g. Tail(50, WithReverse()) returns the newest 50 messages with
+// the newest first. It has no effect on Follow and the iterators.
+func WithReverse() Option {
+	return func(o *options) {
+		o.reverse = true
+	}
+}
+
+// WithoutDeviceInfo skips parsing device info, leaving Subsystem, Device and DeviceInfo empty,
+// which saves time and allocations when device info isn't needed. WithSubsystem and WithDevice
+// still work.
+func WithoutDeviceInfo() Option {
+	return func(o *options) {
+		o.noDevInfo = true
+	}
+}
+
+// WithRawEscapes keeps the \xNN escapes of the kernel in Msg.Text and the device info instead
+// of decoding them, e.g. a tab stays "\x09". MarshalRecord escapes the messages again, so
+// messages read with it are escaped twice.
+func WithRawEscapes() Option {
+	return func(o *options) {
+		o.rawEscapes = true
+	}
+}
+
+// WithMergeFragments joins the parts of messages continued over several records into single
+// messages like MergeFragments. It applies to the messages returned at once, e.g. by Dmesg and
+// each call of Reader.ReadNew, a message whose parts are split over two calls isn't joined.
+// Follow and the iterators deliver the records as they are. Filters apply to the records
+// before they are joined, and WithMaxMessages and Tail count records.
+func WithMergeFragments() Option {
+	return func(o *options) {
+		o.mergeFragments = true
+	}
+}
+
+// WithMinLevel keeps only messages at least as severe as level, i.e. with Level <= level.
+// It overrides WithLevels.
+func WithMinLevel(level Level) Option {
+	return func(o *options) {
+		o.levels = 0
+		for l := LevelEmerg; l <= level && l <= LevelDebug; l++ {
+			o.levels |= 1 << l
+		}
+		o.levelFilter = true
+	}
+}
+
+// WithLevels keeps only messages with one of levels, like 'dmesg --level'.
+// It overrides WithMinLevel.
+func WithLevels(levels ...Level) Option {
+	return func(o *options) {
+		o.levels = 0
+		for _, l := range levels {
+			if l <= LevelDebug {
+				o.levels |= 1 << l
+			}
+		}
+		o.levelFilter = true
+	}
+}
+
+// WithFacilities keeps only messages with one of facilities, e.g. WithFacilities(FacilityKern)
+// keeps kernel messages and drops the ones written to /dev/kmsg by userspace.
+func WithFacilities(facilities ...Facility) Option {
+	return func(o *options) {
+		o.facilities = 0
+		for _, f := range facilities {
+			if f < 64 {
+				o.facilities |= 1 << f
+			}
+		}
+		o.facilityFilter = true
+	}
+}
+
+// WithCaller keeps only messages whose caller, e.g. "T1234" for a task or "C3" for a CPU,
+// matches pattern, which is either the exact caller or a glob like "T12*" as in path.Match.
+// The caller is only in messages from kernels built with CONFIG_PRINTK_CALLER, messages
+// without it never match. A malformed pattern matches no message.
+func WithCaller(pattern string) Option {
+	return func(o *options) {
+		o.caller = pattern
+		o.callerFilter = true
+	}
+}
+
+// WithSince keeps only messages logged at or after d since boot, like 'dmesg --since'.
+// It overrides WithSinceTime. Messages without timestamp, e.g. parsed from 'dmesg -T' output,
+// are dropped.
+func WithSince(d time.Duration) Option {
+	return func(o *options) {
+		o.since = d.Microseconds()
+		o.hasSince = true
+		o.sinceTime = time.Time{}
+	}
+}
+
+// WithUntil keeps only messages logged at or before d since boot, like 'dmesg --until'.
+// If it's earlier than the time of WithSince no message is kept. It overrides WithUntilTime.
+func WithUntil(d time.Duration) Option {
+	return func(o *options) {
+		o.until = d.Microseconds()
+		o.hasUntil = true
+		o.untilTime = time.Time{}
+	}
+}
+
+// WithSinceTime is like WithSince but takes a wall clock time, which is converted to the time
+// since boot using the boot time of the system. Message timestamps don't advance while the
+// system is suspended, so after a suspend messages are older than their converted time says.
+// The WallTime of a message without timestamp, e.g. parsed from 'dmesg -T' output, is compared
+// with t as is. It overrides WithSince.
+func WithSinceTime(t time.Time) Option {
+	return func(o *options) {
+		o.sinceTime = t
+		o.hasSince = true
+	}
+}
+
+// WithUntilTime is like WithUntil but takes a wall clock time, see WithSinceTime for how it's
+// converted. It overrides WithUntil.
+func WithUntilTime(t time.Time) Option {
+	return func(o *options) {
+		o.untilTime = t
+		o.hasUntil = true
+	}
+}
+
+// WithBootTime sets Msg.BootTime of the messages to the time the system booted, read from
+// /proc/stat when reading starts, so the wall clock time of messages is known e.g. by
+// MarshalJSON. It's the boot time of the running system, which is wrong for a dump of
+// another system read with WithPath.
+func WithBootTime() Option {
+	return func(o *options) {
+		o.withBootTime = true
+	}
+}
+
+// suspendResync is how often WithSuspendCorrection measures the suspended time again while
+// reading.
+const suspendResync = time.Minute
+
+// WithSuspendCorrection sets Msg.Suspended of the messages to the time the system was
+// suspended, so Msg.CorrectedTime gives their wall clock time like 'dmesg -T' does. It implies
+// WithBootTime. The kernel clock of message timestamps stops during suspend, so Msg.Time of
+// Msg.BootTime is too early by the time spent suspended before the message was logged.
+// The suspended time is measured when reading starts and again every minute while reading,
+// so following doesn't accumulate skew across suspends.
+//
+// The correction assumes all messages were logged after the last suspend, messages logged
+// before it appear too late by the duration of the later suspends. It's the suspended time of
+// the running system, which is wrong for a dump of another system read with WithPath.
+func WithSuspendCorrection() Option {
+	return func(o *options) {
+		o.withBootTime = true
+		o.suspendCorrection = true
+	}
+}
+
+// WithMatch keeps only messages whose text matches re. Unlike other options it can be
+// given several times, a message is kept if it matches any of them. The text is matched once
+// its \xNN escapes are decoded, e.g. a tab is matched by \t, and as escaped by the kernel with
+// WithRawEscapes, e.g. by \\x09.
+func WithMatch(re *regexp.Regexp) Option {
+	return func(o *options) {
+		o.matches = append(o.matches, re)
+	}
+}
+
+// WithExclude drops messages whose text matches re. It can be given several times and takes
+// precedence over WithMatch. Like WithMatch, it matches the decoded text unless WithRawEscapes
+// is given.
+func WithExclude(re *regexp.Regexp) Option {
+	return func(o *options) {
+		o.excludes = append(o.excludes, re)
+	}
+}
+
+// WithSubsystem keeps only messages whose SUBSYSTEM device info is one of names,
+// e.g. "block" or "net". Messages without device info never match.
+func WithSubsystem(names ...string) Option {
+	return func(o *options) {
+		o.subsystems = names
+	}
+}
+
+// WithDevice keeps only messages whose DEVICE device info is dev, e.g. "b8:0" or
+// "+pci:0000:00:1f.2". Messages without device info never match.
+func WithDevice(dev string) Option {
+	return func(o *options) {
+		o.device = dev
+		o.hasDevice = true
+	}
+}
+
+// filtered reports whether any filter is set.
+func (o *options) filtered() bool {
+	return o.levelFilter || o.facilityFilter || o.callerFilter || o.hasSince || o.hasUntil ||
+		len(o.matches) > 0 || len(o.excludes) > 0 || o.deviceFiltered()
+}
+
+// resolveTimes converts the wall clock times of WithSinceTime and WithUntilTime to
+// timestamps since boot and resolves the boot time of WithBootTime.
+func (o *options) resolveTimes() error {
+	if o.sinceTime.IsZero() && o.untilTime.IsZero() && !o.withBootTime {
+		return nil
+	}
+
+	boot, err := BootTime()
+	if err != nil {
+		return err
+	}
+	if o.withBootTime {
+		o.bootTime = boot
+	}
+	if o.suspendCorrection {
+		o.syncSuspended()
+		boot = boot.Add(o.suspended)
+	}
+
+	if !o.sinceTime.IsZero() {
+		o.since = o.sinceTime.Sub(boot).Microseconds()
+	}
+	if !o.untilTime.IsZero() {
+		o.until = o.untilTime.Sub(boot).Microseconds()
+	}
+
+	return nil
+}
+
+// syncSuspended measures the suspended time of WithSuspendCorrection again when it was last
+// measured suspendResync ago. The wall clock is compared since the monotonic clock of
+// time.Since stops during suspend too.
+func (o *options) syncSuspended() {
+	now := time.Now().Round(0)
+	if !o.suspendCorrection || now.Sub(o.suspendSynced) < suspendResync {
+		return
+	}
+
+	if suspended, err := SuspendedTime(); err == nil {
+		o.suspended = suspended
+	}
+	o.suspendSynced = now
+}
+
+// matchPrefix reports whether msg passes the filters which only need the prefix fields,
+// so messages can be dropped before the rest of the record is parsed.
+func (o *options) matchPrefix(msg *Msg) bool {
+	if o.levelFilter && o.levels&(1<<msg.Level) == 0 {
+		return false
+	}
+	if o.facilityFilter && (msg.Facility >= 64 || o.facilities&(1<<msg.Facility) == 0) {
+		return false
+	}
+	if o.callerFilter && !matchCaller(o.caller, msg.Caller) {
+		return false
+	}
+	if (o.hasSince || o.hasUntil) && !o.matchTime(msg) {
+		return false
+	}
+
+	return true
+}
+
+// matchTime reports whether msg was logged within the times of WithSince and WithUntil. A
+// message whose WallTime only is known is compared with the wall clock times of WithSinceTime
+// and WithUntilTime, it's dropped by WithSince and WithUntil which need a timestamp.
+func (o *options) matchTime(msg *Msg) bool {
+	if msg.TsUsec >= 0 {
+		return !(o.hasSince && msg.TsUsec < o.since || o.hasUntil && msg.TsUsec > o.until)
+	}
+	if msg.WallTime.IsZero() {
+		return false
+	}
+	if o.hasSince && (o.sinceTime.IsZero() || msg.WallTime.Before(o.sinceTime)) {
+		return false
+	}
+
+	return !o.hasUntil || !o.untilTime.IsZero() && !msg.WallTime.After(o.untilTime)
+}
+
+func matchCaller(pattern, caller string) bool {
+	if caller == "" {
+		return false
+	}
+	if pattern == caller {
+		return true
+	}
+
+	matched, err := path.Match(pattern, caller)
+
+	return err == nil && matched
+}
+
+// matchText reports whether the text of msg passes the text filters, so messages can be
+// dropped before device info is parsed.
+func (o *options) matchText(msg *Msg) bool {
+	for _, re := range o.excludes {
+		if re.MatchString(msg.Text) {
+			return false
+		}
+	}
+
+	if len(o.matches) == 0 {
+		return true
+	}
+	for _, re := range o.matches {
+		if re.MatchString(msg.Text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deviceFiltered reports whether any device info filter is set.
+func (o *options) deviceFiltered() bool {
+	return len(o.subsystems) > 0 || o.hasDevice
+}
+
+// match reports whether the fully parsed msg passes the device info filters.
+func (o *options) match(msg *Msg) bool {
+	if len(o.subsystems) > 0 {
+		if msg.Subsystem == "" || !slices.Contains(o.subsystems, msg.Subsystem) {
+			return false
+		}
+	}
+	if o.hasDevice && (msg.Device == "" || msg.Device != o.device) {
+		return false
+	}
+
+	return true
+}
+
+// useKlogFallback reports whether messages should be read with syslog(2) since opening
+// DefaultPath failed with err, see WithKlogctlFallback.
+func (o *options) useKlogFallback(err error) bool {
+	return o.klogFallback && o.path == DefaultPath && o.whence != io.SeekEnd && !o.hasAfterSeq &&
+		(errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission))
+}
+
+// filter returns the messages of msgs passing all filters, with the boot time of WithBootTime
+// set, for messages parsed from other sources than /dev/kmsg records.
+func (o *options) filter(msgs []Msg) []Msg {
+	kept := msgs[:0]
+	for _, msg := range msgs {
+		if o.keep(&msg) {
+			kept = append(kept, msg)
+		}
+	}
+
+	return kept
+}
+
+// keep reports whether msg passes all filters and sets the boot time of WithBootTime if so.
+func (o *options) keep(msg *Msg) bool {
+	if !o.matchPrefix(msg) || !o.matchText(msg) || !o.match(msg) {
+		return false
+	}
+	msg.BootTime = o.bootTime
+	msg.Suspended = o.suspended
+
+	return true
+}
@@ -0,0 +1,264 @@
+package dmesg
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mixedRecords are records of several levels and facilities, some with device info.
+var mixedRecords = []string{
+	"3,1,1000000,-;ata1: COMRESET failed\n SUBSYSTEM=scsi\n DEVICE=+scsi:0:0:0:0\n", // kern.err
+	"6,2,2000000,-;sd 0:0:0:0: [sda] Attached\n SUBSYSTEM=block\n DEVICE=b8:0\n",    // kern.info
+	"11,3,3000000,-;user err\n",                                           // user.err
+	"14,4,4000000,-;user info\n",                                          // user.info
+	"27,5,5000000,-;daemon err\n",                                         // daemon.err
+	"30,6,6000000,-;systemd[1]: daemon info\n",                            // daemon.info
+	"4,7,7000000,-;I/O error, dev sdb\n SUBSYSTEM=block\n DEVICE=b8:16\n", // kern.warn
+	"8,8,8000000,-;user emerg\n",                                          // user.emerg
+}
+
+// readSeqs returns the sequence numbers of the messages read from a dump of records with read.
+func readSeqs(t *testing.T, records []string, read func(...Option) ([]Msg, error), opts ...Option) []uint64 {
+	t.Helper()
+
+	msgs, err := read(append(opts, WithPath(writeDump(t, records...)))...)
+	if err != nil && err != ErrTruncatedResult {
+		t.Fatal(err)
+	}
+
+	return seqs(msgs)
+}
+
+func TestWithMatchEscapes(t *testing.T) {
+	path := writeDump(t,
+		"6,1,100,-;tab\\x09here\n",
+		"6,2,200,-;back\\x5cslash\n",
+		"6,3,300,-;plain text\n",
+	)
+
+	tests := []struct {
+		name string
+		opts []Option
+		want []uint64
+	}{
+		{"decoded tab", []Option{WithMatch(regexp.MustCompile(`tab\there`))}, []uint64{1}},
+		{"decoded backslash", []Option{WithMatch(regexp.MustCompile(`back\\slash`))}, []uint64{2}},
+		{"escape not matched decoded", []Option{WithMatch(regexp.MustCompile(`\\x09`))}, []uint64{}},
+		{"raw escape", []Option{WithRawEscapes(), WithMatch(regexp.MustCompile(`tab\\x09here`))}, []uint64{1}},
+		{"raw tab not matched", []Option{WithRawEscapes(), WithMatch(regexp.MustCompile(`\t`))}, []uint64{}},
+		{"exclude decoded", []Option{WithExclude(regexp.MustCompile(`\t|\\`))}, []uint64{3}},
+		{"exclude raw", []Option{WithRawEscapes(), WithExclude(regexp.MustCompile(`\\x5c`))}, []uint64{1, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs, err := Dmesg(append(tt.opts, WithPath(path))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := seqs(msgs); !slices.Equal(got, tt.want) {
+				t.Errorf("seqs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptionsCompose(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []uint64
+	}{
+		{"none", nil, []uint64{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"level and facility", []Option{WithMinLevel(LevelErr), WithFacilities(FacilityKern)}, []uint64{1}},
+		{"facility and match", []Option{WithFacilities(FacilityUser), WithMatch(regexp.MustCompile(`err`))}, []uint64{3}},
+		{"subsystem and level", []Option{WithSubsystem("block"), WithMinLevel(LevelWarn)}, []uint64{7}},
+		{"since, until and exclude", []Option{WithSince(2 * time.Second), WithUntil(6 * time.Second), WithExclude(regexp.MustCompile(`info`))}, []uint64{2, 3, 5}},
+		{"levels overridden by min level", []Option{WithLevels(LevelInfo), WithMinLevel(LevelErr)}, []uint64{1, 3, 5, 8}},
+		{"min level overridden by levels", []Option{WithMinLevel(LevelErr), WithLevels(LevelInfo)}, []uint64{2, 4, 6}},
+		{"facilities overridden", []Option{WithFacilities(FacilityKern), WithFacilities(FacilityDaemon)}, []uint64{5, 6}},
+		{"limit counts kept messages", []Option{WithMinLevel(LevelErr), WithMaxMessages(2)}, []uint64{1, 3}},
+		{"limit before reverse", []Option{WithReverse(), WithMaxMessages(3)}, []uint64{3, 2, 1}},
+		{"filter and reverse", []Option{WithMinLevel(LevelErr), WithReverse()}, []uint64{8, 5, 3, 1}},
+		{"device info filter without device info", []Option{WithoutDeviceInfo(), WithSubsystem("block")}, []uint64{2, 7}},
+		{"raw escapes and match", []Option{WithRawEscapes(), WithMatch(regexp.MustCompile(`^user`))}, []uint64{3, 4, 8}},
+		{"max bytes and filter", []Option{WithFacilities(FacilityUser), WithMaxBytes(10)}, []uint64{3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readSeqs(t, mixedRecords, Dmesg, tt.opts...); !slices.Equal(got, tt.want) {
+				t.Errorf("seqs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRawDmesgOptions(t *testing.T) {
+	path := writeDump(t, mixedRecords...)
+	raw, err := RawDmesg(WithPath(path), WithMinLevel(LevelErr), WithReverse(), WithMaxMessages(3))
+	if err != nil && err != ErrTruncatedResult {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range raw {
+		got = append(got, strings.SplitN(string(r), ";", 2)[0])
+	}
+	if want := []string{"27,5,5000000,-", "11,3,3000000,-", "3,1,1000000,-"}; !slices.Equal(got, want) {
+		t.Errorf("raw prefixes = %v, want %v", got, want)
+	}
+}
+
+func TestLevelFilterIgnoresFacility(t *testing.T) {
+	// The priorities of user and daemon messages are above the levels, only the low 3 bits
+	// are compared.
+	records := append(slices.Clone(mixedRecords), "1019,9,9000000,-;out of range priority\n")
+	tests := []struct {
+		name string
+		opt  Option
+		want []uint64
+	}{
+		{"levels err", WithLevels(LevelErr), []uint64{1, 3, 5, 9}},
+		{"levels info and warn", WithLevels(LevelInfo, LevelWarn), []uint64{2, 4, 6, 7}},
+		{"min level emerg", WithMinLevel(LevelEmerg), []uint64{8}},
+		{"min level err", WithMinLevel(LevelErr), []uint64{1, 3, 5, 8, 9}},
+		{"min level debug", WithMinLevel(LevelDebug), []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"no levels", WithLevels(), []uint64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readSeqs(t, records, Dmesg, tt.opt); !slices.Equal(got, tt.want) {
+				t.Errorf("seqs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithFacilities(t *testing.T) {
+	records := append(slices.Clone(mixedRecords), "1019,9,9000000,-;out of range priority\n")
+	tests := []struct {
+		name string
+		opts []Option
+		want []uint64
+	}{
+		{"kern", []Option{WithFacilities(FacilityKern)}, []uint64{1, 2, 7}},
+		{"userspace", []Option{WithFacilities(FacilityUser, FacilityDaemon)}, []uint64{3, 4, 5, 6, 8}},
+		{"unused facility", []Option{WithFacilities(FacilityMail)}, []uint64{}},
+		{"out of range facility", []Option{WithFacilities(Facility(127))}, []uint64{}},
+		{"user and level", []Option{WithFacilities(FacilityUser), WithMinLevel(LevelErr)}, []uint64{3, 8}},
+		{"daemon and caller", []Option{WithFacilities(FacilityDaemon), WithCaller("T*")}, []uint64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readSeqs(t, records, Dmesg, tt.opts...); !slices.Equal(got, tt.want) {
+				t.Errorf("seqs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The split is the same for raw messages, which are only parsed for the filter.
+	raw, err := RawDmesg(WithPath(writeDump(t, records...)), WithFacilities(FacilityKern))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 3 || string(raw[2]) != "4,7,7000000,-;I/O error, dev sdb\n SUBSYSTEM=block\n DEVICE=b8:16\n" {
+		t.Errorf("RawDmesg() = %q, want the 3 kern records", raw)
+	}
+}
+
+func TestWithSubsystemDevice(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []uint64
+	}{
+		{"block", []Option{WithSubsystem("block")}, []uint64{2, 7}},
+		{"block or scsi", []Option{WithSubsystem("scsi", "block")}, []uint64{1, 2, 7}},
+		{"no such subsystem", []Option{WithSubsystem("net")}, []uint64{}},
+		{"empty subsystem", []Option{WithSubsystem("")}, []uint64{}},
+		{"device", []Option{WithDevice("b8:16")}, []uint64{7}},
+		{"empty device", []Option{WithDevice("")}, []uint64{}},
+		{"subsystem and device", []Option{WithSubsystem("block"), WithDevice("b8:0")}, []uint64{2}},
+		{"subsystem and other device", []Option{WithSubsystem("scsi"), WithDevice("b8:0")}, []uint64{}},
+		{"subsystem and match", []Option{WithSubsystem("block"), WithMatch(regexp.MustCompile(`I/O`))}, []uint64{7}},
+		{"subsystem and limit", []Option{WithSubsystem("block"), WithMaxMessages(1)}, []uint64{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readSeqs(t, mixedRecords, Dmesg, tt.opts...); !slices.Equal(got, tt.want) {
+				t.Errorf("seqs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Without device info parsed into the messages, the filter still sees it.
+	msgs, err := Dmesg(WithPath(writeDump(t, mixedRecords...)), WithoutDeviceInfo(), WithDevice("b8:16"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Seq != 7 || msgs[0].Subsystem != "" || msgs[0].Device != "" {
+		t.Errorf("Dmesg(WithoutDeviceInfo(), WithDevice()) = %+v, want message 7 without device info", msgs)
+	}
+}
+
+func TestTailReverse(t *testing.T) {
+	tail := func(n int) func(...Option) ([]Msg, error) {
+		return func(opts ...Option) ([]Msg, error) {
+			return Tail(n, opts...)
+		}
+	}
+	tests := []struct {
+		name string
+		read func(...Option) ([]Msg, error)
+		opts []Option
+		want []uint64
+	}{
+		{"reverse", Dmesg, []Option{WithReverse()}, []uint64{8, 7, 6, 5, 4, 3, 2, 1}},
+		{"tail", tail(3), nil, []uint64{6, 7, 8}},
+		{"tail reverse", tail(3), []Option{WithReverse()}, []uint64{8, 7, 6}},
+		{"tail longer than messages", tail(10), []Option{WithReverse()}, []uint64{8, 7, 6, 5, 4, 3, 2, 1}},
+		{"tail filtered", tail(2), []Option{WithReverse(), WithFacilities(FacilityKern)}, []uint64{7, 2}},
+		{"tail of limit", tail(2), []Option{WithReverse(), WithMaxMessages(5)}, []uint64{5, 4}},
+		{"tail zero", tail(0), []Option{WithReverse()}, []uint64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readSeqs(t, mixedRecords, tt.read, tt.opts...); !slices.Equal(got, tt.want) {
+				t.Errorf("seqs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	raw, err := TailRaw(2, WithPath(writeDump(t, mixedRecords...)), WithReverse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 2 || string(raw[0]) != mixedRecords[7] || string(raw[1]) != mixedRecords[6] {
+		t.Errorf("TailRaw(2, WithReverse()) = %q, want the last 2 records newest first", raw)
+	}
+}
+
+func TestReaderReverse(t *testing.T) {
+	path := writeDump(t, mixedRecords[:3]...)
+	r, err := Open(WithPath(path), WithReverse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	msgs, err := r.ReadNew()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := seqs(msgs); !slices.Equal(got, []uint64{3, 2, 1}) {
+		t.Errorf("ReadNew() seqs = %v, want [3 2 1]", got)
+	}
+}
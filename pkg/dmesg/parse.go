@@ -0,0 +1,200 @@
+package dmesg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	levelMask     = uint64(1<<3 - 1)
+	facilityShift = 3
+)
+
+// ErrInvalidRecord is wrapped by all errors returned by ParseRecord.
+var ErrInvalidRecord = errors.New("dmesg: invalid record")
+
+// ParseRecord parses a native message from /dev/kmsg, e.g. a record returned by RawDmesg.
+// The record is formatted as "pri,seq,ts,flags[,caller=id];text\n" followed by optional
+// device info lines " KEY=VALUE\n", the caller is only there on kernels with
+// CONFIG_PRINTK_CALLER and Caller is empty otherwise, a caller without "caller=" is accepted.
+// The \xNN escapes of non-printable characters and '\' in the text and device info are decoded.
+// It returns an error wrapping ErrInvalidRecord describing why the record is rejected.
+func ParseRecord(data []byte) (Msg, error) {
+	msg, _, err := parseRecord(data, nil)
+
+	return msg, err
+}
+
+// parseRecord parses data like ParseRecord. When o is not nil, parsing stops as soon as
+// the message doesn't pass the filters of o and false is returned.
+func parseRecord(data []byte, o *options) (Msg, bool, error) {
+	msg := Msg{}
+
+	prefixEnd := bytes.IndexByte(data, ';')
+	if prefixEnd == -1 {
+		return msg, false, fmt.Errorf("%w: missing ';' separator", ErrInvalidRecord)
+	}
+
+	textEnd := bytes.IndexByte(data, '\n')
+	if textEnd == -1 {
+		return msg, false, fmt.Errorf("%w: missing newline after text", ErrInvalidRecord)
+	}
+	if textEnd < prefixEnd {
+		return msg, false, fmt.Errorf("%w: newline in prefix", ErrInvalidRecord)
+	}
+
+	if err := parsePrefix(data[:prefixEnd], &msg); err != nil {
+		return Msg{}, false, err
+	}
+	if o != nil && !o.matchPrefix(&msg) {
+		return Msg{}, false, nil
+	}
+
+	decode := o == nil || !o.rawEscapes
+	msg.Text = string(data[prefixEnd+1 : textEnd])
+	if decode {
+		msg.Text = unescape(msg.Text)
+	}
+	if o != nil && !o.matchText(&msg) {
+		return Msg{}, false, nil
+	}
+
+	// Most records carry no device info, the record ends right after the text.
+	if textEnd < len(data)-1 && (o == nil || !o.noDevInfo || o.deviceFiltered()) {
+		msg.DeviceInfoList = parseDeviceInfo(bytes.TrimSuffix(data[textEnd+1:], []byte("\n")), decode)
+		msg.DeviceInfo = deviceInfoMap(msg.DeviceInfoList)
+		splitDeviceInfo(&msg)
+	}
+	if o != nil && !o.match(&msg) {
+		return Msg{}, false, nil
+	}
+	if o != nil && o.noDevInfo {
+		msg.Subsystem, msg.Device, msg.DeviceInfo, msg.DeviceInfoList = "", "", nil, nil
+	}
+	if o != nil {
+		msg.BootTime = o.bootTime
+		msg.Suspended = o.suspended
+	}
+
+	return msg, true, nil
+}
+
+// parseSeq returns the sequence number of a record without parsing the whole record.
+func parseSeq(data []byte) (uint64, bool) {
+	start := bytes.IndexByte(data, ',')
+	if start == -1 {
+		return 0, false
+	}
+
+	end := bytes.IndexByte(data[start+1:], ',')
+	if end == -1 {
+		return 0, false
+	}
+
+	seq, err := strconv.ParseUint(string(data[start+1:start+1+end]), 10, 64)
+
+	return seq, err == nil
+}
+
+// parsePrefix parses the "pri,seq,ts,flags[,caller=id]" prefix of a record into msg. The caller
+// field is only there with CONFIG_PRINTK_CALLER, Caller is left empty without it. Other field
+// counts are rejected rather than guessing which field is which.
+func parsePrefix(prefix []byte, msg *Msg) error {
+	fields := bytes.Split(prefix, []byte(","))
+	if len(fields) < 4 || len(fields) > 5 {
+		return fmt.Errorf("%w: prefix %q has %d fields, want 4, or 5 with a caller", ErrInvalidRecord, prefix, len(fields))
+	}
+
+	for index, field := range fields {
+		switch index {
+		case 0:
+			val, err := strconv.ParseUint(string(field), 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w: invalid priority %q", ErrInvalidRecord, field)
+			}
+			msg.Priority = val
+			msg.Level = Level(val & levelMask)
+			msg.Facility = Facility(val >> facilityShift)
+		case 1:
+			val, err := strconv.ParseUint(string(field), 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w: invalid sequence number %q", ErrInvalidRecord, field)
+			}
+			msg.Seq = val
+		case 2:
+			val, err := strconv.ParseInt(string(field), 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w: invalid timestamp %q", ErrInvalidRecord, field)
+			}
+			msg.TsUsec = val
+		case 3:
+			// A NUL flag is the zero Flag, which means none like an empty field.
+			msg.Flag = FlagNone
+			if len(field) > 0 && field[0] != 0 {
+				msg.Flag = Flag(field[0])
+			}
+		case 4:
+			msg.Caller = string(bytes.TrimPrefix(field, []byte("caller=")))
+		}
+	}
+
+	return nil
+}
+
+// parseDeviceInfo parses the device info lines following the text of a record, keeping their
+// order and repeated keys, and decodes their escapes if decode is set.
+func parseDeviceInfo(data []byte, decode bool) []KV {
+	var deviceInfo []KV
+	for _, info := range bytes.Split(data, []byte("\n")) {
+		if len(info) == 0 || info[0] != ' ' {
+			continue
+		}
+
+		// Device info lines are formatted as " KEY=VALUE", the leading space is not part of the key.
+		// Only the first '=' separates key and value, the value may contain '=' itself.
+		kv := bytes.SplitN(info[1:], []byte("="), 2)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			continue
+		}
+
+		key, value := string(kv[0]), string(kv[1])
+		if decode {
+			key, value = unescape(key), unescape(value)
+		}
+		deviceInfo = append(deviceInfo, KV{Key: key, Value: value})
+	}
+
+	return deviceInfo
+}
+
+// deviceInfoMap returns the device info lines as a map, the last line wins for repeated keys.
+func deviceInfoMap(list []KV) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+
+	deviceInfo := make(map[string]string, len(list))
+	for _, kv := range list {
+		deviceInfo[kv.Key] = kv.Value
+	}
+
+	return deviceInfo
+}
+
+// splitDeviceInfo moves SUBSYSTEM and DEVICE of msg.DeviceInfo to msg.Subsystem and
+// msg.Device, leaving DeviceInfo nil when there are no other keys.
+func splitDeviceInfo(msg *Msg) {
+	if subsystem, ok := msg.DeviceInfo["SUBSYSTEM"]; ok {
+		msg.Subsystem = subsystem
+		delete(msg.DeviceInfo, "SUBSYSTEM")
+	}
+	if device, ok := msg.DeviceInfo["DEVICE"]; ok {
+		msg.Device = device
+		delete(msg.DeviceInfo, "DEVICE")
+	}
+	if len(msg.DeviceInfo) == 0 {
+		msg.DeviceInfo = nil
+	}
+}
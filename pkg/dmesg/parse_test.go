@@ -0,0 +1,464 @@
+package dmesg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRecordMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		record string
+	}{
+		{"empty", ""},
+		{"newline only", "\n"},
+		{"separator only", ";"},
+		{"missing separator", "6,1,0,-text\n"},
+		{"missing newline", "6,1,0,-;text"},
+		{"newline in prefix", "6,1\n,0,-;text\n"},
+		{"empty prefix", ";text\n"},
+		{"three fields", "6,1,0;text\n"},
+		{"six fields", "6,1,0,-,T1,x;text\n"},
+		{"negative priority", "-6,1,0,-;text\n"},
+		{"priority overflow", "18446744073709551616,1,0,-;text\n"},
+		{"empty priority", ",1,0,-;text\n"},
+		{"invalid sequence number", "6,x,0,-;text\n"},
+		{"negative sequence number", "6,-1,0,-;text\n"},
+		{"invalid timestamp", "6,1,1.5,-;text\n"},
+		{"empty timestamp", "6,1,,-;text\n"},
+		{"binary", "\x00\xff,\x01;\x02\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := ParseRecord([]byte(tt.record))
+			if !errors.Is(err, ErrInvalidRecord) {
+				t.Fatalf("ParseRecord(%q) error = %v, want ErrInvalidRecord", tt.record, err)
+			}
+			if !reflect.DeepEqual(msg, Msg{}) {
+				t.Errorf("ParseRecord(%q) = %+v, want zero Msg", tt.record, msg)
+			}
+		})
+	}
+}
+
+func TestParseRecordLenient(t *testing.T) {
+	tests := []struct {
+		name   string
+		record string
+		want   Msg
+	}{
+		{
+			name:   "empty lines in device info",
+			record: "6,1,0,-;text\n\n \n\n SUBSYSTEM=pci\n\n",
+			want:   Msg{Priority: 6, Level: LevelInfo, Seq: 1, Flag: FlagNone, Text: "text", Subsystem: "pci", DeviceInfoList: []KV{{"SUBSYSTEM", "pci"}}},
+		},
+		{
+			name:   "device info without leading space",
+			record: "6,1,0,-;text\nSUBSYSTEM=pci\n",
+			want:   Msg{Priority: 6, Level: LevelInfo, Seq: 1, Flag: FlagNone, Text: "text"},
+		},
+		{
+			name:   "device info without key or separator",
+			record: "6,1,0,-;text\n =value\n NOVALUE\n",
+			want:   Msg{Priority: 6, Level: LevelInfo, Seq: 1, Flag: FlagNone, Text: "text"},
+		},
+		{
+			name:   "empty flags",
+			record: "6,1,0,;text\n",
+			want:   Msg{Priority: 6, Level: LevelInfo, Seq: 1, Flag: FlagNone, Text: "text"},
+		},
+		{
+			name:   "empty text",
+			record: "6,1,0,-;\n",
+			want:   Msg{Priority: 6, Level: LevelInfo, Seq: 1, Flag: FlagNone},
+		},
+		{
+			name:   "separator in text",
+			record: "6,1,0,-;a;b\n",
+			want:   Msg{Priority: 6, Level: LevelInfo, Seq: 1, Flag: FlagNone, Text: "a;b"},
+		},
+		{
+			name:   "negative timestamp",
+			record: "6,1,-5,-;text\n",
+			want:   Msg{Priority: 6, Level: LevelInfo, Seq: 1, TsUsec: -5, Flag: FlagNone, Text: "text"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := ParseRecord([]byte(tt.record))
+			if err != nil {
+				t.Fatalf("ParseRecord(%q) error = %v", tt.record, err)
+			}
+			if !reflect.DeepEqual(msg, tt.want) {
+				t.Errorf("ParseRecord(%q) = %+v, want %+v", tt.record, msg, tt.want)
+			}
+		})
+	}
+}
+
+// fuzzSeeds are records and text lines triggering the parsers and the detectors.
+var fuzzSeeds = []string{
+	"6,1,0,-;text\n",
+	"3,2,1000,-,T123;usb 1-1: device descriptor read/64, error -71\n SUBSYSTEM=usb\n DEVICE=c189:1\n",
+	"4,3,2000,c;first part\n",
+	"4,4,2000,+;continued\n",
+	"4,4,2000,\x00;nul flag\n",
+	"0,5,3000,-;\\x09tab \\x5c backslash \\xzz invalid\n",
+	"6,5,3000,-;text\n K\\x3dEY=value=x\n",
+	"11,6,4000,-;Out of memory: Killed process 1234 (java) total-vm:123kB, anon-rss:45kB, file-rss:0kB, shmem-rss:0kB, UID:0 pgtables:12kB oom_score_adj:0\n",
+	"1,7,5000,-;BUG: kernel NULL pointer dereference, address: 0000000000000000\n",
+	"4,8,5001,-;CPU: 0 PID: 1 Comm: init Tainted: G        W  O      6.1.0 #1\n",
+	"4,9,5002,-;---[ end trace 0000000000000000 ]---\n",
+	"6,10,6000,-;app[123]: segfault at 0 ip 0000000000401000 sp 00007ffd00000000 error 4 in app[401000+1000]\n",
+	"3,11,7000,-;blk_update_request: I/O error, dev sda, sector 123456 op 0x0:(READ) flags 0x0 phys_seg 1 prio class 0\n",
+	"2,12,8000,-;EXT4-fs error (device sda1): ext4_find_entry:1463: inode #2: comm ls: reading directory lblock 0\n",
+	"6,13,9000,-;e1000e 0000:00:19.0 eth0: NIC Link is Up 1000 Mbps Full Duplex, Flow Control: None\n",
+	"3,14,10000,-;INFO: task kworker/0:1:123 blocked for more than 120 seconds.\n",
+	"5,15,11000,-;audit: type=1400 audit(1700000000.123:42): apparmor=\"DENIED\" operation=\"open\" profile=\"snap\" name=\"/etc/x\" pid=1 comm=\"cat\"\n",
+	"4,16,12000,-;[UFW BLOCK] IN=eth0 OUT= MAC=00:11 SRC=10.0.0.1 DST=10.0.0.2 LEN=60 TOS=0x00 PREC=0x00 TTL=64 ID=1 DF PROTO=TCP SPT=1 DPT=22 WINDOW=1 RES=0x00 SYN URGP=0\n",
+	"4,17,13000,-;CPU0: Core temperature above threshold, cpu clock throttled (total events = 1)\n",
+	"3,18,14000,-;mce: [Hardware Error]: Machine check events logged\n",
+	"[    1.234567] text line of dmesg\n",
+	"<6>[    1.234567] syslog line\n",
+	"[Tue Oct 14 04:23:54 2026] ctime line\n",
+	"kern  :err   : [    2.000000] decoded line\n",
+}
+
+func FuzzParseRecord(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := ParseRecord(data)
+		switch {
+		case err != nil && !errors.Is(err, ErrInvalidRecord):
+			t.Fatalf("ParseRecord(%q) error = %v, want ErrInvalidRecord", data, err)
+		case err == nil:
+			// A parsed record is marshaled back to a record parsed the same.
+			record := MarshalRecord(msg)
+			again, err := ParseRecord(record)
+			if err != nil {
+				t.Fatalf("ParseRecord(%q) of MarshalRecord(%+v) error = %v", record, msg, err)
+			}
+			if !reflect.DeepEqual(again, msg) {
+				t.Fatalf("ParseRecord(MarshalRecord(%+v)) = %+v", msg, again)
+			}
+		}
+
+		var msgs []Msg
+		dec := NewDecoder(bytes.NewReader(data))
+		for {
+			msg, err := dec.Decode()
+			if err != nil && !errors.Is(err, ErrInvalidRecord) {
+				break
+			}
+			if err == nil {
+				msgs = append(msgs, msg)
+			}
+		}
+		text, _ := ParseText(bytes.NewReader(data))
+		runDetectors(append(msgs, text...))
+	})
+}
+
+// runDetectors runs all parsers and detectors of messages over msgs.
+func runDetectors(msgs []Msg) {
+	AuditEvents(msgs)
+	FSErrorEvents(msgs)
+	HardwareErrorEvents(msgs)
+	IOErrorEvents(msgs)
+	LinkFlaps(LinkEvents(msgs), time.Minute)
+	NFLogEvents(msgs)
+	OOMEvents(msgs)
+	OopsEvents(msgs)
+	SegfaultEvents(msgs)
+	StallEvents(msgs)
+	ThermalEvents(msgs)
+	USBEvents(msgs)
+	DroppedMarkers(msgs)
+	MergeFragments(msgs)
+	Dedup(msgs)
+	Gaps(msgs)
+	Summarize(msgs)
+	TopCallers(msgs, 3)
+	TopMessages(msgs, 3)
+	Histogram(msgs, time.Second)
+}
+
+func TestParseRecordDeviceInfoLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		record string
+		want   []KV
+	}{
+		{
+			name:   "none",
+			record: "6,1,0,-;text\n",
+		},
+		{
+			name:   "one",
+			record: "6,1,0,-;text\n SUBSYSTEM=pci\n",
+			want:   []KV{{"SUBSYSTEM", "pci"}},
+		},
+		{
+			name:   "several",
+			record: "6,1,0,-;text\n SUBSYSTEM=usb\n DEVICE=c189:1\n DRIVER=usb\n MODALIAS=usb:v1D6Bp0002\n",
+			want:   []KV{{"SUBSYSTEM", "usb"}, {"DEVICE", "c189:1"}, {"DRIVER", "usb"}, {"MODALIAS", "usb:v1D6Bp0002"}},
+		},
+		{
+			name:   "repeated key",
+			record: "6,1,0,-;text\n TAG=a\n TAG=b\n",
+			want:   []KV{{"TAG", "a"}, {"TAG", "b"}},
+		},
+		{
+			name:   "without trailing newline",
+			record: "6,1,0,-;text\n SUBSYSTEM=pci",
+			want:   []KV{{"SUBSYSTEM", "pci"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := ParseRecord([]byte(tt.record))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Text != "text" || msg.Seq != 1 {
+				t.Errorf("ParseRecord() = %+v, want the message with text", msg)
+			}
+			if !reflect.DeepEqual(msg.DeviceInfoList, tt.want) {
+				t.Errorf("DeviceInfoList = %v, want %v", msg.DeviceInfoList, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRecordDeviceInfoKeys(t *testing.T) {
+	msg, err := ParseRecord([]byte("6,1,0,-;text\n SUBSYSTEM=pci\n DEVICE=+pci:0000:00:1f.2\n DRIVER=ahci\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Subsystem != "pci" || msg.Device != "+pci:0000:00:1f.2" {
+		t.Errorf("Subsystem %q, Device %q, want pci and +pci:0000:00:1f.2", msg.Subsystem, msg.Device)
+	}
+	if want := map[string]string{"DRIVER": "ahci"}; !reflect.DeepEqual(msg.DeviceInfo, want) {
+		t.Errorf("DeviceInfo = %q, want %q", msg.DeviceInfo, want)
+	}
+	for _, kv := range msg.DeviceInfoList {
+		if strings.TrimSpace(kv.Key) != kv.Key {
+			t.Errorf("key %q has blanks", kv.Key)
+		}
+	}
+}
+
+func TestParseRecordDeviceInfoValues(t *testing.T) {
+	tests := []struct {
+		line  string
+		key   string
+		value string
+	}{
+		{" DRIVER=foo=bar", "DRIVER", "foo=bar"},
+		{" DEVICE=+pci:0000:00:1f.2", "DEVICE", "+pci:0000:00:1f.2"},
+		{" DEVICE=b8:0", "DEVICE", "b8:0"},
+		{" DEVICE=n2", "DEVICE", "n2"},
+		{" MODALIAS=usb:v1D6Bp0002d0515dc09dsc00dp01ic09isc00ip00in00", "MODALIAS", "usb:v1D6Bp0002d0515dc09dsc00dp01ic09isc00ip00in00"},
+		{" PHYSDEVPATH=/devices/pci0000:00/0000:00:14.0/usb1", "PHYSDEVPATH", "/devices/pci0000:00/0000:00:14.0/usb1"},
+		{" ID=a,b;c!@#$%^&*()[]{}<>?|~`'\"", "ID", "a,b;c!@#$%^&*()[]{}<>?|~`'\""},
+		{" VALUE= leading and trailing blanks ", "VALUE", " leading and trailing blanks "},
+		{" EMPTY=", "EMPTY", ""},
+		{" EQUALS===", "EQUALS", "=="},
+	}
+
+	for _, tt := range tests {
+		msg, err := ParseRecord([]byte("6,1,0,-;text\n" + tt.line + "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []KV{{tt.key, tt.value}}; !reflect.DeepEqual(msg.DeviceInfoList, want) {
+			t.Errorf("line %q: DeviceInfoList = %q, want %q", tt.line, msg.DeviceInfoList, want)
+		}
+	}
+}
+
+func TestParseRecordPriority(t *testing.T) {
+	tests := []struct {
+		priority uint64
+		level    Level
+		facility Facility
+	}{
+		{0, LevelEmerg, FacilityKern},
+		{6, LevelInfo, FacilityKern},
+		{14, LevelInfo, FacilityUser},
+		{30, LevelInfo, FacilityDaemon},
+		{35, LevelErr, FacilityAuth},
+		{134, LevelInfo, FacilityLocal0},
+		{191, LevelDebug, FacilityLocal7},
+	}
+
+	for _, tt := range tests {
+		msg, err := ParseRecord([]byte(fmt.Sprintf("%d,1,0,-;text\n", tt.priority)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Level != tt.level || msg.Facility != tt.facility {
+			t.Errorf("priority %d: level %v, facility %v, want %v, %v", tt.priority, msg.Level, msg.Facility, tt.level, tt.facility)
+		}
+	}
+}
+
+func TestParseRecordRawPriority(t *testing.T) {
+	for _, priority := range []uint64{0, 6, 30, 191, 192, 1023, 2047, 4096, 1 << 40} {
+		msg, err := ParseRecord([]byte(fmt.Sprintf("%d,1,0,-;text\n", priority)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Priority != priority {
+			t.Errorf("Priority = %d, want %d", msg.Priority, priority)
+		}
+		// The facility only holds the priorities of a byte-sized facility.
+		if priority < 1<<(8+facilityShift) && msg.Priority != uint64(msg.Facility)<<3|uint64(msg.Level) {
+			t.Errorf("Priority %d != Facility %d << 3 | Level %d", msg.Priority, msg.Facility, msg.Level)
+		}
+		if got := MarshalRecord(msg); !strings.HasPrefix(string(got), fmt.Sprintf("%d,", priority)) {
+			t.Errorf("MarshalRecord() = %q, want priority %d kept", got, priority)
+		}
+	}
+}
+
+func TestParseRecordSubsystemDevice(t *testing.T) {
+	tests := []struct {
+		name       string
+		info       string
+		subsystem  string
+		device     string
+		deviceInfo map[string]string
+	}{
+		{"neither", "", "", "", nil},
+		{"neither with other keys", " DRIVER=e1000e\n", "", "", map[string]string{"DRIVER": "e1000e"}},
+		{"only subsystem", " SUBSYSTEM=net\n", "net", "", nil},
+		{"only device", " DEVICE=n2\n", "", "n2", nil},
+		{"both", " SUBSYSTEM=block\n DEVICE=b8:0\n", "block", "b8:0", nil},
+		{"both with other keys", " SUBSYSTEM=usb\n DEVICE=c189:1\n DRIVER=usb\n", "usb", "c189:1", map[string]string{"DRIVER": "usb"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := ParseRecord([]byte("6,1,0,-;text\n" + tt.info))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Subsystem != tt.subsystem || msg.Device != tt.device {
+				t.Errorf("Subsystem %q, Device %q, want %q, %q", msg.Subsystem, msg.Device, tt.subsystem, tt.device)
+			}
+			if !reflect.DeepEqual(msg.DeviceInfo, tt.deviceInfo) {
+				t.Errorf("DeviceInfo = %q, want %q", msg.DeviceInfo, tt.deviceInfo)
+			}
+		})
+	}
+}
+
+func TestParseRecordFlag(t *testing.T) {
+	tests := []struct {
+		field    string
+		flag     Flag
+		fragment bool
+	}{
+		{"-", FlagNone, false},
+		{"c", FlagFragment, true},
+		{"+", FlagContinuation, true},
+		{"", FlagNone, false},
+		{"x", Flag('x'), false},
+		{"c-", FlagFragment, true},
+		{"\x00", FlagNone, false},
+	}
+
+	for _, tt := range tests {
+		msg, err := ParseRecord([]byte("6,1,0," + tt.field + ";text\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Flag != tt.flag || msg.IsFragment() != tt.fragment {
+			t.Errorf("flags %q: Flag %q, IsFragment %v, want %q, %v", tt.field, msg.Flag, msg.IsFragment(), tt.flag, tt.fragment)
+		}
+	}
+}
+
+// testdata/kmsg-6.1 and kmsg-6.1-caller are the same records of linux 6.1 built without and
+// with CONFIG_PRINTK_CALLER.
+func TestParseRecordCallerConfig(t *testing.T) {
+	plain, err := Dmesg(WithPath("testdata/kmsg-6.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withCaller, err := Dmesg(WithPath("testdata/kmsg-6.1-caller"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plain) != 15 || len(withCaller) != len(plain) {
+		t.Fatalf("read %d and %d messages, want 15", len(plain), len(withCaller))
+	}
+
+	for i, msg := range withCaller {
+		if plain[i].Caller != "" {
+			t.Errorf("message %d without CONFIG_PRINTK_CALLER has caller %q", plain[i].Seq, plain[i].Caller)
+		}
+		if len(msg.Caller) < 2 || msg.Caller[0] != 'T' && msg.Caller[0] != 'C' {
+			t.Errorf("message %d has caller %q, want a task or CPU", msg.Seq, msg.Caller)
+		}
+		msg.Caller = ""
+		if !reflect.DeepEqual(msg, plain[i]) {
+			t.Errorf("message %d with caller = %+v, want %+v", msg.Seq, msg, plain[i])
+		}
+	}
+	if withCaller[12].Caller != "C2" || withCaller[14].Caller != "T288" {
+		t.Errorf("callers %q and %q, want C2 and T288", withCaller[12].Caller, withCaller[14].Caller)
+	}
+
+	for _, path := range []string{"testdata/kmsg-6.1", "testdata/kmsg-6.1-caller"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := RawDmesg(WithPath(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The records are marshaled back as the kernel wrote them, and the callers are
+		// filtered on its id.
+		var marshaled []byte
+		for _, record := range raw {
+			msg, err := ParseRecord(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			marshaled = append(marshaled, MarshalRecord(msg)...)
+		}
+		if string(marshaled) != string(data) {
+			t.Errorf("%s marshaled back =\n%s\nwant\n%s", path, marshaled, data)
+		}
+
+		msgs, err := Dmesg(WithPath(path), WithCaller("T86"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []uint64{340, 341, 342}
+		if path == "testdata/kmsg-6.1" {
+			want = []uint64{}
+		}
+		if got := seqs(msgs); !slices.Equal(got, want) {
+			t.Errorf("%s WithCaller(T86) seqs = %v, want %v", path, got, want)
+		}
+	}
+}
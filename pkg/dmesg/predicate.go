@@ -0,0 +1,55 @@
+package dmesg
+
+// Predicate reports whether a message is kept, e.g. by Messages.Filter.
+type Predicate func(Msg) bool
+
+// Matcher returns a Predicate keeping the messages which pass the filters of opts, e.g.
+// WithMinLevel, WithCaller, WithSince, WithMatch or WithSubsystem, so the filters of a read can
+// be applied to messages already read. Other options are ignored. Wall clock times of
+// WithSinceTime and WithUntilTime are converted with the boot time of the system, resolving it
+// may fail.
+func Matcher(opts ...Option) (Predicate, error) {
+	o := newOptions(opts)
+	if err := o.resolveTimes(); err != nil {
+		return nil, err
+	}
+
+	return func(msg Msg) bool {
+		return o.matchPrefix(&msg) && o.matchText(&msg) && o.match(&msg)
+	}, nil
+}
+
+// And returns a Predicate keeping the messages kept by all of preds, or all messages when
+// there are none.
+func And(preds ...Predicate) Predicate {
+	return func(msg Msg) bool {
+		for _, pred := range preds {
+			if !pred(msg) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// Or returns a Predicate keeping the messages kept by any of preds, or no message when there
+// are none.
+func Or(preds ...Predicate) Predicate {
+	return func(msg Msg) bool {
+		for _, pred := range preds {
+			if pred(msg) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Not returns a Predicate keeping the messages pred drops.
+func Not(pred Predicate) Predicate {
+	return func(msg Msg) bool {
+		return !pred(msg)
+	}
+}
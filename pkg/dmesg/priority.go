@@ -0,0 +1,134 @@
+package dmesg
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Level is the syslog level of a message, the lower the more severe.
+type Level uint8
+
+const (
+	LevelEmerg  Level = iota // System is unusable
+	LevelAlert               // Action must be taken immediately
+	LevelCrit                // Critical conditions
+	LevelErr                 // Error conditions
+	LevelWarn                // Warning conditions
+	LevelNotice              // Normal but significant condition
+	LevelInfo                // Informational
+	LevelDebug               // Debug-level messages
+)
+
+// levelNames are the names of the levels as printed by 'dmesg -x'.
+var levelNames = [...]string{"emerg", "alert", "crit", "err", "warn", "notice", "info", "debug"}
+
+// String returns the name of l as printed by 'dmesg -x', e.g. "err", or its number if it's
+// not a valid level.
+func (l Level) String() string {
+	if int(l) < len(levelNames) {
+		return levelNames[l]
+	}
+
+	return strconv.Itoa(int(l))
+}
+
+// ParseLevel parses a level from its name like 'dmesg --level' accepts, e.g. "err", or from
+// its number, e.g. "3".
+func ParseLevel(s string) (Level, error) {
+	for l, name := range levelNames {
+		if s == name {
+			return Level(l), nil
+		}
+	}
+
+	if n, err := strconv.ParseUint(s, 10, 8); err == nil && n <= uint64(LevelDebug) {
+		return Level(n), nil
+	}
+
+	return 0, fmt.Errorf("dmesg: unknown level %q", s)
+}
+
+// Facility is the syslog facility of a message, telling which part of the system logged it.
+// Messages of the kernel have FacilityKern, the ones written to /dev/kmsg by userspace usually
+// FacilityUser.
+type Facility uint8
+
+const (
+	FacilityKern     Facility = iota // Kernel messages
+	FacilityUser                     // Random user-level messages
+	FacilityMail                     // Mail system
+	FacilityDaemon                   // System daemons
+	FacilityAuth                     // Security/authorization messages
+	FacilitySyslog                   // Messages generated internally by syslogd
+	FacilityLpr                      // Line printer subsystem
+	FacilityNews                     // Network news subsystem
+	FacilityUucp                     // UUCP subsystem
+	FacilityCron                     // Clock daemon
+	FacilityAuthpriv                 // Security/authorization messages (private)
+	FacilityFtp                      // FTP daemon
+)
+
+const (
+	FacilityLocal0 Facility = iota + 16 // Reserved for local use
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// facilityNames are the names of the facilities as printed by 'dmesg -x'.
+var facilityNames = [...]string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "res0", "res1", "res2", "res3",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// String returns the name of f as printed by 'dmesg -x', e.g. "kern", or its number if it's
+// not a known facility.
+func (f Facility) String() string {
+	if int(f) < len(facilityNames) {
+		return facilityNames[f]
+	}
+
+	return strconv.Itoa(int(f))
+}
+
+// ParseFacility parses a facility from its name like 'dmesg --facility' accepts, e.g. "daemon",
+// or from its number, e.g. "3".
+func ParseFacility(s string) (Facility, error) {
+	for f, name := range facilityNames {
+		if s == name {
+			return Facility(f), nil
+		}
+	}
+
+	if n, err := strconv.ParseUint(s, 10, 8); err == nil && n < 1<<(8-facilityShift) {
+		return Facility(n), nil
+	}
+
+	return 0, fmt.Errorf("dmesg: unknown facility %q", s)
+}
+
+// priority returns Priority when it agrees with Level and Facility, i.e. m was parsed from a
+// record and not modified, or combines Level and Facility otherwise.
+func (m Msg) priority() uint64 {
+	if Level(m.Priority&levelMask) == m.Level && Facility(m.Priority>>facilityShift) == m.Facility {
+		return m.Priority
+	}
+
+	return uint64(m.Facility)<<facilityShift | uint64(m.Level)
+}
+
+// IsKernel reports whether m was logged by the kernel, i.e. has FacilityKern.
+func (m Msg) IsKernel() bool {
+	return m.Facility == FacilityKern
+}
+
+// IsUserspace reports whether m was written to /dev/kmsg by userspace, i.e. has another
+// facility than FacilityKern.
+func (m Msg) IsUserspace() bool {
+	return m.Facility != FacilityKern
+}
@@ -0,0 +1,95 @@
+package dmesg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Source is the kernel interface messages are read from, see WithSource.
+type Source uint8
+
+const (
+	// SourceDevKmsg reads the records of /dev/kmsg, or of the path of WithPath. It's the
+	// default.
+	SourceDevKmsg Source = iota
+	// SourceProcKmsg reads the lines of the legacy /proc/kmsg, for kernels without /dev/kmsg,
+	// and only works with Follow. Reads consume the messages: a message read is gone for all
+	// other readers of /proc/kmsg and syslog(2), e.g. klogd, and only one reader gets each
+	// message. Reading needs CAP_SYSLOG. The messages have the fidelity of
+	// WithKlogctlFallback, Seq is 0 and there is no device info. Following starts at the
+	// oldest message not yet consumed, WithPath and the options for the start position and
+	// buf sizes don't apply.
+	SourceProcKmsg
+)
+
+var sourceNames = [...]string{"/dev/kmsg", "/proc/kmsg"}
+
+// String returns the path of the interface of s, e.g. "/proc/kmsg".
+func (s Source) String() string {
+	if int(s) < len(sourceNames) {
+		return sourceNames[s]
+	}
+
+	return strconv.Itoa(int(s))
+}
+
+// followProcKmsg delivers the messages read from /proc/kmsg to msgs until ctx is done or
+// reading fails, see SourceProcKmsg.
+func followProcKmsg(ctx context.Context, o options, msgs chan<- Msg) error {
+	if err := o.resolveTimes(); err != nil {
+		return err
+	}
+
+	// Nonblocking so reads wait in the poller and can be canceled with a deadline.
+	file, err := os.OpenFile(SourceProcKmsg.String(), syscall.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if errors.Is(err, os.ErrPermission) {
+		return fmt.Errorf("dmesg: reading %v needs CAP_SYSLOG: %w", SourceProcKmsg, err)
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		file.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	// A read returns as many lines as fit, the last one may continue in the next read.
+	reader := bufio.NewReader(file)
+	delivered := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return err
+		}
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		if len(line) == 0 {
+			continue
+		}
+
+		o.syncSuspended()
+		msg, _ := parseSyslogLine(line)
+		if !o.keep(&msg) {
+			continue
+		}
+
+		select {
+		case msgs <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		delivered++
+		if delivered == o.maxMessages {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package prometheus provides a prometheus.Collector of kernel messages, counting them by
+// level and facility so alerts can be set on e.g. the rate of messages with level <= 3:
+//
+//	c := prometheus.NewCollector()
+//	client.MustRegister(c)
+//	go c.Follow(ctx)
+package prometheus
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	client "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/martzki/dmesg/pkg/dmesg"
+)
+
+var (
+	messagesDesc = client.NewDesc("dmesg_messages_total",
+		"Number of kernel messages by level and facility.", []string{"level", "facility"}, nil)
+	overrunsDesc = client.NewDesc("dmesg_overruns_total",
+		"Number of times kernel messages were overwritten before being read by the process.", nil, nil)
+	droppedDesc = client.NewDesc("dmesg_dropped_records_total",
+		"Number of kernel records lost before being read by the process.", nil, nil)
+	parseErrorsDesc = client.NewDesc("dmesg_parse_errors_total",
+		"Number of kernel records which failed to be parsed by the process.", nil, nil)
+	newestAgeDesc = client.NewDesc("dmesg_newest_message_age_seconds",
+		"Time since the newest kernel message was logged.", nil, nil)
+)
+
+type key struct {
+	level    dmesg.Level
+	facility dmesg.Facility
+}
+
+// Collector is a prometheus.Collector of kernel messages. Messages are counted by level and
+// facility, each message once by its sequence number, whether it comes from Scrape, Poll or
+// Follow. Overruns, dropped records and parse errors are the ones of all reads of the process,
+// see dmesg.TotalStats. A Collector is safe for concurrent use.
+type Collector struct {
+	mu      sync.Mutex
+	counts  map[key]uint64
+	seq     uint64 // Sequence number of the newest message counted
+	hasSeq  bool
+	newest  time.Time // Wall clock time of the newest message counted
+	hasTime bool
+}
+
+// NewCollector returns a Collector which didn't count any message yet.
+func NewCollector() *Collector {
+	return &Collector{counts: make(map[key]uint64)}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *client.Desc) {
+	ch <- messagesDesc
+	ch <- overrunsDesc
+	ch <- droppedDesc
+	ch <- parseErrorsDesc
+	ch <- newestAgeDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- client.Metric) {
+	// Metrics are sent once unlocked, so a slow scrape doesn't block counting.
+	c.mu.Lock()
+	counts := make([]client.Metric, 0, len(c.counts))
+	for k, n := range c.counts {
+		counts = append(counts, client.MustNewConstMetric(messagesDesc, client.CounterValue, float64(n),
+			strconv.Itoa(int(k.level)), strconv.Itoa(int(k.facility))))
+	}
+	newest, hasTime := c.newest, c.hasTime
+	c.mu.Unlock()
+
+	for _, m := range counts {
+		ch <- m
+	}
+	stats := dmesg.TotalStats()
+	ch <- client.MustNewConstMetric(overrunsDesc, client.CounterValue, float64(stats.Overruns))
+	ch <- client.MustNewConstMetric(droppedDesc, client.CounterValue, float64(stats.Dropped))
+	ch <- client.MustNewConstMetric(parseErrorsDesc, client.CounterValue, float64(stats.ParseErrors))
+	if hasTime {
+		ch <- client.MustNewConstMetric(newestAgeDesc, client.GaugeValue, time.Since(newest).Seconds())
+	}
+}
+
+// WriteMsg counts msg unless a message with the same or a later sequence number was counted,
+// it makes a Collector a dmesg.Sink.
+func (c *Collector) WriteMsg(msg dmesg.Msg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.count(msg)
+	return nil
+}
+
+// Observe counts msgs like WriteMsg.
+func (c *Collector) Observe(msgs []dmesg.Msg) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, msg := range msgs {
+		c.count(msg)
+	}
+}
+
+func (c *Collector) count(msg dmesg.Msg) {
+	if c.hasSeq && msg.Seq <= c.seq {
+		return
+	}
+	c.seq, c.hasSeq = msg.Seq, true
+	if msg.Truncated {
+		return
+	}
+
+	c.counts[key{msg.Level, msg.Facility}]++
+	if !msg.BootTime.IsZero() {
+		c.newest = msg.CorrectedTime()
+		c.hasTime = true
+	}
+}
+
+// Scrape reads the messages in kernel ring buffer newer than the ones counted and counts them.
+// opts are passed to dmesg.DmesgSince, e.g. to only count messages of some facilities. The
+// error of reading is returned, messages read are counted anyway.
+func (c *Collector) Scrape(opts ...dmesg.Option) error {
+	c.mu.Lock()
+	seq, hasSeq := c.seq, c.hasSeq
+	c.mu.Unlock()
+
+	opts = append(opts, dmesg.WithBootTime())
+	var msgs []dmesg.Msg
+	var err error
+	if hasSeq {
+		msgs, err = dmesg.DmesgSince(seq, opts...)
+	} else {
+		msgs, err = dmesg.Dmesg(opts...)
+	}
+	c.Observe(msgs)
+
+	return err
+}
+
+// Poll calls Scrape every interval until ctx is done. Errors of Scrape don't stop polling.
+func (c *Collector) Poll(ctx context.Context, interval time.Duration, opts ...dmesg.Option) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.Scrape(opts...)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Follow follows messages with opts like dmesg.Follow and counts them, until ctx is done or
+// following fails.
+func (c *Collector) Follow(ctx context.Context, opts ...dmesg.Option) error {
+	return dmesg.FollowTo(ctx, c, append(opts, dmesg.WithBootTime())...)
+}
@@ -0,0 +1,117 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/martzki/dmesg/pkg/dmesg"
+)
+
+func TestCollectorCounts(t *testing.T) {
+	c := NewCollector()
+	c.Observe([]dmesg.Msg{
+		{Seq: 1, Level: dmesg.LevelErr, Facility: dmesg.FacilityKern},
+		{Seq: 2, Level: dmesg.LevelErr, Facility: dmesg.FacilityKern},
+		{Seq: 3, Level: dmesg.LevelInfo, Facility: dmesg.FacilityDaemon},
+		{Seq: 4, Truncated: true},
+	})
+	// Messages already counted, e.g. read again by a later scrape, are ignored.
+	c.WriteMsg(dmesg.Msg{Seq: 2, Level: dmesg.LevelErr, Facility: dmesg.FacilityKern})
+	c.WriteMsg(dmesg.Msg{Seq: 4, Level: dmesg.LevelErr, Facility: dmesg.FacilityKern})
+	c.WriteMsg(dmesg.Msg{Seq: 5, Level: dmesg.LevelErr, Facility: dmesg.FacilityKern})
+
+	want := `
+# HELP dmesg_messages_total Number of kernel messages by level and facility.
+# TYPE dmesg_messages_total counter
+dmesg_messages_total{facility="0",level="3"} 3
+dmesg_messages_total{facility="3",level="6"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), "dmesg_messages_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectorNewestAge(t *testing.T) {
+	c := NewCollector()
+	c.WriteMsg(dmesg.Msg{Seq: 1})
+	if n := testutil.CollectAndCount(c, "dmesg_newest_message_age_seconds"); n != 0 {
+		t.Errorf("age metrics without boot time = %d, want 0", n)
+	}
+
+	c.WriteMsg(dmesg.Msg{Seq: 2, TsUsec: 1e6, BootTime: time.Now().Add(-time.Minute - time.Second)})
+	if n := testutil.CollectAndCount(c, "dmesg_newest_message_age_seconds"); n != 1 {
+		t.Fatalf("age metrics = %d, want 1", n)
+	}
+
+	ch := make(chan client.Metric, 16)
+	c.Collect(ch)
+	close(ch)
+	for m := range ch {
+		if m.Desc() != newestAgeDesc {
+			continue
+		}
+		if age := testutil.ToFloat64(constCollector{m}); age < 60 || age > 120 {
+			t.Errorf("newest message age = %v, want about 60s", age)
+		}
+	}
+}
+
+func TestCollectorLint(t *testing.T) {
+	c := NewCollector()
+	c.WriteMsg(dmesg.Msg{Seq: 1, BootTime: time.Now()})
+
+	problems, err := testutil.CollectAndLint(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Errorf("%s: %s", p.Metric, p.Text)
+	}
+}
+
+func TestCollectorCollectUnlocked(t *testing.T) {
+	c := NewCollector()
+	c.Observe([]dmesg.Msg{
+		{Seq: 1, Level: dmesg.LevelErr},
+		{Seq: 2, Level: dmesg.LevelWarn},
+	})
+
+	// A scrape reading the metrics slowly must not block counting.
+	ch := make(chan client.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	<-ch
+
+	counted := make(chan struct{})
+	go func() {
+		c.WriteMsg(dmesg.Msg{Seq: 3})
+		close(counted)
+	}()
+	select {
+	case <-counted:
+	case <-time.After(5 * time.Second):
+		t.Error("WriteMsg blocked by Collect")
+	}
+
+	for range ch {
+	}
+}
+
+// constCollector collects a single metric, for testutil.ToFloat64.
+type constCollector struct {
+	m client.Metric
+}
+
+func (c constCollector) Describe(ch chan<- *client.Desc) {
+	ch <- c.m.Desc()
+}
+
+func (c constCollector) Collect(ch chan<- client.Metric) {
+	ch <- c.m
+}
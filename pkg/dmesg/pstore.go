@@ -0,0 +1,159 @@
+package dmesg
+
+import (
+	"bytes"
+	"cmp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPstoreDir is where pstore is mounted, read by ReadPstore when no dir is given.
+const DefaultPstoreDir = "/sys/fs/pstore"
+
+// BootLog is the kernel log of an earlier boot dumped to pstore when it crashed, e.g. by ramoops
+// or efi-pstore.
+type BootLog struct {
+	Reason string    // Why the log was dumped, e.g. "Oops" or "Panic", empty if unknown
+	Count  int       // Number of oopses when the log was dumped, "Oops#2" is the second
+	Time   time.Time // Time of the dump, from the files
+	Files  []string  // Paths of the parts of the dump, the newest part first
+	Msgs   []Msg     // Messages of all parts, from the oldest to the newest
+}
+
+// pstoreHeaderRe matches the header pstore puts in front of each part of a dump, e.g.
+// "Panic#1 Part1".
+var pstoreHeaderRe = regexp.MustCompile(`^(\w+)#(\d+) Part(\d+)$`)
+
+// pstorePart is a file of a dump.
+type pstorePart struct {
+	path    string
+	backend string // Backend of pstore which stored the part, e.g. "ramoops"
+	reason  string
+	count   int
+	part    int
+	time    time.Time
+	text    []byte
+}
+
+// ReadPstore reads the kernel logs dumped to pstore mounted at dir, DefaultPstoreDir when empty,
+// from the dmesg-* files, e.g. dmesg-ramoops-0 or dmesg-efi-170000000001001. A dump too large
+// for a file is split into parts, Part1 holding the newest messages, the parts are joined into
+// one BootLog. The logs are returned in the order they were dumped.
+//
+// The lines of a dump are like the ones of syslog(2), "<6>[    1.234567] text", so messages have
+// the fidelity of WithKlogctlFallback: Seq is 0 and there is no device info. Compressed dumps of
+// older kernels, named *.enc.z, can't be read and are skipped.
+func ReadPstore(dir string) ([]BootLog, error) {
+	if dir == "" {
+		dir = DefaultPstoreDir
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []pstorePart
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "dmesg-") || strings.HasSuffix(name, ".enc.z") || entry.IsDir() {
+			continue
+		}
+
+		part, err := readPstorePart(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+
+	return joinPstoreParts(parts), nil
+}
+
+// readPstorePart reads a file of a dump and its header, if any.
+func readPstorePart(path string) (pstorePart, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pstorePart{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return pstorePart{}, err
+	}
+
+	// Names are dmesg-<backend>-<id>, e.g. dmesg-ramoops-0.
+	backend, _, _ := strings.Cut(strings.TrimPrefix(filepath.Base(path), "dmesg-"), "-")
+	part := pstorePart{path: path, backend: backend, part: 1, time: info.ModTime(), text: data}
+	header, rest, _ := bytes.Cut(data, []byte("\n"))
+	if m := pstoreHeaderRe.FindSubmatch(bytes.TrimSpace(header)); m != nil {
+		part.reason = string(m[1])
+		part.count, _ = strconv.Atoi(string(m[2]))
+		part.part, _ = strconv.Atoi(string(m[3]))
+		part.text = rest
+	}
+
+	return part, nil
+}
+
+// joinPstoreParts joins the parts of each dump into a BootLog. The parts of a dump have the same
+// backend, reason and count, a file without header is a dump of its own. The count starts over
+// each boot, a part already seen starts another dump.
+func joinPstoreParts(parts []pstorePart) []BootLog {
+	type dump struct {
+		backend string
+		reason  string
+		count   int
+	}
+
+	var logs []BootLog
+	index := make(map[dump]int) // Index in logs of each dump
+	grouped := make(map[int][]pstorePart)
+	for _, part := range parts {
+		key := dump{part.backend, part.reason, part.count}
+		i, ok := index[key]
+		if ok && slices.ContainsFunc(grouped[i], func(p pstorePart) bool { return p.part == part.part }) {
+			ok = false
+		}
+		if !ok || part.reason == "" {
+			i = len(logs)
+			logs = append(logs, BootLog{Reason: part.reason, Count: part.count})
+			if part.reason != "" {
+				index[key] = i
+			}
+		}
+		grouped[i] = append(grouped[i], part)
+	}
+
+	for i := range logs {
+		// Part1 is the newest, the text of a dump starts with the highest part.
+		group := grouped[i]
+		slices.SortFunc(group, func(a, b pstorePart) int {
+			return cmp.Compare(b.part, a.part)
+		})
+
+		for j := len(group) - 1; j >= 0; j-- {
+			logs[i].Files = append(logs[i].Files, group[j].path)
+		}
+		logs[i].Time = group[len(group)-1].time
+
+		var text []byte
+		for _, part := range group {
+			text = append(text, part.text...)
+			if len(text) > 0 && text[len(text)-1] != '\n' {
+				text = append(text, '\n')
+			}
+		}
+		logs[i].Msgs = parseSyslogText(text)
+	}
+
+	slices.SortStableFunc(logs, func(a, b BootLog) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	return logs
+}
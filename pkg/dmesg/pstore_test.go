@@ -0,0 +1,150 @@
+package dmesg
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// copyPstore copies the files of testdata/pstore/backend into a temporary directory with
+// the times pstore gives them, the time of the dump, and returns the directory.
+func copyPstore(t *testing.T, backend string, times map[string]time.Time) string {
+	t.Helper()
+
+	src := filepath.Join("testdata", "pstore", backend)
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(src, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := os.WriteFile(path, data, 0o444); err != nil {
+			t.Fatal(err)
+		}
+		if mtime, ok := times[entry.Name()]; ok {
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	return dir
+}
+
+func TestReadPstoreRamoops(t *testing.T) {
+	oops := time.Date(2026, time.October, 12, 9, 14, 2, 0, time.UTC)
+	panicked := time.Date(2026, time.October, 13, 17, 40, 55, 0, time.UTC)
+	// The later dump is listed first, the logs are ordered by time.
+	dir := copyPstore(t, "ramoops", map[string]time.Time{
+		"dmesg-ramoops-0": oops,
+		"dmesg-ramoops-1": panicked,
+	})
+
+	logs, err := ReadPstore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("ReadPstore() = %d logs, want 2", len(logs))
+	}
+
+	// Compressed dumps and the console log are skipped.
+	tests := []struct {
+		reason string
+		count  int
+		time   time.Time
+		file   string
+		msgs   int
+		first  Msg
+		last   Msg
+	}{
+		{
+			"Oops", 1, oops, "dmesg-ramoops-0", 23,
+			Msg{Priority: 6, Level: LevelInfo, Flag: FlagNone, TsUsec: 288318823, Text: "usb 1-1: USB disconnect, device number 2"},
+			Msg{Priority: 4, Level: LevelWarn, Flag: FlagNone, TsUsec: 302101417, Text: "---[ end trace 0000000000000000 ]---"},
+		},
+		{
+			"Panic", 2, panicked, "dmesg-ramoops-1", 18,
+			Msg{Priority: 6, Level: LevelInfo, Flag: FlagNone, TsUsec: 411730018, Text: "sysrq: Trigger a crash"},
+			Msg{
+				Priority: 0, Level: LevelEmerg, Flag: FlagNone, TsUsec: 411730512,
+				Text: "Kernel Offset: 0x1a000000 from 0xffffffff81000000 (relocation range: 0xffffffff80000000-0xffffffffbfffffff)",
+			},
+		},
+	}
+	for i, tt := range tests {
+		log := logs[i]
+		if log.Reason != tt.reason || log.Count != tt.count || !log.Time.Equal(tt.time) {
+			t.Errorf("log %d is %s#%d at %v, want %s#%d at %v", i, log.Reason, log.Count, log.Time, tt.reason, tt.count, tt.time)
+		}
+		if !slices.Equal(log.Files, []string{filepath.Join(dir, tt.file)}) {
+			t.Errorf("log %d files = %v, want %s", i, log.Files, tt.file)
+		}
+		if len(log.Msgs) != tt.msgs {
+			t.Fatalf("log %d has %d messages, want %d", i, len(log.Msgs), tt.msgs)
+		}
+		if first, last := log.Msgs[0], log.Msgs[len(log.Msgs)-1]; !equalMsg(first, tt.first) || !equalMsg(last, tt.last) {
+			t.Errorf("log %d from %+v to %+v, want %+v to %+v", i, first, last, tt.first, tt.last)
+		}
+	}
+}
+
+func TestReadPstoreEFI(t *testing.T) {
+	dumped := time.Date(2023, time.November, 14, 22, 13, 19, 0, time.UTC)
+	dir := copyPstore(t, "efi", map[string]time.Time{
+		"dmesg-efi-169999999901001": dumped,
+		"dmesg-efi-169999999902001": dumped,
+	})
+
+	logs, err := ReadPstore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("ReadPstore() = %d logs, want the 2 parts joined", len(logs))
+	}
+
+	log := logs[0]
+	if log.Reason != "Panic" || log.Count != 1 || !log.Time.Equal(dumped) {
+		t.Errorf("log is %s#%d at %v, want Panic#1 at %v", log.Reason, log.Count, log.Time, dumped)
+	}
+	wantFiles := []string{filepath.Join(dir, "dmesg-efi-169999999901001"), filepath.Join(dir, "dmesg-efi-169999999902001")}
+	if !slices.Equal(log.Files, wantFiles) {
+		t.Errorf("files = %v, want %v", log.Files, wantFiles)
+	}
+
+	// Part2 holds the older messages, they come first.
+	if len(log.Msgs) != 15 {
+		t.Fatalf("%d messages, want 15", len(log.Msgs))
+	}
+	for i := 1; i < len(log.Msgs); i++ {
+		if log.Msgs[i].TsUsec < log.Msgs[i-1].TsUsec {
+			t.Errorf("message %d at %d before message %d at %d", i, log.Msgs[i].TsUsec, i-1, log.Msgs[i-1].TsUsec)
+		}
+	}
+	if text := log.Msgs[4].Text; text != "sd 0:0:0:0: [sda] Attached SCSI disk" {
+		t.Errorf("last message of Part2 = %q", text)
+	}
+	if text := log.Msgs[7].Text; text != "Kernel panic - not syncing: hung_task: blocked tasks" {
+		t.Errorf("panic message = %q", text)
+	}
+}
+
+func TestReadPstoreEmpty(t *testing.T) {
+	logs, err := ReadPstore(t.TempDir())
+	if err != nil || len(logs) != 0 {
+		t.Errorf("ReadPstore() of an empty pstore = %v, %v, want no logs", logs, err)
+	}
+
+	if _, err := ReadPstore(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("ReadPstore() of a missing dir = %v, want not exist", err)
+	}
+}
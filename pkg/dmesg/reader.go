@@ -0,0 +1,388 @@
+package dmesg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultBufSize is the buf size for each message used by default, 16KB.
+const DefaultBufSize = uint32(1 << 14)
+
+const (
+	defaultMaxBufSize = uint32(1 << 20) // 1MB by default
+	bufSizeLimit      = uint32(1 << 24) // 16MB at most
+)
+
+// Reader keeps /dev/kmsg open and remembers its position, so each read only returns
+// the messages appended since the previous one. When it reads a regular file or a FIFO
+// given by WithPath, e.g. a dump of /dev/kmsg, the end of the file is the same as no more
+// messages in kernel ring buffer.
+type Reader struct {
+	file kmsgFile
+	conn syscall.RawConn
+	o    options
+	buf  []byte
+	dec  *Decoder // Decoder for files which are not /dev/kmsg
+
+	seq     uint64 // Sequence number of the last record read
+	hasSeq  bool
+	bootID  string
+	dropped uint64 // Records lost during the last read
+
+	truncated uint64 // Reads failed with EINVAL since the last record read
+	pending   []byte // Record read but not handled yet since the messages were full
+
+	stats stats
+}
+
+// kmsgFile is the file messages are read from, *os.File for real files. It's an interface so
+// tests can replace openFile to simulate /dev/kmsg, together with sysRead for each read.
+type kmsgFile interface {
+	io.Reader // Reads the stream of records of files which are not /dev/kmsg
+	Seek(offset int64, whence int) (int64, error)
+	Stat() (os.FileInfo, error)
+	SyscallConn() (syscall.RawConn, error)
+	SetReadDeadline(t time.Time) error
+	Close() error
+}
+
+// openFile opens the file at path for reading messages, it can be replaced in tests.
+var openFile = func(path string) (kmsgFile, error) {
+	return os.OpenFile(path, syscall.O_RDONLY|syscall.O_NONBLOCK, 0)
+}
+
+// Open opens /dev/kmsg, or the path of WithPath, and returns a Reader positioned at the oldest
+// message in kernel ring buffer, or where WithStartAtEnd or WithStartAfterClear tell.
+func Open(opts ...Option) (*Reader, error) {
+	return open(newOptions(opts))
+}
+
+func open(o options) (*Reader, error) {
+	if o.bufSize > bufSizeLimit || o.maxBufSize > bufSizeLimit {
+		return nil, fmt.Errorf("dmesg: buf size %d exceeds the limit of %d", max(o.bufSize, o.maxBufSize), bufSizeLimit)
+	}
+	// 0 means the default buf size, an empty buf can't read any record.
+	if o.bufSize == 0 {
+		o.bufSize = DefaultBufSize
+	}
+	if o.source != SourceDevKmsg {
+		return nil, fmt.Errorf("dmesg: source %v can only be followed", o.source)
+	}
+	if err := o.resolveTimes(); err != nil {
+		return nil, err
+	}
+
+	file, err := openFile(o.path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if o.whence != io.SeekStart {
+		if _, err := file.Seek(0, o.whence); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	conn, err := file.SyscallConn()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	r := &Reader{file: file, conn: conn, o: o}
+	// A regular file or a FIFO is a stream of records rather than a record per read.
+	if info.Mode()&os.ModeCharDevice == 0 {
+		r.dec = NewDecoder(file)
+	} else {
+		// Each read returns exactly one record, so the buf can be reused for all records.
+		r.buf = make([]byte, o.bufSize)
+	}
+
+	return r, nil
+}
+
+// ReadNew reads the messages appended since the previous call, the first call reads all
+// messages in kernel ring buffer. Filter options, WithMaxMessages and WithMaxBytes apply to
+// each call.
+// If records were overwritten before being read, the Reader continues with the oldest
+// available one and an *OverrunError is returned along with the messages.
+func (r *Reader) ReadNew() ([]Msg, error) {
+	d := dmesg{msg: make([]Msg, 0), limit: r.o.maxMessages, maxBytes: r.o.maxBytes}
+	err := r.read(context.Background(), &d, false)
+	if err == nil && d.full() {
+		err = ErrTruncatedResult
+	}
+	if r.o.mergeFragments {
+		d.msg = MergeFragments(d.msg)
+	}
+	if r.o.reverse {
+		d.reverse()
+	}
+
+	return d.msg, err
+}
+
+// DroppedRecords returns the number of records lost during the last ReadNew, overwritten
+// before they could be read or missing from a file, as far as known from the gaps in the
+// sequence numbers of the records read. Records dropped by filters are not lost.
+func (r *Reader) DroppedRecords() uint64 {
+	return r.dropped
+}
+
+// Close closes /dev/kmsg or the file read.
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
+
+// sysRead is the read syscall used to read records, it can be replaced in tests.
+var sysRead = syscall.Read
+
+// readRecord reads a record from fd into buf, retrying reads interrupted by signals.
+func readRecord(fd int, buf []byte) (int, error) {
+	for {
+		n, err := sysRead(fd, buf)
+		if !errors.Is(err, syscall.EINTR) {
+			return n, err
+		}
+	}
+}
+
+// growBufSize doubles size without exceeding max.
+func growBufSize(size, max uint32) uint32 {
+	if size >= max/2 {
+		return max
+	}
+	if size == 0 {
+		return DefaultBufSize
+	}
+
+	return size * 2
+}
+
+// truncatedConsumed reports whether a read of /dev/kmsg failing with EINVAL consumes the
+// record which didn't fit, which the kernel does since linux 5.10. It can be replaced in tests.
+var truncatedConsumed = sync.OnceValue(func() bool {
+	var uts syscall.Utsname
+	if err := syscall.Uname(&uts); err != nil {
+		return false
+	}
+
+	release := make([]byte, 0, len(uts.Release))
+	for _, c := range uts.Release {
+		if c == 0 {
+			break
+		}
+		release = append(release, byte(c))
+	}
+
+	var major, minor int
+	if _, err := fmt.Sscanf(string(release), "%d.%d", &major, &minor); err != nil {
+		return false
+	}
+
+	return major > 5 || major == 5 && minor >= 10
+})
+
+// nextRecord reads the next record from fd, or from the decoder when reading a file. A pending
+// record is returned first.
+func (r *Reader) nextRecord(fd int) ([]byte, error) {
+	if r.pending != nil {
+		record := r.pending
+		r.pending = nil
+		return record, nil
+	}
+
+	if r.dec != nil {
+		record, err := r.dec.readRecord()
+		// The end of a file is the same as no more records in /dev/kmsg.
+		if errors.Is(err, io.EOF) {
+			return nil, syscall.EAGAIN
+		}
+
+		return record, err
+	}
+
+	n, err := readRecord(fd, r.buf)
+	if err != nil || n <= 0 {
+		return nil, err
+	}
+
+	return r.buf[:n], nil
+}
+
+// readFd calls f with the fd of r like syscall.RawConn.Read. Files are read through the
+// decoder, f is called directly then.
+func (r *Reader) readFd(f func(fd uintptr) bool) error {
+	if r.dec != nil {
+		f(^uintptr(0))
+		return nil
+	}
+
+	return r.conn.Read(f)
+}
+
+// read reads available records into d until there are no more records or d is full.
+func (r *Reader) read(ctx context.Context, d *dmesg, fetchRaw bool) error {
+	r.dropped = 0
+
+	var readErr error
+	overrun := &OverrunError{}
+	err := r.readFd(func(fd uintptr) bool {
+		readErr = r.readRecords(ctx, int(fd), d, fetchRaw, overrun)
+		return true
+	})
+	// EAGAIN means no more data, should be treated as normal.
+	if err == nil && !errors.Is(readErr, syscall.EAGAIN) {
+		err = readErr
+	}
+	if err == nil && overrun.Count > 0 {
+		err = overrun
+	}
+
+	return err
+}
+
+// readRecords reads records into d until reading fails or d is full. It returns syscall.EAGAIN
+// when there are no more records.
+func (r *Reader) readRecords(ctx context.Context, fd int, d *dmesg, fetchRaw bool, overrun *OverrunError) error {
+	r.o.syncSuspended()
+
+	overran := false
+	done := ctx.Done()
+	for !d.full() {
+		select {
+		case <-done:
+			return ctx.Err()
+		default:
+		}
+
+		record, err := r.nextRecord(fd)
+		if err != nil {
+			// EPIPE means records were overwritten before being read, the next read
+			// continues from the oldest available record.
+			if errors.Is(err, syscall.EPIPE) {
+				r.countOverrun()
+				overrun.Count++
+				overran = true
+				continue
+			}
+
+			// EINVAL means buf is not enough for the record, read it again with a larger
+			// buf until the max buf size is reached. Once it is, the record is skipped when
+			// the failed read consumed it, older kernels fail with it again.
+			if errors.Is(err, syscall.EINVAL) {
+				r.countTruncated()
+				if uint32(len(r.buf)) < r.o.maxBufSize {
+					r.buf = make([]byte, growBufSize(uint32(len(r.buf)), r.o.maxBufSize))
+					r.truncated++
+					continue
+				}
+				if truncatedConsumed() {
+					r.truncated++
+					continue
+				}
+			}
+
+			return err
+		}
+		if len(record) == 0 {
+			continue
+		}
+
+		seq, ok := parseSeq(record)
+		if !ok {
+			r.countParseError()
+			continue
+		}
+
+		if r.hasSeq && seq > r.seq+1 {
+			missed := seq - r.seq - 1
+			if overran {
+				overrun.Missed += missed
+				r.countDropped(missed)
+			} else if !r.addTruncated(d, seq, missed, fetchRaw) {
+				// The truncated messages filled d, the record is handled by the next read.
+				r.pending = bytes.Clone(record)
+				return nil
+			}
+		}
+		r.countRecord(len(record), seq)
+		r.seq, r.hasSeq = seq, true
+		r.truncated, overran = 0, false
+		if !d.hasOldest {
+			d.oldest, d.hasOldest = seq, true
+		}
+		d.newest, d.hasNewest = seq, true
+		if r.o.hasAfterSeq && seq <= r.o.afterSeq {
+			continue
+		}
+
+		// Raw records only need to be parsed when they are filtered.
+		if fetchRaw && !r.o.filtered() {
+			// Copy the record out so the raw data is not padded to the buf size.
+			d.addRaw(bytes.Clone(record))
+			continue
+		}
+
+		msg, ok, err := parseRecord(record, &r.o)
+		if err != nil {
+			r.countParseError()
+		}
+		if err != nil || !ok {
+			continue
+		}
+
+		if fetchRaw {
+			d.addRaw(bytes.Clone(record))
+		} else {
+			d.addMsg(msg)
+		}
+	}
+
+	return nil
+}
+
+// addTruncated handles the missed records before the record seq which weren't overwritten.
+// Since linux 5.10 the records that don't fit are consumed by the failed reads, so the last ones
+// as many as reads failed with EINVAL are reported as truncated messages with only their
+// sequence number, unless they would be dropped by the filters: the content of a truncated
+// message is unknown, it's only kept when there are no filters. The others are dropped.
+// It returns false when d got full before all truncated messages were added, the ones left
+// are added when the record seq is handled again.
+func (r *Reader) addTruncated(d *dmesg, seq, missed uint64, fetchRaw bool) bool {
+	truncated := min(r.truncated, missed)
+	r.countDropped(missed - truncated)
+	r.seq += missed - truncated
+	r.truncated = truncated
+
+	for ; r.truncated > 0; r.truncated-- {
+		if d.full() {
+			return false
+		}
+
+		r.seq++
+		if !fetchRaw && !r.o.filtered() && !(r.o.hasAfterSeq && r.seq <= r.o.afterSeq) {
+			d.addMsg(Msg{Seq: r.seq, Truncated: true})
+		}
+		if !d.hasOldest {
+			d.oldest, d.hasOldest = r.seq, true
+		}
+		d.newest, d.hasNewest = r.seq, true
+	}
+
+	return !d.full()
+}
@@ -0,0 +1,722 @@
+package dmesg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeKmsg simulates /dev/kmsg by replacing openFile and sysRead: each read returns the next
+// queued record or error, EAGAIN when there is none. Tests using it can't run in parallel.
+type fakeKmsg struct {
+	consume bool // A read failing with EINVAL consumes the record, like linux 5.10 and later
+
+	mu       sync.Mutex
+	reads    []fakeRead
+	ready    chan struct{} // Closed when reads are queued or the deadline is set
+	deadline time.Time
+	path     string
+	whence   []int // Whence of each seek
+	closed   bool
+	sizes    []int // Size of the buf of each read of a record
+}
+
+type fakeRead struct {
+	record string
+	err    error
+}
+
+// newFakeKmsg replaces /dev/kmsg by a fakeKmsg delivering reads until the test ends.
+func newFakeKmsg(t *testing.T, consume bool, reads ...fakeRead) *fakeKmsg {
+	t.Helper()
+
+	k := &fakeKmsg{consume: consume, reads: reads, ready: make(chan struct{})}
+	openFile0, sysRead0, truncatedConsumed0 := openFile, sysRead, truncatedConsumed
+	openFile = func(path string) (kmsgFile, error) {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+
+		k.path = path
+		return k, nil
+	}
+	sysRead = k.read
+	truncatedConsumed = func() bool { return consume }
+	t.Cleanup(func() {
+		openFile, sysRead, truncatedConsumed = openFile0, sysRead0, truncatedConsumed0
+	})
+
+	return k
+}
+
+// record returns a record of /dev/kmsg with level info.
+func record(seq uint64, text string) fakeRead {
+	return fakeRead{record: fmt.Sprintf("6,%d,%d,-;%s\n", seq, seq*1000, text)}
+}
+
+func fail(err error) fakeRead {
+	return fakeRead{err: err}
+}
+
+// push queues reads, waking up a reader waiting for them.
+func (k *fakeKmsg) push(reads ...fakeRead) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.reads = append(k.reads, reads...)
+	k.wake()
+}
+
+func (k *fakeKmsg) wake() {
+	close(k.ready)
+	k.ready = make(chan struct{})
+}
+
+func (k *fakeKmsg) read(fd int, buf []byte) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if len(k.reads) == 0 {
+		return -1, syscall.EAGAIN
+	}
+
+	read := k.reads[0]
+	if read.err != nil {
+		k.reads = k.reads[1:]
+		return -1, read.err
+	}
+
+	k.sizes = append(k.sizes, len(buf))
+	if len(read.record) > len(buf) {
+		if k.consume {
+			k.reads = k.reads[1:]
+		}
+		return -1, syscall.EINVAL
+	}
+	k.reads = k.reads[1:]
+
+	return copy(buf, read.record), nil
+}
+
+func (k *fakeKmsg) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (k *fakeKmsg) Seek(offset int64, whence int) (int64, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.whence = append(k.whence, whence)
+	return 0, nil
+}
+
+func (k *fakeKmsg) Stat() (os.FileInfo, error) {
+	return fakeCharDevice{}, nil
+}
+
+func (k *fakeKmsg) SyscallConn() (syscall.RawConn, error) {
+	return fakeConn{k}, nil
+}
+
+func (k *fakeKmsg) SetReadDeadline(t time.Time) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.deadline = t
+	k.wake()
+	return nil
+}
+
+func (k *fakeKmsg) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.closed = true
+	return nil
+}
+
+// fakeConn calls read functions again when reads are queued, until the deadline passed, like
+// the poller of a nonblocking file.
+type fakeConn struct {
+	k *fakeKmsg
+}
+
+func (c fakeConn) Control(f func(fd uintptr)) error {
+	f(3)
+	return nil
+}
+
+func (c fakeConn) Read(f func(fd uintptr) bool) error {
+	for !f(3) {
+		c.k.mu.Lock()
+		ready, deadline, queued := c.k.ready, c.k.deadline, len(c.k.reads) > 0
+		c.k.mu.Unlock()
+		if queued {
+			continue
+		}
+
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return os.ErrDeadlineExceeded
+			}
+			timeout = time.After(wait)
+		}
+		select {
+		case <-ready:
+		case <-timeout:
+		}
+	}
+
+	return nil
+}
+
+func (c fakeConn) Write(f func(fd uintptr) bool) error {
+	return errors.ErrUnsupported
+}
+
+type fakeCharDevice struct{}
+
+func (fakeCharDevice) Name() string       { return "kmsg" }
+func (fakeCharDevice) Size() int64        { return 0 }
+func (fakeCharDevice) Mode() os.FileMode  { return os.ModeDevice | os.ModeCharDevice | 0o644 }
+func (fakeCharDevice) ModTime() time.Time { return time.Time{} }
+func (fakeCharDevice) IsDir() bool        { return false }
+func (fakeCharDevice) Sys() any           { return nil }
+
+// truncatedSeqs returns the sequence numbers of the truncated messages of msgs.
+func truncatedSeqs(msgs []Msg) []uint64 {
+	var s []uint64
+	for _, msg := range msgs {
+		if msg.Truncated {
+			s = append(s, msg.Seq)
+		}
+	}
+
+	return s
+}
+
+func TestReadTruncated(t *testing.T) {
+	huge := strings.Repeat("x", 200)
+	tests := []struct {
+		name          string
+		consume       bool
+		opts          []Option
+		reads         []fakeRead
+		want          []uint64
+		wantTruncated []uint64
+		wantErr       error
+	}{
+		{
+			name:          "skipped at the max buf size",
+			consume:       true,
+			opts:          []Option{WithBufSize(64), WithMaxBufSize(64)},
+			reads:         []fakeRead{record(1, "a"), record(2, huge), record(3, "c")},
+			want:          []uint64{1, 2, 3},
+			wantTruncated: []uint64{2},
+		},
+		{
+			name:          "several skipped at the max buf size",
+			consume:       true,
+			opts:          []Option{WithBufSize(64), WithMaxBufSize(64)},
+			reads:         []fakeRead{record(1, "a"), record(2, huge), record(3, huge), record(4, "d")},
+			want:          []uint64{1, 2, 3, 4},
+			wantTruncated: []uint64{2, 3},
+		},
+		{
+			name:          "consumed while growing",
+			consume:       true,
+			opts:          []Option{WithBufSize(64), WithMaxBufSize(1024)},
+			reads:         []fakeRead{record(1, "a"), record(2, huge), record(3, "c")},
+			want:          []uint64{1, 2, 3},
+			wantTruncated: []uint64{2},
+		},
+		{
+			name:    "read again while growing before 5.10",
+			opts:    []Option{WithBufSize(64), WithMaxBufSize(1024)},
+			reads:   []fakeRead{record(1, "a"), record(2, huge), record(3, "c")},
+			want:    []uint64{1, 2, 3},
+			wantErr: nil,
+		},
+		{
+			name:    "EINVAL at the max buf size before 5.10",
+			opts:    []Option{WithBufSize(64), WithMaxBufSize(64)},
+			reads:   []fakeRead{record(1, "a"), record(2, huge), record(3, "c")},
+			want:    []uint64{1},
+			wantErr: syscall.EINVAL,
+		},
+		{
+			name:    "dropped by filters",
+			consume: true,
+			opts:    []Option{WithBufSize(64), WithMaxBufSize(64), WithMinLevel(LevelInfo)},
+			reads:   []fakeRead{record(1, "a"), record(2, huge), record(3, "c")},
+			want:    []uint64{1, 3},
+		},
+		{
+			name:          "lost records before the truncated one",
+			consume:       true,
+			opts:          []Option{WithBufSize(64), WithMaxBufSize(64)},
+			reads:         []fakeRead{record(1, "a"), record(4, huge), record(5, "e")},
+			want:          []uint64{1, 4, 5},
+			wantTruncated: []uint64{4},
+		},
+		{
+			name:          "counted toward the limit",
+			consume:       true,
+			opts:          []Option{WithBufSize(64), WithMaxBufSize(64), WithMaxMessages(2)},
+			reads:         []fakeRead{record(1, "a"), record(2, huge), record(3, "c")},
+			want:          []uint64{1, 2},
+			wantTruncated: []uint64{2},
+			wantErr:       ErrTruncatedResult,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeKmsg(t, tt.consume, tt.reads...)
+
+			msgs, err := Dmesg(tt.opts...)
+			if !errors.Is(err, tt.wantErr) || tt.wantErr == nil && err != nil {
+				t.Fatalf("Dmesg() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := seqs(msgs); !slices.Equal(got, tt.want) {
+				t.Errorf("seqs = %v, want %v", got, tt.want)
+			}
+			if got := truncatedSeqs(msgs); !slices.Equal(got, tt.wantTruncated) {
+				t.Errorf("truncated seqs = %v, want %v", got, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestDmesgSinceTruncated(t *testing.T) {
+	huge := strings.Repeat("x", 200)
+	newFakeKmsg(t, true, record(1, "a"), record(2, huge), record(3, "c"), record(4, huge), record(5, "e"))
+
+	msgs, err := DmesgSince(2, WithBufSize(64), WithMaxBufSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := seqs(msgs), []uint64{3, 4, 5}; !slices.Equal(got, want) {
+		t.Errorf("seqs = %v, want %v", got, want)
+	}
+	if got, want := truncatedSeqs(msgs), []uint64{4}; !slices.Equal(got, want) {
+		t.Errorf("truncated seqs = %v, want %v", got, want)
+	}
+}
+
+func TestReaderTruncatedFillsLimit(t *testing.T) {
+	huge := strings.Repeat("x", 200)
+	newFakeKmsg(t, true, record(1, "a"), record(2, huge), record(3, huge), record(4, "d"))
+
+	r, err := Open(WithBufSize(64), WithMaxBufSize(64), WithMaxMessages(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// The record read after the truncated ones is kept for the next read.
+	var got []uint64
+	for _, wantErr := range []error{ErrTruncatedResult, ErrTruncatedResult, nil} {
+		msgs, err := r.ReadNew()
+		if err != wantErr {
+			t.Fatalf("ReadNew() error = %v, want %v", err, wantErr)
+		}
+		got = append(got, seqs(msgs)...)
+	}
+	if want := []uint64{1, 2, 3, 4}; !slices.Equal(got, want) {
+		t.Errorf("seqs = %v, want %v", got, want)
+	}
+
+	stats := r.Stats()
+	if stats.Records != 2 || stats.Truncated != 2 || stats.Dropped != 0 {
+		t.Errorf("Stats() = %+v, want 2 records, 2 truncated and none dropped", stats)
+	}
+}
+
+func TestReadErrorSequences(t *testing.T) {
+	tests := []struct {
+		name    string
+		reads   []fakeRead
+		want    []uint64
+		wantErr error
+		stats   Stats
+	}{
+		{
+			name:  "empty",
+			reads: nil,
+			want:  []uint64{},
+		},
+		{
+			name:  "EAGAIN ends reading",
+			reads: []fakeRead{record(1, "a"), fail(syscall.EAGAIN), record(2, "b")},
+			want:  []uint64{1},
+			stats: Stats{Records: 1, Bytes: 13, LastSeq: 1},
+		},
+		{
+			name:  "EINTR is retried",
+			reads: []fakeRead{fail(syscall.EINTR), record(1, "a"), fail(syscall.EINTR), fail(syscall.EINTR), record(2, "b")},
+			want:  []uint64{1, 2},
+			stats: Stats{Records: 2, Bytes: 26, LastSeq: 2},
+		},
+		{
+			name:    "EPIPE continues with an overrun",
+			reads:   []fakeRead{record(1, "a"), fail(syscall.EPIPE), record(5, "e"), record(6, "f")},
+			want:    []uint64{1, 5, 6},
+			wantErr: &OverrunError{Count: 1, Missed: 3},
+			stats:   Stats{Records: 3, Bytes: 39, Overruns: 1, Dropped: 3, LastSeq: 6},
+		},
+		{
+			name:  "EINVAL skips the record and grows the buf",
+			reads: []fakeRead{record(1, "a"), record(2, strings.Repeat("b", 100)), record(3, "c")},
+			want:  []uint64{1, 2, 3},
+			stats: Stats{Records: 2, Bytes: 26, Truncated: 1, LastSeq: 3},
+		},
+		{
+			name:  "gap without error",
+			reads: []fakeRead{record(1, "a"), record(3, "c")},
+			want:  []uint64{1, 3},
+			stats: Stats{Records: 2, Bytes: 26, Dropped: 1, LastSeq: 3},
+		},
+		{
+			name:  "malformed record skipped",
+			reads: []fakeRead{record(1, "a"), {record: "garbage\n"}, {record: "6,2,x,-;bad timestamp\n"}, record(3, "c")},
+			want:  []uint64{1, 3},
+			stats: Stats{Records: 3, Bytes: 48, ParseErrors: 2, LastSeq: 3},
+		},
+		{
+			name:    "other errors end reading",
+			reads:   []fakeRead{record(1, "a"), fail(syscall.EIO), record(2, "b")},
+			want:    []uint64{1},
+			wantErr: syscall.EIO,
+			stats:   Stats{Records: 1, Bytes: 13, LastSeq: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newFakeKmsg(t, true, tt.reads...)
+
+			r, err := Open(WithBufSize(64))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			msgs, err := r.ReadNew()
+			var overrun *OverrunError
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("ReadNew() error = %v", err)
+				}
+			case *OverrunError:
+				if !errors.As(err, &overrun) || *overrun != *want {
+					t.Fatalf("ReadNew() error = %v, want %v", err, want)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Fatalf("ReadNew() error = %v, want %v", err, want)
+				}
+			}
+			if got := seqs(msgs); !slices.Equal(got, tt.want) {
+				t.Errorf("seqs = %v, want %v", got, tt.want)
+			}
+			if got := r.Stats(); got != tt.stats {
+				t.Errorf("Stats() = %+v, want %+v", got, tt.stats)
+			}
+		})
+	}
+}
+
+func TestRawDmesgNoPadding(t *testing.T) {
+	records := []fakeRead{
+		record(1, "first"),
+		{record: "3,2,2000,-;with device info\n SUBSYSTEM=pci\n DEVICE=+pci:0000:00:1f.2\n"},
+		record(3, "last"),
+	}
+	for _, opts := range [][]Option{nil, {WithMinLevel(LevelDebug)}} {
+		newFakeKmsg(t, true, records...)
+
+		raw, err := RawDmesg(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(raw) != len(records) {
+			t.Fatalf("RawDmesg(%d options) = %d records, want %d", len(opts), len(raw), len(records))
+		}
+		for i, rec := range raw {
+			if string(rec) != records[i].record {
+				t.Errorf("record %d = %q, want %q", i, rec, records[i].record)
+			}
+			// The record is copied out of the buf rather than keeping the whole buf alive.
+			if cap(rec) >= int(DefaultBufSize) {
+				t.Errorf("record %d has capacity %d, want about its length %d", i, cap(rec), len(rec))
+			}
+		}
+	}
+}
+
+func TestReadRecordRetriesEINTR(t *testing.T) {
+	sysRead0 := sysRead
+	defer func() { sysRead = sysRead0 }()
+
+	calls := 0
+	sysRead = func(fd int, buf []byte) (int, error) {
+		calls++
+		if calls <= 3 {
+			return -1, syscall.EINTR
+		}
+		return copy(buf, "6,1,0,-;a\n"), nil
+	}
+
+	buf := make([]byte, 64)
+	n, err := readRecord(3, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "6,1,0,-;a\n" || calls != 4 {
+		t.Errorf("readRecord() = %q after %d reads, want the record after 4", buf[:n], calls)
+	}
+
+	// Other errors are returned right away.
+	calls = 0
+	sysRead = func(fd int, buf []byte) (int, error) {
+		calls++
+		return -1, syscall.EIO
+	}
+	if _, err := readRecord(3, buf); !errors.Is(err, syscall.EIO) || calls != 1 {
+		t.Errorf("readRecord() = %v after %d reads, want EIO after 1", err, calls)
+	}
+}
+
+func TestDmesgEINTR(t *testing.T) {
+	newFakeKmsg(t, true,
+		fail(syscall.EINTR), fail(syscall.EINTR), fail(syscall.EINTR),
+		record(1, "a"),
+		fail(syscall.EINTR),
+		record(2, "b"),
+	)
+
+	msgs, err := Dmesg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := seqs(msgs), []uint64{1, 2}; !slices.Equal(got, want) {
+		t.Errorf("seqs = %v, want %v", got, want)
+	}
+}
+
+func TestDmesgContextCanceled(t *testing.T) {
+	var reads []fakeRead
+	for seq := uint64(1); seq <= 10; seq++ {
+		reads = append(reads, record(seq, "text"))
+	}
+	k := newFakeKmsg(t, true, reads...)
+
+	// The read of the third record cancels, as a slow read would outlive the deadline.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	read, calls := sysRead, 0
+	sysRead = func(fd int, buf []byte) (int, error) {
+		calls++
+		if calls == 3 {
+			cancel()
+		}
+		return read(fd, buf)
+	}
+
+	msgs, err := DmesgContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("DmesgContext() error = %v, want context.Canceled", err)
+	}
+	if got, want := seqs(msgs), []uint64{1, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("seqs = %v, want %v", got, want)
+	}
+	if calls != 3 || !k.closed {
+		t.Errorf("%d reads, closed %v, want 3 reads and closed", calls, k.closed)
+	}
+}
+
+func TestFollowFakeKmsg(t *testing.T) {
+	k := newFakeKmsg(t, true, record(1, "a"), record(2, "b"))
+	read, calls := sysRead, 0
+	var callsMu sync.Mutex
+	sysRead = func(fd int, buf []byte) (int, error) {
+		callsMu.Lock()
+		calls++
+		callsMu.Unlock()
+		return read(fd, buf)
+	}
+	readCalls := func() int {
+		callsMu.Lock()
+		defer callsMu.Unlock()
+		return calls
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs, errs := Follow(ctx)
+
+	receive := func(want uint64) {
+		t.Helper()
+		select {
+		case msg := <-msgs:
+			if msg.Seq != want {
+				t.Fatalf("Follow() delivered %d, want %d", msg.Seq, want)
+			}
+		case err := <-errs:
+			t.Fatalf("Follow() error = %v, want message %d", err, want)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Follow() didn't deliver %d", want)
+		}
+	}
+	receive(1)
+	receive(2)
+
+	// Waiting for new records doesn't spin.
+	before := readCalls()
+	time.Sleep(50 * time.Millisecond)
+	if n := readCalls() - before; n > 1 {
+		t.Errorf("%d reads while no record was queued, want at most 1", n)
+	}
+
+	k.push(record(3, "c"))
+	receive(3)
+
+	// Records overwritten before being read are reported, following continues after.
+	k.push(fail(syscall.EPIPE), record(7, "g"))
+	receive(7)
+	select {
+	case err := <-errs:
+		var overrun *OverrunError
+		if !errors.As(err, &overrun) || overrun.Count != 1 || overrun.Missed != 3 {
+			t.Errorf("Follow() error = %v, want an overrun of 3 messages", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Follow() didn't report the overrun")
+	}
+
+	k.push(record(8, "h"))
+	receive(8)
+
+	cancel()
+	for msgs != nil || errs != nil {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				msgs = nil
+				continue
+			}
+			t.Errorf("Follow() delivered %d after cancel", msg.Seq)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			t.Errorf("Follow() error = %v after cancel", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Follow() channels not closed after cancel")
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.closed {
+		t.Error("kmsg not closed after following ended")
+	}
+}
+
+func TestFollowMaxMessages(t *testing.T) {
+	newFakeKmsg(t, true, record(1, "a"), record(2, "b"), record(3, "c"))
+
+	msgs, errs := Follow(context.Background(), WithMaxMessages(2))
+	var got []uint64
+	for msg := range msgs {
+		got = append(got, msg.Seq)
+	}
+	for err := range errs {
+		t.Errorf("Follow() error = %v", err)
+	}
+	if want := []uint64{1, 2}; !slices.Equal(got, want) {
+		t.Errorf("seqs = %v, want %v", got, want)
+	}
+}
+
+func TestOpenSeek(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []int
+	}{
+		{"oldest", nil, nil},
+		{"end", []Option{WithStartAtEnd()}, []int{io.SeekEnd}},
+		{"after clear", []Option{WithStartAfterClear()}, []int{seekData}},
+		{"last wins", []Option{WithStartAfterClear(), WithStartAtEnd()}, []int{io.SeekEnd}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := newFakeKmsg(t, true)
+
+			r, err := Open(append(tt.opts, WithPath("/dev/kmsg.test"))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Close()
+
+			if !slices.Equal(k.whence, tt.want) {
+				t.Errorf("seeks = %v, want %v", k.whence, tt.want)
+			}
+			if k.path != "/dev/kmsg.test" {
+				t.Errorf("opened %q, want the path of WithPath", k.path)
+			}
+		})
+	}
+}
+
+func TestReaderDroppedRecords(t *testing.T) {
+	k := newFakeKmsg(t, true, record(1, "a"), record(3, "c"), fail(syscall.EPIPE), record(7, "g"))
+
+	r, err := Open(WithLevels(LevelInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// Record 2 is missing and 4 to 6 were overwritten.
+	if _, err := r.ReadNew(); !errors.As(err, new(*OverrunError)) {
+		t.Fatalf("ReadNew() error = %v, want an overrun", err)
+	}
+	if n := r.DroppedRecords(); n != 4 {
+		t.Errorf("DroppedRecords() = %d, want 4", n)
+	}
+
+	// Records dropped by filters aren't lost, the count is of the last read only.
+	k.push(record(8, "h"), fakeRead{record: "3,9,9000,-;err\n"}, record(10, "j"))
+	msgs, err := r.ReadNew()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := seqs(msgs); !slices.Equal(got, []uint64{8, 10}) {
+		t.Errorf("seqs = %v, want [8 10]", got)
+	}
+	if n := r.DroppedRecords(); n != 0 {
+		t.Errorf("DroppedRecords() = %d, want 0", n)
+	}
+	if gaps := Gaps(msgs); !slices.Equal(gaps, []SeqRange{{9, 9}}) {
+		t.Errorf("Gaps() = %v, want [9]", gaps)
+	}
+
+	if _, err := r.ReadNew(); err != nil || r.DroppedRecords() != 0 {
+		t.Errorf("ReadNew() without records = %v, DroppedRecords() = %d, want 0", err, r.DroppedRecords())
+	}
+}
@@ -0,0 +1,106 @@
+package dmesg
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SegfaultEvent is a userspace process killed by a fatal signal of a fault, see ParseSegfault.
+type SegfaultEvent struct {
+	Seq    uint64 // Sequence number of the message
+	TsUsec int64  // Timestamp of the message
+
+	Kind      string // Fault, "segfault", "general protection fault", a trap like "invalid opcode" or an arm64 fault like "level 3 translation fault"
+	Comm      string // Command of the process
+	PID       int    // PID of the process
+	Addr      uint64 // Faulting address, 0 if not logged
+	IP        uint64 // Instruction pointer, 0 if not logged
+	SP        uint64 // Stack pointer, 0 if not logged
+	ErrorCode uint64 // Error code of x86 or ESR of arm64
+	Object    string // File mapped at IP, e.g. "libfoo.so", empty if not logged
+	Base      uint64 // Address Object is mapped at
+	Offset    uint64 // Offset of IP in Object, e.g. for addr2line, 0 if unknown
+}
+
+var (
+	// "myproc[1234]: segfault at 7f3c00000000 ip 00007f3c12345678 sp 00007ffd12345678 error 4
+	// in libfoo.so[7f3c12300000+10000]".
+	segfaultRe = regexp.MustCompile(`^(.+?)\[(\d+)\]: segfault at ([0-9a-f]+) ip ([0-9a-f]+) sp ([0-9a-f]+) error ([0-9a-f]+)`)
+	// "traps: myproc[1234] general protection fault ip:7f3c12345678 sp:7ffd12345678 error:0 in
+	// libc.so.6[7f3c12300000+178000]" and "traps: myproc[1234] trap invalid opcode ip:... ".
+	trapRe = regexp.MustCompile(`^(?:traps: )?(.+?)\[(\d+)\] (general protection fault|trap .+?) ip:([0-9a-f]+) sp:([0-9a-f]+) error:([0-9a-f]+)`)
+	// "myproc[1234]: unhandled level 3 translation fault (11) at 0x0000000000000000, esr
+	// 0x92000046, in libfoo.so[aaaa00000000+1000]" of arm64.
+	unhandledRe = regexp.MustCompile(`^(.+?)\[(\d+)\]: unhandled (.+?) \(\d+\) at 0x([0-9a-f]+), esr 0x([0-9a-f]+)`)
+	// "myproc[1234]: unhandled exception: DABT (lower EL), ESR 0x92000046, level 3 translation
+	// fault in libfoo.so[aaaa00000000+1000]" of arm64 since 5.x, without the address.
+	unhandledExceptionRe = regexp.MustCompile(`^(.+?)\[(\d+)\]: unhandled exception: (?:.+?, ESR 0x([0-9a-f]+), )?(.+?)(?: in .+)?$`)
+	// " in libfoo.so[7f3c12300000+10000]" of print_vma_addr after the fault.
+	vmaAddrRe = regexp.MustCompile(` in (.+?)\[([0-9a-f]+)\+[0-9a-f]+\]`)
+)
+
+// ParseSegfault returns the SegfaultEvent of msg if it's one of the messages the kernel logs when
+// a userspace process is killed by a fault: "segfault at" and the "general protection fault" and
+// other traps of x86, or "unhandled ... fault" and "unhandled exception" of arm64. It returns
+// false for other messages and for messages with malformed or too large numbers.
+func ParseSegfault(msg Msg) (SegfaultEvent, bool) {
+	event := SegfaultEvent{Seq: msg.Seq, TsUsec: msg.TsUsec}
+
+	var pid, addr, ip, sp, code string
+	if m := segfaultRe.FindStringSubmatch(msg.Text); m != nil {
+		event.Kind, event.Comm, pid = "segfault", m[1], m[2]
+		addr, ip, sp, code = m[3], m[4], m[5], m[6]
+	} else if m := trapRe.FindStringSubmatch(msg.Text); m != nil {
+		event.Kind, event.Comm, pid = strings.TrimPrefix(m[3], "trap "), m[1], m[2]
+		ip, sp, code = m[4], m[5], m[6]
+	} else if m := unhandledRe.FindStringSubmatch(msg.Text); m != nil {
+		event.Kind, event.Comm, pid = m[3], m[1], m[2]
+		addr, code = m[4], m[5]
+	} else if m := unhandledExceptionRe.FindStringSubmatch(msg.Text); m != nil {
+		event.Kind, event.Comm, pid = m[4], m[1], m[2]
+		code = m[3]
+	} else {
+		return SegfaultEvent{}, false
+	}
+
+	var err error
+	if event.PID, err = strconv.Atoi(pid); err != nil {
+		return SegfaultEvent{}, false
+	}
+	for _, field := range []struct {
+		text  string
+		value *uint64
+	}{{addr, &event.Addr}, {ip, &event.IP}, {sp, &event.SP}, {code, &event.ErrorCode}} {
+		if field.text == "" {
+			continue
+		}
+		if *field.value, err = strconv.ParseUint(field.text, 16, 64); err != nil {
+			return SegfaultEvent{}, false
+		}
+	}
+
+	if m := vmaAddrRe.FindStringSubmatch(msg.Text); m != nil {
+		if event.Base, err = strconv.ParseUint(m[2], 16, 64); err != nil {
+			return SegfaultEvent{}, false
+		}
+		event.Object = m[1]
+		if event.IP >= event.Base {
+			event.Offset = event.IP - event.Base
+		}
+	}
+
+	return event, true
+}
+
+// SegfaultEvents returns the SegfaultEvents of msgs, see ParseSegfault.
+func SegfaultEvents(msgs []Msg) []SegfaultEvent {
+	var events []SegfaultEvent
+	for _, msg := range msgs {
+		if event, ok := ParseSegfault(msg); ok {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
@@ -0,0 +1,103 @@
+package dmesg
+
+import "testing"
+
+// The fixtures have the faults of userspace logged by linux 4.19 and 6.1 on x86_64, which adds
+// "likely on CPU" and the code of the fault since 6.1, and by linux 4.14 and 6.1 on arm64.
+func TestSegfaultEvents(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []SegfaultEvent
+	}{
+		{"segfault-4.19", []SegfaultEvent{
+			{
+				Seq: 3012, Kind: "segfault", Comm: "nginx", PID: 18422,
+				Addr: 0x8, IP: 0x55f1c3a2e7b4, SP: 0x7ffe5d7c0a20, ErrorCode: 4,
+				Object: "nginx", Base: 0x55f1c39f4000, Offset: 0x3a7b4,
+			},
+			{
+				Seq: 3013, Kind: "general protection fault", Comm: "php-fpm7.3", PID: 2210,
+				IP: 0x7f9a0c13b6f2, SP: 0x7ffd4a88e3d0,
+				Object: "libc-2.28.so", Base: 0x7f9a0c0d9000, Offset: 0x626f2,
+			},
+			{
+				Seq: 3014, Kind: "invalid opcode", Comm: "node", PID: 30121,
+				IP: 0x55a0d6a9b1c2, SP: 0x7ffc1b1fd8e8,
+				Object: "node", Base: 0x55a0d5c00000, Offset: 0xe9b1c2,
+			},
+			{
+				Seq: 3015, Kind: "divide error", Comm: "calc", PID: 771,
+				IP: 0x4004f6, SP: 0x7ffd35e8b2a0, Object: "calc", Base: 0x400000, Offset: 0x4f6,
+			},
+			{
+				Seq: 3016, Kind: "segfault", Comm: "python3", PID: 5531,
+				Addr: 0x7f2b8e1ff000, IP: 0x7f2b9c6a5b1e, SP: 0x7ffd8e1b8c48, ErrorCode: 6,
+				Object: "libc-2.28.so", Base: 0x7f2b9c62c000, Offset: 0x79b1e,
+			},
+		}},
+		{"segfault-6.1", []SegfaultEvent{
+			{
+				Seq: 5177, Kind: "segfault", Comm: "app", PID: 2741,
+				IP: 0x56023bcbf139, SP: 0x7ffcc1700f00, ErrorCode: 6,
+				Object: "app", Base: 0x56023bcbf000, Offset: 0x139,
+			},
+			{
+				Seq: 5179, Kind: "int3", Comm: "chrome", PID: 3310,
+				IP: 0x5584a6fd3b4e, SP: 0x7ffe8bd55ad0,
+				Object: "chrome", Base: 0x5584a2e59000, Offset: 0x417ab4e,
+			},
+			// The fault of a stack overflow, IP isn't in a mapped file.
+			{
+				Seq: 5180, Kind: "segfault", Comm: "ld-linux-x86-64", PID: 9102,
+				Addr: 0x7ffd3c6c1ff8, IP: 0x7f5d12c3a0a5, SP: 0x7ffd3c6c2000, ErrorCode: 6,
+			},
+		}},
+		{"segfault-4.14-arm64", []SegfaultEvent{
+			{
+				Seq: 421, Kind: "level 3 translation fault", Comm: "crash", PID: 1701,
+				ErrorCode: 0x92000046, Object: "crash", Base: 0x400000,
+			},
+		}},
+		{"segfault-6.1-arm64", []SegfaultEvent{
+			{
+				Seq: 2290, Kind: "level 0 translation fault", Comm: "crash", PID: 4412,
+				ErrorCode: 0x92000046, Object: "crash", Base: 0xaaaabb2f0000,
+			},
+			{Seq: 2292, Kind: "Oops - BUG", Comm: "sh", PID: 4413},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			msgs := readRecords(t, tt.fixture)
+			events := SegfaultEvents(msgs)
+			if len(events) != len(tt.want) {
+				t.Fatalf("SegfaultEvents() = %d events, want %d: %+v", len(events), len(tt.want), events)
+			}
+			for i, event := range events {
+				want := tt.want[i]
+				want.TsUsec = msgs[want.Seq-msgs[0].Seq].TsUsec
+				if event != want {
+					t.Errorf("event %d =\n%+v\nwant\n%+v", i, event, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSegfaultMalformed(t *testing.T) {
+	for _, text := range []string{
+		"app[1]: segfault at 10000000000000000 ip 0 sp 0 error 4",
+		"app[99999999999999999999]: segfault at 0 ip 0 sp 0 error 4",
+		"app[1]: segfault at 0 ip 0 sp 0 error 4 in app[10000000000000000+1000]",
+		"traps: app[1] trap invalid opcode ip:fffffffffffffffff sp:0 error:0",
+		"app[1]: unhandled level 3 translation fault (11) at 0x, esr 0x92000046",
+		"app[1]: segfault at",
+		"app: segfault at 0 ip 0 sp 0 error 4",
+		"segfault at 0 ip 0 sp 0 error 4",
+	} {
+		if event, ok := ParseSegfault(Msg{Text: text}); ok {
+			t.Errorf("ParseSegfault(%q) = %+v, want false", text, event)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package dmesg
+
+import (
+	"context"
+	"errors"
+)
+
+// Sink receives messages one by one as they are read, e.g. to stream them to a log pipeline
+// with FollowTo or from the loop over All.
+type Sink interface {
+	WriteMsg(msg Msg) error
+}
+
+// FollowTo is like Follow but writes each message to sink. It returns when following ends,
+// with the first error of sink, which stops following right away, or the error which ended
+// following, nil when ctx is done. An *OverrunError doesn't end following and isn't returned,
+// the messages lost are only visible as gaps of Seq.
+func FollowTo(ctx context.Context, sink Sink, opts ...Option) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var err error
+	msgs, errs := Follow(ctx, opts...)
+	for msgs != nil || errs != nil {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				msgs = nil
+				continue
+			}
+			if err != nil {
+				continue
+			}
+			if err = sink.WriteMsg(msg); err != nil {
+				cancel()
+			}
+		case followErr, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			var overrun *OverrunError
+			if err == nil && !errors.As(followErr, &overrun) {
+				err = followErr
+			}
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,98 @@
+package dmesg
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// WithSlogLevels overrides the slog levels LogTo, RunBridge and NewSlogSink log messages at,
+// mapping message levels to slog levels. Levels missing from levels keep the default mapping:
+// emerg, alert, crit and err to slog.LevelError, warn to slog.LevelWarn, notice and info to
+// slog.LevelInfo and debug to slog.LevelDebug.
+func WithSlogLevels(levels map[Level]slog.Level) Option {
+	return func(o *options) {
+		o.slogLevels = levels
+	}
+}
+
+// LogTo logs msgs to logger, each at the slog level of its level with the attributes seq,
+// facility, caller if any and a device_info group. The record time is the wall clock time of
+// the message, using the boot time of the system when BootTime isn't set. Of opts, only
+// WithSlogLevels is used.
+func LogTo(logger *slog.Logger, msgs []Msg, opts ...Option) {
+	sink := NewSlogSink(logger, opts...)
+	for _, msg := range msgs {
+		sink.WriteMsg(msg)
+	}
+}
+
+// RunBridge follows messages in kernel ring buffer with opts like Follow and logs them to
+// logger like LogTo, until ctx is done or following fails.
+func RunBridge(ctx context.Context, logger *slog.Logger, opts ...Option) error {
+	return FollowTo(ctx, NewSlogSink(logger, opts...), append(opts, WithBootTime())...)
+}
+
+// NewSlogSink returns a Sink logging each message to logger like LogTo.
+func NewSlogSink(logger *slog.Logger, opts ...Option) Sink {
+	o := newOptions(opts)
+	return &slogSink{logger: logger, levels: o.slogLevels}
+}
+
+type slogSink struct {
+	logger   *slog.Logger
+	levels   map[Level]slog.Level
+	bootTime time.Time // Resolved for the first message without BootTime
+}
+
+func (s *slogSink) WriteMsg(msg Msg) error {
+	level, ok := s.levels[msg.Level]
+	if !ok {
+		level = slogLevel(msg.Level)
+	}
+
+	ctx := context.Background()
+	if !s.logger.Enabled(ctx, level) {
+		return nil
+	}
+
+	if msg.BootTime.IsZero() {
+		if s.bootTime.IsZero() {
+			s.bootTime, _ = BootTime()
+		}
+		msg.BootTime = s.bootTime
+	}
+	var t time.Time
+	if !msg.BootTime.IsZero() {
+		t = msg.CorrectedTime()
+	}
+
+	r := slog.NewRecord(t, level, msg.Text, 0)
+	r.AddAttrs(slog.Uint64("seq", msg.Seq), slog.String("facility", msg.Facility.String()))
+	if msg.Caller != "" {
+		r.AddAttrs(slog.String("caller", msg.Caller))
+	}
+	if pairs := msg.deviceInfoPairs(); len(pairs) > 0 {
+		attrs := make([]any, 0, len(pairs))
+		for _, kv := range pairs {
+			attrs = append(attrs, slog.String(kv.Key, kv.Value))
+		}
+		r.AddAttrs(slog.Group("device_info", attrs...))
+	}
+
+	return s.logger.Handler().Handle(ctx, r)
+}
+
+// slogLevel maps a message level to the default slog level.
+func slogLevel(level Level) slog.Level {
+	switch {
+	case level <= LevelErr:
+		return slog.LevelError
+	case level == LevelWarn:
+		return slog.LevelWarn
+	case level <= LevelInfo:
+		return slog.LevelInfo
+	default:
+		return slog.LevelDebug
+	}
+}
@@ -0,0 +1,186 @@
+package dmesg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// SnapshotVersion is the version of the format Snapshot.Save writes. LoadSnapshot reads this
+// and older versions.
+const SnapshotVersion = 1
+
+// ErrUnsupportedSnapshot is returned by LoadSnapshot for a snapshot of a newer version than
+// SnapshotVersion or of no version.
+var ErrUnsupportedSnapshot = errors.New("dmesg: unsupported snapshot version")
+
+// Snapshot is a copy of the messages of a system with what is needed to analyze them
+// elsewhere, e.g. to load a snapshot taken on another machine and run the same filters and
+// formatters on it.
+type Snapshot struct {
+	Time          time.Time // Time the snapshot was taken
+	BootID        string    // Boot ID of the system, /proc/sys/kernel/random/boot_id
+	BootTime      time.Time // Time the system booted, see BootTime
+	KernelVersion string    // Release of the kernel, /proc/sys/kernel/osrelease
+	Msgs          []Msg     // Messages with BootID set
+}
+
+// jsonSnapshot is the JSON shape of a Snapshot, see Snapshot.Save.
+type jsonSnapshot struct {
+	Version       int               `json:"version"`
+	Time          time.Time         `json:"time"`
+	BootID        string            `json:"boot_id"`
+	BootTime      time.Time         `json:"boot_time"`
+	KernelVersion string            `json:"kernel_version"`
+	Msgs          []jsonSnapshotMsg `json:"messages"`
+}
+
+// jsonSnapshotMsg is the JSON shape of a Msg in a snapshot. Unlike MarshalJSON it keeps all
+// fields read from kernel ring buffer, so messages are loaded back as they were saved.
+type jsonSnapshotMsg struct {
+	Seq        uint64        `json:"seq"`
+	Priority   uint64        `json:"priority"`
+	Level      Level         `json:"level"`
+	Facility   Facility      `json:"facility"`
+	TsUsec     int64         `json:"ts_usec"`
+	Caller     string        `json:"caller,omitempty"`
+	Flag       string        `json:"flag"`
+	Fragments  []uint64      `json:"fragments,omitempty"`
+	Text       string        `json:"text"`
+	DeviceInfo []KV          `json:"device_info,omitempty"`
+	Truncated  bool          `json:"truncated,omitempty"`
+	Suspended  time.Duration `json:"suspended,omitempty"`
+	WallTime   *time.Time    `json:"wall_time,omitempty"`
+}
+
+// TakeSnapshot reads the messages of kernel ring buffer with opts like Dmesg and the boot ID,
+// boot time and kernel version of the system, the messages get the boot ID. An *OverrunError
+// or ErrTruncatedResult is returned along with the snapshot like Dmesg does.
+func TakeSnapshot(opts ...Option) (*Snapshot, error) {
+	msgs, readErr := Dmesg(opts...)
+	var overrun *OverrunError
+	if readErr != nil && !errors.As(readErr, &overrun) && !errors.Is(readErr, ErrTruncatedResult) {
+		return nil, readErr
+	}
+
+	var err error
+	snapshot := &Snapshot{Time: time.Now().Round(0), Msgs: msgs}
+	if snapshot.BootID, err = bootID(); err != nil {
+		return nil, err
+	}
+	if snapshot.BootTime, err = BootTime(); err != nil {
+		return nil, err
+	}
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return nil, err
+	}
+	snapshot.KernelVersion = string(bytes.TrimSpace(release))
+	for i := range msgs {
+		msgs[i].BootID = snapshot.BootID
+	}
+
+	return snapshot, readErr
+}
+
+// Save writes s to w as a JSON object of version SnapshotVersion:
+//
+//	version         version of the format
+//	time            RFC 3339 time the snapshot was taken
+//	boot_id         boot ID of the system
+//	boot_time       RFC 3339 time the system booted
+//	kernel_version  release of the kernel
+//	messages        array of the messages
+//
+// Each message has the fields seq, priority, level, facility, ts_usec, caller, flag,
+// fragments, text, device_info as an array of key and value objects in record order,
+// truncated, suspended in nanoseconds and wall_time. Empty fields are omitted.
+func (s *Snapshot) Save(w io.Writer) error {
+	j := jsonSnapshot{
+		Version:       SnapshotVersion,
+		Time:          s.Time,
+		BootID:        s.BootID,
+		BootTime:      s.BootTime,
+		KernelVersion: s.KernelVersion,
+		Msgs:          make([]jsonSnapshotMsg, 0, len(s.Msgs)),
+	}
+	for _, msg := range s.Msgs {
+		jm := jsonSnapshotMsg{
+			Seq:        msg.Seq,
+			Priority:   msg.Priority,
+			Level:      msg.Level,
+			Facility:   msg.Facility,
+			TsUsec:     msg.TsUsec,
+			Caller:     msg.Caller,
+			Flag:       string(rune(msg.Flag)),
+			Fragments:  msg.Fragments,
+			Text:       msg.Text,
+			DeviceInfo: msg.deviceInfoPairs(),
+			Truncated:  msg.Truncated,
+			Suspended:  msg.Suspended,
+		}
+		if msg.Flag == 0 {
+			jm.Flag = string(FlagNone)
+		}
+		if !msg.WallTime.IsZero() {
+			jm.WallTime = &msg.WallTime
+		}
+		j.Msgs = append(j.Msgs, jm)
+	}
+
+	return json.NewEncoder(w).Encode(j)
+}
+
+// LoadSnapshot reads a snapshot written by Snapshot.Save from r. The messages get the BootID
+// and BootTime of the snapshot. It returns an error wrapping ErrUnsupportedSnapshot if the
+// snapshot is of a newer version than SnapshotVersion or of no version.
+func LoadSnapshot(r io.Reader) (*Snapshot, error) {
+	var j jsonSnapshot
+	if err := json.NewDecoder(r).Decode(&j); err != nil {
+		return nil, fmt.Errorf("dmesg: decoding snapshot: %w", err)
+	}
+	if j.Version < 1 || j.Version > SnapshotVersion {
+		return nil, fmt.Errorf("%w %d, want 1 to %d", ErrUnsupportedSnapshot, j.Version, SnapshotVersion)
+	}
+
+	s := &Snapshot{
+		Time:          j.Time,
+		BootID:        j.BootID,
+		BootTime:      j.BootTime,
+		KernelVersion: j.KernelVersion,
+		Msgs:          make([]Msg, 0, len(j.Msgs)),
+	}
+	for _, jm := range j.Msgs {
+		msg := Msg{
+			Priority:       jm.Priority,
+			Level:          jm.Level,
+			Facility:       jm.Facility,
+			Seq:            jm.Seq,
+			TsUsec:         jm.TsUsec,
+			Caller:         jm.Caller,
+			Flag:           FlagNone,
+			Fragments:      jm.Fragments,
+			Text:           jm.Text,
+			DeviceInfoList: jm.DeviceInfo,
+			Truncated:      jm.Truncated,
+			BootTime:       j.BootTime,
+			Suspended:      jm.Suspended,
+			BootID:         j.BootID,
+		}
+		if len(jm.Flag) == 1 {
+			msg.Flag = Flag(jm.Flag[0])
+		}
+		if jm.WallTime != nil {
+			msg.WallTime = *jm.WallTime
+		}
+		msg.DeviceInfo = deviceInfoMap(msg.DeviceInfoList)
+		splitDeviceInfo(&msg)
+		s.Msgs = append(s.Msgs, msg)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,121 @@
+package dmesg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	boot := time.Date(2026, 10, 14, 3, 0, 0, 123456789, time.FixedZone("CEST", 2*60*60))
+	wall, err := ParseText(strings.NewReader("[Tue Oct 14 04:23:54 2026] usb 1-1: new device\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flags of fragments, repeated device info keys in the order of the record, merged
+	// fragments, wall clock time and suspended time.
+	msgs := readRecords(t, "kmsg-6.1-escapes")
+	msgs = append(msgs, MergeFragments(readRecords(t, "fragment-caller"))...)
+	msgs = append(msgs, wall...)
+	msgs = append(msgs,
+		Msg{Seq: 2000, Truncated: true},
+		Msg{Priority: 4, Level: LevelWarn, Seq: 2001, TsUsec: 7200000000, Flag: FlagNone, Text: "resumed", Suspended: 90 * time.Minute},
+	)
+	for i := range msgs {
+		msgs[i].BootID = "c6c8d1c2-8a3e-4b8f-9d4e-1f2a3b4c5d6e"
+	}
+	snapshot := &Snapshot{
+		Time:          boot.Add(5 * time.Hour),
+		BootID:        "c6c8d1c2-8a3e-4b8f-9d4e-1f2a3b4c5d6e",
+		BootTime:      boot,
+		KernelVersion: "6.1.0-13-amd64",
+		Msgs:          msgs,
+	}
+
+	var buf bytes.Buffer
+	if err := snapshot.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !loaded.Time.Equal(snapshot.Time) || !loaded.BootTime.Equal(boot) || loaded.BootID != snapshot.BootID ||
+		loaded.KernelVersion != snapshot.KernelVersion {
+		t.Errorf("LoadSnapshot() = %+v, want %+v", loaded, snapshot)
+	}
+	if len(loaded.Msgs) != len(msgs) {
+		t.Fatalf("LoadSnapshot() = %d messages, want %d", len(loaded.Msgs), len(msgs))
+	}
+	for i, msg := range msgs {
+		// The messages get the boot time of the snapshot, a zero Flag is FlagNone and the
+		// device info is the one of the record.
+		msg.BootTime = boot
+		if msg.Flag == 0 {
+			msg.Flag = FlagNone
+		}
+		if msg.DeviceInfoList == nil {
+			msg.DeviceInfoList = msg.deviceInfoPairs()
+		}
+		if len(msg.DeviceInfoList) == 0 {
+			msg.DeviceInfoList = nil
+		}
+		if got := loaded.Msgs[i]; !equalMsg(got, msg) {
+			t.Errorf("message %d = %+v, want %+v", i, got, msg)
+		}
+	}
+}
+
+func TestLoadSnapshotVersion(t *testing.T) {
+	for _, version := range []string{"0", fmt.Sprint(SnapshotVersion + 1), "-1"} {
+		_, err := LoadSnapshot(strings.NewReader(`{"version":` + version + `,"messages":[]}`))
+		if !errors.Is(err, ErrUnsupportedSnapshot) {
+			t.Errorf("LoadSnapshot() of version %s = %v, want %v", version, err, ErrUnsupportedSnapshot)
+		}
+	}
+
+	// A snapshot without version is of version 0.
+	if _, err := LoadSnapshot(strings.NewReader(`{"messages":[]}`)); !errors.Is(err, ErrUnsupportedSnapshot) {
+		t.Errorf("LoadSnapshot() without version = %v, want %v", err, ErrUnsupportedSnapshot)
+	}
+	if _, err := LoadSnapshot(strings.NewReader(`{"version":1,`)); err == nil || errors.Is(err, ErrUnsupportedSnapshot) {
+		t.Errorf("LoadSnapshot() of invalid JSON = %v, want a decoding error", err)
+	}
+
+	s, err := LoadSnapshot(strings.NewReader(`{"version":1,"boot_id":"x","messages":[{"seq":1,"text":"a"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Msgs) != 1 || s.Msgs[0].Flag != FlagNone || s.Msgs[0].BootID != "x" {
+		t.Errorf("LoadSnapshot() = %+v, want a message of flag %c and boot ID x", s.Msgs, FlagNone)
+	}
+}
+
+// TakeSnapshot of a fixture has the boot ID of the running system on every message.
+func TestTakeSnapshot(t *testing.T) {
+	s, err := TakeSnapshot(WithPath("testdata/kmsg-6.1"))
+	if err != nil {
+		t.Skip(err)
+	}
+	if s.BootID == "" || s.KernelVersion == "" || s.BootTime.IsZero() || s.Time.IsZero() {
+		t.Errorf("TakeSnapshot() = %+v, want the boot ID, kernel version, boot time and time", s)
+	}
+	want := readRecords(t, "kmsg-6.1")
+	if len(s.Msgs) != len(want) {
+		t.Fatalf("TakeSnapshot() = %d messages, want %d", len(s.Msgs), len(want))
+	}
+	for i, msg := range s.Msgs {
+		if msg.BootID != s.BootID {
+			t.Errorf("BootID of message %d = %q, want %q", msg.Seq, msg.BootID, s.BootID)
+		}
+		want[i].BootID = s.BootID
+		if !equalMsg(msg, want[i]) {
+			t.Errorf("message %d = %+v, want %+v", i, msg, want[i])
+		}
+	}
+}
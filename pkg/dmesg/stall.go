@@ -0,0 +1,201 @@
+package dmesg
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StallKind is the kind of stall of a StallEvent.
+type StallKind uint8
+
+const (
+	StallHungTask   StallKind = iota // Task blocked, "INFO: task foo:1234 blocked for more than 120 seconds."
+	StallSoftLockup                  // CPU stuck in the kernel, "watchdog: BUG: soft lockup - CPU#3 stuck for 22s! [foo:1234]"
+	StallHardLockup                  // CPU stuck with interrupts disabled, "watchdog: Watchdog detected hard LOCKUP on cpu 3"
+	StallRCU                         // RCU grace period stalled, "rcu: INFO: rcu_sched detected stalls on CPUs/tasks:"
+)
+
+var stallKindNames = [...]string{
+	StallHungTask:   "hung task",
+	StallSoftLockup: "soft lockup",
+	StallHardLockup: "hard lockup",
+	StallRCU:        "rcu stall",
+}
+
+func (k StallKind) String() string {
+	if int(k) < len(stallKindNames) {
+		return stallKindNames[k]
+	}
+
+	return "stallkind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// StallEvent is a report of the kernel about a task or CPU not making progress, grouped from the
+// messages it spans, see StallDetector.
+type StallEvent struct {
+	Kind      StallKind
+	Title     string // Text of the first message, e.g. "INFO: task foo:1234 blocked for more than 120 seconds."
+	Seq       uint64 // Sequence number of the first message
+	TsUsec    int64  // Timestamp of the first message
+	EndTsUsec int64  // Timestamp of the last message
+
+	Comm     string        // Command of the task blocked or running on the CPU stuck, empty if not logged
+	PID      int           // PID of the task, -1 if not logged
+	CPU      int           // CPU stuck or, for RCU, the first CPU stalling the grace period, -1 if not logged
+	Duration time.Duration // Time the task was blocked or the CPU stuck, 0 if not logged, e.g. for RCU
+	Jiffies  int64         // Jiffies since the grace period started of an RCU stall, "t=21002 jiffies", 0 if not logged
+
+	Msgs []Msg // Messages of the report, e.g. with its call trace
+}
+
+var (
+	// "INFO: task foo:1234 blocked for more than 120 seconds.".
+	hungTaskRe = regexp.MustCompile(`^INFO: task (.+):(\d+) blocked for more than (\d+) seconds`)
+	// "watchdog: BUG: soft lockup - CPU#3 stuck for 22s! [foo:1234]", "NMI watchdog: " before 4.x.
+	softLockupRe = regexp.MustCompile(`^(?:(?:NMI )?watchdog: )?BUG: soft lockup - CPU#(\d+) stuck for (\d+)s! \[(.+):(\d+)\]`)
+	// "watchdog: Watchdog detected hard LOCKUP on cpu 3", "NMI watchdog: " before 4.x.
+	hardLockupRe = regexp.MustCompile(`^(?:(?:NMI )?watchdog: )?Watchdog detected hard LOCKUP on cpu (\d+)`)
+	// "rcu: INFO: rcu_sched detected stalls on CPUs/tasks:" and "rcu: INFO: rcu_preempt
+	// self-detected stall on CPU", without "rcu: " before 4.20.
+	rcuStallRe = regexp.MustCompile(`^(?:rcu: )?INFO: \w+ (?:self-)?detected (?:expedited )?stalls? on`)
+	// "rcu: \t3-...!: (1 GPs behind) idle=..." of a CPU stalling the grace period.
+	rcuStallCPURe = regexp.MustCompile(`^(?:rcu: )?\s+(\d+)-\S*: \(`)
+	// "{ 5}" of the CPUs stalling the grace period listed on the marker before 4.x, or "{ 6-.... }"
+	// of an expedited stall.
+	rcuStallCPUsRe = regexp.MustCompile(`\{ (\d+)`)
+	// "(detected by 5, t=21002 jiffies, g=12345, q=678)", "(t=21002 jiffies g=12345 q=678)" or
+	// "{ 6-.... } 21003 jiffies s: 2217" of an expedited stall.
+	rcuJiffiesRe = regexp.MustCompile(`(?:\bt=|\} )(\d+) jiffies`)
+)
+
+// StallDetector groups the messages of reports of hung tasks, soft and hard lockups and RCU
+// stalls into StallEvents. It's fed messages in the order they were logged, from a snapshot or
+// Follow. A report starts with its marker, e.g. "INFO: task foo:1234 blocked for more than 120
+// seconds." or "watchdog: BUG: soft lockup - CPU#3 stuck for 22s!", and ends with the start of
+// another report, a message more than a second later or, except for RCU stalls which dump the
+// stacks of several CPUs, the " </TASK>" line after the call trace of kernels since 5.16. When
+// messages have callers, only the ones of the caller of the report are part of it. The zero
+// value is ready to use, it isn't safe for concurrent use.
+type StallDetector struct {
+	event StallEvent
+	open  bool  // Whether a report started
+	last  int64 // Timestamp of the last message of the report
+}
+
+// Observe adds msg to the report in progress and returns the StallEvent of a report ended by
+// msg, either by its end or by msg starting another report.
+func (d *StallDetector) Observe(msg Msg) (StallEvent, bool) {
+	var done StallEvent
+	var ok bool
+
+	if d.open && msg.TsUsec-d.last > reportGap.Microseconds() {
+		done, ok = d.Flush()
+	}
+	event, start := parseStallStart(msg)
+	if d.open && start {
+		done, ok = d.Flush()
+	}
+	if !d.open {
+		if !start {
+			return done, ok
+		}
+		d.event, d.open = event, true
+	} else if d.event.Msgs[0].Caller != "" && msg.Caller != "" && msg.Caller != d.event.Msgs[0].Caller {
+		return done, ok
+	}
+
+	d.add(msg)
+	if d.event.Kind != StallRCU && msg.Text == " </TASK>" {
+		return d.Flush()
+	}
+
+	return done, ok
+}
+
+// Flush returns the StallEvent of the report in progress, if any, and ends it, e.g. at the end
+// of a snapshot or when Follow stops.
+func (d *StallDetector) Flush() (StallEvent, bool) {
+	if !d.open {
+		return StallEvent{}, false
+	}
+
+	event := d.event
+	d.event, d.open = StallEvent{}, false
+
+	return event, true
+}
+
+// parseStallStart returns the StallEvent started by msg, if any, with the fields of its marker.
+func parseStallStart(msg Msg) (StallEvent, bool) {
+	event := StallEvent{Title: msg.Text, Seq: msg.Seq, TsUsec: msg.TsUsec, PID: -1, CPU: -1}
+
+	if m := hungTaskRe.FindStringSubmatch(msg.Text); m != nil {
+		event.Kind, event.Comm = StallHungTask, m[1]
+		event.PID, _ = strconv.Atoi(m[2])
+		seconds, _ := strconv.Atoi(m[3])
+		event.Duration = time.Duration(seconds) * time.Second
+	} else if m := softLockupRe.FindStringSubmatch(msg.Text); m != nil {
+		event.Kind, event.Comm = StallSoftLockup, m[3]
+		event.CPU, _ = strconv.Atoi(m[1])
+		event.PID, _ = strconv.Atoi(m[4])
+		seconds, _ := strconv.Atoi(m[2])
+		event.Duration = time.Duration(seconds) * time.Second
+	} else if m := hardLockupRe.FindStringSubmatch(msg.Text); m != nil {
+		event.Kind = StallHardLockup
+		event.CPU, _ = strconv.Atoi(m[1])
+	} else if rcuStallRe.MatchString(msg.Text) {
+		event.Kind = StallRCU
+		if m := rcuStallCPUsRe.FindStringSubmatch(msg.Text); m != nil {
+			event.CPU, _ = strconv.Atoi(m[1])
+		}
+	} else {
+		return StallEvent{}, false
+	}
+
+	return event, true
+}
+
+// add adds msg to the report in progress and parses the fields it logs.
+func (d *StallDetector) add(msg Msg) {
+	e := &d.event
+	if len(e.Msgs) < maxReportMsgs {
+		e.Msgs = append(e.Msgs, msg)
+	}
+	e.EndTsUsec = msg.TsUsec
+	d.last = msg.TsUsec
+
+	if e.Kind == StallRCU {
+		if m := rcuStallCPURe.FindStringSubmatch(msg.Text); m != nil && e.CPU == -1 {
+			e.CPU, _ = strconv.Atoi(m[1])
+		}
+		if m := rcuJiffiesRe.FindStringSubmatch(msg.Text); m != nil && e.Jiffies == 0 {
+			e.Jiffies, _ = strconv.ParseInt(m[1], 10, 64)
+		}
+		return
+	}
+	// The current task of a hard lockup is only logged with the registers.
+	if e.PID == -1 && strings.HasPrefix(msg.Text, "CPU: ") {
+		if m := oopsCPURe.FindStringSubmatch(msg.Text); m != nil {
+			e.PID, _ = strconv.Atoi(m[2])
+			e.Comm = m[3]
+		}
+	}
+}
+
+// StallEvents returns the StallEvents of msgs, see StallDetector.
+func StallEvents(msgs []Msg) []StallEvent {
+	var d StallDetector
+	var events []StallEvent
+	for _, msg := range msgs {
+		if event, ok := d.Observe(msg); ok {
+			events = append(events, event)
+		}
+	}
+	if event, ok := d.Flush(); ok {
+		events = append(events, event)
+	}
+
+	return events
+}
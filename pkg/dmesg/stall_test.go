@@ -0,0 +1,127 @@
+package dmesg
+
+import (
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// stallEvent is a StallEvent without its title and messages, and the sequence numbers of the
+// messages.
+type stallEvent struct {
+	event StallEvent
+	seqs  []uint64
+}
+
+// seqRange returns the sequence numbers from first to last.
+func seqRange(first, last uint64) []uint64 {
+	var seqs []uint64
+	for seq := first; seq <= last; seq++ {
+		seqs = append(seqs, seq)
+	}
+
+	return seqs
+}
+
+func TestStallEvents(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []stallEvent
+	}{
+		// Linux 3.10 lists the CPUs stalling a grace period on the marker, and reports a hard
+		// lockup with a warning, whose first messages aren't part of the report.
+		{"stall-3.10", []stallEvent{
+			{StallEvent{Kind: StallHungTask, Comm: "jbd2/sda1-8", PID: 312, CPU: -1, Duration: 120 * time.Second}, seqRange(4410, 4418)},
+			{StallEvent{Kind: StallSoftLockup, Comm: "kworker/2:1", PID: 8841, CPU: 2, Duration: 22 * time.Second}, seqRange(4419, 4428)},
+			{StallEvent{Kind: StallRCU, PID: -1, CPU: 5, Jiffies: 60002}, seqRange(4429, 4433)},
+			{StallEvent{Kind: StallRCU, PID: -1, CPU: 3, Jiffies: 60000}, seqRange(4434, 4435)},
+			{StallEvent{Kind: StallHardLockup, Comm: "swapper/7", PID: 0, CPU: 7}, seqRange(4438, 4444)},
+		}},
+		// The jiffies of the kthread starved aren't those of the grace period.
+		{"stall-4.15", []stallEvent{
+			{StallEvent{Kind: StallSoftLockup, Comm: "kworker/1:2", PID: 4521, CPU: 1, Duration: 23 * time.Second}, seqRange(1207, 1215)},
+			{StallEvent{Kind: StallRCU, PID: -1, CPU: 1, Jiffies: 15002}, seqRange(1216, 1222)},
+			{StallEvent{Kind: StallHungTask, Comm: "systemd-journal", PID: 402, CPU: -1, Duration: 120 * time.Second}, seqRange(1223, 1229)},
+			{StallEvent{Kind: StallHungTask, Comm: "kworker/u4:3", PID: 6124, CPU: -1, Duration: 120 * time.Second}, seqRange(1230, 1235)},
+			{StallEvent{Kind: StallHardLockup, Comm: "stress-ng", PID: 7731, CPU: 0}, seqRange(1236, 1238)},
+		}},
+		// The reports end with " </TASK>", not " </IRQ>", and the messages of other callers
+		// aren't part of them, e.g. the backtrace of the CPU stalling the grace period.
+		{"stall-6.1", []stallEvent{
+			{
+				StallEvent{Kind: StallHungTask, Comm: "kworker/u16:2", PID: 1234, CPU: -1, Duration: 122 * time.Second},
+				slices.Concat(seqRange(30551, 30557), seqRange(30559, 30562)),
+			},
+			{StallEvent{Kind: StallSoftLockup, Comm: "kworker/3:1", PID: 187, CPU: 3, Duration: 26 * time.Second}, seqRange(30563, 30576)},
+			{StallEvent{Kind: StallRCU, PID: -1, CPU: 3, Jiffies: 21002}, []uint64{30577, 30578, 30579, 30580, 30581, 30584}},
+			{StallEvent{Kind: StallRCU, PID: -1, CPU: 6, Jiffies: 21003}, seqRange(30585, 30587)},
+			{StallEvent{Kind: StallHardLockup, Comm: "swapper/6", PID: 0, CPU: 6}, seqRange(30588, 30598)},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			msgs := readRecords(t, tt.fixture)
+			checkStallEvents(t, msgs, StallEvents(msgs), tt.want)
+		})
+	}
+}
+
+// testdata/stall-2.6.32.txt is the text of dmesg of linux 2.6.32, which logs no CPU line with
+// the registers of a soft lockup.
+func TestStallEventsText(t *testing.T) {
+	file, err := os.Open("testdata/stall-2.6.32.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	msgs, err := ParseText(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []StallEvent{
+		{
+			Kind: StallHungTask, Title: msgs[0].Text, TsUsec: msgs[0].TsUsec, EndTsUsec: msgs[5].TsUsec,
+			Comm: "kjournald", PID: 831, CPU: -1, Duration: 120 * time.Second, Msgs: msgs[:6],
+		},
+		{
+			Kind: StallSoftLockup, Title: msgs[6].Text, TsUsec: msgs[6].TsUsec, EndTsUsec: msgs[11].TsUsec,
+			Comm: "java", PID: 28761, CPU: 1, Duration: 67 * time.Second, Msgs: msgs[6:],
+		},
+	}
+	if events := StallEvents(msgs); !reflect.DeepEqual(events, want) {
+		t.Errorf("StallEvents() =\n%+v\nwant\n%+v", events, want)
+	}
+}
+
+func TestStallKindString(t *testing.T) {
+	if got := StallRCU.String(); got != "rcu stall" {
+		t.Errorf("String() = %q, want rcu stall", got)
+	}
+	if got := StallKind(9).String(); got != "stallkind(9)" {
+		t.Errorf("String() = %q, want stallkind(9)", got)
+	}
+}
+
+func checkStallEvents(t *testing.T, msgs []Msg, events []StallEvent, want []stallEvent) {
+	t.Helper()
+	if len(events) != len(want) {
+		t.Fatalf("StallEvents() = %d events, want %d", len(events), len(want))
+	}
+
+	for i, event := range events {
+		w := want[i]
+		first, last := msgs[w.seqs[0]-msgs[0].Seq], msgs[w.seqs[len(w.seqs)-1]-msgs[0].Seq]
+		w.event.Title, w.event.Seq, w.event.TsUsec, w.event.EndTsUsec = first.Text, first.Seq, first.TsUsec, last.TsUsec
+		if got := seqs(event.Msgs); !slices.Equal(got, w.seqs) {
+			t.Errorf("event %d of messages %v, want %v", i, got, w.seqs)
+		}
+		event.Msgs = nil
+		if !reflect.DeepEqual(event, w.event) {
+			t.Errorf("event %d =\n%+v\nwant\n%+v", i, event, w.event)
+		}
+	}
+}
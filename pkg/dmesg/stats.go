@@ -0,0 +1,108 @@
+package dmesg
+
+import (
+	"expvar"
+	"sync/atomic"
+)
+
+// Stats are counters of records read from kernel ring buffer.
+type Stats struct {
+	Records     uint64 // Records read
+	Bytes       uint64 // Size of the records read
+	ParseErrors uint64 // Records which failed to be parsed and were skipped
+	Overruns    uint64 // Overruns while reading (EPIPE)
+	Truncated   uint64 // Records which didn't fit in the buf (EINVAL)
+	Dropped     uint64 // Records lost, from the gaps in the sequence numbers of the records read
+	LastSeq     uint64 // Sequence number of the last record read
+}
+
+// stats is updated atomically as Stats are read by other goroutines than the one reading,
+// e.g. the goroutine of Follow.
+type stats struct {
+	records     atomic.Uint64
+	bytes       atomic.Uint64
+	parseErrors atomic.Uint64
+	overruns    atomic.Uint64
+	truncated   atomic.Uint64
+	dropped     atomic.Uint64
+	lastSeq     atomic.Uint64
+}
+
+// totals are the counters of all reads of the process, see TotalStats.
+var totals stats
+
+func (s *stats) snapshot() Stats {
+	return Stats{
+		Records:     s.records.Load(),
+		Bytes:       s.bytes.Load(),
+		ParseErrors: s.parseErrors.Load(),
+		Overruns:    s.overruns.Load(),
+		Truncated:   s.truncated.Load(),
+		Dropped:     s.dropped.Load(),
+		LastSeq:     s.lastSeq.Load(),
+	}
+}
+
+// countRecord counts a record of size bytes read by r.
+func (r *Reader) countRecord(size int, seq uint64) {
+	for _, s := range []*stats{&r.stats, &totals} {
+		s.records.Add(1)
+		s.bytes.Add(uint64(size))
+		s.lastSeq.Store(seq)
+	}
+}
+
+func (r *Reader) countParseError() {
+	r.stats.parseErrors.Add(1)
+	totals.parseErrors.Add(1)
+}
+
+func (r *Reader) countOverrun() {
+	r.stats.overruns.Add(1)
+	totals.overruns.Add(1)
+}
+
+func (r *Reader) countTruncated() {
+	r.stats.truncated.Add(1)
+	totals.truncated.Add(1)
+}
+
+// countDropped counts n records lost before the record read, also for DroppedRecords.
+func (r *Reader) countDropped(n uint64) {
+	r.dropped += n
+	r.stats.dropped.Add(n)
+	totals.dropped.Add(n)
+}
+
+// Stats returns the counters of records read by r. It's safe to call while r is read by
+// another goroutine.
+func (r *Reader) Stats() Stats {
+	return r.stats.snapshot()
+}
+
+// TotalStats returns the counters of records read by the process, by all functions reading
+// kernel ring buffer including Follow. LastSeq is the one of the last record read by any of them.
+func TotalStats() Stats {
+	return totals.snapshot()
+}
+
+// PublishExpvar publishes the counters of TotalStats with expvar as prefix.records,
+// prefix.bytes, prefix.parse_errors, prefix.overruns, prefix.truncated, prefix.dropped and
+// prefix.last_seq.
+// Like expvar.Publish, it panics if a name is already published.
+func PublishExpvar(prefix string) {
+	vars := map[string]*atomic.Uint64{
+		"records":      &totals.records,
+		"bytes":        &totals.bytes,
+		"parse_errors": &totals.parseErrors,
+		"overruns":     &totals.overruns,
+		"truncated":    &totals.truncated,
+		"dropped":      &totals.dropped,
+		"last_seq":     &totals.lastSeq,
+	}
+	for name, v := range vars {
+		expvar.Publish(prefix+"."+name, expvar.Func(func() any {
+			return v.Load()
+		}))
+	}
+}
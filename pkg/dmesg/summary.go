@@ -0,0 +1,101 @@
+package dmesg
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Summary are counts of messages by level, facility and subsystem, see Summarize.
+type Summary struct {
+	Total       int                 // Number of messages
+	Errors      int                 // Messages at LevelErr or more severe
+	Levels      [LevelDebug + 1]int // Messages by level
+	Facilities  map[Facility]int    // Messages by facility
+	Subsystems  map[string]int      // Messages by SUBSYSTEM of the device info, messages without one aren't counted
+	First, Last int64               // Lowest and highest TsUsec of the messages
+	Span        time.Duration       // Time between First and Last
+}
+
+// Summarize counts msgs by level, facility and subsystem in a single pass. Messages of levels
+// above LevelDebug are only counted in Total, messages without timestamp, e.g. parsed from
+// 'dmesg -T' output, aren't counted in the time span.
+func Summarize(msgs []Msg) Summary {
+	s := Summary{Facilities: make(map[Facility]int), Subsystems: make(map[string]int)}
+
+	hasTs := false
+	for _, msg := range msgs {
+		s.Total++
+		if msg.Level <= LevelErr {
+			s.Errors++
+		}
+		if msg.Level <= LevelDebug {
+			s.Levels[msg.Level]++
+		}
+		s.Facilities[msg.Facility]++
+		if msg.Subsystem != "" {
+			s.Subsystems[msg.Subsystem]++
+		}
+
+		if msg.TsUsec < 0 {
+			continue
+		}
+		if !hasTs || msg.TsUsec < s.First {
+			s.First = msg.TsUsec
+		}
+		if !hasTs || msg.TsUsec > s.Last {
+			s.Last = msg.TsUsec
+		}
+		hasTs = true
+	}
+	s.Span = time.Duration(s.Last-s.First) * time.Microsecond
+
+	return s
+}
+
+// String returns a compact report of s, e.g.
+// "412 messages over 1h2m3s, 3 errors; levels: err=3 warn=12 info=397; facilities: kern=400
+// user=12; subsystems: pci=20 usb=4". Counts of 0 are left out, subsystems are ordered by
+// count.
+func (s Summary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d messages over %v, %d errors", s.Total, s.Span, s.Errors)
+	if s.Total == 0 {
+		return b.String()
+	}
+
+	b.WriteString("; levels:")
+	for l, n := range s.Levels {
+		if n > 0 {
+			fmt.Fprintf(&b, " %v=%d", Level(l), n)
+		}
+	}
+
+	facilities := make([]Facility, 0, len(s.Facilities))
+	for f := range s.Facilities {
+		facilities = append(facilities, f)
+	}
+	slices.Sort(facilities)
+	b.WriteString("; facilities:")
+	for _, f := range facilities {
+		fmt.Fprintf(&b, " %v=%d", f, s.Facilities[f])
+	}
+
+	if len(s.Subsystems) > 0 {
+		subsystems := make([]string, 0, len(s.Subsystems))
+		for subsystem := range s.Subsystems {
+			subsystems = append(subsystems, subsystem)
+		}
+		slices.SortFunc(subsystems, func(a, b string) int {
+			return cmp.Or(cmp.Compare(s.Subsystems[b], s.Subsystems[a]), cmp.Compare(a, b))
+		})
+		b.WriteString("; subsystems:")
+		for _, subsystem := range subsystems {
+			fmt.Fprintf(&b, " %s=%d", subsystem, s.Subsystems[subsystem])
+		}
+	}
+
+	return b.String()
+}
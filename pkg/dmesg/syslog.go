@@ -0,0 +1,143 @@
+package dmesg
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sdID is the SD-ID of the structured data RenderRFC5424 adds, using the enterprise number
+// reserved for documentation by RFC 5612.
+const sdID = "kmsg@32473"
+
+// Maximum lengths of RFC 5424 fields.
+const (
+	maxHostname = 255
+	maxAppName  = 48
+	maxSDName   = 32
+)
+
+// Pri returns the syslog PRI value of m, facility*8 + level.
+func (m Msg) Pri() int {
+	return int(m.Facility)<<facilityShift | int(m.Level)
+}
+
+// wallTime returns the wall clock time of m when it's known, see knownTime, or the current
+// time.
+func (m Msg) wallTime() time.Time {
+	if t, ok := m.knownTime(); ok {
+		return t
+	}
+
+	return time.Now()
+}
+
+// RenderRFC3164 renders msg as a BSD syslog message of RFC 3164 with the tag "kernel":
+// "<6>Oct 14 03:03:22 hostname kernel: text". The timestamp is the wall clock time of msg when
+// BootTime is set, see WithBootTime, its WallTime when only it is known, e.g. from 'dmesg -T'
+// output, or the current time otherwise. Characters of hostname other than printable US-ASCII
+// are replaced by '_'.
+func RenderRFC3164(msg Msg, hostname string) []byte {
+	buf := make([]byte, 0, 32+len(hostname)+len(msg.Text))
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(msg.Pri()), 10)
+	buf = append(buf, '>')
+	buf = msg.wallTime().AppendFormat(buf, time.Stamp)
+	buf = append(buf, ' ')
+	buf = appendPrintUSASCII(buf, hostname, len(hostname), "")
+	buf = append(buf, " kernel: "...)
+	buf = append(buf, msg.Text...)
+
+	return buf
+}
+
+// RenderRFC5424 renders msg as a syslog message of RFC 5424:
+//
+//	<6>1 2026-10-14T03:03:22.123456Z hostname appName - - [kmsg@32473 seq="42" SUBSYSTEM="pci"] text
+//
+// The timestamp is the wall clock time of msg when BootTime is set, see WithBootTime, its
+// WallTime when only it is known, or the NILVALUE "-" otherwise, like an empty hostname or
+// appName. The structured data carries seq, caller if any and the device info, SUBSYSTEM and
+// DEVICE first.
+//
+// hostname, appName and the device info keys are made fit for their fields: characters other than
+// printable US-ASCII, and '=', ']' and '"' in keys, are replaced by '_', and they are cut to 255,
+// 48 and 32 bytes.
+func RenderRFC5424(msg Msg, hostname, appName string) []byte {
+	buf := make([]byte, 0, 96+len(hostname)+len(appName)+len(msg.Text))
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(msg.Pri()), 10)
+	buf = append(buf, ">1 "...)
+	if t, ok := msg.knownTime(); ok {
+		buf = t.AppendFormat(buf, "2006-01-02T15:04:05.000000Z07:00")
+	} else {
+		buf = append(buf, '-')
+	}
+	buf = append(buf, ' ')
+	buf = appendNilValue(buf, hostname, maxHostname)
+	buf = append(buf, ' ')
+	buf = appendNilValue(buf, appName, maxAppName)
+	buf = append(buf, " - - ["+sdID...)
+
+	buf = appendSDParam(buf, "seq", strconv.FormatUint(msg.Seq, 10))
+	if msg.Caller != "" {
+		buf = appendSDParam(buf, "caller", msg.Caller)
+	}
+	for _, kv := range msg.deviceInfoPairs() {
+		buf = appendSDParam(buf, kv.Key, kv.Value)
+	}
+	buf = append(buf, ']')
+
+	if msg.Text != "" {
+		buf = append(buf, ' ')
+		buf = append(buf, msg.Text...)
+	}
+
+	return buf
+}
+
+// appendNilValue appends s to buf like appendPrintUSASCII, or the NILVALUE "-" when s is empty.
+func appendNilValue(buf []byte, s string, max int) []byte {
+	if s == "" {
+		return append(buf, '-')
+	}
+
+	return appendPrintUSASCII(buf, s, max, "")
+}
+
+// appendPrintUSASCII appends at most max bytes of s to buf, replacing the bytes other than
+// PRINTUSASCII of RFC 5424, '!' to '~', and the ones in exclude by '_'.
+func appendPrintUSASCII(buf []byte, s string, max int, exclude string) []byte {
+	if len(s) > max {
+		s = s[:max]
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '!' || c > '~' || strings.IndexByte(exclude, c) >= 0 {
+			c = '_'
+		}
+		buf = append(buf, c)
+	}
+
+	return buf
+}
+
+// appendSDParam appends ` name="value"` to buf, escaping '"', '\' and ']' in value. name is made
+// an SD-NAME, see RenderRFC5424, "_" when it's empty.
+func appendSDParam(buf []byte, name, value string) []byte {
+	buf = append(buf, ' ')
+	if name == "" {
+		name = "_"
+	}
+	buf = appendPrintUSASCII(buf, name, maxSDName, `=]"`)
+	buf = append(buf, '=', '"')
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"', '\\', ']':
+			buf = append(buf, '\\')
+		}
+		buf = append(buf, value[i])
+	}
+
+	return append(buf, '"')
+}
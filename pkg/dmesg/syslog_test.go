@@ -0,0 +1,127 @@
+package dmesg
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	// rfc3164Re is the format of RFC 3164 section 4.1: PRI, TIMESTAMP "Mmm dd hh:mm:ss" with the
+	// day padded by a space, HOSTNAME without spaces and a TAG of at most 32 alphanumeric
+	// characters before the CONTENT.
+	rfc3164Re = regexp.MustCompile(`^<(\d{1,3})>[A-Z][a-z]{2} [ 1-3]\d \d{2}:\d{2}:\d{2} [!-~]+ [[:alnum:]]{1,32}: `)
+	// rfc5424Re is the ABNF of RFC 5424 section 6: HEADER, STRUCTURED-DATA and MSG. An SD-NAME
+	// is 1 to 32 PRINTUSASCII except '=', SP, ']' and '"', a PARAM-VALUE escapes '"', '\' and ']'.
+	rfc5424Re = regexp.MustCompile(`(?s)^<(\d{1,3})>1 ` +
+		`(-|\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d{1,6})?(Z|[+-]\d{2}:\d{2})) ` +
+		`(-|[!-~]{1,255}) (-|[!-~]{1,48}) (-|[!-~]{1,128}) (-|[!-~]{1,32}) ` +
+		`(-|(\[[!#-<>-\\^-~]{1,32}( [!#-<>-\\^-~]{1,32}="([^"\\\]]|\\["\\\]])*")*\])+)` +
+		`( .*)?$`)
+)
+
+// The examples of RFC 3164 section 5.4 and RFC 5424 section 6.5, as far as RenderRFC3164 and
+// RenderRFC5424 can render them: the tag is "kernel", MSGID and PROCID are "-" and the structured
+// data is the one of the message.
+func TestRenderRFCExamples(t *testing.T) {
+	utc := time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC)
+	su := Msg{Level: LevelCrit, Facility: FacilityAuth, TsUsec: -1, WallTime: utc, Text: "'su root' failed for lonvick on /dev/pts/8"}
+	if got, want := string(RenderRFC3164(su, "mymachine")), "<34>Oct 11 22:14:15 mymachine kernel: 'su root' failed for lonvick on /dev/pts/8"; got != want {
+		t.Errorf("RenderRFC3164() = %q, want %q", got, want)
+	}
+	if got, want := string(RenderRFC5424(su, "mymachine.example.com", "su")),
+		`<34>1 2003-10-11T22:14:15.003000Z mymachine.example.com su - - [kmsg@32473 seq="0"] 'su root' failed for lonvick on /dev/pts/8`; got != want {
+		t.Errorf("RenderRFC5424() = %q, want %q", got, want)
+	}
+
+	// The day is padded by a space.
+	su.WallTime = time.Date(1987, 8, 4, 0, 0, 0, 0, time.UTC)
+	if got, want := string(RenderRFC3164(su, "mymachine")), "<34>Aug  4 00:00:00 mymachine kernel: "+su.Text; got != want {
+		t.Errorf("RenderRFC3164() = %q, want %q", got, want)
+	}
+
+	tz := time.FixedZone("", -7*60*60)
+	evnt := Msg{
+		Level: LevelNotice, Facility: FacilityLocal4, Seq: 1011, TsUsec: -1,
+		WallTime: time.Date(2003, 8, 24, 5, 14, 15, 3000, tz), Text: "An application event log entry...",
+		DeviceInfoList: []KV{{"iut", "3"}, {"eventSource", "Application"}},
+	}
+	if got, want := string(RenderRFC5424(evnt, "192.0.2.1", "evntslog")),
+		`<165>1 2003-08-24T05:14:15.000003-07:00 192.0.2.1 evntslog - - [kmsg@32473 seq="1011" iut="3" eventSource="Application"] An application event log entry...`; got != want {
+		t.Errorf("RenderRFC5424() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderRFC5424(t *testing.T) {
+	boot := time.Date(2026, 10, 14, 3, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		msg      Msg
+		hostname string
+		appName  string
+		want     string
+	}{
+		{
+			"no time",
+			Msg{Level: LevelInfo, Seq: 42, Text: "text"},
+			"", "",
+			`<6>1 - - - - - [kmsg@32473 seq="42"] text`,
+		},
+		{
+			"no text",
+			Msg{Level: LevelInfo, Seq: 42, Caller: "T1", BootTime: boot, TsUsec: 1500000},
+			"host", "kernel",
+			`<6>1 2026-10-14T03:00:01.500000Z host kernel - - [kmsg@32473 seq="42" caller="T1"]`,
+		},
+		{
+			"escaped values",
+			Msg{Level: LevelErr, Subsystem: "pci", Device: "+pci:0000:00:1f.2", DeviceInfo: map[string]string{"x": `a"b\c]d`}},
+			"host", "kernel",
+			`<3>1 - host kernel - - [kmsg@32473 seq="0" SUBSYSTEM="pci" DEVICE="+pci:0000:00:1f.2" x="a\"b\\c\]d"]`,
+		},
+		{
+			"invalid names",
+			Msg{DeviceInfoList: []KV{{`a=b c]"d`, "1"}, {"", "2"}, {"é", "3"}, {strings.Repeat("k", 40), "4"}}},
+			"my host\x00", "kernel log",
+			`<0>1 - my_host_ kernel_log - - [kmsg@32473 seq="0" a_b_c__d="1" _="2" __="3" ` + strings.Repeat("k", 32) + `="4"]`,
+		},
+		{
+			"long names",
+			Msg{},
+			strings.Repeat("h", 300), strings.Repeat("a", 60),
+			`<0>1 - ` + strings.Repeat("h", 255) + " " + strings.Repeat("a", 48) + ` - - [kmsg@32473 seq="0"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(RenderRFC5424(tt.msg, tt.hostname, tt.appName))
+			if got != tt.want {
+				t.Errorf("RenderRFC5424() = %q, want %q", got, tt.want)
+			}
+			if !rfc5424Re.MatchString(got) {
+				t.Errorf("RenderRFC5424() = %q, not a message of RFC 5424", got)
+			}
+		})
+	}
+}
+
+// The messages rendered of the fixtures match the formats of the RFCs.
+func TestRenderRFCFormat(t *testing.T) {
+	for _, fixture := range []string{"kmsg-6.1", "kmsg-6.1-caller", "usb-6.1"} {
+		for _, msg := range readRecords(t, fixture) {
+			if got := string(RenderRFC5424(msg, "host.example.com", "kernel")); !rfc5424Re.MatchString(got) {
+				t.Errorf("RenderRFC5424() = %q, not a message of RFC 5424", got)
+			}
+			got := string(RenderRFC3164(msg, "host name"))
+			if !rfc3164Re.MatchString(got) {
+				t.Errorf("RenderRFC3164() = %q, not a message of RFC 3164", got)
+			}
+			if m := rfc3164Re.FindStringSubmatch(got); m != nil && m[1] != strconv.Itoa(msg.Pri()) {
+				t.Errorf("PRI of %q, want %d", got, msg.Pri())
+			}
+		}
+	}
+}
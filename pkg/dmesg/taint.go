@@ -0,0 +1,167 @@
+package dmesg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Taint is the taint of the kernel, the bits of /proc/sys/kernel/tainted, telling whether it's
+// in a state its developers may not support, e.g. after loading a proprietary module or an oops.
+type Taint uint64
+
+const (
+	TaintProprietaryModule   Taint = 1 << iota // P, a proprietary module was loaded
+	TaintForcedModule                          // F, a module was force loaded
+	TaintCPUOutOfSpec                          // S, the system is out of specification
+	TaintForcedRmmod                           // R, a module was force unloaded
+	TaintMachineCheck                          // M, the processor reported a machine check
+	TaintBadPage                               // B, a bad page was referenced
+	TaintUser                                  // U, userspace tainted the kernel
+	TaintDie                                   // D, the kernel died recently, an oops or BUG
+	TaintOverriddenACPITable                   // A, an ACPI table was overridden
+	TaintWarn                                  // W, the kernel issued a warning
+	TaintCrap                                  // C, a staging driver was loaded
+	TaintFirmwareWorkaround                    // I, a workaround of a bug of the firmware was applied
+	TaintOOTModule                             // O, an out-of-tree module was loaded
+	TaintUnsignedModule                        // E, an unsigned module was loaded
+	TaintSoftLockup                            // L, a soft lockup occurred
+	TaintLivepatch                             // K, the kernel was live patched
+	TaintAux                                   // X, taint of distributions
+	TaintRandstruct                            // T, the kernel was built with the randstruct plugin
+	TaintTest                                  // N, an in-kernel test was run
+	TaintFwctl                                 // J, userspace used a mutating debug operation of fwctl
+)
+
+// taintFlags are the letter and reason of each bit of a Taint, in the order of the bits.
+var taintFlags = [...]struct {
+	letter byte
+	reason string
+}{
+	{'P', "proprietary module was loaded"},
+	{'F', "module was force loaded"},
+	{'S', "kernel running on an out of specification system"},
+	{'R', "module was force unloaded"},
+	{'M', "processor reported a machine check exception"},
+	{'B', "bad page referenced or unexpected page flags"},
+	{'U', "taint requested by userspace"},
+	{'D', "kernel died recently, i.e. there was an oops or BUG"},
+	{'A', "ACPI table overridden by user"},
+	{'W', "kernel issued warning"},
+	{'C', "staging driver was loaded"},
+	{'I', "workaround for bug in platform firmware applied"},
+	{'O', "externally-built (out-of-tree) module was loaded"},
+	{'E', "unsigned module was loaded"},
+	{'L', "soft lockup occurred"},
+	{'K', "kernel has been live patched"},
+	{'X', "auxiliary taint, defined for and used by distros"},
+	{'T', "kernel was built with the struct randomization plugin"},
+	{'N', "an in-kernel test has been run"},
+	{'J', "userspace used a mutating debug operation in fwctl"},
+}
+
+// ParseTaintFlags parses the taint flags the kernel logs, e.g. "P        W  O" of the "Tainted:"
+// of an oops, where 'G' and spaces stand for flags not set, or "[W]=WARN, [O]=OOT_MODULE" of
+// kernels since 6.10. "Not tainted" and an empty string are no taint. It returns an error for
+// letters it doesn't know, with the taint of the known ones.
+func ParseTaintFlags(s string) (Taint, error) {
+	s = strings.TrimSpace(s)
+	if s == "Not tainted" {
+		return 0, nil
+	}
+
+	letters := s
+	if strings.HasPrefix(s, "[") {
+		letters = ""
+		for _, flag := range strings.Split(s, ",") {
+			flag = strings.TrimSpace(flag)
+			if len(flag) < 3 || flag[0] != '[' || flag[2] != ']' {
+				return 0, fmt.Errorf("dmesg: invalid taint flag %q", flag)
+			}
+			letters += flag[1:2]
+		}
+	}
+
+	var taint Taint
+	var unknown []byte
+	for i := 0; i < len(letters); i++ {
+		c := letters[i]
+		if c == ' ' || c == 'G' {
+			continue
+		}
+		bit := taintBit(c)
+		if bit < 0 {
+			unknown = append(unknown, c)
+			continue
+		}
+		taint |= 1 << bit
+	}
+	if len(unknown) > 0 {
+		return taint, fmt.Errorf("dmesg: unknown taint flags %q", unknown)
+	}
+
+	return taint, nil
+}
+
+// taintBit returns the bit of the taint flag letter c, -1 if unknown.
+func taintBit(c byte) int {
+	for bit, flag := range taintFlags {
+		if flag.letter == c {
+			return bit
+		}
+	}
+
+	return -1
+}
+
+// ReadTaint returns the current taint of the kernel, read from /proc/sys/kernel/tainted.
+func ReadTaint() (Taint, error) {
+	data, err := os.ReadFile("/proc/sys/kernel/tainted")
+	if err != nil {
+		return 0, err
+	}
+
+	taint, err := strconv.ParseUint(string(bytes.TrimSpace(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("dmesg: invalid /proc/sys/kernel/tainted: %w", err)
+	}
+
+	return Taint(taint), nil
+}
+
+// String returns the letters of the flags of t, e.g. "POE", "Not tainted" if none. Bits
+// unknown are left out, see Reasons.
+func (t Taint) String() string {
+	if t == 0 {
+		return "Not tainted"
+	}
+
+	var b strings.Builder
+	for bit, flag := range taintFlags {
+		if t&(1<<bit) != 0 {
+			b.WriteByte(flag.letter)
+		}
+	}
+
+	return b.String()
+}
+
+// Reasons returns the reason of each flag of t, e.g. "proprietary module was loaded" for P, in
+// the order of the bits, "unknown taint bit N" for bits unknown.
+func (t Taint) Reasons() []string {
+	var reasons []string
+	for bit := 0; bit < 64; bit++ {
+		if t&(1<<bit) == 0 {
+			continue
+		}
+		if bit < len(taintFlags) {
+			reasons = append(reasons, taintFlags[bit].reason)
+		} else {
+			reasons = append(reasons, "unknown taint bit "+strconv.Itoa(bit))
+		}
+	}
+
+	return reasons
+}
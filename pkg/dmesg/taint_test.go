@@ -0,0 +1,112 @@
+package dmesg
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseTaintFlags(t *testing.T) {
+	tests := []struct {
+		flags string
+		want  Taint
+		ok    bool
+	}{
+		// The positional flags of the "Tainted:" of an oops, one column for each flag, 'G' in
+		// the one of P when no proprietary module was loaded.
+		{"G        W  OE     ", TaintWarn | TaintOOTModule | TaintUnsignedModule, true},
+		{"P           O      ", TaintProprietaryModule | TaintOOTModule, true},
+		{"G    B  D          ", TaintBadPage | TaintDie, true},
+		{"GPW", TaintProprietaryModule | TaintWarn, true},
+		{"G", 0, true},
+		// Kernels since 6.10 log the flags set with their names.
+		{"[W]=WARN, [O]=OOT_MODULE", TaintWarn | TaintOOTModule, true},
+		{"[D]=DIE, [W]=WARN, [L]=SOFTLOCKUP", TaintDie | TaintWarn | TaintSoftLockup, true},
+		{"[N]=TEST", TaintTest, true},
+		{"[W]", TaintWarn, true},
+		{"Not tainted", 0, true},
+		{"  Not tainted ", 0, true},
+		{"", 0, true},
+
+		// Letters unknown give the taint of the known ones and an error.
+		{"G        W  Z", TaintWarn, false},
+		{"Gw", 0, false},
+		{"[Q]=QUUX, [W]=WARN", TaintWarn, false},
+		{"[W=WARN", 0, false},
+		{"[W]=WARN, O", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTaintFlags(tt.flags)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseTaintFlags(%q) = %v, %v, want %v, ok %v", tt.flags, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTaintString(t *testing.T) {
+	tests := []struct {
+		taint Taint
+		want  string
+	}{
+		{0, "Not tainted"},
+		{TaintProprietaryModule | TaintOOTModule | TaintUnsignedModule, "POE"},
+		{TaintWarn | 1<<40, "W"},
+		{1 << 63, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.taint.String(); got != tt.want {
+			t.Errorf("Taint(%#x).String() = %q, want %q", uint64(tt.taint), got, tt.want)
+		}
+	}
+
+	// String gives the positional flags of every known bit back.
+	for bit := range taintFlags {
+		taint := Taint(1) << bit
+		if got, err := ParseTaintFlags(taint.String()); err != nil || got != taint {
+			t.Errorf("ParseTaintFlags(%q) = %v, %v, want %v", taint.String(), got, err, taint)
+		}
+	}
+	if taint := TaintFwctl<<1 - 1; taint.String() != "PFSRMBUDAWCIOELKXTNJ" {
+		t.Errorf("String() of all flags = %q", taint.String())
+	}
+}
+
+func TestTaintReasons(t *testing.T) {
+	tests := []struct {
+		taint Taint
+		want  []string
+	}{
+		{0, nil},
+		{TaintWarn, []string{"kernel issued warning"}},
+		{
+			TaintProprietaryModule | TaintFwctl | 1<<40 | 1<<63,
+			[]string{
+				"proprietary module was loaded", "userspace used a mutating debug operation in fwctl",
+				"unknown taint bit 40", "unknown taint bit 63",
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.taint.Reasons(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Taint(%#x).Reasons() = %q, want %q", uint64(tt.taint), got, tt.want)
+		}
+	}
+}
+
+func TestReadTaint(t *testing.T) {
+	data, err := os.ReadFile("/proc/sys/kernel/tainted")
+	if err != nil {
+		t.Skip(err)
+	}
+	want, err := strconv.ParseUint(string(bytes.TrimSpace(data)), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := ReadTaint(); err != nil || got != Taint(want) {
+		t.Errorf("ReadTaint() = %v, %v, want %v", got, err, Taint(want))
+	}
+}
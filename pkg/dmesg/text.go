@@ -0,0 +1,163 @@
+package dmesg
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// MalformedLinesError is returned by ParseText along with the messages parsed when lines
+// couldn't be parsed, they are skipped.
+type MalformedLinesError struct {
+	Count int // Number of lines skipped
+	First int // Number of the first line skipped, or record of a dump of records, from 1
+}
+
+func (e *MalformedLinesError) Error() string {
+	return fmt.Sprintf("dmesg: skipped %d malformed lines, the first at line %d", e.Count, e.First)
+}
+
+// ctimeLayout is the time format of 'dmesg -T', "%a %b %e %H:%M:%S %Y" of strftime.
+const ctimeLayout = "Mon Jan _2 15:04:05 2006"
+
+// decodedPrefixRe matches the facility and level of 'dmesg -x', e.g. "kern  :info  : ".
+var decodedPrefixRe = regexp.MustCompile(`^([a-z0-9]+) *:([a-z]+) *: `)
+
+// ParseText parses the text output of dmesg back into messages, e.g. captures of 'dmesg' and
+// 'dmesg -r' or the vmcore-dmesg.txt of kdump. A line may start with the priority "<6>" or the
+// decoded "kern  :info  : " of 'dmesg -x', followed by the timestamp "[    1.234567]", and the
+// caller "[  T123]" when the kernel logs it. A line without them continues the text of the
+// previous message, except the "** 4 printk messages dropped **" consoles print without prefix,
+// in captures of a console. It's a message of its own with the time of the previous message, and
+// the message older kernels print after it in the same line is parsed as a line of its own.
+//
+// Without priority the level is LevelWarn, the default of printk, and the facility
+// FacilityKern. Timestamps of 'dmesg -T', "[Tue Oct 14 04:23:54 2026]", are relative to no
+// boot: TsUsec is -1 and WallTime the time in the local time zone. The text has no sequence
+// numbers or device info, Seq is 0. Texts are kept as printed, with the escapes of dmesg.
+//
+// Lines starting like a prefix which can't be parsed are skipped and a *MalformedLinesError
+// is returned along with the messages. Other errors are the ones of reading r.
+func ParseText(r io.Reader) ([]Msg, error) {
+	reader := bufio.NewReader(r)
+	malformed := &MalformedLinesError{}
+
+	var msgs []Msg
+	for number := 1; ; number++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return msgs, err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+
+		if loc := printkDroppedRe.FindIndex(line); loc != nil {
+			marker := Msg{Priority: uint64(LevelWarn), Level: LevelWarn, Flag: FlagNone, Text: string(line[:loc[1]])}
+			if len(msgs) > 0 {
+				marker.TsUsec, marker.WallTime = msgs[len(msgs)-1].TsUsec, msgs[len(msgs)-1].WallTime
+			}
+			msgs = append(msgs, marker)
+			line = bytes.TrimLeft(line[loc[1]:], " ")
+		}
+
+		if len(line) > 0 {
+			msg, ok, prefixed := parseTextLine(line)
+			switch {
+			case ok:
+				msgs = append(msgs, msg)
+			case !prefixed && len(msgs) > 0:
+				msgs[len(msgs)-1].Text += "\n" + string(line)
+			default:
+				if malformed.Count == 0 {
+					malformed.First = number
+				}
+				malformed.Count++
+			}
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if malformed.Count > 0 {
+		return msgs, malformed
+	}
+
+	return msgs, nil
+}
+
+// parseTextLine parses a line of dmesg output, see ParseText. It returns false when the line
+// has no prefix, and whether it starts like one.
+func parseTextLine(line []byte) (Msg, bool, bool) {
+	msg := Msg{Priority: uint64(LevelWarn), Level: LevelWarn, Flag: FlagNone}
+
+	prefixed := false
+	if line[0] == '<' {
+		end := bytes.IndexByte(line, '>')
+		if end == -1 {
+			return Msg{}, false, true
+		}
+		pri, err := strconv.ParseUint(string(line[1:end]), 10, 64)
+		if err != nil {
+			return Msg{}, false, true
+		}
+		msg.Priority = pri
+		msg.Level = Level(pri & levelMask)
+		msg.Facility = Facility(pri >> facilityShift)
+		line = line[end+1:]
+		prefixed = true
+	} else if m := decodedPrefixRe.FindSubmatch(line); m != nil {
+		facility, err := ParseFacility(string(m[1]))
+		if err != nil {
+			return Msg{}, false, true
+		}
+		level, err := ParseLevel(string(m[2]))
+		if err != nil {
+			return Msg{}, false, true
+		}
+		msg.Level, msg.Facility = level, facility
+		msg.Priority = msg.priority()
+		line = line[len(m[0]):]
+		prefixed = true
+	}
+
+	if field, rest, ok := cutBracket(line); ok {
+		if ts, ok := parseSyslogTime(field); ok {
+			msg.TsUsec = ts
+		} else if wall, err := time.ParseInLocation(ctimeLayout, string(field), time.Local); err == nil {
+			msg.TsUsec = -1
+			msg.WallTime = wall
+		} else {
+			return Msg{}, false, true
+		}
+		line = rest
+		prefixed = true
+
+		// dmesg puts a space between the timestamp and the caller, the kernel doesn't.
+		if field, rest, ok := cutBracket(bytes.TrimPrefix(line, []byte(" "))); ok && isCaller(field) {
+			msg.Caller = string(field)
+			line = rest
+		}
+	}
+	if !prefixed {
+		return Msg{}, false, false
+	}
+	msg.Text = string(bytes.TrimPrefix(line, []byte(" ")))
+
+	return msg, true, true
+}
+
+// isCaller reports whether field is a caller of CONFIG_PRINTK_CALLER, e.g. "T123" or "C2".
+func isCaller(field []byte) bool {
+	if len(field) < 2 || field[0] != 'T' && field[0] != 'C' {
+		return false
+	}
+	_, err := strconv.ParseUint(string(field[1:]), 10, 32)
+
+	return err == nil
+}
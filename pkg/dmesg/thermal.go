@@ -0,0 +1,137 @@
+package dmesg
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// ThermalEvent is an episode of a CPU throttled because of its temperature, from the first
+// "temperature above threshold" message to the "temperature/speed normal" one, see
+// ThermalDetector.
+type ThermalEvent struct {
+	CPU       int    // CPU which logged the episode
+	Package   bool   // Whether the package of CPU was throttled, else its core
+	Seq       uint64 // Sequence number of the first throttling message
+	TsUsec    int64  // Timestamp of the first throttling message
+	EndSeq    uint64 // Sequence number of the normal message
+	EndTsUsec int64  // Timestamp of the normal message, -1 if the episode didn't end yet
+
+	Count int    // Throttling messages of the episode, 0 if its start wasn't read
+	Total uint64 // Throttling events of CPU since boot, "total events = 5", 0 if not logged
+
+	Msgs []Msg // Messages of the episode
+}
+
+// Duration returns the time CPU was throttled, 0 if the episode didn't end yet or its start
+// wasn't read.
+func (e ThermalEvent) Duration() time.Duration {
+	if e.EndTsUsec < 0 || e.Count == 0 {
+		return 0
+	}
+
+	return time.Duration(e.EndTsUsec-e.TsUsec) * time.Microsecond
+}
+
+var (
+	// "CPU0: Core temperature above threshold, cpu clock throttled (total events = 1)" and
+	// "Package temperature", with "mce: " before of x86, or "is above" and "is throttled". Kernels
+	// before 2.6.36 log "Temperature" for the core.
+	thermalThrottledRe = regexp.MustCompile(`^(?:mce: )?CPU(\d+): (?:(Core|Package) t|T)emperature (?:is )?above threshold, cpu clock (?:is )?throttled(?: \(total events = (\d+)\))?`)
+	// "CPU0: Core temperature/speed normal", "Temperature/speed normal" before 2.6.36.
+	thermalNormalRe = regexp.MustCompile(`^(?:mce: )?CPU(\d+): (?:(Core|Package) t|T)emperature/speed normal`)
+)
+
+// thermalKey is the core or package of a CPU throttled.
+type thermalKey struct {
+	cpu int
+	pkg bool
+}
+
+// ThermalDetector groups the thermal throttling messages of x86 into ThermalEvents, one per
+// episode of a CPU core or package throttled. It's fed messages in the order they were logged,
+// from a snapshot or Follow. The kernel logs "temperature above threshold" at most every five
+// minutes while throttling, the later messages are part of the episode, and "temperature/speed
+// normal" when it ends. The zero value is ready to use, it isn't safe for concurrent use.
+type ThermalDetector struct {
+	throttled map[thermalKey]*ThermalEvent // Episodes in progress
+}
+
+// Observe adds msg to the episodes in progress and returns the ThermalEvent of an episode ended
+// by msg. A normal message of an episode whose start wasn't read is an event of its own, with
+// the sequence number and timestamp of msg.
+func (d *ThermalDetector) Observe(msg Msg) (ThermalEvent, bool) {
+	if m := thermalThrottledRe.FindStringSubmatch(msg.Text); m != nil {
+		cpu, err := strconv.Atoi(m[1])
+		if err != nil {
+			return ThermalEvent{}, false
+		}
+		key := thermalKey{cpu, m[2] == "Package"}
+		e := d.throttled[key]
+		if e == nil {
+			if d.throttled == nil {
+				d.throttled = make(map[thermalKey]*ThermalEvent)
+			}
+			e = &ThermalEvent{CPU: cpu, Package: key.pkg, Seq: msg.Seq, TsUsec: msg.TsUsec, EndTsUsec: -1}
+			d.throttled[key] = e
+		}
+		e.Count++
+		if m[3] != "" {
+			e.Total, _ = strconv.ParseUint(m[3], 10, 64)
+		}
+		if len(e.Msgs) < maxReportMsgs {
+			e.Msgs = append(e.Msgs, msg)
+		}
+
+		return ThermalEvent{}, false
+	}
+
+	m := thermalNormalRe.FindStringSubmatch(msg.Text)
+	if m == nil {
+		return ThermalEvent{}, false
+	}
+	cpu, err := strconv.Atoi(m[1])
+	if err != nil {
+		return ThermalEvent{}, false
+	}
+	key := thermalKey{cpu, m[2] == "Package"}
+	e := d.throttled[key]
+	if e == nil {
+		e = &ThermalEvent{CPU: cpu, Package: key.pkg, Seq: msg.Seq, TsUsec: msg.TsUsec}
+	}
+	delete(d.throttled, key)
+	e.EndSeq, e.EndTsUsec = msg.Seq, msg.TsUsec
+	e.Msgs = append(e.Msgs, msg)
+
+	return *e, true
+}
+
+// Flush returns the episodes in progress, in the order they started, and ends them, e.g. at
+// the end of a snapshot or when Follow stops. Their EndTsUsec is -1.
+func (d *ThermalDetector) Flush() []ThermalEvent {
+	var events []ThermalEvent
+	for _, e := range d.throttled {
+		events = append(events, *e)
+	}
+	slices.SortFunc(events, func(a, b ThermalEvent) int {
+		return cmp.Or(cmp.Compare(a.TsUsec, b.TsUsec), cmp.Compare(a.Seq, b.Seq), cmp.Compare(a.CPU, b.CPU))
+	})
+	d.throttled = nil
+
+	return events
+}
+
+// ThermalEvents returns the ThermalEvents of msgs, see ThermalDetector.
+func ThermalEvents(msgs []Msg) []ThermalEvent {
+	var d ThermalDetector
+	var events []ThermalEvent
+	for _, msg := range msgs {
+		if event, ok := d.Observe(msg); ok {
+			events = append(events, event)
+		}
+	}
+
+	return append(events, d.Flush()...)
+}
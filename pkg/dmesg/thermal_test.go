@@ -0,0 +1,118 @@
+package dmesg
+
+import (
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// thermalEpisode is a ThermalEvent by its CPU, the sequence numbers of its messages and whether
+// it ended with the last of them.
+type thermalEpisode struct {
+	cpu   int
+	pkg   bool
+	count int
+	total uint64
+	seqs  []uint64
+	ended bool
+}
+
+// The fixtures have the throttling messages of linux 4.15, with "mce: " before them, and of
+// linux 5.15, which logs "is above threshold" and "is throttled".
+func TestThermalEvents(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []thermalEpisode
+	}{
+		{"thermal-4.15", []thermalEpisode{
+			{2, false, 1, 1, []uint64{2811, 2817}, true},
+			{6, false, 1, 1, []uint64{2812, 2818}, true},
+			{0, true, 1, 1, []uint64{2813, 2819}, true},
+			{4, true, 1, 1, []uint64{2814, 2820}, true},
+			{1, true, 1, 1, []uint64{2815, 2821}, true},
+			{7, true, 1, 1, []uint64{2816, 2822}, true},
+			// Still throttled five minutes later, the message is part of the episode.
+			{3, false, 2, 5309, []uint64{2823, 2825, 2826}, true},
+		}},
+		{"thermal-5.15", []thermalEpisode{
+			{3, false, 1, 41, []uint64{7730, 7732}, true},
+			{7, false, 1, 41, []uint64{7731, 7733}, true},
+			{0, true, 1, 88, []uint64{7734}, false},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			msgs := readRecords(t, tt.fixture)
+			events := ThermalEvents(msgs)
+			if len(events) != len(tt.want) {
+				t.Fatalf("ThermalEvents() = %d events, want %d", len(events), len(tt.want))
+			}
+
+			for i, event := range events {
+				w := tt.want[i]
+				first, last := msgs[w.seqs[0]-msgs[0].Seq], msgs[w.seqs[len(w.seqs)-1]-msgs[0].Seq]
+				want := ThermalEvent{
+					CPU: w.cpu, Package: w.pkg, Seq: first.Seq, TsUsec: first.TsUsec, EndTsUsec: -1,
+					Count: w.count, Total: w.total,
+				}
+				if w.ended {
+					want.EndSeq, want.EndTsUsec = last.Seq, last.TsUsec
+				}
+				if got := seqs(event.Msgs); !slices.Equal(got, w.seqs) {
+					t.Errorf("event %d of messages %v, want %v", i, got, w.seqs)
+				}
+				event.Msgs = nil
+				if !reflect.DeepEqual(event, want) {
+					t.Errorf("event %d =\n%+v\nwant\n%+v", i, event, want)
+				}
+			}
+		})
+	}
+}
+
+// testdata/thermal-2.6.32.txt is the text of dmesg of linux 2.6.32, which logs the temperature
+// of cores without "Core".
+func TestThermalEventsText(t *testing.T) {
+	file, err := os.Open("testdata/thermal-2.6.32.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	msgs, err := ParseText(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ThermalEvent{
+		{CPU: 1, TsUsec: 53021412008, EndTsUsec: 53021424003, Count: 1, Total: 4, Msgs: []Msg{msgs[0], msgs[2]}},
+		{CPU: 0, TsUsec: 53021412014, EndTsUsec: 53021424010, Count: 1, Total: 4, Msgs: []Msg{msgs[1], msgs[3]}},
+	}
+	events := ThermalEvents(msgs)
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("ThermalEvents() =\n%+v\nwant\n%+v", events, want)
+	}
+	if len(events) > 0 && events[0].Duration() != 11995*time.Microsecond {
+		t.Errorf("Duration() = %v, want 11.995ms", events[0].Duration())
+	}
+}
+
+func TestThermalEventDuration(t *testing.T) {
+	msgs := readRecords(t, "thermal-4.15")
+
+	// The start of the episode wasn't read, e.g. it was overwritten in the ring buffer.
+	events := ThermalEvents(msgs[len(msgs)-1:])
+	if len(events) != 1 || events[0].Count != 0 || events[0].Duration() != 0 || events[0].Seq != 2826 {
+		t.Errorf("ThermalEvents() of the normal message = %+v, want an episode without start", events)
+	}
+
+	events = ThermalEvents(msgs)
+	if d := events[len(events)-1].Duration(); d != 310253031*time.Microsecond {
+		t.Errorf("Duration() = %v, want 5m10.253031s", d)
+	}
+	if d := ThermalEvents(msgs[:1])[0].Duration(); d != 0 {
+		t.Errorf("Duration() of an episode in progress = %v, want 0", d)
+	}
+}
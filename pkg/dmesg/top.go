@@ -0,0 +1,128 @@
+package dmesg
+
+import (
+	"slices"
+	"strings"
+)
+
+// Talker is a caller or a message fingerprint with the number of messages of it, see
+// TopCallers and TopMessages.
+type Talker struct {
+	Key   string // Caller, e.g. "T123", or fingerprint of the text
+	Count int    // Number of messages
+	First Msg    // First message of Key, e.g. to show an example of the text of a fingerprint
+}
+
+// TopCallers returns the n callers logging the most messages, see Msg.Caller, the most first,
+// or all callers when n <= 0. Callers with the same count are in the order they first logged.
+// Messages without caller, logged by kernels without CONFIG_PRINTK_CALLER, aren't counted.
+func TopCallers(msgs []Msg, n int) []Talker {
+	return topTalkers(msgs, n, func(msg *Msg) string {
+		return msg.Caller
+	})
+}
+
+// TopMessages returns the n message fingerprints of the most messages like TopCallers, e.g.
+// to find the driver flooding kernel ring buffer. Messages are grouped by Fingerprint of the
+// text, so "I/O error, sector 12345" and "I/O error, sector 98765" are counted together.
+func TopMessages(msgs []Msg, n int) []Talker {
+	return topTalkers(msgs, n, func(msg *Msg) string {
+		return Fingerprint(msg.Text)
+	})
+}
+
+// topTalkers counts msgs by key, see TopCallers. Messages of an empty key aren't counted.
+func topTalkers(msgs []Msg, n int, key func(*Msg) string) []Talker {
+	var talkers []Talker
+	index := make(map[string]int) // Index in talkers of each key
+	for i := range msgs {
+		k := key(&msgs[i])
+		if k == "" {
+			continue
+		}
+		if j, ok := index[k]; ok {
+			talkers[j].Count++
+			continue
+		}
+		index[k] = len(talkers)
+		talkers = append(talkers, Talker{Key: k, Count: 1, First: msgs[i]})
+	}
+
+	slices.SortStableFunc(talkers, func(a, b Talker) int {
+		return b.Count - a.Count
+	})
+	if n > 0 && n < len(talkers) {
+		talkers = talkers[:n]
+	}
+
+	return talkers
+}
+
+// Fingerprint normalizes text so messages differing only in numbers group together, e.g. for
+// TopMessages, deduplication or alerting. Numbers, hex addresses like "0xffffffc0" or
+// "ffff8880deadbeef", and digits within words like "sda1" are replaced with '#':
+//
+//	"I/O error, dev sda1, sector 12345" -> "I/O error, dev sda#, sector #"
+//	"RIP: 0010:0xffffffffc0a1b2c3"     -> "RIP: #:#"
+//
+// Words of hex letters only, e.g. "dead" or "face", are kept.
+func Fingerprint(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+
+	for len(text) > 0 {
+		end := 0
+		for end < len(text) && isWordByte(text[end]) {
+			end++
+		}
+		if end == 0 {
+			b.WriteByte(text[0])
+			text = text[1:]
+			continue
+		}
+
+		word := text[:end]
+		text = text[end:]
+		if isHexNumber(word) {
+			b.WriteByte('#')
+			continue
+		}
+		// Collapse each run of digits within the word.
+		for i := 0; i < len(word); i++ {
+			if !isDigit(word[i]) {
+				b.WriteByte(word[i])
+			} else if i == 0 || !isDigit(word[i-1]) {
+				b.WriteByte('#')
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// isHexNumber reports whether word is a number: "0x" followed by hex digits, or hex digits
+// with at least one decimal digit.
+func isHexNumber(word string) bool {
+	if len(word) > 2 && word[0] == '0' && (word[1] == 'x' || word[1] == 'X') {
+		word = word[2:]
+	} else if !strings.ContainsAny(word, "0123456789") {
+		return false
+	}
+
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		if !isDigit(c) && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isWordByte(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,227 @@
+package dmesg
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// USBEvent is a USB device attached or detached, parsed from the messages of the USB core, see
+// USBDetector.
+type USBEvent struct {
+	Attach bool   // Whether the device was attached, else detached
+	Path   string // Bus and port path of the device, e.g. "3-2" or "3-2.1", "usb1" for the root hub of bus 1
+	Number int    // Device number on the bus, 0 if not logged
+	Speed  string // e.g. "high-speed" or "SuperSpeed", empty if not logged
+	Driver string // Driver of the host controller, e.g. "xhci_hcd", empty if not logged
+	Seq    uint64 // Sequence number of the first message
+	TsUsec int64  // Timestamp of the first message
+
+	VendorID     uint16 // idVendor, e.g. 0x0781
+	ProductID    uint16 // idProduct, e.g. 0x5583
+	BCDDevice    string // Release of the device, e.g. "1.00", empty if not logged
+	Product      string // Product string, e.g. "Ultra Fit"
+	Manufacturer string // Manufacturer string, e.g. "SanDisk"
+	SerialNumber string // Serial number string
+
+	Msgs []Msg // Messages of the event
+}
+
+var (
+	// "usb 3-2: " of the USB core, "usb usb1: " for root hubs.
+	usbPrefixRe = regexp.MustCompile(`^usb (\d+-[\d.]+|usb\d+): `)
+	// "new high-speed USB device number 7 using xhci_hcd", "new high speed USB device using
+	// ehci_hcd and address 7" in kernels before 2.6.36.
+	usbNewRe = regexp.MustCompile(`^new (.+?) USB device (?:number (\d+) using (\S+)|using (\S+) and address (\d+))`)
+	// "New USB device found, idVendor=0781, idProduct=5583, bcdDevice= 1.00", without bcdDevice
+	// before 4.19.
+	usbFoundRe = regexp.MustCompile(`^New USB device found, idVendor=([0-9a-f]{4}), idProduct=([0-9a-f]{4})(?:, bcdDevice=\s*(\S+))?`)
+	// "New USB device strings: Mfr=1, Product=2, SerialNumber=3", 0 for a string not logged.
+	usbStringsRe = regexp.MustCompile(`^New USB device strings: Mfr=(\d+), Product=(\d+), SerialNumber=(\d+)`)
+	// "USB disconnect, device number 7", "address 7" in kernels before 2.6.36.
+	usbDisconnectRe = regexp.MustCompile(`^USB disconnect, (?:device number|address) (\d+)`)
+)
+
+// usbAttach is an attach in progress by USBDetector.
+type usbAttach struct {
+	event   USBEvent
+	strings int // Strings the device announced and weren't logged yet
+}
+
+// USBDetector groups the messages of the USB core about a device being attached, from "new
+// high-speed USB device number 7 using xhci_hcd" to the "SerialNumber:" string, into a USBEvent,
+// and returns another when the device is detached, "USB disconnect, device number 7". It's fed
+// messages in the order they were logged, from a snapshot or Follow. Messages are told apart by
+// the path of the device they start with, "usb 3-2: ", so devices attached at the same time are
+// grouped correctly. A detach has the IDs and strings of the device attached before at the path.
+//
+// An attach ends when all strings announced were logged, with the next attach or detach at its
+// path, or with a message more than a second later when the kernel doesn't announce devices.
+// Other messages of the path in between, e.g. enumeration errors, are part of it. The zero value
+// is ready to use, it isn't safe for concurrent use.
+type USBDetector struct {
+	attaching map[string]*usbAttach // Attaches in progress by path
+	attached  map[string]USBEvent   // Last attach by path, for detaches
+}
+
+// Observe adds msg to the events in progress and returns the USBEvents ended by it, usually
+// none or one, the ones of several devices when msg is more than a second after them.
+func (d *USBDetector) Observe(msg Msg) []USBEvent {
+	events := d.expire(msg.TsUsec)
+
+	m := usbPrefixRe.FindStringSubmatch(msg.Text)
+	if m == nil {
+		return events
+	}
+	path, text := m[1], msg.Text[len(m[0]):]
+
+	a := d.attaching[path]
+	if m := usbNewRe.FindStringSubmatch(text); m != nil {
+		if a != nil {
+			events = append(events, d.end(path))
+		}
+		number, driver := m[2], m[3]
+		if number == "" {
+			number, driver = m[5], m[4]
+		}
+		n, _ := strconv.Atoi(number)
+		d.start(path, msg, n, m[1], driver)
+		return events
+	}
+	if m := usbDisconnectRe.FindStringSubmatch(text); m != nil {
+		if a != nil {
+			events = append(events, d.end(path))
+		}
+		number, _ := strconv.Atoi(m[1])
+		event := USBEvent{Path: path, Number: number, Seq: msg.Seq, TsUsec: msg.TsUsec, Msgs: []Msg{msg}}
+		if attached, ok := d.attached[path]; ok && attached.Number == number {
+			event.VendorID, event.ProductID, event.BCDDevice = attached.VendorID, attached.ProductID, attached.BCDDevice
+			event.Product, event.Manufacturer, event.SerialNumber = attached.Product, attached.Manufacturer, attached.SerialNumber
+			event.Speed, event.Driver = attached.Speed, attached.Driver
+		}
+		delete(d.attached, path)
+		return append(events, event)
+	}
+
+	if m := usbFoundRe.FindStringSubmatch(text); m != nil {
+		// Root hubs are announced without "new ... USB device".
+		if a == nil || a.event.VendorID != 0 || a.event.ProductID != 0 {
+			if a != nil {
+				events = append(events, d.end(path))
+			}
+			a = d.start(path, msg, 0, "", "")
+		} else {
+			a.event.Msgs = append(a.event.Msgs, msg)
+		}
+		vendor, _ := strconv.ParseUint(m[1], 16, 16)
+		product, _ := strconv.ParseUint(m[2], 16, 16)
+		a.event.VendorID, a.event.ProductID, a.event.BCDDevice = uint16(vendor), uint16(product), m[3]
+		return events
+	}
+	if a == nil {
+		return events
+	}
+
+	if m := usbStringsRe.FindStringSubmatch(text); m != nil {
+		a.strings = 0
+		for _, index := range m[1:] {
+			if index != "0" {
+				a.strings++
+			}
+		}
+	} else if product, ok := strings.CutPrefix(text, "Product: "); ok {
+		a.event.Product = product
+		a.strings--
+	} else if manufacturer, ok := strings.CutPrefix(text, "Manufacturer: "); ok {
+		a.event.Manufacturer = manufacturer
+		a.strings--
+	} else if serial, ok := strings.CutPrefix(text, "SerialNumber: "); ok {
+		a.event.SerialNumber = serial
+		a.strings--
+	}
+	if len(a.event.Msgs) < maxReportMsgs {
+		a.event.Msgs = append(a.event.Msgs, msg)
+	}
+	if a.strings == 0 {
+		events = append(events, d.end(path))
+	}
+
+	return events
+}
+
+// Flush returns the attaches in progress and ends them, e.g. at the end of a snapshot or when
+// Follow stops.
+func (d *USBDetector) Flush() []USBEvent {
+	return d.expire(-1)
+}
+
+// start starts an attach at path with msg.
+func (d *USBDetector) start(path string, msg Msg, number int, speed, driver string) *usbAttach {
+	if d.attaching == nil {
+		d.attaching = make(map[string]*usbAttach)
+	}
+
+	a := &usbAttach{
+		event: USBEvent{
+			Attach: true,
+			Path:   path,
+			Number: number,
+			Speed:  speed,
+			Driver: driver,
+			Seq:    msg.Seq,
+			TsUsec: msg.TsUsec,
+			Msgs:   []Msg{msg},
+		},
+		strings: -1,
+	}
+	d.attaching[path] = a
+
+	return a
+}
+
+// end ends the attach in progress at path and returns its event.
+func (d *USBDetector) end(path string) USBEvent {
+	event := d.attaching[path].event
+	delete(d.attaching, path)
+	if d.attached == nil {
+		d.attached = make(map[string]USBEvent)
+	}
+	d.attached[path] = event
+
+	return event
+}
+
+// expire ends the attaches in progress started more than reportGap before ts, all of them when
+// ts is negative, in the order they started.
+func (d *USBDetector) expire(ts int64) []USBEvent {
+	var paths []string
+	for path, a := range d.attaching {
+		if ts < 0 || ts-a.event.TsUsec > reportGap.Microseconds() {
+			paths = append(paths, path)
+		}
+	}
+	slices.SortFunc(paths, func(a, b string) int {
+		ea, eb := &d.attaching[a].event, &d.attaching[b].event
+		return cmp.Or(cmp.Compare(ea.TsUsec, eb.TsUsec), cmp.Compare(ea.Seq, eb.Seq))
+	})
+
+	var events []USBEvent
+	for _, path := range paths {
+		events = append(events, d.end(path))
+	}
+
+	return events
+}
+
+// USBEvents returns the USBEvents of msgs, see USBDetector.
+func USBEvents(msgs []Msg) []USBEvent {
+	var d USBDetector
+	var events []USBEvent
+	for _, msg := range msgs {
+		events = append(events, d.Observe(msg)...)
+	}
+
+	return append(events, d.Flush()...)
+}
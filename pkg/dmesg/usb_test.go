@@ -0,0 +1,142 @@
+package dmesg
+
+import (
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// usbEvent is a USBEvent without its messages, and the sequence numbers of the messages.
+type usbEvent struct {
+	event USBEvent
+	seqs  []uint64
+}
+
+func TestUSBEvents(t *testing.T) {
+	hub := USBEvent{
+		Path: "3-2", Number: 7, Speed: "high-speed", Driver: "xhci_hcd",
+		VendorID: 0x05e3, ProductID: 0x0610, BCDDevice: "60.60", Product: "USB2.1 Hub",
+	}
+	receiver := USBEvent{
+		Path: "3-2.1", Number: 8, Speed: "full-speed", Driver: "xhci_hcd",
+		VendorID: 0x046d, ProductID: 0xc52b, BCDDevice: "12.11", Product: "USB Receiver", Manufacturer: "Logitech",
+	}
+	ethernet := USBEvent{
+		Path: "3-2.4", Number: 10, Speed: "high-speed", Driver: "xhci_hcd",
+		VendorID: 0x0bda, ProductID: 0x8153, BCDDevice: "30.00",
+		Product: "USB 10/100/1000 LAN", Manufacturer: "Realtek", SerialNumber: "001000001",
+	}
+	stick := USBEvent{
+		Path: "1-1.2", Number: 3, Speed: "high-speed", Driver: "ehci-pci", VendorID: 0x0781, ProductID: 0x5567,
+		Product: "Cruzer Blade", Manufacturer: "SanDisk", SerialNumber: "4C530001230912112345",
+	}
+
+	tests := []struct {
+		fixture string
+		want    []usbEvent
+	}{
+		// Linux 4.9 logs no bcdDevice. The root hub is announced without "new ... USB device".
+		{"usb-4.9", []usbEvent{
+			{USBEvent{
+				Attach: true, Path: "usb1", VendorID: 0x1d6b, ProductID: 0x0002,
+				Product: "EHCI Host Controller", Manufacturer: "Linux 4.9.0-8-amd64 ehci_hcd", SerialNumber: "0000:00:1d.0",
+			}, []uint64{402, 403, 404, 405, 406}},
+			{attachEvent(stick), []uint64{408, 409, 410, 411, 412, 413}},
+			{stick, []uint64{416}},
+		}},
+		// A hub and two devices plugged into it at the same time, the first attach of one of them
+		// fails, and the hub is unplugged with them.
+		{"usb-6.1", []usbEvent{
+			{attachEvent(hub), []uint64{1733, 1734, 1735, 1736}},
+			{USBEvent{Attach: true, Path: "3-2.4", Number: 9, Speed: "high-speed", Driver: "xhci_hcd"}, []uint64{1740, 1741}},
+			{attachEvent(receiver), []uint64{1739, 1742, 1743, 1744, 1746}},
+			{attachEvent(ethernet), []uint64{1745, 1747, 1748, 1750, 1751, 1752}},
+			{hub, []uint64{1754}},
+			{receiver, []uint64{1755}},
+			{ethernet, []uint64{1756}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			msgs := readRecords(t, tt.fixture)
+			checkUSBEvents(t, msgs, USBEvents(msgs), tt.want)
+		})
+	}
+}
+
+// testdata/usb-2.6.32.txt is the text of dmesg of linux 2.6.32, which logs the address of a
+// device instead of its number.
+func TestUSBEventsText(t *testing.T) {
+	file, err := os.Open("testdata/usb-2.6.32.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	msgs, err := ParseText(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stick := USBEvent{
+		Path: "1-1", Number: 2, Speed: "high speed", Driver: "ehci_hcd", VendorID: 0x0781, ProductID: 0x5567,
+		Product: "Cruzer Blade", Manufacturer: "SanDisk", SerialNumber: "4C530001230912112345",
+	}
+	events := USBEvents(msgs)
+	if len(events) != 2 {
+		t.Fatalf("USBEvents() = %d events, want 2", len(events))
+	}
+	attach, detach := attachEvent(stick), stick
+	attach.TsUsec, attach.Msgs = msgs[0].TsUsec, msgs[:6]
+	detach.TsUsec, detach.Msgs = msgs[9].TsUsec, msgs[9:]
+	if !reflect.DeepEqual(events, []USBEvent{attach, detach}) {
+		t.Errorf("USBEvents() =\n%+v\nwant\n%+v", events, []USBEvent{attach, detach})
+	}
+}
+
+// An attach without the strings of the device ends with a message more than a second later.
+func TestUSBDetectorExpire(t *testing.T) {
+	var d USBDetector
+	msgs := readRecords(t, "usb-6.1")
+	if events := d.Observe(msgs[0]); len(events) != 0 {
+		t.Fatalf("Observe() = %+v, want no event", events)
+	}
+	later := msgs[1]
+	later.TsUsec = msgs[0].TsUsec + 1_000_001
+	later.Text = "usb 3-3: new full-speed USB device number 4 using xhci_hcd"
+	events := d.Observe(later)
+	if len(events) != 1 || events[0].Path != "3-2" || len(events[0].Msgs) != 1 {
+		t.Errorf("Observe() of a later message = %+v, want the attach of 3-2", events)
+	}
+	if events := d.Flush(); len(events) != 1 || events[0].Path != "3-3" {
+		t.Errorf("Flush() = %+v, want the attach of 3-3", events)
+	}
+}
+
+// attachEvent returns the attach of the device of the detach event.
+func attachEvent(event USBEvent) USBEvent {
+	event.Attach = true
+
+	return event
+}
+
+func checkUSBEvents(t *testing.T, msgs []Msg, events []USBEvent, want []usbEvent) {
+	t.Helper()
+	if len(events) != len(want) {
+		t.Fatalf("USBEvents() = %d events, want %d", len(events), len(want))
+	}
+
+	for i, event := range events {
+		w := want[i]
+		w.event.Seq = w.seqs[0]
+		w.event.TsUsec = msgs[w.seqs[0]-msgs[0].Seq].TsUsec
+		if got := seqs(event.Msgs); !slices.Equal(got, w.seqs) {
+			t.Errorf("event %d of messages %v, want %v", i, got, w.seqs)
+		}
+		event.Msgs = nil
+		if !reflect.DeepEqual(event, w.event) {
+			t.Errorf("event %d =\n%+v\nwant\n%+v", i, event, w.event)
+		}
+	}
+}